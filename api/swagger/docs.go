@@ -25,9 +25,41 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/job/complete": {
+        "/admin/breaker": {
+            "get": {
+                "description": "Method to get circuit breakers of task destinations",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get Circuit Breakers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Breaker"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/breaker/{host}/reset": {
             "post": {
-                "description": "Method to complete job",
+                "description": "Method to close circuit breaker of the host",
                 "consumes": [
                     "application/json"
                 ],
@@ -35,34 +67,33 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Job"
+                    "Admin"
                 ],
-                "summary": "Complete Job",
+                "summary": "Reset Circuit Breaker",
                 "parameters": [
                     {
-                        "description": "Complete Job Message",
-                        "name": "complete_job_message",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.JobCompleteMessage"
-                        }
+                        "type": "string",
+                        "description": "Host",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {},
+                    "404": {},
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/mapping": {
-            "get": {
-                "description": "Method to get all read mappings",
+        "/job/cancel": {
+            "post": {
+                "description": "Method to cancel job which isn't completed, it won't be started again",
                 "consumes": [
                     "application/json"
                 ],
@@ -70,29 +101,35 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Read Mapping"
+                    "Job"
                 ],
-                "summary": "Get Read Mappings",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "summary": "Cancel Job",
+                "parameters": [
+                    {
+                        "description": "Job Reference",
+                        "name": "job_ref",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.ReadMapping"
-                            }
+                            "$ref": "#/definitions/api.JobRef"
                         }
-                    },
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "404": {},
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/job/complete": {
             "post": {
-                "description": "Method to create read mapping",
+                "description": "Method to complete job",
                 "consumes": [
                     "application/json"
                 ],
@@ -100,39 +137,53 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Read Mapping"
+                    "Job"
                 ],
-                "summary": "Create Read Mapping",
+                "summary": "Complete Job",
                 "parameters": [
                     {
-                        "description": "Read Mapping (without id)",
-                        "name": "read_mapping",
+                        "description": "Complete Job Message",
+                        "name": "complete_job_message",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.ReadMapping"
+                            "$ref": "#/definitions/api.JobCompleteMessage"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Attempt Id (if it's absent in the message)",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/domain.ReadMapping"
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/mapping/{id}": {
-            "get": {
-                "description": "Method to get read mapping by id",
+        "/job/fetch": {
+            "post": {
+                "description": "Method to fetch and lock ready jobs of external tasks by topics",
                 "consumes": [
                     "application/json"
                 ],
@@ -140,35 +191,48 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Read Mapping"
+                    "Job"
                 ],
-                "summary": "Get Read Mapping by Id",
+                "summary": "Fetch and Lock Jobs",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Read Mapping Id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Fetch Request",
+                        "name": "fetch_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.JobFetchRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/domain.ReadMapping"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.LockedJob"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Method to delete read mapping by id",
+            }
+        },
+        "/job/lock/{lock_id}/complete": {
+            "post": {
+                "description": "Method to complete job locked by the external worker",
                 "consumes": [
                     "application/json"
                 ],
@@ -176,32 +240,54 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Read Mapping"
+                    "Job"
                 ],
-                "summary": "Delete Read Mapping by Id",
+                "summary": "Complete Locked Job",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Read Mapping Id",
-                        "name": "id",
+                        "description": "Lock Id",
+                        "name": "lock_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Complete Message",
+                        "name": "complete_message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.JobLockCompleteMessage"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/order": {
-            "get": {
-                "description": "Method to get all orders",
+        "/job/lock/{lock_id}/extend": {
+            "post": {
+                "description": "Method to extend lock of the job by the external worker",
                 "consumes": [
                     "application/json"
                 ],
@@ -209,29 +295,53 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Job"
+                ],
+                "summary": "Extend Job Lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lock Id",
+                        "name": "lock_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Extend Message",
+                        "name": "extend_message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.JobLockExtendMessage"
+                        }
+                    }
                 ],
-                "summary": "Get Orders",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.Order"
-                            }
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/job/lock/{lock_id}/fail": {
             "post": {
-                "description": "Method to submit order",
+                "description": "Method to report failure of job locked by the external worker, job is retried unless it's permanent",
                 "consumes": [
                     "application/json"
                 ],
@@ -239,46 +349,53 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Job"
                 ],
-                "summary": "Submit Order",
+                "summary": "Fail Locked Job",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Process Id",
-                        "name": "process_id",
+                        "description": "Lock Id",
+                        "name": "lock_id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Order (without id)",
-                        "name": "order",
+                        "description": "Fail Message",
+                        "name": "fail_message",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.Order"
+                            "$ref": "#/definitions/api.JobLockFailMessage"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/domain.Order"
+                            "$ref": "#/definitions/api.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/order/{id}": {
-            "get": {
-                "description": "Method to get Order by id",
+        "/job/retry": {
+            "post": {
+                "description": "Method to restart job which isn't completed (e.g. failed one)",
                 "consumes": [
                     "application/json"
                 ],
@@ -286,37 +403,35 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Job"
                 ],
-                "summary": "Get Order by Id",
+                "summary": "Retry Job",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Order Id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Job Reference",
+                        "name": "job_ref",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.JobRef"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/domain.Order"
-                        }
-                    },
+                    "200": {},
+                    "404": {},
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/process": {
+        "/mapping": {
             "get": {
-                "description": "Method to get all processes",
+                "description": "Method to get the page of the read mappings",
                 "consumes": [
                     "application/json"
                 ],
@@ -324,29 +439,65 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Process"
+                    "Read Mapping"
+                ],
+                "summary": "Get Read Mappings",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page limit (100 by default, 1000 at most)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page (X-Next-Cursor of the previous one)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: id (by default), '-' prefix is descending",
+                        "name": "sort",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get Processes",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/domain.Process"
+                                "$ref": "#/definitions/api.ReadMapping"
                             }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page (absent on the last page)"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Count of the read mappings"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Method to create process",
+                "description": "Method to create read mapping",
                 "consumes": [
                     "application/json"
                 ],
@@ -354,17 +505,17 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Process"
+                    "Read Mapping"
                 ],
-                "summary": "Create Process",
+                "summary": "Create Read Mapping",
                 "parameters": [
                     {
-                        "description": "Process (without id)",
-                        "name": "process",
+                        "description": "Read Mapping (without id)",
+                        "name": "read_mapping",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.Process"
+                            "$ref": "#/definitions/api.ReadMapping"
                         }
                     }
                 ],
@@ -372,21 +523,21 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/domain.Process"
+                            "$ref": "#/definitions/api.ReadMapping"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
         },
-        "/process/{id}": {
+        "/mapping/{id}": {
             "get": {
-                "description": "Method to get Process by id",
+                "description": "Method to get read mapping by id",
                 "consumes": [
                     "application/json"
                 ],
@@ -394,13 +545,13 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Process"
+                    "Read Mapping"
                 ],
-                "summary": "Get Process by Id",
+                "summary": "Get Read Mapping by Id",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Process Id",
+                        "description": "Read Mapping Id",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -410,19 +561,19 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/domain.Process"
+                            "$ref": "#/definitions/api.ReadMapping"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             },
             "delete": {
-                "description": "Method to delete process by id",
+                "description": "Method to delete read mapping by id",
                 "consumes": [
                     "application/json"
                 ],
@@ -430,13 +581,13 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Process"
+                    "Read Mapping"
                 ],
-                "summary": "Delete process by Id",
+                "summary": "Delete Read Mapping by Id",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Process Id",
+                        "description": "Read Mapping Id",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -444,51 +595,1129 @@ var doc = `{
                 ],
                 "responses": {
                     "200": {},
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/domain.Error"
+                            "$ref": "#/definitions/api.Error"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "domain.Body": {
-            "type": "object",
-            "additionalProperties": true
         },
-        "domain.Error": {
-            "type": "object"
-        },
-        "domain.JobCompleteMessage": {
-            "type": "object",
-            "properties": {
-                "orderId": {
-                    "type": "string"
-                },
+        "/order": {
+            "get": {
+                "description": "Method to get the page of the orders, body fields are filtered by body.{field} parameters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Get Orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page limit (100 by default, 1000 at most)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page (X-Next-Cursor of the previous one)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: createdAt (by default) or id, '-' prefix is descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "process_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Status: running, completed or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Order"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page (absent on the last page)"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Count of the orders matched by the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{id}": {
+            "get": {
+                "description": "Method to get Order by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Get Order by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Order"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{id}/graph": {
+            "get": {
+                "description": "Method to render process of the order as Graphviz DOT, Mermaid or SVG, tasks are colored\nby the statuses of their jobs",
+                "produces": [
+                    "text/plain",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Get Order Graph",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "svg",
+                        "description": "Format (dot, mermaid or svg)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{id}/jobs": {
+            "get": {
+                "description": "Method to get jobs of the order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Get Order Jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Job"
+                            }
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{process_id}": {
+            "post": {
+                "description": "Method to submit order. Resubmission with the same idempotency key (header or field of the order)\nreturns the original order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Submit Order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "process_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key (unique per process)",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Order (without id)",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.Order"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Order"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{process_id}/bulk": {
+            "post": {
+                "description": "Method to submit array (or NDJSON stream) of orders, orders are decoded and created by chunks\nof transactions. Atomic submission creates either all orders or none of them (422 with the error\nand the items of the orders). Items of the orders submitted before the error (e.g. the limit\nof the orders is exceeded) are returned by 422 as well",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Submit Orders",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "process_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "All or nothing",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Orders (without id)",
+                        "name": "orders",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Order"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkOrderResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/{process_id}/search": {
+            "post": {
+                "description": "Method to get the page of the orders of the process which match all the predicates (path and value).\nPaths are jsonpath-style ($.customer.id, $.items[*].productId) and they have to be the search paths\nof the process, values are required. Search paths are set by the creation (or the import) of the\nprocess only. Query parameters are the ones of the order list (except process_id)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Search Orders",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "process_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page limit (100 by default, 1000 at most)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page (X-Next-Cursor of the previous one)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: createdAt (by default) or id, '-' prefix is descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Status: running, completed or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Predicates of the search",
+                        "name": "search",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderSearch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Order"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page (absent on the last page)"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Count of the orders matched by the predicates and the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process": {
+            "get": {
+                "description": "Method to get the page of the processes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Get Processes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page limit (100 by default, 1000 at most)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page (X-Next-Cursor of the previous one)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: name (by default) or id, '-' prefix is descending",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Process"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page (absent on the last page)"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Count of the processes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Method to create process",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Create Process",
+                "parameters": [
+                    {
+                        "description": "Process (without id)",
+                        "name": "process",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.Process"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process/bpmn": {
+            "post": {
+                "description": "Method to create process from BPMN 2.0 document (raw body or \"file\" field of multipart form),\nunsupported elements are reported with their lines",
+                "consumes": [
+                    "text/xml",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Import BPMN Process",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "BPMN document",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process/import": {
+            "post": {
+                "description": "Method to create process and its read mappings from the definition with name-based references",
+                "consumes": [
+                    "application/json",
+                    "application/x-yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Import Process Definition",
+                "parameters": [
+                    {
+                        "description": "Process Definition",
+                        "name": "definition",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ProcessDefinition"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process/{id}": {
+            "get": {
+                "description": "Method to get Process by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Get Process by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Process"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Method to delete process by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Delete process by Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process/{id}/definition": {
+            "get": {
+                "description": "Method to get definition of the process, read mappings are named by their ids",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-yaml"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Export Process Definition",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProcessDefinition"
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/process/{id}/graph": {
+            "get": {
+                "description": "Method to render process as Graphviz DOT, Mermaid or SVG",
+                "produces": [
+                    "text/plain",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Process"
+                ],
+                "summary": "Get Process Graph",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "svg",
+                        "description": "Format (dot, mermaid or svg)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    },
+                    "404": {},
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Error"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.Body": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "api.Breaker": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer"
+                },
+                "host": {
+                    "type": "string"
+                },
+                "openedAt": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.BulkOrderItem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.BulkOrderResult": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BulkOrderItem"
+                    }
+                },
+                "submitted": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Error": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "err": {
+                    "type": "error"
+                },
+                "msg": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.HttpTaskConfig": {
+            "type": "object",
+            "properties": {
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "method": {
+                    "description": "POST by default",
+                    "type": "string"
+                },
+                "query": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "retriesMax": {
+                    "description": "Overrides rest.client.retriesMax",
+                    "type": "integer"
+                },
+                "timeoutSec": {
+                    "description": "Overrides rest.client.timeoutSec",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Job": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "errorCode": {
+                    "type": "string"
+                },
+                "lockedUntil": {
+                    "description": "Lock of the external job",
+                    "type": "string"
+                },
+                "orderId": {
+                    "type": "string"
+                },
+                "output": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "response": {
+                    "description": "Truncated response of the last failed attempt",
+                    "type": "string"
+                },
+                "startAfter": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "taskId": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.JobCompleteMessage": {
+            "type": "object",
+            "properties": {
+                "attemptId": {
+                    "type": "string"
+                },
+                "body": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "orderId": {
+                    "type": "string"
+                },
+                "taskId": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.JobFetchRequest": {
+            "type": "object",
+            "properties": {
+                "lockDurationSec": {
+                    "type": "integer"
+                },
+                "maxJobs": {
+                    "type": "integer"
+                },
+                "topics": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.JobLockCompleteMessage": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                }
+            }
+        },
+        "api.JobLockExtendMessage": {
+            "type": "object",
+            "properties": {
+                "lockDurationSec": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.JobLockFailMessage": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "permanent": {
+                    "description": "Job won't be retried",
+                    "type": "boolean"
+                },
+                "retryDelaySec": {
+                    "description": "Used if it's greater than scheduler.retryDelaySec",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.JobRef": {
+            "type": "object",
+            "properties": {
+                "orderId": {
+                    "type": "string"
+                },
+                "taskId": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LockedJob": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "lockId": {
+                    "type": "string"
+                },
+                "lockedUntil": {
+                    "type": "string"
+                },
+                "orderId": {
+                    "type": "string"
+                },
                 "taskId": {
                     "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                },
+                "trace": {
+                    "description": "Span context of the order submission",
+                    "type": "string"
                 }
             }
         },
-        "domain.Order": {
+        "api.Order": {
             "type": "object",
             "properties": {
                 "body": {
                     "type": "object",
-                    "$ref": "#/definitions/domain.Body"
+                    "$ref": "#/definitions/api.Body"
+                },
+                "createdAt": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
+                "idempotencyKey": {
+                    "type": "string"
+                },
                 "processId": {
                     "type": "string"
                 }
             }
         },
-        "domain.Process": {
+        "api.OrderSearch": {
+            "type": "object",
+            "properties": {
+                "predicates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SearchPredicate"
+                    }
+                }
+            }
+        },
+        "api.Process": {
             "type": "object",
             "properties": {
                 "id": {
@@ -497,33 +1726,89 @@ var doc = `{
                 "name": {
                     "type": "string"
                 },
+                "orderSchema": {
+                    "description": "JSON Schema of the order body",
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "searchPaths": {
+                    "description": "Paths of the order body which are searchable",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "taskRelations": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.TaskRelation"
+                        "$ref": "#/definitions/api.TaskRelation"
+                    }
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Task"
+                    }
+                }
+            }
+        },
+        "api.ProcessDefinition": {
+            "type": "object",
+            "properties": {
+                "mappings": {
+                    "description": "Read mappings shared by tasks",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/api.Body"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "orderSchema": {
+                    "description": "JSON Schema of the order body",
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "searchPaths": {
+                    "description": "Paths of the order body which are searchable, e.g. $.customer.id or $.items[*].productId. They can't be\nchanged after the import, the definition is imported again as the new process to change them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
                 },
                 "tasks": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.Task"
+                        "$ref": "#/definitions/api.TaskDefinition"
                     }
                 }
             }
         },
-        "domain.ReadMapping": {
+        "api.ReadMapping": {
             "type": "object",
             "properties": {
                 "body": {
                     "type": "object",
-                    "$ref": "#/definitions/domain.Body"
+                    "$ref": "#/definitions/api.Body"
                 },
                 "id": {
                     "type": "string"
                 }
             }
         },
-        "domain.Task": {
+        "api.SearchPredicate": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "object"
+                }
+            }
+        },
+        "api.Task": {
             "type": "object",
             "properties": {
                 "action": {
@@ -533,18 +1818,74 @@ var doc = `{
                     "description": "TBD Return string value instead of integer",
                     "type": "integer"
                 },
+                "http": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.HttpTaskConfig"
+                },
                 "id": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
+                "outputSchema": {
+                    "description": "JSON Schema of the body of the completed job",
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
                 "readMappingId": {
                     "type": "string"
+                },
+                "sync": {
+                    "description": "Job is completed by the response of the action",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.TaskDefinition": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "category": {
+                    "description": "http (by default), nats, external or timer",
+                    "type": "string"
+                },
+                "dependsOn": {
+                    "description": "Names of the parent tasks",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "http": {
+                    "type": "object",
+                    "$ref": "#/definitions/api.HttpTaskConfig"
+                },
+                "mapping": {
+                    "description": "Name of the shared read mapping",
+                    "type": "string"
+                },
+                "mappingBody": {
+                    "description": "Inlined read mapping",
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "outputSchema": {
+                    "description": "JSON Schema of the body of the completed job",
+                    "type": "object",
+                    "$ref": "#/definitions/api.Body"
+                },
+                "sync": {
+                    "type": "boolean"
                 }
             }
         },
-        "domain.TaskRelation": {
+        "api.TaskRelation": {
             "type": "object",
             "properties": {
                 "childId": {
@@ -70,13 +70,13 @@ func initRouter(restCfg domain.RestConfig, balanceCfg domain.BalanceConfig) *gin
 	router.Use(tracing.Middleware(), rest.BalanceMiddleware(balanceCfg.RequestUrl, balanceCfg.RetryMax))
 
 	// Prometheus handler initialization
-	router.GET(restCfg.MetricsUrl, metric.PrometheusHandler())
+	router.GET(restCfg.Server.MetricsUrl, metric.PrometheusHandler())
 
 	return router
 }
 
 func initServer(cfg domain.RestConfig, r *gin.Engine) {
-	if err := endless.ListenAndServe(cfg.Host+":"+strconv.Itoa(cfg.Port), r); err != nil {
+	if err := endless.ListenAndServe(cfg.Server.Host+":"+strconv.Itoa(cfg.Server.Port), r); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	appconf "example.com/oligzeev/pp-gin/internal/config"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const checkUsage = `Usage: pp-gin check [flags]
+
+Reports rows which reference the deleted ones (orphans), e.g. tasks of the deleted process.
+
+Flags:
+`
+
+// Check consistency of the configured database, orphans are deleted with -repair: pp-gin check [-repair]
+func check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), checkUsage)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "config/pp-gin.yaml", "Configuration file")
+	repair := flags.Bool("repair", false, "Delete orphans which could be repaired")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := appconf.ReadConfig(*configFile, "pp")
+	if err != nil {
+		return err
+	}
+	db, _, closer, err := openDatabase(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	orphans, err := database.CheckConsistency(context.Background(), db, *repair)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORPHANS\tCOUNT\tREPAIRED")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%d\t%t\n", o.Name, o.Count, o.Repaired)
+		if !o.Repaired {
+			remaining += o.Count
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return fmt.Errorf("database has orphans (%d)", remaining)
+	}
+	return nil
+}
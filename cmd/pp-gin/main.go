@@ -2,20 +2,25 @@ package main
 
 import (
 	"context"
+	"example.com/oligzeev/pp-gin/internal/breaker"
 	"example.com/oligzeev/pp-gin/internal/cache"
 	appconf "example.com/oligzeev/pp-gin/internal/config"
+	"example.com/oligzeev/pp-gin/internal/dashboard"
 	"example.com/oligzeev/pp-gin/internal/database"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"example.com/oligzeev/pp-gin/internal/logging"
 	"example.com/oligzeev/pp-gin/internal/metric"
+	"example.com/oligzeev/pp-gin/internal/nats"
 	"example.com/oligzeev/pp-gin/internal/rest"
 	"example.com/oligzeev/pp-gin/internal/service"
 	"example.com/oligzeev/pp-gin/internal/tracing"
 	"example.com/oligzeev/pp-gin/internal/util"
+	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/hashicorp/go-retryablehttp"
 	"github.com/jmoiron/sqlx"
+	natsio "github.com/nats-io/nats.go"
 	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
@@ -25,17 +30,32 @@ import (
 	"golang.org/x/sync/errgroup"
 	"io"
 	"os"
-	"time"
 
 	"github.com/gin-contrib/pprof"
 
 	_ "example.com/oligzeev/pp-gin/api/swagger"
 )
 
+// Commands which are executed instead of the server: pp-gin <command> [flags] [arguments]
+var commands = map[string]func(args []string) error{
+	"migrate": migrate,
+	"check":   check,
+}
+
 // @title PP Gin
 // @version 0.0.1
 // @description This is a PP-Gin application.
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil && err != flag.ErrHelp {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	ctx, done := context.WithCancel(context.Background())
 	group, groupCtx := errgroup.WithContext(ctx)
 	group.Go(func() error {
@@ -48,12 +68,6 @@ func main() {
 	// Initialize logger
 	initLogger(cfg.Logging)
 
-	// Initialize database connection
-	db := initDatabase(cfg.DB)
-	execTxFunc := func(ctx context.Context, f domain.TxFunc) error {
-		return database.ExecTx(ctx, db, f)
-	}
-
 	// Initialize open tracing
 	_, closer := initTracing(cfg.Tracing)
 	defer closer.Close()
@@ -62,38 +76,103 @@ func main() {
 	newUUIDFunc := func() (uuid.UUID, error) {
 		return uuid.NewUUID()
 	}
-	readMappingRepo := database.NewRDBReadMappingRepo(db, newUUIDFunc)
-	processRepo := database.NewRDBProcessRepo(db, newUUIDFunc)
-	jobRepo := database.NewRDBJobRepo(db)
-	orderRepo := database.NewRDBOrderRepo(db, newUUIDFunc)
+	var readMappingRepo database.ReadMappingRepo
+	var processRepo database.ProcessRepo
+	var jobRepo database.JobRepo
+	var orderRepo database.OrderRepo
+	var execTxFunc domain.ExecTxFunc
+	switch cfg.DB.Driver {
+	case domain.DbDriverMemory:
+		store := database.NewMemStore()
+		readMappingRepo = database.NewMemReadMappingRepo(store, newUUIDFunc)
+		processRepo = database.NewMemProcessRepo(store, newUUIDFunc)
+		jobRepo = database.NewMemJobRepo(store)
+		orderRepo = database.NewMemOrderRepo(store, newUUIDFunc)
+		execTxFunc = store.ExecTx
+	case domain.DbDriverSqlite:
+		db := initSQLite(cfg.DB)
+		if cfg.DB.Migrate {
+			initMigrations(database.NewSQLiteMigrator(db))
+		}
+		readMappingRepo = database.NewRDBReadMappingRepo(db, newUUIDFunc)
+		processRepo = database.NewRDBProcessRepo(db, newUUIDFunc)
+		jobRepo = database.NewSQLiteJobRepo(db)
+		orderRepo = database.NewRDBOrderRepo(db, newUUIDFunc)
+		execTxFunc = db.ExecTx
+	case "", domain.DbDriverPostgres:
+		// Initialize database connection
+		db := initDatabase(cfg.DB)
+		if cfg.DB.Migrate {
+			initMigrations(database.NewPostgresMigrator(db))
+		}
+		readMappingRepo = database.NewRDBReadMappingRepo(db, newUUIDFunc)
+		processRepo = database.NewRDBProcessRepo(db, newUUIDFunc)
+		jobRepo = database.NewRDBJobRepo(db)
+		orderRepo = database.NewRDBOrderRepo(db, newUUIDFunc)
+		execTxFunc = func(ctx context.Context, f domain.TxFunc) error {
+			return database.ExecTx(ctx, db, f)
+		}
+	default:
+		log.Fatalf("unsupported database driver: %s", cfg.DB.Driver)
+	}
 
 	// Initialize http clients
-	httpClient := retryablehttp.NewClient()
-	httpClient.RetryMax = cfg.Rest.Client.RetriesMax
-	httpClient.StandardClient().Timeout = cfg.Rest.Client.TimeoutSec * time.Second
-	jobStartClient := rest.NewJobStartRestClient(httpClient)
+	jobStartClient := rest.NewJobStartRestClient(cfg.Rest.Client)
+	restHandlers := make([]domain.RestHandler, 0)
+	if cfg.Breaker.Enabled {
+		breakerClient := breaker.NewBreakerJobStartClient(cfg.Breaker, jobStartClient)
+		restHandlers = append(restHandlers, rest.NewBreakerRestHandler(breakerClient))
+		jobStartClient = breakerClient
+	}
+	jobStartClients := map[int]domain.JobStartClient{domain.HttpTaskCategory: jobStartClient}
+
+	// Initialize nats clients
+	var natsJs natsio.JetStreamContext
+	if cfg.Nats.Enabled {
+		natsConn, js, err := nats.Connect(cfg.Nats)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer natsConn.Close()
+		natsJs = js
+		jobStartClients[domain.NatsTaskCategory] = nats.NewJobStartNatsClient(natsJs)
+	}
 
 	// Initialize services
 	readMappingService := NewReadMappingService(cfg.Cache, readMappingRepo)
-	processService := NewProcessService(cfg.Cache, processRepo, execTxFunc)
-	orderService := NewOrderService(cfg.Cache, processService, orderRepo, jobRepo, execTxFunc)
+	schemas := service.NewSchemaCache()
+	processService := NewProcessService(cfg.Cache, processRepo, execTxFunc, schemas)
+	orderService := NewOrderService(cfg.Cache, cfg.Order, processService, orderRepo, jobRepo, execTxFunc, schemas)
+	externalJobService := NewExternalJobService(cfg.Scheduler, jobRepo, orderService, readMappingService)
+	definitionService := NewProcessDefinitionService(processRepo, readMappingRepo, newUUIDFunc, execTxFunc)
 
 	// Initialize scheduler
 	if cfg.Scheduler.Enabled {
 		group.Go(func() error {
-			s := service.NewJobScheduler(cfg.Scheduler, jobRepo, orderService, readMappingService, jobStartClient)
+			s := service.NewJobScheduler(cfg.Scheduler, jobRepo, orderService, readMappingService, jobStartClients)
 			return s.Start(groupCtx)
 		})
 	}
 
+	// Initialize nats consumers
+	if cfg.Nats.Enabled {
+		group.Go(func() error {
+			return nats.NewJobCompleteNatsConsumer(cfg.Nats, natsJs, orderService).Start(groupCtx)
+		})
+	}
+
 	// Initialize rest server
-	restServer := rest.NewServer(cfg.Rest.Server, []domain.RestHandler{
+	restServer := rest.NewServer(cfg.Rest.Server, append([]domain.RestHandler{
 		rest.NewMappingRestHandler(readMappingService),
 		rest.NewProcessRestHandler(processService),
+		rest.NewProcessDefinitionRestHandler(definitionService),
 		rest.NewJobRestHandler(orderService),
 		rest.NewOrderRestHandler(orderService),
-	})
-	initRouter(cfg, restServer.Router())
+		rest.NewExternalJobRestHandler(externalJobService),
+		rest.NewGraphRestHandler(processService, orderService),
+	}, restHandlers...))
+	initRouter(cfg, restServer.Router(),
+		dashboard.NewHandler(cfg.Rest.Server.DashboardUrl, processService, readMappingService, orderService))
 
 	group.Go(func() error {
 		return restServer.Start(groupCtx)
@@ -160,7 +239,24 @@ func initDatabase(cfg domain.DbConfig) *sqlx.DB {
 	return db
 }
 
-func initRouter(cfg *domain.ApplicationConfig, router *gin.Engine) {
+func initSQLite(cfg domain.DbConfig) *database.SQLiteDB {
+	db, err := database.ConnectSQLite(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// Replicas are serialized by the migrator, so the migrations are applied once
+func initMigrations(migrator *database.Migrator) {
+	count, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Applied migrations: %d", count)
+}
+
+func initRouter(cfg *domain.ApplicationConfig, router *gin.Engine, dashboardHandler domain.RestHandler) {
 	// Logging & Recovery middleware
 	if cfg.Logging.Default {
 		router.Use(gin.Logger())
@@ -178,6 +274,11 @@ func initRouter(cfg *domain.ApplicationConfig, router *gin.Engine) {
 	// From the root directory: swag init --dir ./ --generalInfo ./cmd/pp-gin/main.go --output ./api/swagger
 	router.GET(cfg.Rest.Server.SwaggerUrl+"/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Dashboard handler initialization
+	if cfg.Rest.Server.DashboardUrl != "" {
+		dashboardHandler.Register(router)
+	}
+
 	// Prometheus handler initialization
 	// prom := ginprom.NewPrometheus("gin") and prom.Use(router)
 	router.GET(cfg.Rest.Server.MetricsUrl, metric.PrometheusHandler())
@@ -201,8 +302,10 @@ func NewReadMappingService(cfg domain.CacheConfig, repo database.ReadMappingRepo
 	return tracing.NewSpanReadMappingService(cached)
 }
 
-func NewProcessService(cfg domain.CacheConfig, repo database.ProcessRepo, txFunc domain.ExecTxFunc) domain.ProcessService {
-	s := service.NewProcessService(repo, txFunc)
+func NewProcessService(cfg domain.CacheConfig, repo database.ProcessRepo, txFunc domain.ExecTxFunc,
+	schemas *service.SchemaCache) domain.ProcessService {
+
+	s := service.NewProcessService(repo, txFunc, schemas)
 	cached, err := cache.NewCachedProcessRepo(cfg.DefaultEntityCount, s)
 	if err != nil {
 		log.Fatal(err)
@@ -210,13 +313,28 @@ func NewProcessService(cfg domain.CacheConfig, repo database.ProcessRepo, txFunc
 	return tracing.NewSpanProcessService(cached)
 }
 
-func NewOrderService(cfg domain.CacheConfig, processService domain.ProcessService, orderRepo database.OrderRepo,
-	jobRepo database.JobRepo, txFunc domain.ExecTxFunc) domain.OrderService {
+func NewProcessDefinitionService(processRepo database.ProcessRepo, readMappingRepo database.ReadMappingRepo,
+	newUUIDFunc database.NewUUIDFunc, txFunc domain.ExecTxFunc) domain.ProcessDefinitionService {
+
+	s := service.NewProcessDefinitionService(processRepo, readMappingRepo, newUUIDFunc, txFunc)
+	return tracing.NewSpanProcessDefinitionService(s)
+}
+
+func NewOrderService(cfg domain.CacheConfig, orderCfg domain.OrderConfig, processService domain.ProcessService,
+	orderRepo database.OrderRepo, jobRepo database.JobRepo, txFunc domain.ExecTxFunc,
+	schemas *service.SchemaCache) domain.OrderService {
 
-	s := service.NewOrderService(processService, orderRepo, jobRepo, txFunc)
+	s := service.NewOrderService(orderCfg, processService, orderRepo, jobRepo, txFunc, schemas)
 	cached, err := cache.NewCachedOrderService(cfg.DefaultEntityCount, s)
 	if err != nil {
 		log.Fatal(err)
 	}
 	return tracing.NewSpanOrderService(cached)
 }
+
+func NewExternalJobService(cfg domain.SchedulerConfig, jobRepo database.JobRepo, orderService domain.OrderService,
+	readMappingService domain.ReadMappingService) domain.ExternalJobService {
+
+	s := service.NewExternalJobService(cfg, jobRepo, orderService, readMappingService)
+	return tracing.NewSpanExternalJobService(s)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	appconf "example.com/oligzeev/pp-gin/internal/config"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `Usage: pp-gin migrate [flags] <command>
+
+Commands:
+  status          print applied and pending migrations
+  up              apply pending migrations
+  down [-steps n] revert the latest applied migrations
+
+Flags:
+`
+
+// Manage schema migrations of the configured database: pp-gin migrate status | up | down [-steps n]
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "config/pp-gin.yaml", "Configuration file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("command is required")
+	}
+
+	cfg, err := appconf.ReadConfig(*configFile, "pp")
+	if err != nil {
+		return err
+	}
+	_, migrator, closer, err := openDatabase(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	ctx := context.Background()
+	switch flags.Arg(0) {
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	case "up":
+		count, err := migrator.Up(ctx)
+		fmt.Printf("Applied migrations: %d\n", count)
+		return err
+	case "down":
+		downFlags := flag.NewFlagSet("down", flag.ContinueOnError)
+		steps := downFlags.Int("steps", 1, "Number of migrations to revert")
+		if err := downFlags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+		count, err := migrator.Down(ctx, *steps)
+		fmt.Printf("Reverted migrations: %d\n", count)
+		return err
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command: %s", flags.Arg(0))
+	}
+}
+
+// Connect to the database of the commands (migrate, check), in-memory database isn't supported
+func openDatabase(cfg domain.DbConfig) (database.DB, *database.Migrator, io.Closer, error) {
+	switch cfg.Driver {
+	case domain.DbDriverSqlite:
+		db, err := database.ConnectSQLite(cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return db, database.NewSQLiteMigrator(db), db, nil
+	case "", domain.DbDriverPostgres:
+		db, err := database.Connect(cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return db, database.NewPostgresMigrator(db), db, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("database driver isn't supported by the command: %s", cfg.Driver)
+	}
+}
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
 	appconf "example.com/oligzeev/pp-gin/internal/config"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"example.com/oligzeev/pp-gin/internal/metric"
-	"example.com/oligzeev/pp-gin/internal/rest"
 	"example.com/oligzeev/pp-gin/internal/tracing"
+	"example.com/oligzeev/pp-gin/pkg/worker"
 	"github.com/fvbock/endless"
 	"github.com/gin-gonic/gin"
-	"github.com/hashicorp/go-retryablehttp"
 	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/uber/jaeger-client-go"
 	jaegerconf "github.com/uber/jaeger-client-go/config"
 	"io"
 	"strconv"
+	"time"
+)
+
+const (
+	stubStartPath = "/stub/start"
+	stubSyncPath  = "/stub/sync"
 )
 
 func main() {
@@ -24,16 +30,25 @@ func main() {
 	_, closer := initTracing(cfg.Tracing)
 	defer closer.Close()
 
-	httpClient := retryablehttp.NewClient()
-	httpClient.RetryMax = cfg.Stub.SendJobRetriesMax
-	jobCompleteClient := rest.NewJobCompleteRestClient(cfg.Stub.ResponseUrl, httpClient)
+	w := initWorker(cfg.Rest.Client, cfg.Stub)
+	if len(cfg.Stub.Topics) > 0 {
+		go func() {
+			if err := w.Run(context.Background()); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
 
-	router := initRouter(cfg.Rest, []domain.RestHandler{
-		rest.NewStubRestHandler(jobCompleteClient),
-	})
+	router := initRouter(cfg.Rest, w)
 	initServer(cfg.Rest, router)
 }
 
+// Stub returns body of the start message as the output of the job
+func stubHandler(ctx context.Context, job *worker.Job) (map[string]interface{}, error) {
+	log.Tracef("Stub: job received (%s, %s, %s)", job.Topic, job.TaskId, job.OrderId)
+	return job.Body, nil
+}
+
 // *****************************
 // *** Initialize components ***
 // *****************************
@@ -70,23 +85,38 @@ func initTracing(cfg domain.TracingConfig) (opentracing.Tracer, io.Closer) {
 	return tracer, closer
 }
 
-func initRouter(cfg domain.RestConfig, handlers []domain.RestHandler) *gin.Engine {
+func initWorker(clientCfg domain.ClientRestConfig, cfg domain.StubConfig) *worker.Worker {
+	w := worker.New(worker.Config{
+		ServerUrl:    cfg.ServerUrl,
+		RetriesMax:   clientCfg.RetriesMax,
+		Timeout:      clientCfg.TimeoutSec * time.Second,
+		MaxJobs:      cfg.MaxJobs,
+		LockDuration: cfg.LockDurationSec * time.Second,
+	})
+	w.Handle(stubStartPath, stubHandler)
+	w.HandleSync(stubSyncPath, stubHandler)
+	for _, topic := range cfg.Topics {
+		w.Handle(topic, stubHandler)
+	}
+	return w
+}
+
+func initRouter(cfg domain.RestConfig, w *worker.Worker) *gin.Engine {
 	router := gin.Default()
 
 	// Jaeger middleware initialization
 	router.Use(tracing.Middleware())
 
 	// Prometheus handler initialization
-	router.GET(cfg.MetricsUrl, metric.PrometheusHandler())
+	router.GET(cfg.Server.MetricsUrl, metric.PrometheusHandler())
 
-	for _, handler := range handlers {
-		handler.Register(router)
-	}
+	router.POST(stubStartPath, gin.WrapH(w))
+	router.POST(stubSyncPath, gin.WrapH(w))
 	return router
 }
 
 func initServer(cfg domain.RestConfig, r *gin.Engine) {
-	if err := endless.ListenAndServe(cfg.Host+":"+strconv.Itoa(cfg.Port), r); err != nil {
+	if err := endless.ListenAndServe(cfg.Server.Host+":"+strconv.Itoa(cfg.Server.Port), r); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	client  *client.Client
+	printer *printer
+}
+
+func (c command) run(ctx context.Context, resource, name string, args []string) error {
+	switch resource {
+	case "mapping":
+		return c.mapping(ctx, name, args)
+	case "process":
+		return c.process(ctx, name, args)
+	case "order":
+		return c.order(ctx, name, args)
+	case "job":
+		return c.job(ctx, name, args)
+	case "breaker":
+		return c.breaker(ctx, name, args)
+	}
+	return fmt.Errorf("unknown resource (%s)", resource)
+}
+
+func (c command) mapping(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "list":
+		var result []client.ReadMapping
+		if err := c.client.GetReadMappings(ctx, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "get":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		var result client.ReadMapping
+		if err := c.client.GetReadMappingById(ctx, id, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	case "delete":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		return c.client.DeleteReadMappingById(ctx, id)
+	case "create":
+		var obj client.ReadMapping
+		if _, err := parseFile(name, args, true, &obj); err != nil {
+			return err
+		}
+		if err := c.client.CreateReadMapping(ctx, &obj); err != nil {
+			return err
+		}
+		return c.printer.print(&obj)
+	}
+	return fmt.Errorf("unknown mapping command (%s)", name)
+}
+
+func (c command) process(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "list":
+		var result []client.Process
+		if err := c.client.GetProcesses(ctx, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "get":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		var result client.Process
+		if err := c.client.GetProcessById(ctx, id, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	case "delete":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		return c.client.DeleteProcessById(ctx, id)
+	case "create":
+		var obj client.Process
+		if _, err := parseFile(name, args, true, &obj); err != nil {
+			return err
+		}
+		if err := c.client.CreateProcess(ctx, &obj); err != nil {
+			return err
+		}
+		return c.printer.print(&obj)
+	case "import":
+		var def client.ProcessDefinition
+		if _, err := parseFile(name, args, true, &def); err != nil {
+			return err
+		}
+		var result client.Process
+		if err := c.client.ImportProcess(ctx, &def, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	case "bpmn":
+		flags := flag.NewFlagSet(name, flag.ContinueOnError)
+		fileName := flags.String("f", "", "BPMN 2.0 file (- means stdin)")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if *fileName == "" {
+			return fmt.Errorf("file is required")
+		}
+		data, err := readFile(*fileName)
+		if err != nil {
+			return err
+		}
+		var result client.Process
+		if err := c.client.ImportBpmn(ctx, data, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	case "graph":
+		return c.graph(ctx, args, c.client.GetProcessGraph)
+	case "export":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		var result client.ProcessDefinition
+		if err := c.client.ExportProcess(ctx, id, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	}
+	return fmt.Errorf("unknown process command (%s)", name)
+}
+
+func (c command) order(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "list":
+		var result []client.Order
+		if err := c.client.GetOrders(ctx, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "get":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		var result client.Order
+		if err := c.client.GetOrderById(ctx, id, &result); err != nil {
+			return err
+		}
+		return c.printer.print(&result)
+	case "jobs":
+		id, err := argument(args, 0, "id")
+		if err != nil {
+			return err
+		}
+		var result []client.Job
+		if err := c.client.GetOrderJobs(ctx, id, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "submit":
+		var body client.Body
+		rest, err := parseFile(name, args, true, &body)
+		if err != nil {
+			return err
+		}
+		processId, err := argument(rest, 0, "process_id")
+		if err != nil {
+			return err
+		}
+		order := client.Order{Body: body}
+		if err := c.client.SubmitOrder(ctx, &order, processId); err != nil {
+			return err
+		}
+		return c.printer.print(&order)
+	case "search":
+		processId, err := argument(args, 0, "process_id")
+		if err != nil {
+			return err
+		}
+		search, err := parsePredicates(args[1:])
+		if err != nil {
+			return err
+		}
+		var result []client.Order
+		if err := c.client.SearchOrders(ctx, processId, search, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "graph":
+		return c.graph(ctx, args, c.client.GetOrderGraph)
+	case "watch":
+		flags := flag.NewFlagSet(name, flag.ContinueOnError)
+		interval := flags.Duration("interval", 2*time.Second, "Polling interval")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		id, err := argument(flags.Args(), 0, "id")
+		if err != nil {
+			return err
+		}
+		return c.watchOrder(ctx, id, *interval)
+	}
+	return fmt.Errorf("unknown order command (%s)", name)
+}
+
+// Write rendered graph as-is, the output format isn't applied
+func (c command) graph(ctx context.Context, args []string,
+	render func(ctx context.Context, id, format string, result *[]byte) error) error {
+
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := flags.String("format", "dot", "Graph format (dot, mermaid or svg)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := argument(flags.Args(), 0, "id")
+	if err != nil {
+		return err
+	}
+	var result []byte
+	if err := render(ctx, id, *format, &result); err != nil {
+		return err
+	}
+	_, err = c.printer.out.Write(result)
+	return err
+}
+
+// Print jobs of the order until all of them are finished (completed, failed or cancelled)
+func (c command) watchOrder(ctx context.Context, id string, interval time.Duration) error {
+	for {
+		var jobs []client.Job
+		if err := c.client.GetOrderJobs(ctx, id, &jobs); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.printer.out, "%s\n", time.Now().Format(time.RFC3339))
+		if err := c.printer.print(jobs); err != nil {
+			return err
+		}
+		if finished(jobs) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func finished(jobs []client.Job) bool {
+	for _, job := range jobs {
+		if job.Status != client.JobCompleted && job.Status != client.JobFailed && job.Status != client.JobCancelled {
+			return false
+		}
+	}
+	return true
+}
+
+func (c command) job(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "complete":
+		var output client.Body
+		rest, err := parseFile(name, args, false, &output)
+		if err != nil {
+			return err
+		}
+		ref, err := jobRef(rest)
+		if err != nil {
+			return err
+		}
+		return c.client.CompleteJob(ctx, &client.JobCompleteMessage{
+			TaskId:  ref.TaskId,
+			OrderId: ref.OrderId,
+			Body:    output,
+		})
+	case "retry":
+		ref, err := jobRef(args)
+		if err != nil {
+			return err
+		}
+		return c.client.RetryJob(ctx, ref)
+	case "cancel":
+		ref, err := jobRef(args)
+		if err != nil {
+			return err
+		}
+		return c.client.CancelJob(ctx, ref)
+	}
+	return fmt.Errorf("unknown job command (%s)", name)
+}
+
+func (c command) breaker(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "list":
+		var result []client.Breaker
+		if err := c.client.GetBreakers(ctx, &result); err != nil {
+			return err
+		}
+		return c.printer.print(result)
+	case "reset":
+		host, err := argument(args, 0, "host")
+		if err != nil {
+			return err
+		}
+		return c.client.ResetBreaker(ctx, host)
+	}
+	return fmt.Errorf("unknown breaker command (%s)", name)
+}
+
+func argument(args []string, i int, name string) (string, error) {
+	if len(args) <= i || args[i] == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return args[i], nil
+}
+
+// Predicates of the search are path=value arguments, the value is decoded as JSON (if it's valid) or taken as-is
+func parsePredicates(args []string) (*client.OrderSearch, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("predicate is required")
+	}
+	result := &client.OrderSearch{}
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("predicate has to be path=value (%s)", arg)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(arg[i+1:]), &value); err != nil {
+			value = arg[i+1:]
+		}
+		result.Predicates = append(result.Predicates, client.SearchPredicate{Path: arg[:i], Value: value})
+	}
+	return result, nil
+}
+
+func jobRef(args []string) (*client.JobRef, error) {
+	orderId, err := argument(args, 0, "order_id")
+	if err != nil {
+		return nil, err
+	}
+	taskId, err := argument(args, 1, "task_id")
+	if err != nil {
+		return nil, err
+	}
+	return &client.JobRef{TaskId: taskId, OrderId: orderId}, nil
+}
+
+// Parse -f flag of the command and decode the file into the object, returns remaining arguments
+func parseFile(name string, args []string, required bool, obj interface{}) ([]string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	fileName := flags.String("f", "", "JSON or YAML file (- means stdin)")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *fileName == "" {
+		if required {
+			return nil, fmt.Errorf("file is required")
+		}
+		return flags.Args(), nil
+	}
+	if err := decodeFile(*fileName, obj); err != nil {
+		return nil, err
+	}
+	return flags.Args(), nil
+}
+
+// JSON is a subset of YAML, so both are decoded by yaml and converted into json to use json names of the fields
+func decodeFile(fileName string, obj interface{}) error {
+	data, err := readFile(fileName)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("can't unmarshal file (%s): %w", fileName, err)
+	}
+	jsonBytes, err := json.Marshal(toJsonValue(value))
+	if err != nil {
+		return fmt.Errorf("can't convert file (%s): %w", fileName, err)
+	}
+	if err := json.Unmarshal(jsonBytes, obj); err != nil {
+		return fmt.Errorf("can't decode file (%s): %w", fileName, err)
+	}
+	return nil
+}
+
+func readFile(fileName string) ([]byte, error) {
+	var data []byte
+	var err error
+	if fileName == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fileName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read file (%s): %w", fileName, err)
+	}
+	return data, nil
+}
+
+// Convert yaml maps (with interface{} keys) into json ones
+func toJsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[fmt.Sprint(key)] = toJsonValue(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range value {
+			value[i] = toJsonValue(item)
+		}
+	}
+	return value
+}
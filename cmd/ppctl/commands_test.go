@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePredicates(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		args     []string
+		expected []client.SearchPredicate
+		valid    bool
+	}{
+		{[]string{"$.id=1"}, []client.SearchPredicate{{Path: "$.id", Value: float64(1)}}, true},
+		{[]string{"$.id=c1", `$.flag=true`}, []client.SearchPredicate{{Path: "$.id", Value: "c1"},
+			{Path: "$.flag", Value: true}}, true},
+		{[]string{`$.id="1"`}, []client.SearchPredicate{{Path: "$.id", Value: "1"}}, true},
+		{[]string{`$.item={"id":"1"}`}, []client.SearchPredicate{{Path: "$.item",
+			Value: map[string]interface{}{"id": "1"}}}, true},
+		{[]string{"$.id=a=b"}, []client.SearchPredicate{{Path: "$.id", Value: "a=b"}}, true},
+		{[]string{"$.id="}, []client.SearchPredicate{{Path: "$.id", Value: ""}}, true},
+		{nil, nil, false},
+		{[]string{"$.id"}, nil, false},
+		{[]string{"=1"}, nil, false},
+	}
+	for _, test := range tests {
+		result, err := parsePredicates(test.args)
+		if test.valid {
+			if assert.Nil(err, test.args) {
+				assert.Equal(test.expected, result.Predicates, test.args)
+			}
+		} else {
+			assert.NotNil(err, test.args)
+		}
+	}
+}
+
+func TestJobRef(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		args     []string
+		expected *client.JobRef
+	}{
+		{[]string{"o1", "t1"}, &client.JobRef{OrderId: "o1", TaskId: "t1"}},
+		{[]string{"o1", "t1", "extra"}, &client.JobRef{OrderId: "o1", TaskId: "t1"}},
+		{[]string{"o1"}, nil},
+		{[]string{"", "t1"}, nil},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		result, err := jobRef(test.args)
+		assert.Equal(test.expected, result, test.args)
+		assert.Equal(test.expected == nil, err != nil, test.args)
+	}
+}
+
+func TestToJsonValue(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{"value", "value"},
+		{map[interface{}]interface{}{"key": 1, 2: "two"}, map[string]interface{}{"key": 1, "2": "two"}},
+		{[]interface{}{map[interface{}]interface{}{"key": []interface{}{map[interface{}]interface{}{"nested": true}}}},
+			[]interface{}{map[string]interface{}{"key": []interface{}{map[string]interface{}{"nested": true}}}}},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		assert.Equal(test.expected, toJsonValue(test.value))
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "ppctl")
+	if !assert.Nil(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	expected := client.Process{Name: "process", Tasks: []client.Task{{Id: "t1", Name: "first",
+		Action: "http://first", ReadMappingId: "m1"}}}
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"process.json", `{"name": "process", "tasks": [{"id": "t1", "name": "first", "action": "http://first",
+			"readMappingId": "m1"}]}`, true},
+		{"process.yaml", "name: process\ntasks:\n  - id: t1\n    name: first\n    action: http://first\n" +
+			"    readMappingId: m1\n", true},
+		{"invalid.yaml", "name: [process", false},
+		{"mismatch.json", `{"name": ["process"]}`, false},
+	}
+	for _, test := range tests {
+		fileName := filepath.Join(dir, test.name)
+		if !assert.Nil(ioutil.WriteFile(fileName, []byte(test.content), 0600)) {
+			continue
+		}
+		var result client.Process
+		err := decodeFile(fileName, &result)
+		if test.valid {
+			assert.Nil(err, test.name)
+			assert.Equal(expected, result, test.name)
+		} else {
+			assert.NotNil(err, test.name)
+		}
+	}
+	var result client.Process
+	assert.NotNil(decodeFile(filepath.Join(dir, "absent.json"), &result))
+}
+
+// Server of the mapping, process, order and job endpoints, requests are recorded by the paths
+func testServer(requests map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	record := func(r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = body
+	}
+	create := func(id string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			record(r)
+			var obj map[string]interface{}
+			_ = json.Unmarshal(requests[r.Method+" "+r.URL.Path], &obj)
+			obj["id"] = id
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(obj)
+		}
+	}
+	mux.HandleFunc("/mapping/", create("m1"))
+	mux.HandleFunc("/process/", create("p1"))
+	mux.HandleFunc("/order/o1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]client.Job{
+			{TaskId: "t1", OrderId: "o1", Status: client.JobCompleted, Attempt: 1},
+			{TaskId: "t2", OrderId: "o1", Status: client.JobCancelled, Attempt: 2, ErrorCode: client.ErrCancelled},
+		})
+	})
+	mux.HandleFunc("/order/p1/search", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]client.Order{{Id: "o1", ProcessId: "p1"}})
+	})
+	mux.HandleFunc("/job/cancel", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+	})
+	return httptest.NewServer(mux)
+}
+
+func testCommand(url, format string) (*command, *bytes.Buffer) {
+	var out bytes.Buffer
+	c := client.New(client.Config{Url: url})
+	return &command{client: c, printer: &printer{format: format, out: &out}}, &out
+}
+
+func TestCommand_OrderJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(map[string][]byte)
+	server := testServer(requests)
+	defer server.Close()
+
+	cmd, out := testCommand(server.URL, formatTable)
+	assert.Nil(cmd.run(context.Background(), "order", "jobs", []string{"o1"}))
+	assert.Contains(requests, "GET /order/o1/jobs")
+	assert.Equal("TASK_ID  STATUS     ATTEMPT  START_AFTER  ERROR_CODE\n"+
+		"t1       completed  1                     \n"+
+		"t2       cancelled  2                     "+string(client.ErrCancelled)+"\n", out.String())
+
+	// Watch is finished since all the jobs are finished
+	out.Reset()
+	assert.Nil(cmd.run(context.Background(), "order", "watch", []string{"o1"}))
+	assert.Contains(out.String(), "t2       cancelled")
+
+	assert.NotNil(cmd.run(context.Background(), "order", "jobs", nil))
+	assert.NotNil(cmd.run(context.Background(), "order", "unknown", nil))
+	assert.NotNil(cmd.run(context.Background(), "unknown", "list", nil))
+}
+
+func TestCommand_OrderSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(map[string][]byte)
+	server := testServer(requests)
+	defer server.Close()
+
+	cmd, out := testCommand(server.URL, formatJson)
+	assert.Nil(cmd.run(context.Background(), "order", "search", []string{"p1", "$.customer.id=c1"}))
+	var search client.OrderSearch
+	if assert.Nil(json.Unmarshal(requests["POST /order/p1/search"], &search)) {
+		assert.Equal([]client.SearchPredicate{{Path: "$.customer.id", Value: "c1"}}, search.Predicates)
+	}
+	var orders []client.Order
+	assert.Nil(json.Unmarshal(out.Bytes(), &orders))
+	assert.Equal([]client.Order{{Id: "o1", ProcessId: "p1"}}, orders)
+
+	assert.NotNil(cmd.run(context.Background(), "order", "search", []string{"p1"}))
+}
+
+func TestCommand_JobCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(map[string][]byte)
+	server := testServer(requests)
+	defer server.Close()
+
+	cmd, _ := testCommand(server.URL, formatTable)
+	assert.Nil(cmd.run(context.Background(), "job", "cancel", []string{"o1", "t1"}))
+	var ref client.JobRef
+	if assert.Nil(json.Unmarshal(requests["POST /job/cancel"], &ref)) {
+		assert.Equal(client.JobRef{OrderId: "o1", TaskId: "t1"}, ref)
+	}
+
+	// Unknown endpoint is returned as not found
+	err := cmd.run(context.Background(), "job", "retry", []string{"o1", "t1"})
+	assert.Equal(client.ErrNotFound, client.ECode(err))
+}
+
+func TestCommand_Create(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(map[string][]byte)
+	server := testServer(requests)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "ppctl")
+	if !assert.Nil(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	mappingFile := filepath.Join(dir, "mapping.yaml")
+	processFile := filepath.Join(dir, "process.yaml")
+	assert.Nil(ioutil.WriteFile(mappingFile, []byte("body:\n  id: $.id\n"), 0600))
+	assert.Nil(ioutil.WriteFile(processFile, []byte("name: process\n"), 0600))
+
+	cmd, out := testCommand(server.URL, formatJson)
+	assert.Nil(cmd.run(context.Background(), "mapping", "create", []string{"-f", mappingFile}))
+	var mapping client.ReadMapping
+	if assert.Nil(json.Unmarshal(requests["POST /mapping/"], &mapping)) {
+		assert.Equal(client.Body{"id": "$.id"}, mapping.Body)
+	}
+	assert.Nil(json.Unmarshal(out.Bytes(), &mapping))
+	assert.Equal("m1", mapping.Id)
+
+	out.Reset()
+	assert.Nil(cmd.run(context.Background(), "process", "create", []string{"-f", processFile}))
+	var process client.Process
+	if assert.Nil(json.Unmarshal(requests["POST /process/"], &process)) {
+		assert.Equal("process", process.Name)
+	}
+	assert.Nil(json.Unmarshal(out.Bytes(), &process))
+	assert.Equal("p1", process.Id)
+
+	// Apply is replaced by create, since the existing ones aren't updated
+	assert.NotNil(cmd.run(context.Background(), "mapping", "apply", []string{"-f", mappingFile}))
+	assert.NotNil(cmd.run(context.Background(), "process", "apply", []string{"-f", processFile}))
+}
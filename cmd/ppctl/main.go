@@ -0,0 +1,124 @@
+// ppctl is a command-line tool to manage processes, mappings, orders and jobs of pp-gin.
+//
+//	ppctl [-config file] [-url url] [-o table|json|yaml] <resource> <command> [arguments]
+//
+// Configuration (server url, retries and timeout) is read from $HOME/.ppctl.yaml by default,
+// flags override it.
+package main
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage: ppctl [flags] <resource> <command> [arguments]
+
+Resources and commands:
+  mapping list | get <id> | delete <id> | create -f <file>
+  process list | get <id> | delete <id> | create -f <file> | import -f <file> | bpmn -f <file> | export <id> |
+          graph [-format dot] <id>
+  order   list | get <id> | jobs <id> | submit -f <file> <process_id> | watch [-interval 2s] <id> |
+          graph [-format dot] <id> | search <process_id> <path>=<value>...
+  job     complete [-f <output file>] <order_id> <task_id> | retry <order_id> <task_id> |
+          cancel <order_id> <task_id>
+  breaker list | reset <host>
+
+Files could be in JSON or YAML format, "-" means stdin. Paths of the search are the search paths of the process,
+e.g. '$.customer.id=c1', values are decoded as JSON (if they're valid). Search paths are set by the import
+of the process only, the definition is imported again (as the new process) to change them. Mappings and processes
+aren't updated, so every create makes the new one.
+
+Flags:
+`
+
+type Config struct {
+	Url        string        `yaml:"url"`
+	RetriesMax int           `yaml:"retriesMax"`
+	TimeoutSec time.Duration `yaml:"timeoutSec"`
+	Output     string        `yaml:"output"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil && err != flag.ErrHelp {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ppctl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", defaultConfigFile(), "Configuration file")
+	url := flags.String("url", "", "Server url (overrides configuration)")
+	output := flags.String("o", "", "Output format: table, json or yaml (overrides configuration)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := readConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if *url != "" {
+		cfg.Url = *url
+	}
+	if *output != "" {
+		cfg.Output = *output
+	}
+	printer, err := newPrinter(cfg.Output, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("resource and command are required")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	c := client.New(client.Config{Url: cfg.Url, RetriesMax: cfg.RetriesMax, Timeout: cfg.TimeoutSec * time.Second})
+	cmd := &command{client: c, printer: printer}
+	return cmd.run(ctx, flags.Arg(0), flags.Arg(1), flags.Args()[2:])
+}
+
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".ppctl.yaml"
+	}
+	return filepath.Join(home, ".ppctl.yaml")
+}
+
+// Absent configuration file means default configuration
+func readConfig(fileName string) (*Config, error) {
+	cfg := Config{Url: "http://localhost:8080", RetriesMax: 2, TimeoutSec: 10, Output: formatTable}
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return &cfg, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read configuration (%s): %w", fileName, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("can't unmarshal configuration (%s): %w", fileName, err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJson  = "json"
+	formatYaml  = "yaml"
+)
+
+type printer struct {
+	format string
+	out    io.Writer
+}
+
+func newPrinter(format string, out io.Writer) (*printer, error) {
+	switch format {
+	case formatTable, formatJson, formatYaml:
+		return &printer{format: format, out: out}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format (%s)", format)
+}
+
+// Print object in json or yaml format, in table format there's a row per object
+func (p printer) print(obj interface{}) error {
+	switch p.format {
+	case formatJson:
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(obj)
+	case formatYaml:
+		// Use json names of the fields
+		var value interface{}
+		jsonBytes, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(jsonBytes, &value); err != nil {
+			return err
+		}
+		yamlBytes, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = p.out.Write(yamlBytes)
+		return err
+	}
+	header, rows := tableOf(obj)
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func tableOf(obj interface{}) ([]string, [][]string) {
+	switch obj := obj.(type) {
+	case *client.ReadMapping:
+		return tableOf([]client.ReadMapping{*obj})
+	case []client.ReadMapping:
+		rows := make([][]string, len(obj))
+		for i, mapping := range obj {
+			keys := make([]string, 0, len(mapping.Body))
+			for key := range mapping.Body {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			rows[i] = []string{mapping.Id, strings.Join(keys, ",")}
+		}
+		return []string{"ID", "KEYS"}, rows
+	case *client.Process:
+		return tableOf([]client.Process{*obj})
+	case []client.Process:
+		rows := make([][]string, len(obj))
+		for i, process := range obj {
+			rows[i] = []string{process.Id, process.Name, fmt.Sprint(len(process.Tasks)),
+				fmt.Sprint(len(process.TaskRelations))}
+		}
+		return []string{"ID", "NAME", "TASKS", "RELATIONS"}, rows
+	case *client.ProcessDefinition:
+		rows := make([][]string, len(obj.Tasks))
+		for i, task := range obj.Tasks {
+			rows[i] = []string{task.Name, task.Category, task.Action, task.Mapping, strings.Join(task.DependsOn, ",")}
+		}
+		return []string{"NAME", "CATEGORY", "ACTION", "MAPPING", "DEPENDS_ON"}, rows
+	case *client.Order:
+		return tableOf([]client.Order{*obj})
+	case []client.Order:
+		rows := make([][]string, len(obj))
+		for i, order := range obj {
+			rows[i] = []string{order.Id, order.ProcessId}
+		}
+		return []string{"ID", "PROCESS_ID"}, rows
+	case []client.Job:
+		rows := make([][]string, len(obj))
+		for i, job := range obj {
+			rows[i] = []string{job.TaskId, job.Status, fmt.Sprint(job.Attempt), formatTime(job.StartAfter),
+				string(job.ErrorCode)}
+		}
+		return []string{"TASK_ID", "STATUS", "ATTEMPT", "START_AFTER", "ERROR_CODE"}, rows
+	case []client.Breaker:
+		rows := make([][]string, len(obj))
+		for i, breaker := range obj {
+			rows[i] = []string{breaker.Host, breaker.State, fmt.Sprint(breaker.Failures), formatTime(breaker.OpenedAt)}
+		}
+		return []string{"HOST", "STATE", "FAILURES", "OPENED_AT"}, rows
+	}
+	return []string{"VALUE"}, [][]string{{fmt.Sprint(obj)}}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
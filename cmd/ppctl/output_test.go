@@ -0,0 +1,43 @@
+package main
+
+import (
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTableOf(t *testing.T) {
+	assert := assert.New(t)
+
+	openedAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		obj    interface{}
+		header []string
+		rows   [][]string
+	}{
+		{&client.ReadMapping{Id: "m1", Body: client.Body{"b": "$.b", "a": "$.a"}},
+			[]string{"ID", "KEYS"}, [][]string{{"m1", "a,b"}}},
+		{[]client.Process{{Id: "p1", Name: "process", Tasks: []client.Task{{Id: "t1"}, {Id: "t2"}},
+			TaskRelations: []client.TaskRelation{{ParentId: "t1", ChildId: "t2"}}}},
+			[]string{"ID", "NAME", "TASKS", "RELATIONS"}, [][]string{{"p1", "process", "2", "1"}}},
+		{&client.ProcessDefinition{Tasks: []client.TaskDefinition{{Name: "second", Category: "http",
+			Action: "http://second", Mapping: "m1", DependsOn: []string{"first", "third"}}}},
+			[]string{"NAME", "CATEGORY", "ACTION", "MAPPING", "DEPENDS_ON"},
+			[][]string{{"second", "http", "http://second", "m1", "first,third"}}},
+		{&client.Order{Id: "o1", ProcessId: "p1"}, []string{"ID", "PROCESS_ID"}, [][]string{{"o1", "p1"}}},
+		{[]client.Order{}, []string{"ID", "PROCESS_ID"}, [][]string{}},
+		{[]client.Job{{TaskId: "t1", Status: client.JobFailed, Attempt: 3, StartAfter: &openedAt,
+			ErrorCode: client.ErrRemotePermanent}}, []string{"TASK_ID", "STATUS", "ATTEMPT", "START_AFTER", "ERROR_CODE"},
+			[][]string{{"t1", client.JobFailed, "3", "2021-03-01T10:00:00Z", string(client.ErrRemotePermanent)}}},
+		{[]client.Breaker{{Host: "localhost:8082", State: "open", Failures: 5, OpenedAt: &openedAt}},
+			[]string{"HOST", "STATE", "FAILURES", "OPENED_AT"},
+			[][]string{{"localhost:8082", "open", "5", "2021-03-01T10:00:00Z"}}},
+		{"value", []string{"VALUE"}, [][]string{{"value"}}},
+	}
+	for _, test := range tests {
+		header, rows := tableOf(test.obj)
+		assert.Equal(test.header, header)
+		assert.Equal(test.rows, rows)
+	}
+}
@@ -0,0 +1,176 @@
+// Package bpmn translates a subset of BPMN 2.0 XML into the process definition.
+//
+// Supported elements are start and end events, service and user tasks, parallel gateways, converging exclusive
+// gateways, timer events (durations only) and embedded sub-processes. Gateways, events and sub-processes don't
+// become tasks, the tasks depend on the nearest preceding tasks. Parameters of the tasks are attributes and
+// extension elements of the pp namespace, e.g.
+//
+//	<serviceTask id="reserve" name="Reserve" pp:category="http" pp:action="http://stock/reserve" pp:method="PUT">
+//	  <extensionElements>
+//	    <pp:input name="productId" value="$.product.id"/>
+//	    <pp:header name="Authorization" value="Bearer {{$.auth.token}}"/>
+//	  </extensionElements>
+//	</serviceTask>
+package bpmn
+
+import (
+	"bytes"
+	"encoding/xml"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ModelNamespace = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+	Namespace      = "urn:pp-gin:bpmn" // Namespace of the task parameters
+)
+
+// Unsupported or invalid element of the document
+type Diagnostic struct {
+	Line    int
+	Element string
+	Id      string
+	Msg     string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: %s (%s): %s", d.Line, d.Element, d.Id, d.Msg)
+}
+
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, len(d))
+	for i, diagnostic := range d {
+		msgs[i] = diagnostic.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Element of the document with the line of its start tag
+type element struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*element
+	text     string
+	line     int
+}
+
+func (e *element) attr(space, local string) string {
+	for _, attr := range e.attrs {
+		// Attributes without prefix belong to the namespace of the element
+		if attr.Name.Local == local && (attr.Name.Space == space || attr.Name.Space == "" && space == e.name.Space) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (e *element) child(space, local string) *element {
+	for _, child := range e.children {
+		if child.name.Space == space && child.name.Local == local {
+			return child
+		}
+	}
+	return nil
+}
+
+func (e *element) diagnostic(format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Line:    e.line,
+		Element: e.name.Local,
+		Id:      e.attr(ModelNamespace, "id"),
+		Msg:     fmt.Sprintf(format, args...),
+	}
+}
+
+func parse(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root *element
+	var stack []*element
+	line, offset := 1, int64(0)
+	for {
+		// Count lines up to the beginning of the token
+		nextOffset := decoder.InputOffset()
+		line += bytes.Count(data[offset:nextOffset], []byte("\n"))
+		offset = nextOffset
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			e := &element{name: token.Name, attrs: token.Attr, line: line}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return root, nil
+}
+
+// Translate BPMN document into the process definition, all unsupported elements are reported by Diagnostics
+func Translate(data []byte) (*domain.ProcessDefinition, error) {
+	const op = "Bpmn.Translate"
+
+	root, err := parse(data)
+	if err != nil {
+		return nil, domain.E(op, domain.ErrValidation, "can't parse document", err)
+	}
+	if root.name.Space != ModelNamespace || root.name.Local != "definitions" {
+		return nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("bpmn definitions are expected (%s)",
+			root.name.Local))
+	}
+	process, err := findProcess(root)
+	if err != nil {
+		return nil, domain.E(op, domain.ErrValidation, err)
+	}
+
+	t := newTranslator()
+	t.collect(process, nil)
+	def := t.definition(process)
+	if len(t.diagnostics) > 0 {
+		return nil, domain.E(op, domain.ErrValidation, "unsupported bpmn", t.diagnostics)
+	}
+	return def, nil
+}
+
+// Single executable process is translated
+func findProcess(root *element) (*element, error) {
+	var processes, executable []*element
+	for _, child := range root.children {
+		if child.name.Space == ModelNamespace && child.name.Local == "process" {
+			processes = append(processes, child)
+			if child.attr(ModelNamespace, "isExecutable") == "true" {
+				executable = append(executable, child)
+			}
+		}
+	}
+	switch {
+	case len(processes) == 1:
+		return processes[0], nil
+	case len(executable) == 1:
+		return executable[0], nil
+	case len(processes) == 0:
+		return nil, fmt.Errorf("there's no process")
+	}
+	return nil, fmt.Errorf("single executable process is expected (%d)", len(executable))
+}
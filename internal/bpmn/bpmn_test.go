@@ -0,0 +1,168 @@
+package bpmn
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const (
+	orderBpmn = `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:pp="urn:pp-gin:bpmn" id="defs">
+  <process id="order" name="Order" isExecutable="true">
+    <startEvent id="start"/>
+    <sequenceFlow id="f1" sourceRef="start" targetRef="fork"/>
+    <parallelGateway id="fork"/>
+    <sequenceFlow id="f2" sourceRef="fork" targetRef="reserve"/>
+    <sequenceFlow id="f3" sourceRef="fork" targetRef="approve"/>
+    <serviceTask id="reserve" name="Reserve" pp:action="http://stock/reserve" pp:method="PUT" pp:sync="true">
+      <extensionElements>
+        <pp:input name="productId" value="$.product.id"/>
+        <pp:header name="Authorization" value="Bearer {{$.auth.token}}"/>
+      </extensionElements>
+    </serviceTask>
+    <userTask id="approve" name="Approve"/>
+    <sequenceFlow id="f4" sourceRef="reserve" targetRef="join"/>
+    <sequenceFlow id="f5" sourceRef="approve" targetRef="join"/>
+    <exclusiveGateway id="join"/>
+    <sequenceFlow id="f6" sourceRef="join" targetRef="wait"/>
+    <intermediateCatchEvent id="wait">
+      <timerEventDefinition><timeDuration>PT1H30M</timeDuration></timerEventDefinition>
+    </intermediateCatchEvent>
+    <sequenceFlow id="f7" sourceRef="wait" targetRef="delivery"/>
+    <subProcess id="delivery">
+      <startEvent id="deliveryStart"/>
+      <sequenceFlow id="f8" sourceRef="deliveryStart" targetRef="ship"/>
+      <serviceTask id="ship" pp:category="nats" pp:action="delivery.ship"/>
+      <serviceTask id="notify" pp:category="external" pp:action="notification"/>
+    </subProcess>
+    <sequenceFlow id="f9" sourceRef="delivery" targetRef="close"/>
+    <serviceTask id="close" name="Close" pp:action="http://order/close"/>
+    <sequenceFlow id="f10" sourceRef="close" targetRef="end"/>
+    <endEvent id="end"/>
+  </process>
+</definitions>`
+
+	unsupportedBpmn = `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:pp="urn:pp-gin:bpmn" id="defs">
+  <process id="order">
+    <startEvent id="start"><messageEventDefinition/></startEvent>
+    <sequenceFlow id="f1" sourceRef="start" targetRef="choice"/>
+    <exclusiveGateway id="choice"/>
+    <sequenceFlow id="f2" sourceRef="choice" targetRef="script">
+      <conditionExpression>${approved}</conditionExpression>
+    </sequenceFlow>
+    <sequenceFlow id="f3" sourceRef="choice" targetRef="task"/>
+    <scriptTask id="script"/>
+    <serviceTask id="task"/>
+    <sequenceFlow id="f4" sourceRef="task" targetRef="absent"/>
+  </process>
+</definitions>`
+)
+
+func TestTranslate(t *testing.T) {
+	assert := assert.New(t)
+
+	def, err := Translate([]byte(orderBpmn))
+	assert.Nil(err)
+	assert.Equal(&domain.ProcessDefinition{
+		Name:     "Order",
+		Mappings: map[string]domain.Body{emptyMapping: {}},
+		Tasks: []domain.TaskDefinition{
+			{Name: "Reserve", Category: "http", Action: "http://stock/reserve", Sync: true,
+				Http: &domain.HttpTaskConfig{
+					Method:  "PUT",
+					Headers: map[string]string{"Authorization": "Bearer {{$.auth.token}}"},
+				},
+				MappingBody: domain.Body{"productId": "$.product.id"}},
+			{Name: "Approve", Category: "external", Action: defaultUserTaskTopic, Mapping: emptyMapping},
+			{Name: "wait", Category: "timer", Action: "1h30m0s", Mapping: emptyMapping,
+				DependsOn: []string{"Approve", "Reserve"}},
+			{Name: "ship", Category: "nats", Action: "delivery.ship", Mapping: emptyMapping,
+				DependsOn: []string{"wait"}},
+			{Name: "notify", Category: "external", Action: "notification", Mapping: emptyMapping,
+				DependsOn: []string{"wait"}},
+			{Name: "Close", Category: "http", Action: "http://order/close", Mapping: emptyMapping,
+				DependsOn: []string{"notify", "ship"}},
+		},
+	}, def)
+}
+
+func TestTranslate_Unsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Translate([]byte(unsupportedBpmn))
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Equal(Diagnostics{
+		{Line: 4, Element: "startEvent", Id: "start", Msg: "messageEventDefinition isn't supported"},
+		{Line: 7, Element: "sequenceFlow", Id: "f2", Msg: "conditional flows aren't supported"},
+		{Line: 11, Element: "scriptTask", Id: "script", Msg: "element isn't supported"},
+		{Line: 12, Element: "serviceTask", Id: "task", Msg: "action is required"},
+		{Line: 13, Element: "sequenceFlow", Id: "f4", Msg: "unknown source or target (task, absent)"},
+		{Line: 6, Element: "exclusiveGateway", Id: "choice",
+			Msg: "diverging exclusive gateways aren't supported, use a parallel gateway"},
+	}, err.(*domain.Error).Err)
+}
+
+func TestTranslate_Loop(t *testing.T) {
+	assert := assert.New(t)
+
+	const loopBpmn = `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:pp="urn:pp-gin:bpmn">
+  <process id="loop">
+    <serviceTask id="first" pp:action="http://first"/>
+    <sequenceFlow id="f1" sourceRef="first" targetRef="merge"/>
+    <exclusiveGateway id="merge"/>
+    <sequenceFlow id="f2" sourceRef="merge" targetRef="fork"/>
+    <parallelGateway id="fork"/>
+    <sequenceFlow id="f3" sourceRef="fork" targetRef="merge"/>
+    <sequenceFlow id="f4" sourceRef="fork" targetRef="second"/>
+    <serviceTask id="second" pp:action="http://second"/>
+  </process>
+</definitions>`
+
+	_, err := Translate([]byte(loopBpmn))
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Equal("Bpmn.Translate|APP-0006|unsupported bpmn, line 7: parallelGateway (fork): loops aren't supported",
+		err.Error())
+}
+
+func TestTranslate_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"xml":         `<definitions`,
+		"root":        `<process xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"/>`,
+		"no process":  `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"/>`,
+		"two process": `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"><process/><process/></definitions>`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Translate([]byte(data))
+			assert.Equal(t, domain.ErrValidation, domain.ECode(err))
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]time.Duration{
+		"PT30S":       30 * time.Second,
+		"PT1.5S":      1500 * time.Millisecond,
+		"PT1H30M":     90 * time.Minute,
+		"P1DT2H":      26 * time.Hour,
+		"P2W":         14 * 24 * time.Hour,
+		"P1DT1H1M1S":  25*time.Hour + time.Minute + time.Second,
+		"PT0S":        0,
+		"P0D":         0,
+		"PT10M0.250S": 10*time.Minute + 250*time.Millisecond,
+	}
+	for value, expected := range tests {
+		duration, err := parseDuration(value)
+		assert.Nil(err, value)
+		assert.Equal(expected, duration, value)
+	}
+	for _, value := range []string{"", "P", "PT", "P1Y", "P1M", "PT1H30", "1h", "P1DT"} {
+		_, err := parseDuration(value)
+		assert.NotNil(err, value)
+	}
+}
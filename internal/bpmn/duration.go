@@ -0,0 +1,33 @@
+package bpmn
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	// ISO 8601 duration without years and months (their length isn't fixed), e.g. P1DT2H or PT1.5S
+	durationRegexp = regexp.MustCompile(`^P(?:(\d+)W|(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?)$`)
+	durationUnits  = []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+)
+
+func parseDuration(value string) (time.Duration, error) {
+	matches := durationRegexp.FindStringSubmatch(value)
+	if matches == nil || value == "P" || value[len(value)-1] == 'T' {
+		return 0, fmt.Errorf("invalid duration (%s), e.g. PT1H30M is expected", value)
+	}
+	var result time.Duration
+	for i, unit := range durationUnits {
+		if matches[i+1] == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration (%s): %w", value, err)
+		}
+		result += time.Duration(number * float64(unit))
+	}
+	return result, nil
+}
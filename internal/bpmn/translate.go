@@ -0,0 +1,361 @@
+package bpmn
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultUserTaskTopic = "user" // Topic of the user tasks which don't have an action
+	emptyMapping         = "empty"
+)
+
+const (
+	nodeTask = iota
+	nodeEvent
+	nodeGateway
+	nodeSubProcess
+)
+
+var (
+	// Elements which don't affect the execution
+	ignoredElements = map[string]bool{
+		"documentation":       true,
+		"extensionElements":   true,
+		"laneSet":             true,
+		"textAnnotation":      true,
+		"association":         true,
+		"group":               true,
+		"dataObject":          true,
+		"dataObjectReference": true,
+		"dataStoreReference":  true,
+		"ioSpecification":     true,
+		"property":            true,
+	}
+)
+
+type node struct {
+	e         *element
+	kind      int
+	exclusive bool   // Exclusive gateway
+	container string // Id of the enclosing sub-process
+	task      *domain.TaskDefinition
+}
+
+type flow struct {
+	e      *element
+	source string
+	target string
+}
+
+type translator struct {
+	nodes       map[string]*node
+	ids         []string // Ids of the nodes in the document order
+	flows       []flow
+	incoming    map[string][]string
+	outgoing    map[string][]string
+	before      map[string]map[string]bool // Names of the tasks completed before the node is entered
+	visiting    map[string]bool
+	diagnostics Diagnostics
+}
+
+func newTranslator() *translator {
+	return &translator{
+		nodes:    make(map[string]*node),
+		incoming: make(map[string][]string),
+		outgoing: make(map[string][]string),
+		before:   make(map[string]map[string]bool),
+		visiting: make(map[string]bool),
+	}
+}
+
+func (t *translator) report(diagnostic Diagnostic) {
+	t.diagnostics = append(t.diagnostics, diagnostic)
+}
+
+// Collect nodes and flows of the process or the sub-process
+func (t *translator) collect(container *element, sub *node) {
+	for _, e := range container.children {
+		// Elements of other namespaces are extensions of the modeling tools
+		if e.name.Space != ModelNamespace || ignoredElements[e.name.Local] {
+			continue
+		}
+		switch e.name.Local {
+		case "sequenceFlow":
+			t.collectFlow(e)
+		case "startEvent", "intermediateCatchEvent", "intermediateThrowEvent", "endEvent":
+			t.collectEvent(e, sub)
+		case "serviceTask":
+			category := e.attr(Namespace, "category")
+			if category == "" {
+				category = domain.TaskCategoryNames[domain.HttpTaskCategory]
+			}
+			action := e.attr(Namespace, "action")
+			if action == "" {
+				t.report(e.diagnostic("action is required"))
+			}
+			t.addNode(e, nodeTask, sub, t.task(e, category, action))
+		case "userTask":
+			action := e.attr(Namespace, "action")
+			if action == "" {
+				action = defaultUserTaskTopic
+			}
+			t.addNode(e, nodeTask, sub, t.task(e, domain.TaskCategoryNames[domain.ExternalTaskCategory], action))
+		case "parallelGateway":
+			t.addNode(e, nodeGateway, sub, nil)
+		case "exclusiveGateway":
+			if n := t.addNode(e, nodeGateway, sub, nil); n != nil {
+				n.exclusive = true
+			}
+		case "subProcess":
+			if e.attr(ModelNamespace, "triggeredByEvent") == "true" {
+				t.report(e.diagnostic("event sub-processes aren't supported"))
+				continue
+			}
+			if n := t.addNode(e, nodeSubProcess, sub, nil); n != nil {
+				t.collect(e, n)
+			}
+		default:
+			// Keep unsupported node to avoid diagnostics of its flows
+			t.report(e.diagnostic("element isn't supported"))
+			t.addNode(e, nodeEvent, sub, nil)
+		}
+	}
+}
+
+func (t *translator) collectFlow(e *element) {
+	if e.child(ModelNamespace, "conditionExpression") != nil {
+		t.report(e.diagnostic("conditional flows aren't supported"))
+	}
+	t.flows = append(t.flows, flow{
+		e:      e,
+		source: e.attr(ModelNamespace, "sourceRef"),
+		target: e.attr(ModelNamespace, "targetRef"),
+	})
+}
+
+// Timer start and intermediate events become timer tasks, other events are only joints of the flows
+func (t *translator) collectEvent(e *element, sub *node) {
+	var definitions []*element
+	for _, child := range e.children {
+		if child.name.Space == ModelNamespace && strings.HasSuffix(child.name.Local, "EventDefinition") {
+			definitions = append(definitions, child)
+		}
+	}
+	switch {
+	case len(definitions) == 0:
+	case len(definitions) > 1:
+		t.report(e.diagnostic("multiple event definitions aren't supported"))
+	case definitions[0].name.Local != "timerEventDefinition":
+		t.report(e.diagnostic("%s isn't supported", definitions[0].name.Local))
+	case e.name.Local != "startEvent" && e.name.Local != "intermediateCatchEvent":
+		t.report(e.diagnostic("timer has to be a start or an intermediate catch event"))
+	default:
+		t.addNode(e, nodeTask, sub, t.timer(e, definitions[0]))
+		return
+	}
+	t.addNode(e, nodeEvent, sub, nil)
+}
+
+func (t *translator) addNode(e *element, kind int, sub *node, task *domain.TaskDefinition) *node {
+	id := e.attr(ModelNamespace, "id")
+	if id == "" {
+		t.report(e.diagnostic("id is required"))
+		return nil
+	}
+	if _, ok := t.nodes[id]; ok {
+		t.report(e.diagnostic("duplicate id"))
+		return nil
+	}
+	n := &node{e: e, kind: kind, task: task}
+	if sub != nil {
+		n.container = sub.e.attr(ModelNamespace, "id")
+	}
+	t.nodes[id] = n
+	t.ids = append(t.ids, id)
+	return n
+}
+
+func (t *translator) task(e *element, category, action string) *domain.TaskDefinition {
+	result := &domain.TaskDefinition{Name: taskName(e), Category: category, Action: action}
+	if value := e.attr(Namespace, "sync"); value != "" {
+		sync, err := strconv.ParseBool(value)
+		if err != nil {
+			t.report(e.diagnostic("invalid sync (%s)", value))
+		}
+		result.Sync = sync
+	}
+	if method := e.attr(Namespace, "method"); method != "" {
+		result.Http = &domain.HttpTaskConfig{Method: method}
+	}
+	for _, child := range e.children {
+		if child.name.Space == ModelNamespace && strings.HasSuffix(child.name.Local, "LoopCharacteristics") {
+			t.report(child.diagnostic("loops aren't supported"))
+		}
+	}
+	if extensions := e.child(ModelNamespace, "extensionElements"); extensions != nil {
+		for _, child := range extensions.children {
+			if child.name.Space != Namespace {
+				continue
+			}
+			name, value := child.attr(Namespace, "name"), child.attr(Namespace, "value")
+			if name == "" {
+				t.report(child.diagnostic("name is required"))
+				continue
+			}
+			switch child.name.Local {
+			case "input":
+				if result.MappingBody == nil {
+					result.MappingBody = make(domain.Body)
+				}
+				result.MappingBody[name] = value
+			case "header", "query":
+				if result.Http == nil {
+					result.Http = &domain.HttpTaskConfig{}
+				}
+				if child.name.Local == "header" {
+					if result.Http.Headers == nil {
+						result.Http.Headers = make(map[string]string)
+					}
+					result.Http.Headers[name] = value
+				} else {
+					if result.Http.Query == nil {
+						result.Http.Query = make(map[string]string)
+					}
+					result.Http.Query[name] = value
+				}
+			default:
+				t.report(child.diagnostic("extension isn't supported"))
+			}
+		}
+	}
+	if result.MappingBody == nil {
+		result.Mapping = emptyMapping
+	}
+	return result
+}
+
+func (t *translator) timer(e, definition *element) *domain.TaskDefinition {
+	duration := definition.child(ModelNamespace, "timeDuration")
+	if duration == nil {
+		t.report(e.diagnostic("only timer durations are supported"))
+		return nil
+	}
+	delay, err := parseDuration(strings.TrimSpace(duration.text))
+	if err != nil {
+		t.report(duration.diagnostic("%v", err))
+		return nil
+	}
+	return &domain.TaskDefinition{
+		Name:     taskName(e),
+		Category: domain.TaskCategoryNames[domain.TimerTaskCategory],
+		Action:   delay.String(),
+		Mapping:  emptyMapping,
+	}
+}
+
+// Build definition from the collected nodes, the tasks depend on the nearest preceding tasks
+func (t *translator) definition(process *element) *domain.ProcessDefinition {
+	for _, f := range t.flows {
+		_, sourceOk := t.nodes[f.source]
+		_, targetOk := t.nodes[f.target]
+		if !sourceOk || !targetOk {
+			t.report(f.e.diagnostic("unknown source or target (%s, %s)", f.source, f.target))
+			continue
+		}
+		t.incoming[f.target] = append(t.incoming[f.target], f.source)
+		t.outgoing[f.source] = append(t.outgoing[f.source], f.target)
+	}
+
+	result := &domain.ProcessDefinition{Name: taskName(process)}
+	names := make(map[string]bool)
+	for _, id := range t.ids {
+		n := t.nodes[id]
+		if n.exclusive && len(t.outgoing[id]) > 1 {
+			t.report(n.e.diagnostic("diverging exclusive gateways aren't supported, use a parallel gateway"))
+		}
+		if n.task == nil {
+			continue
+		}
+		if names[n.task.Name] {
+			t.report(n.e.diagnostic("duplicate task name (%s)", n.task.Name))
+		}
+		names[n.task.Name] = true
+		if n.task.Mapping == emptyMapping {
+			result.Mappings = map[string]domain.Body{emptyMapping: {}}
+		}
+		for name := range t.tasksBefore(id) {
+			n.task.DependsOn = append(n.task.DependsOn, name)
+		}
+		sort.Strings(n.task.DependsOn)
+		result.Tasks = append(result.Tasks, *n.task)
+	}
+	return result
+}
+
+// Names of the tasks completed before the node is entered, nodes without incoming flows of the sub-process
+// are entered along with the sub-process
+func (t *translator) tasksBefore(id string) map[string]bool {
+	if result, ok := t.before[id]; ok {
+		return result
+	}
+	if t.visiting[id] {
+		t.report(t.nodes[id].e.diagnostic("loops aren't supported"))
+		return nil
+	}
+	t.visiting[id] = true
+	defer delete(t.visiting, id)
+
+	result := make(map[string]bool)
+	n := t.nodes[id]
+	sources := t.incoming[id]
+	if len(sources) == 0 && n.container != "" {
+		for name := range t.tasksBefore(n.container) {
+			result[name] = true
+		}
+	}
+	for _, source := range sources {
+		for name := range t.tasksAfter(source) {
+			result[name] = true
+		}
+	}
+	t.before[id] = result
+	return result
+}
+
+// Names of the tasks completed when the node is left, sub-process is left when all nodes without outgoing flows
+// are left
+func (t *translator) tasksAfter(id string) map[string]bool {
+	n := t.nodes[id]
+	switch {
+	case n.task != nil:
+		return map[string]bool{n.task.Name: true}
+	case n.kind == nodeSubProcess:
+		var exits []string
+		for _, childId := range t.ids {
+			if t.nodes[childId].container == id && len(t.outgoing[childId]) == 0 {
+				exits = append(exits, childId)
+			}
+		}
+		if len(exits) == 0 {
+			return t.tasksBefore(id)
+		}
+		result := make(map[string]bool)
+		for _, exit := range exits {
+			for name := range t.tasksAfter(exit) {
+				result[name] = true
+			}
+		}
+		return result
+	}
+	return t.tasksBefore(id)
+}
+
+func taskName(e *element) string {
+	if name := strings.TrimSpace(e.attr(ModelNamespace, "name")); name != "" {
+		return name
+	}
+	return e.attr(ModelNamespace, "id")
+}
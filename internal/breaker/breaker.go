@@ -0,0 +1,159 @@
+package breaker
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+const (
+	// Delay of the requests while half-open breaker waits for the probe
+	probeDelay = time.Second
+
+	defaultFailureThreshold  = 5
+	defaultHalfOpenSuccesses = 1
+)
+
+// Apply defaults to the thresholds which aren't set, otherwise the first failure would open the breaker
+// and the first probe would close it
+func withDefaults(cfg domain.BreakerConfig) domain.BreakerConfig {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.HalfOpenSuccesses <= 0 {
+		cfg.HalfOpenSuccesses = defaultHalfOpenSuccesses
+	}
+	return cfg
+}
+
+// Circuit breaker and token bucket of the destination host
+type breaker struct {
+	mu        sync.Mutex
+	cfg       domain.BreakerConfig
+	host      string
+	state     string
+	failures  int
+	successes int
+	probes    int
+	openedAt  time.Time
+	limiter   *rate.Limiter
+}
+
+func newBreaker(cfg domain.BreakerConfig, host string) *breaker {
+	limit := cfg.RateLimit
+	if hostLimit, ok := cfg.Hosts[host]; ok {
+		limit = hostLimit
+	}
+	var limiter *rate.Limiter
+	if limit.Rate > 0 {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+	}
+	return &breaker{cfg: cfg, host: host, state: domain.BreakerClosed, limiter: limiter}
+}
+
+// Check whether request is allowed, otherwise returns delay after which it could be repeated.
+// Open breaker becomes half-open after the timeout and lets through a single probe request at a time
+func (b *breaker) allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case domain.BreakerOpen:
+		if openUntil := b.openedAt.Add(b.cfg.OpenTimeoutSec * time.Second); now.Before(openUntil) {
+			return false, openUntil.Sub(now)
+		}
+		b.state = domain.BreakerHalfOpen
+		b.successes = 0
+		b.probes = 0
+		fallthrough
+	case domain.BreakerHalfOpen:
+		if b.probes > 0 {
+			return false, probeDelay
+		}
+		b.probes++
+	}
+	return true, 0
+}
+
+// Take token from the bucket, otherwise returns delay after which it'll be available
+func (b *breaker) reserve(now time.Time) time.Duration {
+	if b.limiter == nil {
+		return 0
+	}
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// Release probe of the request which hasn't been sent
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == domain.BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *breaker) done(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case domain.BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open(now)
+		}
+	case domain.BreakerHalfOpen:
+		if b.probes > 0 {
+			b.probes--
+		}
+		if failed {
+			b.failures++
+			b.open(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenSuccesses {
+			b.reset()
+		}
+	}
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = domain.BreakerOpen
+	b.openedAt = now
+}
+
+func (b *breaker) reset() {
+	b.state = domain.BreakerClosed
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+}
+
+func (b *breaker) toDomain(result *domain.Breaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result.Host = b.host
+	result.State = b.state
+	result.Failures = b.failures
+	if b.state != domain.BreakerClosed {
+		openedAt := b.openedAt
+		result.OpenedAt = &openedAt
+	}
+}
@@ -0,0 +1,144 @@
+package breaker
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	reasonOpen      = "open"
+	reasonRateLimit = "rateLimit"
+)
+
+var (
+	breakerStates = map[string]float64{
+		domain.BreakerClosed:   0,
+		domain.BreakerHalfOpen: 1,
+		domain.BreakerOpen:     2,
+	}
+	stateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pp_breaker_state",
+		Help: "Circuit breaker state of the task destination (0 - closed, 1 - half-open, 2 - open)",
+	}, []string{"host"})
+	deferredCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pp_breaker_deferred_total",
+		Help: "Count of deferred job starts",
+	}, []string{"host", "reason"})
+)
+
+// JobStartClient with circuit breaker and rate limit per destination host,
+// start of the job is deferred (ErrDeferred) if breaker is open or rate limit is exceeded
+type BreakerJobStartClient struct {
+	cfg      domain.BreakerConfig
+	client   domain.JobStartClient
+	mu       *sync.Mutex
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+func NewBreakerJobStartClient(cfg domain.BreakerConfig, client domain.JobStartClient) *BreakerJobStartClient {
+	return &BreakerJobStartClient{
+		cfg:      withDefaults(cfg),
+		client:   client,
+		mu:       &sync.Mutex{},
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+func (c BreakerJobStartClient) Start(ctx context.Context, dest string, msg *domain.JobStartMessage,
+	opts *domain.JobStartOptions) (domain.Body, error) {
+
+	const op = "BreakerJobStartClient.Start"
+
+	host := destHost(dest)
+	b := c.breaker(host)
+	if ok, delay := b.allow(c.now()); !ok {
+		c.updateState(b)
+		deferredCounter.WithLabelValues(host, reasonOpen).Inc()
+		return nil, domain.E(op, domain.ErrDeferred, fmt.Sprintf("circuit breaker is open (%s)", host),
+			&domain.DeferredError{Delay: delay})
+	}
+	if delay := b.reserve(c.now()); delay > 0 {
+		b.release()
+		deferredCounter.WithLabelValues(host, reasonRateLimit).Inc()
+		return nil, domain.E(op, domain.ErrDeferred, fmt.Sprintf("rate limit is exceeded (%s)", host),
+			&domain.DeferredError{Delay: delay})
+	}
+
+	output, err := c.client.Start(ctx, dest, msg, opts)
+	b.done(isFailure(err), c.now())
+	c.updateState(b)
+	return output, err
+}
+
+func (c BreakerJobStartClient) GetAll(_ context.Context, result *[]domain.Breaker) error {
+	c.mu.Lock()
+	breakers := make([]*breaker, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		breakers = append(breakers, b)
+	}
+	c.mu.Unlock()
+
+	*result = make([]domain.Breaker, len(breakers))
+	for i, b := range breakers {
+		b.toDomain(&(*result)[i])
+	}
+	sort.Slice(*result, func(i, j int) bool {
+		return (*result)[i].Host < (*result)[j].Host
+	})
+	return nil
+}
+
+func (c BreakerJobStartClient) Reset(_ context.Context, host string) error {
+	const op = "BreakerJobStartClient.Reset"
+
+	c.mu.Lock()
+	b, ok := c.breakers[host]
+	c.mu.Unlock()
+	if !ok {
+		return domain.E(op, domain.ErrNotFound)
+	}
+	b.mu.Lock()
+	b.reset()
+	b.mu.Unlock()
+	c.updateState(b)
+	return nil
+}
+
+func (c BreakerJobStartClient) breaker(host string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = newBreaker(c.cfg, host)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+func (c BreakerJobStartClient) updateState(b *breaker) {
+	var state domain.Breaker
+	b.toDomain(&state)
+	stateGauge.WithLabelValues(state.Host).Set(breakerStates[state.State])
+}
+
+// Permanent errors (e.g. 4xx) mean the destination is available
+func isFailure(err error) bool {
+	return err != nil && domain.ECode(err) != domain.ErrRemotePermanent
+}
+
+func destHost(dest string) string {
+	if destUrl, err := url.Parse(dest); err == nil && destUrl.Host != "" {
+		return destUrl.Host
+	}
+	return dest
+}
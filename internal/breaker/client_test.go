@@ -0,0 +1,152 @@
+package breaker
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testDest = "http://localhost:8082/job"
+
+type testJobStartClient struct {
+	err   error
+	calls int
+}
+
+func (c *testJobStartClient) Start(_ context.Context, _ string, _ *domain.JobStartMessage,
+	_ *domain.JobStartOptions) (domain.Body, error) {
+
+	c.calls++
+	return nil, c.err
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func testBreakerClient(cfg domain.BreakerConfig) (*BreakerJobStartClient, *testJobStartClient, *testClock) {
+	startClient := &testJobStartClient{}
+	clock := &testClock{now: time.Now()}
+	client := NewBreakerJobStartClient(cfg, startClient)
+	client.now = clock.Now
+	return client, startClient, clock
+}
+
+func testBreakerState(t *testing.T, client *BreakerJobStartClient) string {
+	var breakers []domain.Breaker
+	assert.Nil(t, client.GetAll(context.Background(), &breakers))
+	assert.Len(t, breakers, 1)
+	assert.Equal(t, "localhost:8082", breakers[0].Host)
+	return breakers[0].State
+}
+
+func TestBreakerJobStartClient_Start_Open(t *testing.T) {
+	assert := assert.New(t)
+
+	client, startClient, clock := testBreakerClient(domain.BreakerConfig{
+		FailureThreshold: 2, OpenTimeoutSec: 10, HalfOpenSuccesses: 1,
+	})
+	startClient.err = domain.E("Test", domain.ErrRemoteRetryable)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+		assert.Equal(domain.ErrRemoteRetryable, domain.ECode(err))
+	}
+	assert.Equal(domain.BreakerOpen, testBreakerState(t, client))
+
+	clock.now = clock.now.Add(4 * time.Second)
+	_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.ErrDeferred, domain.ECode(err))
+	assert.Equal(6*time.Second, domain.EDeferred(err).Delay)
+	assert.Equal(2, startClient.calls)
+}
+
+func TestBreakerJobStartClient_Start_Defaults(t *testing.T) {
+	assert := assert.New(t)
+
+	// Thresholds which aren't set are replaced by the defaults, so the first failure doesn't open the breaker
+	client, startClient, _ := testBreakerClient(domain.BreakerConfig{OpenTimeoutSec: 10})
+	startClient.err = domain.E("Test", domain.ErrRemoteRetryable)
+
+	for i := 0; i < defaultFailureThreshold-1; i++ {
+		_, _ = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	}
+	assert.Equal(domain.BreakerClosed, testBreakerState(t, client))
+	_, _ = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.BreakerOpen, testBreakerState(t, client))
+}
+
+func TestBreakerJobStartClient_Start_PermanentError(t *testing.T) {
+	assert := assert.New(t)
+
+	client, startClient, _ := testBreakerClient(domain.BreakerConfig{FailureThreshold: 1})
+	startClient.err = domain.E("Test", domain.ErrRemotePermanent)
+
+	_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.ErrRemotePermanent, domain.ECode(err))
+	assert.Equal(domain.BreakerClosed, testBreakerState(t, client))
+}
+
+func TestBreakerJobStartClient_Start_HalfOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	client, startClient, clock := testBreakerClient(domain.BreakerConfig{
+		FailureThreshold: 1, OpenTimeoutSec: 10, HalfOpenSuccesses: 1,
+	})
+	startClient.err = domain.E("Test", domain.ErrRemoteRetryable)
+	_, _ = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.BreakerOpen, testBreakerState(t, client))
+
+	// Failed probe opens breaker again
+	clock.now = clock.now.Add(10 * time.Second)
+	_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.ErrRemoteRetryable, domain.ECode(err))
+	assert.Equal(domain.BreakerOpen, testBreakerState(t, client))
+
+	// Successful probe closes breaker
+	clock.now = clock.now.Add(10 * time.Second)
+	startClient.err = nil
+	_, err = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Nil(err)
+	assert.Equal(domain.BreakerClosed, testBreakerState(t, client))
+	assert.Equal(3, startClient.calls)
+}
+
+func TestBreakerJobStartClient_Start_RateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	client, startClient, clock := testBreakerClient(domain.BreakerConfig{
+		Hosts: map[string]domain.RateLimitConfig{"localhost:8082": {Rate: 1, Burst: 2}},
+	})
+	for i := 0; i < 2; i++ {
+		_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+		assert.Nil(err)
+	}
+	_, err := client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.ErrDeferred, domain.ECode(err))
+	assert.True(domain.EDeferred(err).Delay > 0)
+
+	clock.now = clock.now.Add(time.Second)
+	_, err = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Nil(err)
+	assert.Equal(3, startClient.calls)
+}
+
+func TestBreakerJobStartClient_Reset(t *testing.T) {
+	assert := assert.New(t)
+
+	client, startClient, _ := testBreakerClient(domain.BreakerConfig{FailureThreshold: 1, OpenTimeoutSec: 10})
+	startClient.err = domain.E("Test", domain.ErrRemoteRetryable)
+	_, _ = client.Start(context.Background(), testDest, &domain.JobStartMessage{}, nil)
+	assert.Equal(domain.BreakerOpen, testBreakerState(t, client))
+
+	assert.Nil(client.Reset(context.Background(), "localhost:8082"))
+	assert.Equal(domain.BreakerClosed, testBreakerState(t, client))
+	assert.Equal(domain.ErrNotFound, domain.ECode(client.Reset(context.Background(), "unknown")))
+}
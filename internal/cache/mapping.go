@@ -27,6 +27,13 @@ func (s CachedReadMappingService) GetAll(ctx context.Context, result *[]domain.R
 	return s.service.GetAll(ctx, result)
 }
 
+func (s CachedReadMappingService) GetPage(ctx context.Context, query *domain.PageQuery,
+	result *[]domain.ReadMapping, page *domain.Page) error {
+
+	// Don't use cache
+	return s.service.GetPage(ctx, query, result, page)
+}
+
 func (s CachedReadMappingService) Create(ctx context.Context, obj *domain.ReadMapping) error {
 	err := s.service.Create(ctx, obj)
 	if err != nil {
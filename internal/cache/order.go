@@ -32,10 +32,32 @@ func (s CachedOrderService) SubmitOrder(ctx context.Context, order *domain.Order
 	return s.service.SubmitOrder(ctx, order, processId)
 }
 
+func (s CachedOrderService) SubmitOrders(ctx context.Context, orders domain.OrderReader, processId string,
+	atomic bool, result *domain.BulkOrderResult) error {
+
+	return s.service.SubmitOrders(ctx, orders, processId, atomic, result)
+}
+
 func (s CachedOrderService) GetOrders(ctx context.Context, result *[]domain.Order) error {
 	return s.service.GetOrders(ctx, result)
 }
 
+func (s CachedOrderService) GetOrdersPage(ctx context.Context, query *domain.OrderQuery, result *[]domain.Order,
+	page *domain.Page) error {
+
+	return s.service.GetOrdersPage(ctx, query, result, page)
+}
+
+func (s CachedOrderService) CountOrdersByProcess(ctx context.Context, result *map[string]int) error {
+	return s.service.CountOrdersByProcess(ctx, result)
+}
+
+func (s CachedOrderService) SearchOrders(ctx context.Context, query *domain.OrderQuery, search *domain.OrderSearch,
+	result *[]domain.Order, page *domain.Page) error {
+
+	return s.service.SearchOrders(ctx, query, search, result, page)
+}
+
 func (s CachedOrderService) GetOrderById(ctx context.Context, id string, result *domain.Order) error {
 	const op = "CachedReadMappingService.GetOrderById"
 
@@ -54,6 +76,18 @@ func (s CachedOrderService) GetOrderById(ctx context.Context, id string, result
 	return nil
 }
 
-func (s CachedOrderService) CompleteJob(ctx context.Context, taskId, orderId string) error {
-	return s.service.CompleteJob(ctx, taskId, orderId)
+func (s CachedOrderService) GetOrderJobs(ctx context.Context, orderId string, result *[]domain.Job) error {
+	return s.service.GetOrderJobs(ctx, orderId, result)
+}
+
+func (s CachedOrderService) CompleteJob(ctx context.Context, msg *domain.JobCompleteMessage) error {
+	return s.service.CompleteJob(ctx, msg)
+}
+
+func (s CachedOrderService) RetryJob(ctx context.Context, ref *domain.JobRef) error {
+	return s.service.RetryJob(ctx, ref)
+}
+
+func (s CachedOrderService) CancelJob(ctx context.Context, ref *domain.JobRef) error {
+	return s.service.CancelJob(ctx, ref)
 }
@@ -27,6 +27,13 @@ func (s CachedProcessService) GetAll(ctx context.Context, result *[]domain.Proce
 	return s.service.GetAll(ctx, result)
 }
 
+func (s CachedProcessService) GetPage(ctx context.Context, query *domain.PageQuery, result *[]domain.Process,
+	page *domain.Page) error {
+
+	// Don't use cache
+	return s.service.GetPage(ctx, query, result, page)
+}
+
 func (s CachedProcessService) Create(ctx context.Context, obj *domain.Process) error {
 	err := s.service.Create(ctx, obj)
 	if err != nil {
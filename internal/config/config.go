@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"fmt"
 	"github.com/kelseyhightower/envconfig"
@@ -29,5 +30,34 @@ func ReadConfig(yamlFileName, envPrefix string) (*domain.ApplicationConfig, erro
 	if err = envconfig.Process(envPrefix, &config); err != nil {
 		return nil, domain.E(op, fmt.Sprintf("can't apply envconfig with prefix: %v", yamlFileName), err)
 	}
+	if err = validateConfig(&config); err != nil {
+		return nil, domain.E(op, fmt.Sprintf("invalid config: %v", yamlFileName), err)
+	}
 	return &config, nil
 }
+
+// Zero values are replaced by the defaults where they're applied, so only negative ones are rejected
+func validateConfig(config *domain.ApplicationConfig) error {
+	const op = "ValidateConfig"
+
+	breaker := config.Breaker
+	if breaker.FailureThreshold < 0 || breaker.OpenTimeoutSec < 0 || breaker.HalfOpenSuccesses < 0 {
+		return domain.E(op, domain.ErrValidation, "breaker thresholds and timeout can't be negative")
+	}
+	if err := validateRateLimit(breaker.RateLimit); err != nil {
+		return domain.E(op, domain.ErrValidation, "invalid breaker rate limit", err)
+	}
+	for host, limit := range breaker.Hosts {
+		if err := validateRateLimit(limit); err != nil {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid breaker rate limit (%s)", host), err)
+		}
+	}
+	return nil
+}
+
+func validateRateLimit(limit domain.RateLimitConfig) error {
+	if limit.Rate < 0 || limit.Burst < 0 {
+		return errors.New("rate and burst can't be negative")
+	}
+	return nil
+}
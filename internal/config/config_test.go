@@ -0,0 +1,33 @@
+package config
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateConfig_Breaker(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		cfg   domain.BreakerConfig
+		valid bool
+	}{
+		{domain.BreakerConfig{}, true},
+		{domain.BreakerConfig{FailureThreshold: 5, OpenTimeoutSec: 30, HalfOpenSuccesses: 1,
+			RateLimit: domain.RateLimitConfig{Rate: 10, Burst: 5}}, true},
+		{domain.BreakerConfig{FailureThreshold: -1}, false},
+		{domain.BreakerConfig{OpenTimeoutSec: -1}, false},
+		{domain.BreakerConfig{HalfOpenSuccesses: -1}, false},
+		{domain.BreakerConfig{RateLimit: domain.RateLimitConfig{Rate: -1}}, false},
+		{domain.BreakerConfig{Hosts: map[string]domain.RateLimitConfig{"localhost": {Burst: -1}}}, false},
+	}
+	for i, test := range tests {
+		err := validateConfig(&domain.ApplicationConfig{Breaker: test.cfg})
+		if test.valid {
+			assert.Nil(err, i)
+		} else {
+			assert.Equal(domain.ErrValidation, domain.ECode(err), i)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+// Package dashboard serves server-rendered pages to browse processes, mappings and orders and to retry, cancel
+// or complete jobs of the orders. Pages are built only on the services, so there's no separate frontend build
+// and the dashboard works offline.
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/internal/graph"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	paramId      = "id"
+	paramTaskId  = "task_id"
+	queryText    = "q"
+	queryProcess = "process"
+	queryStatus  = "status"
+	queryBody    = "body"
+	queryCursor  = "cursor"
+	queryMsg     = "msg"
+	queryError   = "error"
+	formOutput   = "output"
+	formCsrf     = "csrf"
+
+	// Token of the actions is submitted by the forms and has to match the cookie (double-submit cookie)
+	csrfCookie      = "pp_csrf"
+	csrfTokenLength = 32
+
+	// Lists are paged by the services, text filters of the processes and mappings match the rows of the page
+	pageLimit = 50
+)
+
+// Data of the page rendered into the layout
+type page struct {
+	Base    string
+	Title   string
+	Query   string
+	Msg     string
+	Error   string
+	Csrf    string
+	Content interface{}
+}
+
+type processRow struct {
+	Process domain.Process
+	Orders  int
+}
+
+type processesContent struct {
+	Processes []processRow
+	Total     int
+	NextUrl   string
+}
+
+type mappingsContent struct {
+	Mappings []domain.ReadMapping
+	Total    int
+	NextUrl  string
+}
+
+type orderRow struct {
+	Order       domain.Order
+	ProcessName string
+}
+
+type ordersContent struct {
+	ProcessId string
+	Statuses  []string
+	Status    string
+	Body      string
+	Orders    []orderRow
+	Total     int
+	NextUrl   string
+}
+
+type processContent struct {
+	Process  domain.Process
+	Mappings map[string]domain.ReadMapping
+	Graph    template.HTML
+}
+
+type jobRow struct {
+	Job      domain.Job
+	TaskName string
+}
+
+type orderContent struct {
+	Order   domain.Order
+	Process domain.Process
+	Jobs    []jobRow
+	Graph   template.HTML
+}
+
+type Handler struct {
+	baseUrl            string
+	processService     domain.ProcessService
+	readMappingService domain.ReadMappingService
+	orderService       domain.OrderService
+	templates          map[string]*template.Template
+}
+
+func NewHandler(baseUrl string, processService domain.ProcessService, readMappingService domain.ReadMappingService,
+	orderService domain.OrderService) *Handler {
+
+	return &Handler{
+		baseUrl:            strings.TrimSuffix(baseUrl, "/"),
+		processService:     processService,
+		readMappingService: readMappingService,
+		orderService:       orderService,
+		templates:          parseTemplates(),
+	}
+}
+
+func (h Handler) Register(router *gin.Engine) {
+	group := router.Group(h.baseUrl)
+	group.GET("/", h.index)
+	group.GET("/processes", h.getProcesses)
+	group.GET("/processes/:"+paramId, h.getProcess)
+	group.GET("/mappings", h.getMappings)
+	group.GET("/orders", h.getOrders)
+	group.GET("/orders/:"+paramId, h.getOrder)
+
+	actions := group.Group("/orders/:"+paramId+"/jobs/:"+paramTaskId, h.checkCsrf)
+	actions.POST("/retry", h.retryJob)
+	actions.POST("/cancel", h.cancelJob)
+	actions.POST("/complete", h.completeJob)
+}
+
+func (h Handler) index(c *gin.Context) {
+	c.Redirect(http.StatusFound, h.baseUrl+"/orders")
+}
+
+func (h Handler) getProcesses(c *gin.Context) {
+	ctx := c.Request.Context()
+	var processes []domain.Process
+	var result domain.Page
+	query := domain.PageQuery{Limit: pageLimit, Cursor: c.Query(queryCursor)}
+	if err := h.processService.GetPage(ctx, &query, &processes, &result); err != nil {
+		h.error(c, err)
+		return
+	}
+	var counts map[string]int
+	if err := h.orderService.CountOrdersByProcess(ctx, &counts); err != nil {
+		h.error(c, err)
+		return
+	}
+	text := c.Query(queryText)
+	rows := make([]processRow, 0, len(processes))
+	for _, process := range processes {
+		if contains(text, process.Id, process.Name) {
+			rows = append(rows, processRow{Process: process, Orders: counts[process.Id]})
+		}
+	}
+	h.render(c, http.StatusOK, "processes", "Processes", processesContent{
+		Processes: rows,
+		Total:     result.Total,
+		NextUrl:   h.nextUrl("/processes", &result, map[string]string{queryText: text}),
+	})
+}
+
+func (h Handler) getProcess(c *gin.Context) {
+	ctx := c.Request.Context()
+	var process domain.Process
+	if err := h.processService.GetById(ctx, c.Param(paramId), &process); err != nil {
+		h.error(c, err)
+		return
+	}
+	mappings := make(map[string]domain.ReadMapping)
+	for _, task := range process.Tasks {
+		if _, ok := mappings[task.ReadMappingId]; ok {
+			continue
+		}
+		var mapping domain.ReadMapping
+		if err := h.readMappingService.GetById(ctx, task.ReadMappingId, &mapping); err != nil {
+			h.error(c, err)
+			return
+		}
+		mappings[task.ReadMappingId] = mapping
+	}
+	svg, err := renderSvg(&process, nil)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	h.render(c, http.StatusOK, "process", process.Name, processContent{
+		Process:  process,
+		Mappings: mappings,
+		Graph:    svg,
+	})
+}
+
+func (h Handler) getMappings(c *gin.Context) {
+	var mappings []domain.ReadMapping
+	var result domain.Page
+	query := domain.PageQuery{Limit: pageLimit, Cursor: c.Query(queryCursor)}
+	if err := h.readMappingService.GetPage(c.Request.Context(), &query, &mappings, &result); err != nil {
+		h.error(c, err)
+		return
+	}
+	text := c.Query(queryText)
+	rows := make([]domain.ReadMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		body, _ := json.Marshal(mapping.Body)
+		if contains(text, mapping.Id, string(body)) {
+			rows = append(rows, mapping)
+		}
+	}
+	h.render(c, http.StatusOK, "mappings", "Mappings", mappingsContent{
+		Mappings: rows,
+		Total:    result.Total,
+		NextUrl:  h.nextUrl("/mappings", &result, map[string]string{queryText: text}),
+	})
+}
+
+func (h Handler) getOrders(c *gin.Context) {
+	const op = "Dashboard.GetOrders"
+
+	ctx := c.Request.Context()
+	// Orders are filtered and paged by the repo, newest ones go first
+	query := domain.OrderQuery{
+		PageQuery: domain.PageQuery{Limit: pageLimit, Cursor: c.Query(queryCursor), Sort: "-createdAt"},
+		ProcessId: c.Query(queryProcess),
+		Status:    c.Query(queryStatus),
+	}
+	body := strings.TrimSpace(c.Query(queryBody))
+	if body != "" {
+		i := strings.Index(body, "=")
+		if i <= 0 {
+			h.error(c, domain.E(op, domain.ErrValidation, "body filter has to be field=value"))
+			return
+		}
+		query.Body = map[string]string{strings.TrimSpace(body[:i]): strings.TrimSpace(body[i+1:])}
+	}
+	var orders []domain.Order
+	var result domain.Page
+	if err := h.orderService.GetOrdersPage(ctx, &query, &orders, &result); err != nil {
+		h.error(c, err)
+		return
+	}
+	names, err := h.processNames(ctx, orders)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	rows := make([]orderRow, len(orders))
+	for i, order := range orders {
+		rows[i] = orderRow{Order: order, ProcessName: names[order.ProcessId]}
+	}
+	h.render(c, http.StatusOK, "orders", "Orders", ordersContent{
+		ProcessId: query.ProcessId,
+		Statuses:  []string{domain.OrderRunning, domain.OrderCompleted, domain.OrderFailed},
+		Status:    query.Status,
+		Body:      body,
+		Orders:    rows,
+		Total:     result.Total,
+		NextUrl: h.nextUrl("/orders", &result, map[string]string{queryProcess: query.ProcessId,
+			queryStatus: query.Status, queryBody: body}),
+	})
+}
+
+// Names of the processes of the orders (processes are cached by the service), the ones which aren't found
+// are skipped, so the ids are shown instead
+func (h Handler) processNames(ctx context.Context, orders []domain.Order) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, order := range orders {
+		if _, ok := names[order.ProcessId]; ok {
+			continue
+		}
+		var process domain.Process
+		err := h.processService.GetById(ctx, order.ProcessId, &process)
+		if err != nil && domain.ECode(err) != domain.ErrNotFound {
+			return nil, err
+		}
+		names[order.ProcessId] = process.Name
+	}
+	return names, nil
+}
+
+// Url of the next page of the list along with the filters of the list, it's empty on the last page
+func (h Handler) nextUrl(path string, page *domain.Page, filters map[string]string) string {
+	if page.NextCursor == "" {
+		return ""
+	}
+	values := url.Values{queryCursor: {page.NextCursor}}
+	for name, value := range filters {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	return h.baseUrl + path + "?" + values.Encode()
+}
+
+func (h Handler) getOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+	orderId := c.Param(paramId)
+
+	var order domain.Order
+	if err := h.orderService.GetOrderById(ctx, orderId, &order); err != nil {
+		h.error(c, err)
+		return
+	}
+	var process domain.Process
+	if err := h.processService.GetById(ctx, order.ProcessId, &process); err != nil {
+		h.error(c, err)
+		return
+	}
+	var jobs []domain.Job
+	if err := h.orderService.GetOrderJobs(ctx, orderId, &jobs); err != nil {
+		h.error(c, err)
+		return
+	}
+	names := make(map[string]string, len(process.Tasks))
+	for _, task := range process.Tasks {
+		names[task.Id] = task.Name
+	}
+	statuses := make(map[string]string, len(jobs))
+	rows := make([]jobRow, len(jobs))
+	for i, job := range jobs {
+		statuses[job.TaskId] = job.Status
+		rows[i] = jobRow{Job: job, TaskName: names[job.TaskId]}
+	}
+	svg, err := renderSvg(&process, statuses)
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	h.render(c, http.StatusOK, "order", "Order "+order.Id, orderContent{
+		Order:   order,
+		Process: process,
+		Jobs:    rows,
+		Graph:   svg,
+	})
+}
+
+func (h Handler) retryJob(c *gin.Context) {
+	ref := &domain.JobRef{TaskId: c.Param(paramTaskId), OrderId: c.Param(paramId)}
+	h.redirectToOrder(c, "job has been retried", h.orderService.RetryJob(c.Request.Context(), ref))
+}
+
+func (h Handler) cancelJob(c *gin.Context) {
+	ref := &domain.JobRef{TaskId: c.Param(paramTaskId), OrderId: c.Param(paramId)}
+	h.redirectToOrder(c, "job has been cancelled", h.orderService.CancelJob(c.Request.Context(), ref))
+}
+
+func (h Handler) completeJob(c *gin.Context) {
+	const op = "Dashboard.CompleteJob"
+
+	var output domain.Body
+	if value := strings.TrimSpace(c.PostForm(formOutput)); value != "" {
+		if err := json.Unmarshal([]byte(value), &output); err != nil {
+			h.redirectToOrder(c, "", domain.E(op, domain.ErrValidation, "output has to be a json object", err))
+			return
+		}
+	}
+	msg := &domain.JobCompleteMessage{TaskId: c.Param(paramTaskId), OrderId: c.Param(paramId), Body: output}
+	h.redirectToOrder(c, "job has been completed", h.orderService.CompleteJob(c.Request.Context(), msg))
+}
+
+// Actions are accepted only from the pages of the dashboard: the origin (or the referer) of the request, if any,
+// has to be the host of the request and the token of the form has to match the cookie
+func (h Handler) checkCsrf(c *gin.Context) {
+	const op = "Dashboard.CheckCsrf"
+
+	source := c.GetHeader("Origin")
+	if source == "" {
+		source = c.GetHeader("Referer")
+	}
+	if source != "" {
+		if u, err := url.Parse(source); err != nil || u.Host != c.Request.Host {
+			h.forbid(c, domain.E(op, fmt.Sprintf("action from the foreign origin (%s)", source)))
+			return
+		}
+	}
+	cookie, err := c.Cookie(csrfCookie)
+	token := c.PostForm(formCsrf)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(token)) != 1 {
+		h.forbid(c, domain.E(op, "csrf token is missing or invalid"))
+		return
+	}
+	c.Next()
+}
+
+func (h Handler) forbid(c *gin.Context, err error) {
+	log.Error(err)
+	h.render(c, http.StatusForbidden, "error", http.StatusText(http.StatusForbidden), errorMsg(err))
+	c.Abort()
+}
+
+// Token of the actions is kept by the cookie, a new one is issued if there's no cookie yet
+func (h Handler) csrfToken(c *gin.Context) (string, error) {
+	if token, err := c.Cookie(csrfCookie); err == nil && token != "" {
+		return token, nil
+	}
+	data := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(data)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     h.baseUrl + "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// Redirect after the action (post/redirect/get), the result is shown by the order page
+func (h Handler) redirectToOrder(c *gin.Context, msg string, err error) {
+	query := url.Values{}
+	if err != nil {
+		log.Error(err)
+		query.Set(queryError, errorMsg(err))
+	} else {
+		query.Set(queryMsg, msg)
+	}
+	c.Redirect(http.StatusSeeOther, h.baseUrl+"/orders/"+url.PathEscape(c.Param(paramId))+"?"+query.Encode())
+}
+
+func (h Handler) render(c *gin.Context, status int, name, title string, content interface{}) {
+	const op = "Dashboard.Render"
+
+	token, err := h.csrfToken(c)
+	if err != nil {
+		log.Error(domain.E(op, "can't issue csrf token", err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	err = h.templates[name].ExecuteTemplate(&buf, layoutTemplate, page{
+		Base:    h.baseUrl,
+		Title:   title,
+		Query:   c.Query(queryText),
+		Msg:     c.Query(queryMsg),
+		Error:   c.Query(queryError),
+		Csrf:    token,
+		Content: content,
+	})
+	if err != nil {
+		log.Error(domain.E(op, fmt.Sprintf("can't render page (%s)", name), err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func (h Handler) error(c *gin.Context, err error) {
+	log.Error(err)
+	status := http.StatusInternalServerError
+	switch domain.ECode(err) {
+	case domain.ErrNotFound:
+		status = http.StatusNotFound
+	case domain.ErrValidation:
+		status = http.StatusBadRequest
+	}
+	h.render(c, status, "error", http.StatusText(status), errorMsg(err))
+}
+
+func renderSvg(process *domain.Process, statuses map[string]string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := graph.Render(&buf, graph.FormatSvg, process, statuses); err != nil {
+		return "", err
+	}
+	// Labels of the graph are escaped by the renderer
+	return template.HTML(buf.String()), nil
+}
+
+func errorMsg(err error) string {
+	msgs := domain.EMsgs(err)
+	if len(msgs) == 0 {
+		return err.Error()
+	}
+	return strings.Join(msgs, ": ")
+}
+
+// Case-insensitive search of the text in any of the values, empty text matches everything
+func contains(text string, values ...string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return true
+	}
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), text) {
+			return true
+		}
+	}
+	return false
+}
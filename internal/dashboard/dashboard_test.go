@@ -0,0 +1,286 @@
+package dashboard
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var testProcess = domain.Process{
+	Id:   "p1",
+	Name: "<process>",
+	Tasks: []domain.Task{
+		{Id: "t1", Name: "first", Action: "http://first", ReadMappingId: "m1"},
+		{Id: "t2", Name: "second", Category: domain.ExternalTaskCategory, Action: "topic", ReadMappingId: "m1"},
+	},
+	TaskRelations: []domain.TaskRelation{{ParentId: "t1", ChildId: "t2"}},
+}
+
+type testProcessService struct {
+	domain.ProcessService
+}
+
+func (s *testProcessService) GetPage(_ context.Context, _ *domain.PageQuery, result *[]domain.Process,
+	page *domain.Page) error {
+
+	*result = []domain.Process{testProcess, {Id: "p2", Name: "other"}}
+	*page = domain.Page{Total: 3, NextCursor: "next"}
+	return nil
+}
+
+func (s *testProcessService) GetById(_ context.Context, id string, result *domain.Process) error {
+	if id != testProcess.Id {
+		return domain.E("ProcessService.GetById", domain.ErrNotFound)
+	}
+	*result = testProcess
+	return nil
+}
+
+type testReadMappingService struct {
+	domain.ReadMappingService
+}
+
+func (s *testReadMappingService) GetPage(_ context.Context, _ *domain.PageQuery, result *[]domain.ReadMapping,
+	page *domain.Page) error {
+
+	*result = []domain.ReadMapping{{Id: "m1", Body: domain.Body{"id": "$.id"}}, {Id: "m2", Body: domain.Body{}}}
+	*page = domain.Page{Total: 2}
+	return nil
+}
+
+func (s *testReadMappingService) GetById(_ context.Context, id string, result *domain.ReadMapping) error {
+	*result = domain.ReadMapping{Id: id, Body: domain.Body{"id": "$.id"}}
+	return nil
+}
+
+type testOrderService struct {
+	domain.OrderService
+	queries   []domain.OrderQuery
+	retried   []domain.JobRef
+	completed []domain.JobCompleteMessage
+}
+
+var testOrders = []domain.Order{
+	{Id: "o1", ProcessId: "p1", Body: domain.Body{"customer": "alice"}},
+	{Id: "o2", ProcessId: "p2", Body: domain.Body{"customer": "bob"}},
+}
+
+func (s *testOrderService) GetOrdersPage(_ context.Context, query *domain.OrderQuery, result *[]domain.Order,
+	page *domain.Page) error {
+
+	s.queries = append(s.queries, *query)
+	*result = nil
+	for _, order := range testOrders {
+		if query.ProcessId != "" && order.ProcessId != query.ProcessId {
+			continue
+		}
+		if value, ok := query.Body["customer"]; ok && order.Body["customer"] != value {
+			continue
+		}
+		*result = append(*result, order)
+	}
+	*page = domain.Page{Total: len(*result), NextCursor: "next"}
+	return nil
+}
+
+func (s *testOrderService) CountOrdersByProcess(_ context.Context, result *map[string]int) error {
+	*result = map[string]int{"p2": 7}
+	return nil
+}
+
+func (s *testOrderService) GetOrderById(_ context.Context, id string, result *domain.Order) error {
+	if id != "o1" {
+		return domain.E("OrderService.GetOrderById", domain.ErrNotFound)
+	}
+	*result = domain.Order{Id: id, ProcessId: "p1"}
+	return nil
+}
+
+func (s *testOrderService) GetOrderJobs(_ context.Context, orderId string, result *[]domain.Job) error {
+	*result = []domain.Job{
+		{TaskId: "t1", OrderId: orderId, Status: domain.JobFailed, Attempt: 3, Response: "<error>"},
+		{TaskId: "t2", OrderId: orderId, Status: domain.JobWaiting},
+	}
+	return nil
+}
+
+func (s *testOrderService) RetryJob(_ context.Context, ref *domain.JobRef) error {
+	s.retried = append(s.retried, *ref)
+	return nil
+}
+
+func (s *testOrderService) CompleteJob(_ context.Context, msg *domain.JobCompleteMessage) error {
+	s.completed = append(s.completed, *msg)
+	return nil
+}
+
+func testRouter(orderService domain.OrderService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewHandler("/ui/", &testProcessService{}, &testReadMappingService{}, orderService).Register(router)
+	return router
+}
+
+func get(router *gin.Engine, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+const testCsrfToken = "token"
+
+// Form of the action is posted along with the csrf token (the cookie and the form value)
+func post(router *gin.Engine, target string, form url.Values) *httptest.ResponseRecorder {
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set(formCsrf, testCsrfToken)
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCsrfToken})
+	return postRequest(router, r)
+}
+
+func postRequest(router *gin.Engine, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler_Lists(t *testing.T) {
+	assert := assert.New(t)
+	orderService := &testOrderService{}
+	router := testRouter(orderService)
+
+	w := get(router, "/ui/")
+	assert.Equal(http.StatusFound, w.Code)
+	assert.Equal("/ui/orders", w.Header().Get("Location"))
+
+	w = get(router, "/ui/processes?q=OTH")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `href="/ui/processes/p2">other</a>`)
+	assert.NotContains(w.Body.String(), "/ui/processes/p1")
+	assert.Contains(w.Body.String(), `<a href="/ui/orders?process=p2">7</a>`)
+	assert.Contains(w.Body.String(), "Total: 3")
+	assert.Contains(w.Body.String(), `href="/ui/processes?cursor=next&amp;q=OTH"`)
+
+	w = get(router, "/ui/mappings?q=id")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), "<td>m1</td>")
+	assert.NotContains(w.Body.String(), "<td>m2</td>")
+	assert.NotContains(w.Body.String(), ">Next</a>")
+
+	w = get(router, "/ui/orders?process=p1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `href="/ui/orders/o1"`)
+	assert.NotContains(w.Body.String(), `href="/ui/orders/o2"`)
+	assert.Contains(w.Body.String(), `<input name="process" value="p1"`)
+	assert.Contains(w.Body.String(), `href="/ui/processes/p1">&lt;process&gt;</a>`)
+	assert.Contains(w.Body.String(), "Total: 1")
+
+	w = get(router, "/ui/orders?status=failed&body=customer%3Dbob")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `href="/ui/orders/o2"`)
+	assert.NotContains(w.Body.String(), `href="/ui/orders/o1"`)
+	assert.Contains(w.Body.String(), `<option value="failed" selected>failed</option>`)
+	assert.Contains(w.Body.String(), `href="/ui/orders?body=customer%3Dbob&amp;cursor=next&amp;status=failed"`)
+	// Process p2 isn't found, so its id is shown
+	assert.Contains(w.Body.String(), `href="/ui/processes/p2">p2</a>`)
+	assert.Equal(domain.OrderQuery{
+		PageQuery: domain.PageQuery{Limit: pageLimit, Sort: "-createdAt"},
+		Status:    domain.OrderFailed,
+		Body:      map[string]string{"customer": "bob"},
+	}, orderService.queries[1])
+
+	w = get(router, "/ui/orders?body=bob")
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_Details(t *testing.T) {
+	assert := assert.New(t)
+	router := testRouter(&testOrderService{})
+
+	w := get(router, "/ui/processes/p1")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), "<svg")
+	assert.Contains(w.Body.String(), "<td>external</td>")
+
+	w = get(router, "/ui/orders/o1")
+	assert.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(body, "<svg")
+	assert.Contains(body, "&lt;error&gt;")
+	assert.Contains(body, `<td class="status-failed">failed</td>`)
+	assert.Contains(body, `action="/ui/orders/o1/jobs/t1/retry"`)
+	assert.NotContains(body, `action="/ui/orders/o1/jobs/t2/retry"`)
+	assert.Contains(body, `action="/ui/orders/o1/jobs/t2/complete"`)
+	cookies := w.Result().Cookies()
+	if assert.Len(cookies, 1) {
+		assert.Equal(csrfCookie, cookies[0].Name)
+		assert.Equal(http.SameSiteStrictMode, cookies[0].SameSite)
+		assert.Contains(body, `<input type="hidden" name="csrf" value="`+cookies[0].Value+`">`)
+	}
+
+	w = get(router, "/ui/orders/o2")
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Actions(t *testing.T) {
+	assert := assert.New(t)
+	orderService := &testOrderService{}
+	router := testRouter(orderService)
+
+	w := post(router, "/ui/orders/o1/jobs/t1/retry", nil)
+	assert.Equal(http.StatusSeeOther, w.Code)
+	assert.Equal("/ui/orders/o1?msg=job+has+been+retried", w.Header().Get("Location"))
+	assert.Equal([]domain.JobRef{{TaskId: "t1", OrderId: "o1"}}, orderService.retried)
+
+	w = post(router, "/ui/orders/o1/jobs/t2/complete", url.Values{formOutput: {`{"id": "1"}`}})
+	assert.Equal(http.StatusSeeOther, w.Code)
+	assert.Equal([]domain.JobCompleteMessage{{TaskId: "t2", OrderId: "o1", Body: domain.Body{"id": "1"}}},
+		orderService.completed)
+
+	w = post(router, "/ui/orders/o1/jobs/t2/complete", url.Values{formOutput: {"[1]"}})
+	assert.Equal(http.StatusSeeOther, w.Code)
+	assert.Contains(w.Header().Get("Location"), "error=output+has+to+be+a+json+object")
+	assert.Len(orderService.completed, 1)
+}
+
+func TestHandler_Actions_Csrf(t *testing.T) {
+	assert := assert.New(t)
+	orderService := &testOrderService{}
+	router := testRouter(orderService)
+	target := "/ui/orders/o1/jobs/t1/retry"
+
+	// No token
+	w := postRequest(router, httptest.NewRequest(http.MethodPost, target, nil))
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	// Token of the form doesn't match the cookie
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{formCsrf: {"other"}}.Encode()))
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCsrfToken})
+	w = postRequest(router, r)
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	// Foreign origin
+	r = httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{formCsrf: {testCsrfToken}}.Encode()))
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCsrfToken})
+	r.Header.Set("Origin", "http://evil.example.com")
+	w = postRequest(router, r)
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Empty(orderService.retried)
+
+	// Same origin
+	r = httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{formCsrf: {testCsrfToken}}.Encode()))
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCsrfToken})
+	r.Header.Set("Referer", "http://"+r.Host+"/ui/orders/o1")
+	w = postRequest(router, r)
+	assert.Equal(http.StatusSeeOther, w.Code)
+	assert.Len(orderService.retried, 1)
+}
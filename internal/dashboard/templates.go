@@ -0,0 +1,248 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+const layoutTemplate = "layout"
+
+// Pages are rendered into the layout by the "content" template
+var pages = map[string]string{
+	"processes": processesPage,
+	"process":   processPage,
+	"mappings":  mappingsPage,
+	"orders":    ordersPage,
+	"order":     orderPage,
+	"error":     errorPage,
+}
+
+var funcs = template.FuncMap{
+	"category": func(category int) string {
+		if name, ok := domain.TaskCategoryNames[category]; ok {
+			return name
+		}
+		return fmt.Sprint(category)
+	},
+	"json": func(value interface{}) string {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	},
+	"time": func(value *time.Time) string {
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	},
+	"parents": func(process domain.Process, taskId string) []string {
+		names := make(map[string]string, len(process.Tasks))
+		for _, task := range process.Tasks {
+			names[task.Id] = task.Name
+		}
+		var result []string
+		for _, rel := range process.TaskRelations {
+			if rel.ChildId == taskId {
+				result = append(result, names[rel.ParentId])
+			}
+		}
+		return result
+	},
+	"canRetry": func(status string) bool {
+		return status == domain.JobFailed || status == domain.JobCancelled
+	},
+	"canCancel": func(status string) bool {
+		return status != domain.JobCompleted && status != domain.JobCancelled
+	},
+	"canComplete": func(status string) bool {
+		return status != domain.JobCompleted
+	},
+}
+
+func parseTemplates() map[string]*template.Template {
+	result := make(map[string]*template.Template, len(pages))
+	for name, content := range pages {
+		t := template.Must(template.New(name).Funcs(funcs).Parse(layout))
+		result[name] = template.Must(t.Parse(content))
+	}
+	return result
+}
+
+const layout = `{{define "layout"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - pp-gin</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 0; color: #212121; }
+nav { background: #37474f; padding: 10px 20px; }
+nav a { color: #ffffff; margin-right: 20px; text-decoration: none; font-weight: bold; }
+main { padding: 10px 20px; }
+table { border-collapse: collapse; margin: 10px 0; }
+th, td { border: 1px solid #cfd8dc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eceff1; }
+pre { margin: 0; font-size: 12px; }
+form { display: inline; }
+textarea { font-family: monospace; vertical-align: middle; }
+.msg { background: #c8e6c9; padding: 8px; }
+.error { background: #ef9a9a; padding: 8px; }
+.status-waiting { background: #eeeeee; }
+.status-ready { background: #bbdefb; }
+.status-started { background: #fff59d; }
+.status-completed { background: #c8e6c9; }
+.status-failed { background: #ef9a9a; }
+.status-cancelled { background: #bdbdbd; }
+</style>
+</head>
+<body>
+<nav>
+<a href="{{.Base}}/processes">Processes</a>
+<a href="{{.Base}}/mappings">Mappings</a>
+<a href="{{.Base}}/orders">Orders</a>
+</nav>
+<main>
+<h2>{{.Title}}</h2>
+{{if .Msg}}<p class="msg">{{.Msg}}</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{template "content" .}}
+</main>
+</body>
+</html>
+{{end}}`
+
+const processesPage = `{{define "content"}}
+<form method="get">
+<input name="q" value="{{.Query}}" placeholder="Id or name">
+<button type="submit">Filter</button>
+</form>
+<p>Total: {{.Content.Total}}</p>
+<table>
+<tr><th>Name</th><th>Id</th><th>Tasks</th><th>Orders</th></tr>
+{{range .Content.Processes}}
+<tr>
+<td><a href="{{$.Base}}/processes/{{.Process.Id}}">{{.Process.Name}}</a></td>
+<td>{{.Process.Id}}</td>
+<td>{{len .Process.Tasks}}</td>
+<td><a href="{{$.Base}}/orders?process={{.Process.Id}}">{{.Orders}}</a></td>
+</tr>
+{{else}}
+<tr><td colspan="4">There are no processes</td></tr>
+{{end}}
+</table>
+{{if .Content.NextUrl}}<p><a href="{{.Content.NextUrl}}">Next</a></p>{{end}}
+{{end}}`
+
+const processPage = `{{define "content"}}
+{{with .Content}}
+<p>Id: {{.Process.Id}}, <a href="{{$.Base}}/orders?process={{.Process.Id}}">orders</a></p>
+<div>{{.Graph}}</div>
+<table>
+<tr><th>Task</th><th>Category</th><th>Action</th><th>Sync</th><th>Depends on</th><th>Mapping</th></tr>
+{{range .Process.Tasks}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{category .Category}}</td>
+<td>{{.Action}}</td>
+<td>{{.Sync}}</td>
+<td>{{range parents $.Content.Process .Id}}{{.}} {{end}}</td>
+<td>{{.ReadMappingId}}<pre>{{json (index $.Content.Mappings .ReadMappingId).Body}}</pre></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}`
+
+const mappingsPage = `{{define "content"}}
+<form method="get">
+<input name="q" value="{{.Query}}" placeholder="Id or body">
+<button type="submit">Filter</button>
+</form>
+<p>Total: {{.Content.Total}}</p>
+<table>
+<tr><th>Id</th><th>Body</th></tr>
+{{range .Content.Mappings}}
+<tr><td>{{.Id}}</td><td><pre>{{json .Body}}</pre></td></tr>
+{{else}}
+<tr><td colspan="2">There are no mappings</td></tr>
+{{end}}
+</table>
+{{if .Content.NextUrl}}<p><a href="{{.Content.NextUrl}}">Next</a></p>{{end}}
+{{end}}`
+
+const ordersPage = `{{define "content"}}
+<form method="get">
+<input name="process" value="{{.Content.ProcessId}}" placeholder="Process id">
+<select name="status">
+<option value="">All statuses</option>
+{{range .Content.Statuses}}
+<option value="{{.}}"{{if eq . $.Content.Status}} selected{{end}}>{{.}}</option>
+{{end}}
+</select>
+<input name="body" value="{{.Content.Body}}" placeholder="Body field=value">
+<button type="submit">Filter</button>
+</form>
+<p>Total: {{.Content.Total}}</p>
+<table>
+<tr><th>Id</th><th>Process</th><th>Body</th></tr>
+{{range .Content.Orders}}
+<tr>
+<td><a href="{{$.Base}}/orders/{{.Order.Id}}">{{.Order.Id}}</a></td>
+<td><a href="{{$.Base}}/processes/{{.Order.ProcessId}}">{{or .ProcessName .Order.ProcessId}}</a></td>
+<td><pre>{{json .Order.Body}}</pre></td>
+</tr>
+{{else}}
+<tr><td colspan="3">There are no orders</td></tr>
+{{end}}
+</table>
+{{if .Content.NextUrl}}<p><a href="{{.Content.NextUrl}}">Next</a></p>{{end}}
+{{end}}`
+
+const orderPage = `{{define "content"}}
+{{with .Content}}
+<p>Process: <a href="{{$.Base}}/processes/{{.Process.Id}}">{{.Process.Name}}</a>,
+<a href="{{$.Base}}/orders/{{.Order.Id}}">refresh</a></p>
+<div>{{.Graph}}</div>
+<table>
+<tr><th>Task</th><th>Status</th><th>Attempt</th><th>Start after</th><th>Locked until</th><th>Error</th>
+<th>Response</th><th>Output</th><th>Actions</th></tr>
+{{range .Jobs}}
+{{$url := printf "%s/orders/%s/jobs/%s" $.Base .Job.OrderId .Job.TaskId}}
+<tr>
+<td>{{or .TaskName .Job.TaskId}}</td>
+<td class="status-{{.Job.Status}}">{{.Job.Status}}</td>
+<td>{{.Job.Attempt}}</td>
+<td>{{time .Job.StartAfter}}</td>
+<td>{{time .Job.LockedUntil}}</td>
+<td>{{.Job.ErrorCode}}</td>
+<td><pre>{{.Job.Response}}</pre></td>
+<td>{{if .Job.Output}}<pre>{{json .Job.Output}}</pre>{{end}}</td>
+<td>
+{{if canRetry .Job.Status}}<form method="post" action="{{$url}}/retry">
+<input type="hidden" name="csrf" value="{{$.Csrf}}">
+<button type="submit">Retry</button></form>{{end}}
+{{if canCancel .Job.Status}}<form method="post" action="{{$url}}/cancel">
+<input type="hidden" name="csrf" value="{{$.Csrf}}">
+<button type="submit">Cancel</button></form>{{end}}
+{{if canComplete .Job.Status}}<form method="post" action="{{$url}}/complete">
+<input type="hidden" name="csrf" value="{{$.Csrf}}">
+<textarea name="output" rows="1" cols="24" placeholder="Output (json)"></textarea>
+<button type="submit">Complete</button></form>{{end}}
+</td>
+</tr>
+{{else}}
+<tr><td colspan="9">There are no jobs</td></tr>
+{{end}}
+</table>
+<h3>Body</h3>
+<pre>{{json .Order.Body}}</pre>
+{{end}}
+{{end}}`
+
+const errorPage = `{{define "content"}}
+<p class="error">{{.Content}}</p>
+{{end}}`
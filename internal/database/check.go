@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+)
+
+// Orphans which reference the rows which don't exist, e.g. created before the foreign keys
+type Orphans struct {
+	Name     string
+	Count    int
+	Repaired bool
+}
+
+type orphanCheck struct {
+	name       string
+	table      string
+	condition  string
+	repairable bool
+}
+
+// Checks are in the order of the repair, e.g. relations and jobs of the deleted tasks are deleted after the tasks.
+// Tasks without read mapping aren't repaired, the mapping has to be recreated (or the process deleted)
+var orphanChecks = []orphanCheck{
+	{name: "tasks without process", table: "pp_task",
+		condition: "process_id NOT IN (SELECT process_id FROM pp_process)", repairable: true},
+	{name: "tasks without read mapping", table: "pp_task",
+		condition: "read_mapping_id NOT IN (SELECT read_mapping_id FROM pp_read_mapping)"},
+	{name: "task relations without process or task", table: "pp_task_rel",
+		condition: "process_id NOT IN (SELECT process_id FROM pp_process) OR " +
+			"parent_id NOT IN (SELECT task_id FROM pp_task) OR child_id NOT IN (SELECT task_id FROM pp_task)",
+		repairable: true},
+	{name: "orders without process", table: "pp_order",
+		condition: "process_id NOT IN (SELECT process_id FROM pp_process)", repairable: true},
+	{name: "jobs without order or task", table: "pp_job",
+		condition:  "order_id NOT IN (SELECT order_id FROM pp_order) OR task_id NOT IN (SELECT task_id FROM pp_task)",
+		repairable: true},
+}
+
+// Count the orphans of every check in a transaction, repairable orphans are deleted if it's requested
+func CheckConsistency(ctx context.Context, db DB, repair bool) ([]Orphans, error) {
+	const op = "Database.CheckConsistency"
+
+	result := make([]Orphans, len(orphanChecks))
+	err := ExecTx(ctx, db, func(txCtx context.Context) error {
+		tx, _ := TransactionFromContext(txCtx)
+		for i, check := range orphanChecks {
+			result[i].Name = check.name
+			query := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", check.table, check.condition)
+			if err := tx.GetContext(txCtx, &result[i].Count, query); err != nil {
+				return domain.E(op, fmt.Sprintf("can't count %s", check.name), err)
+			}
+			if !repair || !check.repairable || result[i].Count == 0 {
+				continue
+			}
+			query = fmt.Sprintf("DELETE FROM %s WHERE %s", check.table, check.condition)
+			if _, err := tx.ExecContext(txCtx, query); err != nil {
+				return domain.E(op, fmt.Sprintf("can't delete %s", check.name), err)
+			}
+			result[i].Repaired = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, domain.E(op, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConsistency_SQLite(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "pp-gin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := NewSQLiteMigrator(db).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Orphans are created without foreign keys, as if they had been created before the migration
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO pp_process (process_id, name) VALUES ('p1', 'process')",
+		"INSERT INTO pp_task (process_id, task_id, name, category, action, read_mapping_id) " +
+			"VALUES ('p1', 't1', 'first', 0, 'http://first', 'm1')",
+		"INSERT INTO pp_task (process_id, task_id, name, category, action, read_mapping_id) " +
+			"VALUES ('p2', 't2', 'second', 0, 'http://second', 'm1')",
+		"INSERT INTO pp_task_rel (process_id, parent_id, child_id) VALUES ('p2', 't2', 't1')",
+		"INSERT INTO pp_order (order_id, process_id) VALUES ('o1', 'p1')",
+		"INSERT INTO pp_order (order_id, process_id) VALUES ('o2', 'p2')",
+		"INSERT INTO pp_job (process_id, task_id, category, action, order_id, read_mapping_id, started, completed, " +
+			"ready_num, ready_req, attempt, trace) VALUES ('p2', 't2', 0, 'http://second', 'o1', 'm1', 0, 0, 0, 0, 0, '')",
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = conn.Close()
+
+	orphans, err := CheckConsistency(ctx, db, false)
+	assert.Nil(err)
+	assert.Equal([]Orphans{
+		{Name: "tasks without process", Count: 1},
+		{Name: "tasks without read mapping", Count: 2},
+		{Name: "task relations without process or task", Count: 1},
+		{Name: "orders without process", Count: 1},
+		{Name: "jobs without order or task", Count: 0},
+	}, orphans)
+
+	// Relations and jobs of the deleted task are deleted by the foreign keys, tasks without read mapping are kept
+	orphans, err = CheckConsistency(ctx, db, true)
+	assert.Nil(err)
+	assert.Equal([]Orphans{
+		{Name: "tasks without process", Count: 1, Repaired: true},
+		{Name: "tasks without read mapping", Count: 1},
+		{Name: "task relations without process or task", Count: 0},
+		{Name: "orders without process", Count: 1, Repaired: true},
+		{Name: "jobs without order or task", Count: 0},
+	}, orphans)
+
+	orphans, err = CheckConsistency(ctx, db, false)
+	assert.Nil(err)
+	for _, o := range orphans {
+		if o.Name == "tasks without read mapping" {
+			assert.Equal(1, o.Count)
+		} else {
+			assert.Equal(0, o.Count, o.Name)
+		}
+	}
+}
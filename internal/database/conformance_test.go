@@ -0,0 +1,727 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Connection string of Postgres database (migrations are applied by the test), Postgres backend is skipped
+// if it isn't set, e.g. PP_TEST_POSTGRES="host=localhost port=5432 user=pg password=pg dbname=pg sslmode=disable"
+const testPostgresEnv = "PP_TEST_POSTGRES"
+
+type testBackend struct {
+	readMappingRepo ReadMappingRepo
+	processRepo     ProcessRepo
+	orderRepo       OrderRepo
+	jobRepo         JobRepo
+	execTx          domain.ExecTxFunc
+}
+
+var testBackends = map[string]func(t *testing.T) (*testBackend, func()){
+	domain.DbDriverMemory: func(t *testing.T) (*testBackend, func()) {
+		store := NewMemStore()
+		return &testBackend{
+			readMappingRepo: NewMemReadMappingRepo(store, uuid.NewUUID),
+			processRepo:     NewMemProcessRepo(store, uuid.NewUUID),
+			orderRepo:       NewMemOrderRepo(store, uuid.NewUUID),
+			jobRepo:         NewMemJobRepo(store),
+			execTx:          store.ExecTx,
+		}, func() {}
+	},
+	domain.DbDriverSqlite: func(t *testing.T) (*testBackend, func()) {
+		dir, err := ioutil.TempDir("", "pp-gin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 4})
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		if _, err := NewSQLiteMigrator(db).Up(context.Background()); err != nil {
+			_ = db.Close()
+			_ = os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		return &testBackend{
+			readMappingRepo: NewRDBReadMappingRepo(db, uuid.NewUUID),
+			processRepo:     NewRDBProcessRepo(db, uuid.NewUUID),
+			orderRepo:       NewRDBOrderRepo(db, uuid.NewUUID),
+			jobRepo:         NewSQLiteJobRepo(db),
+			execTx:          db.ExecTx,
+		}, func() {
+			_ = db.Close()
+			_ = os.RemoveAll(dir)
+		}
+	},
+	domain.DbDriverPostgres: func(t *testing.T) (*testBackend, func()) {
+		cs := os.Getenv(testPostgresEnv)
+		if cs == "" {
+			t.Skipf("%s isn't set", testPostgresEnv)
+		}
+		db, err := sqlx.Connect("pgx", cs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewPostgresMigrator(db).Up(context.Background()); err != nil {
+			_ = db.Close()
+			t.Fatal(err)
+		}
+		return &testBackend{
+			readMappingRepo: NewRDBReadMappingRepo(db, uuid.NewUUID),
+			processRepo:     NewRDBProcessRepo(db, uuid.NewUUID),
+			orderRepo:       NewRDBOrderRepo(db, uuid.NewUUID),
+			jobRepo:         NewRDBJobRepo(db),
+			execTx: func(ctx context.Context, f domain.TxFunc) error {
+				return ExecTx(ctx, db, f)
+			},
+		}, func() {
+			_ = db.Close()
+		}
+	},
+}
+
+// Run the test against every backend, the tests don't expect an empty database
+func testConformance(t *testing.T, test func(t *testing.T, b *testBackend)) {
+	for name, newBackend := range testBackends {
+		newBackend := newBackend
+		t.Run(name, func(t *testing.T) {
+			b, closeFunc := newBackend(t)
+			defer closeFunc()
+			test(t, b)
+		})
+	}
+}
+
+func testConformanceProcess(t *testing.T, b *testBackend) *Process {
+	mapping := &ReadMapping{Body: Body{"id": "$.id"}}
+	assert.Nil(t, b.readMappingRepo.Create(context.Background(), mapping))
+	mappingId := mapping.Id
+
+	ids := []string{uuid.New().String(), uuid.New().String(), uuid.New().String()}
+	process := &Process{
+		Name:        "process",
+		OrderSchema: Body{"required": []interface{}{"id"}},
+		SearchPaths: Strings{"$.id", "$.items[*].productId"},
+		Tasks: []Task{
+			{Id: ids[0], Name: "first", Category: domain.HttpTaskCategory, Action: "http://first",
+				ReadMappingId: mappingId, Sync: true, Http: &HttpTaskConfig{Method: "PUT"},
+				OutputSchema: Body{"type": "object"}},
+			{Id: ids[1], Name: "second", Category: domain.ExternalTaskCategory, Action: ids[1], ReadMappingId: mappingId},
+			{Id: ids[2], Name: "third", Category: domain.HttpTaskCategory, Action: "http://third",
+				ReadMappingId: mappingId},
+		},
+		TaskRelations: []TaskRelation{{ParentId: ids[0], ChildId: ids[2]}, {ParentId: ids[1], ChildId: ids[2]}},
+	}
+	assert.Nil(t, b.execTx(context.Background(), func(txCtx context.Context) error {
+		return b.processRepo.Create(txCtx, process)
+	}))
+	return process
+}
+
+// Jobs of the order, the database could contain other orders
+func testOrderJobs(jobs []Job, orderId string) []Job {
+	var result []Job
+	for _, job := range jobs {
+		if job.OrderId == orderId {
+			result = append(result, job)
+		}
+	}
+	return result
+}
+
+func TestConformance_ReadMapping(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		mapping := &ReadMapping{Body: Body{"id": "$.id"}}
+		assert.Nil(b.readMappingRepo.Create(ctx, mapping))
+		assert.NotEmpty(mapping.Id)
+
+		var result ReadMapping
+		assert.Nil(b.readMappingRepo.GetById(ctx, mapping.Id, &result))
+		assert.Equal(*mapping, result)
+
+		var mappings []ReadMapping
+		assert.Nil(b.readMappingRepo.GetAll(ctx, &mappings))
+		assert.Contains(mappings, *mapping)
+
+		assert.Nil(b.readMappingRepo.DeleteById(ctx, mapping.Id))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.readMappingRepo.DeleteById(ctx, mapping.Id)))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.readMappingRepo.GetById(ctx, mapping.Id, &result)))
+	})
+}
+
+func TestConformance_Rollback(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		mapping := &ReadMapping{Body: Body{"id": "$.id"}}
+		err := b.execTx(ctx, func(txCtx context.Context) error {
+			if err := b.readMappingRepo.Create(txCtx, mapping); err != nil {
+				return err
+			}
+			return errors.New("failure")
+		})
+		assert.NotNil(err)
+		var result ReadMapping
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.readMappingRepo.GetById(ctx, mapping.Id, &result)))
+	})
+}
+
+func TestConformance_Process(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		assert.NotNil(b.processRepo.Create(ctx, &Process{Name: "process"}))
+		process := testConformanceProcess(t, b)
+
+		var result Process
+		assert.Nil(b.processRepo.GetById(ctx, process.Id, &result))
+		assert.Equal(process.Name, result.Name)
+		assert.Equal(process.OrderSchema, result.OrderSchema)
+		assert.Equal(process.SearchPaths, result.SearchPaths)
+		// Process id of the tasks isn't selected by every backend
+		for i := range result.Tasks {
+			result.Tasks[i].ProcessId = ""
+		}
+		for i := range result.TaskRelations {
+			result.TaskRelations[i].ProcessId = ""
+		}
+		sort.Slice(result.Tasks, func(i, j int) bool { return result.Tasks[i].Name < result.Tasks[j].Name })
+		assert.Equal(process.Tasks, result.Tasks)
+		assert.ElementsMatch(process.TaskRelations, result.TaskRelations)
+
+		var processes []Process
+		assert.Nil(b.processRepo.GetAll(ctx, &processes))
+		found := false
+		for _, p := range processes {
+			if p.Id == process.Id {
+				found = true
+				assert.Len(p.Tasks, 3)
+				assert.Len(p.TaskRelations, 2)
+			}
+		}
+		assert.True(found)
+
+		assert.Nil(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.processRepo.DeleteById(txCtx, process.Id)
+		}))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.processRepo.GetById(ctx, process.Id, &result)))
+	})
+}
+
+func TestConformance_Order(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		process := testConformanceProcess(t, b)
+		order := &Order{ProcessId: process.Id, Body: Body{"id": "1", "items": []interface{}{"a"}}}
+		assert.Nil(b.orderRepo.Create(ctx, order))
+
+		var result Order
+		assert.Nil(b.orderRepo.GetById(ctx, order.Id, &result))
+		assert.Equal(*order, result)
+
+		var orders []Order
+		assert.Nil(b.orderRepo.GetAll(ctx, &orders))
+		assert.Contains(orders, *order)
+		var counts map[string]int
+		assert.Nil(b.orderRepo.CountByProcess(ctx, &counts))
+		assert.Equal(1, counts[process.Id])
+
+		assert.Nil(b.orderRepo.DeleteById(ctx, order.Id))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.orderRepo.GetById(ctx, order.Id, &result)))
+	})
+}
+
+// Ids of the orders of the page
+func testOrderIds(orders []Order) []string {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.Id
+	}
+	return ids
+}
+
+func TestConformance_OrderPage(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		process := testConformanceProcess(t, b)
+		running := &Order{ProcessId: process.Id, Body: Body{"id": "1", "count": 2}}
+		failed := &Order{ProcessId: process.Id, Body: Body{"id": "2", "count": 3}}
+		completed := &Order{ProcessId: process.Id, Body: Body{"id": "3"}}
+		for _, order := range []*Order{running, failed, completed} {
+			assert.Nil(b.orderRepo.Create(ctx, order))
+		}
+		span, spanCtx := opentracing.StartSpanFromContext(ctx, "test")
+		defer span.Finish()
+		for _, order := range []*Order{running, failed} {
+			assert.Nil(b.execTx(spanCtx, func(txCtx context.Context) error {
+				return b.jobRepo.CreateJobs(txCtx, order.Id, process)
+			}))
+		}
+		for _, task := range process.Tasks {
+			assert.Nil(b.jobRepo.CancelJob(ctx, task.Id, failed.Id))
+		}
+
+		query := func(q domain.OrderQuery) ([]string, domain.Page) {
+			q.ProcessId = process.Id
+			var orders []Order
+			var page domain.Page
+			assert.Nil(b.orderRepo.GetPage(ctx, &q, &orders, &page))
+			return testOrderIds(orders), page
+		}
+		all := []string{running.Id, failed.Id, completed.Id}
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+		// Pages are continued by the cursor, total is the count of all pages
+		ids, page := query(domain.OrderQuery{PageQuery: domain.PageQuery{Limit: 2, Sort: "id"}})
+		assert.Equal(all[:2], ids)
+		assert.Equal(3, page.Total)
+		idCursor := page.NextCursor
+		assert.NotEmpty(idCursor)
+		ids, page = query(domain.OrderQuery{PageQuery: domain.PageQuery{Limit: 2, Sort: "id", Cursor: idCursor}})
+		assert.Equal(all[2:], ids)
+		assert.Equal(domain.Page{Total: 3}, page)
+
+		ids, _ = query(domain.OrderQuery{PageQuery: domain.PageQuery{Sort: "-id"}})
+		assert.Equal([]string{all[2], all[1], all[0]}, ids)
+
+		// Default sort is by created time, the cursor keeps the sort
+		var created []string
+		cursor := ""
+		for {
+			ids, page = query(domain.OrderQuery{PageQuery: domain.PageQuery{Limit: 1, Cursor: cursor}})
+			created = append(created, ids...)
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+		assert.ElementsMatch(all, created)
+		var last Order
+		assert.Nil(b.orderRepo.GetById(ctx, created[2], &last))
+		ids, _ = query(domain.OrderQuery{CreatedFrom: last.CreatedAt})
+		assert.Contains(ids, last.Id)
+		ids, _ = query(domain.OrderQuery{CreatedTo: running.CreatedAt})
+		assert.NotContains(ids, running.Id)
+		future := time.Now().Add(time.Hour)
+		ids, _ = query(domain.OrderQuery{CreatedFrom: &future})
+		assert.Empty(ids)
+
+		ids, _ = query(domain.OrderQuery{Status: domain.OrderRunning})
+		assert.Equal([]string{running.Id}, ids)
+		ids, _ = query(domain.OrderQuery{Status: domain.OrderFailed})
+		assert.Equal([]string{failed.Id}, ids)
+		ids, _ = query(domain.OrderQuery{Status: domain.OrderCompleted})
+		assert.Equal([]string{completed.Id}, ids)
+
+		ids, _ = query(domain.OrderQuery{Body: map[string]string{"id": "2"}})
+		assert.Equal([]string{failed.Id}, ids)
+		ids, page = query(domain.OrderQuery{Body: map[string]string{"id": "1", "count": "2"}})
+		assert.Equal([]string{running.Id}, ids)
+		assert.Equal(1, page.Total)
+		ids, _ = query(domain.OrderQuery{Body: map[string]string{"id": "1", "count": "3"}})
+		assert.Empty(ids)
+
+		var orders []Order
+		var p domain.Page
+		for _, q := range []domain.OrderQuery{
+			{Status: "unknown"},
+			{PageQuery: domain.PageQuery{Sort: "name"}},
+			{PageQuery: domain.PageQuery{Cursor: "invalid"}},
+			{PageQuery: domain.PageQuery{Sort: "-id", Cursor: idCursor}},
+		} {
+			assert.Equal(domain.ErrValidation, domain.ECode(b.orderRepo.GetPage(ctx, &q, &orders, &p)))
+		}
+	})
+}
+
+func TestConformance_OrderSearch(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		process := testConformanceProcess(t, b)
+		first := &Order{ProcessId: process.Id, Body: Body{"customer": map[string]interface{}{"id": "c1"},
+			"items": []interface{}{map[string]interface{}{"productId": "p1", "count": 2},
+				map[string]interface{}{"productId": "p2"}}}}
+		second := &Order{ProcessId: process.Id, Body: Body{"customer": map[string]interface{}{"id": "c2"},
+			"items": []interface{}{map[string]interface{}{"productId": "p2", "count": 1}}}}
+		third := &Order{ProcessId: process.Id}
+		for _, order := range []*Order{first, second, third} {
+			assert.Nil(b.orderRepo.Create(ctx, order))
+		}
+
+		search := func(docs ...domain.Body) []string {
+			var orders []Order
+			var page domain.Page
+			query := domain.OrderQuery{PageQuery: domain.PageQuery{Sort: "id"}, ProcessId: process.Id, Contains: docs}
+			assert.Nil(b.orderRepo.GetPage(ctx, &query, &orders, &page))
+			assert.Equal(len(orders), page.Total)
+			return testOrderIds(orders)
+		}
+		both := []string{first.Id, second.Id}
+		sort.Strings(both)
+
+		assert.Equal([]string{first.Id}, search(domain.Body{"customer": map[string]interface{}{"id": "c1"}}))
+		assert.Equal(both, search(domain.Body{"items": []interface{}{map[string]interface{}{"productId": "p2"}}}))
+		assert.Equal([]string{first.Id}, search(domain.Body{"items": []interface{}{map[string]interface{}{
+			"productId": "p1", "count": 2}}}))
+		assert.Equal([]string{second.Id}, search(domain.Body{"items": []interface{}{map[string]interface{}{
+			"productId": "p2"}}}, domain.Body{"customer": map[string]interface{}{"id": "c2"}}))
+		assert.Empty(search(domain.Body{"items": []interface{}{map[string]interface{}{"productId": "p1",
+			"count": 1}}}))
+		assert.Empty(search(domain.Body{"customer": "c1"}))
+	})
+}
+
+func TestConformance_ProcessPage(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		first := testConformanceProcess(t, b)
+		second := testConformanceProcess(t, b)
+
+		// Pages of one process are followed till the last one
+		var ids []string
+		var total int
+		query := domain.PageQuery{Limit: 1, Sort: "-id"}
+		for {
+			var processes []Process
+			var page domain.Page
+			assert.Nil(b.processRepo.GetPage(ctx, &query, &processes, &page))
+			if assert.Len(processes, 1) {
+				ids = append(ids, processes[0].Id)
+				if processes[0].Id == first.Id {
+					assert.Len(processes[0].Tasks, 3)
+					assert.Len(processes[0].TaskRelations, 2)
+				}
+			}
+			total = page.Total
+			if query.Cursor = page.NextCursor; query.Cursor == "" {
+				break
+			}
+		}
+		assert.Len(ids, total)
+		assert.Contains(ids, first.Id)
+		assert.Contains(ids, second.Id)
+		assert.True(sort.SliceIsSorted(ids, func(i, j int) bool { return ids[i] > ids[j] }))
+
+		var processes []Process
+		var page domain.Page
+		assert.Nil(b.processRepo.GetPage(ctx, &domain.PageQuery{}, &processes, &page))
+		assert.Len(processes, total)
+		assert.Equal(domain.ErrValidation, domain.ECode(b.processRepo.GetPage(ctx, &domain.PageQuery{Sort: "createdAt"},
+			&processes, &page)))
+	})
+}
+
+func TestConformance_ReadMappingPage(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(b.readMappingRepo.Create(ctx, &ReadMapping{Body: Body{"id": "$.id"}}))
+		}
+		var ids []string
+		var total int
+		query := domain.PageQuery{Limit: 2}
+		for {
+			var mappings []ReadMapping
+			var page domain.Page
+			assert.Nil(b.readMappingRepo.GetPage(ctx, &query, &mappings, &page))
+			for _, mapping := range mappings {
+				ids = append(ids, mapping.Id)
+			}
+			total = page.Total
+			if query.Cursor = page.NextCursor; query.Cursor == "" {
+				break
+			}
+		}
+		assert.GreaterOrEqual(total, 3)
+		assert.Len(ids, total)
+		assert.True(sort.StringsAreSorted(ids))
+	})
+}
+
+func TestConformance_OrderIdempotencyKey(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+
+		process := testConformanceProcess(t, b)
+		key := "key1"
+		order := &Order{ProcessId: process.Id, Body: Body{"id": "1"}, IdempotencyKey: &key}
+		assert.Nil(b.orderRepo.Create(ctx, order))
+		assert.NotNil(order.CreatedAt)
+
+		var result Order
+		assert.Nil(b.orderRepo.GetByIdempotencyKey(ctx, process.Id, key, &result))
+		assert.Equal(*order, result)
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.orderRepo.GetByIdempotencyKey(ctx, process.Id, "key2",
+			&result)))
+
+		// Key is unique per process, orders without key aren't limited
+		assert.Equal(domain.ErrConflict, domain.ECode(b.orderRepo.Create(ctx, &Order{ProcessId: process.Id,
+			IdempotencyKey: &key})))
+		assert.Nil(b.orderRepo.Create(ctx, &Order{ProcessId: process.Id}))
+		assert.Nil(b.orderRepo.Create(ctx, &Order{ProcessId: process.Id}))
+
+		// Released key is used by another order
+		assert.Nil(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.orderRepo.ReleaseIdempotencyKey(txCtx, order.Id)
+		}))
+		assert.Nil(b.orderRepo.GetById(ctx, order.Id, &result))
+		assert.Nil(result.IdempotencyKey)
+		another := &Order{ProcessId: process.Id, IdempotencyKey: &key}
+		assert.Nil(b.orderRepo.Create(ctx, another))
+		assert.Nil(b.orderRepo.GetByIdempotencyKey(ctx, process.Id, key, &result))
+		assert.Equal(another.Id, result.Id)
+	})
+}
+
+func TestConformance_Jobs(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+		process := testConformanceProcess(t, b)
+		first, second, third := process.Tasks[0].Id, process.Tasks[1].Id, process.Tasks[2].Id
+		order := &Order{ProcessId: process.Id}
+		assert.Nil(b.orderRepo.Create(ctx, order))
+		orderId := order.Id
+
+		// Jobs are created along with the order, trace of the order is stored along with the jobs
+		assert.NotNil(b.jobRepo.CreateJobs(ctx, orderId, process))
+		span, spanCtx := opentracing.StartSpanFromContext(ctx, "test")
+		defer span.Finish()
+		assert.Nil(b.execTx(spanCtx, func(txCtx context.Context) error {
+			return b.jobRepo.CreateJobs(txCtx, orderId, process)
+		}))
+
+		// External job isn't started by the scheduler, third one waits for the parents
+		var jobs []Job
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(first, jobs[0].TaskId)
+			assert.Equal(1, jobs[0].Attempt)
+			assert.Equal(&HttpTaskConfig{Method: "PUT"}, jobs[0].Http)
+			assert.True(jobs[0].Sync)
+		}
+
+		// Failed attempt is retried, stale attempt is rejected
+		failure := &JobFailure{TaskId: first, OrderId: orderId, Attempt: 1, ErrorCode: "APP-0004"}
+		assert.Nil(b.jobRepo.RetryJob(ctx, failure, time.Now().Add(time.Hour)))
+		assert.Equal(domain.ErrStaleAttempt, domain.ECode(b.jobRepo.FailJob(ctx, &JobFailure{TaskId: first,
+			OrderId: orderId, Attempt: 2})))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		assert.Empty(testOrderJobs(jobs, orderId))
+
+		// Output of the job which isn't completed is stored by the retry
+		failure.Output = Body{"a": "1"}
+		assert.Nil(b.jobRepo.RetryJob(ctx, failure, time.Now().Add(-time.Second)))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(2, jobs[0].Attempt)
+			assert.Equal(Body{"a": "1"}, jobs[0].Output)
+		}
+
+		assert.Equal(domain.ErrStaleAttempt, domain.ECode(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.jobRepo.CompleteJob(txCtx, first, orderId, domain.JobAttemptId(first, orderId, 1), nil)
+		})))
+		assert.Nil(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.jobRepo.CompleteJob(txCtx, first, orderId, domain.JobAttemptId(first, orderId, 2), Body{"a": "1"})
+		}))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		assert.Empty(testOrderJobs(jobs, orderId))
+
+		// External job is locked, expired lock is taken over
+		topics := []string{second}
+		jobs = nil
+		assert.Nil(b.jobRepo.FetchJobs(ctx, topics, 10, time.Now().Add(-time.Second), &jobs))
+		assert.Len(jobs, 1)
+		jobs = nil
+		assert.Nil(b.jobRepo.FetchJobs(ctx, topics, 10, time.Now().Add(time.Minute), &jobs))
+		if assert.Len(jobs, 1) {
+			assert.Equal(2, jobs[0].Attempt)
+		}
+		jobs = nil
+		assert.Nil(b.jobRepo.FetchJobs(ctx, topics, 10, time.Now().Add(time.Minute), &jobs))
+		assert.Empty(jobs)
+		assert.Nil(b.jobRepo.ExtendJobLock(ctx, second, orderId, 2, time.Now().Add(time.Hour)))
+		assert.Equal(domain.ErrStaleAttempt, domain.ECode(b.jobRepo.ExtendJobLock(ctx, second, orderId, 1,
+			time.Now())))
+
+		// Third job is ready when both parents are completed, deferred attempt isn't counted
+		assert.Nil(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.jobRepo.CompleteJob(txCtx, second, orderId, "", nil)
+		}))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(third, jobs[0].TaskId)
+		}
+		assert.Nil(b.jobRepo.DeferJob(ctx, third, orderId, 1, time.Now().Add(-time.Second)))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(1, jobs[0].Attempt)
+			assert.NotNil(jobs[0].StartAfter)
+		}
+
+		// Late completion doesn't complete the cancelled job
+		assert.Nil(b.jobRepo.CancelJob(ctx, third, orderId))
+		for _, attemptId := range []string{domain.JobAttemptId(third, orderId, 1), ""} {
+			assert.Equal(domain.ErrCancelled, domain.ECode(b.execTx(ctx, func(txCtx context.Context) error {
+				return b.jobRepo.CompleteJob(txCtx, third, orderId, attemptId, Body{"a": "3"})
+			})))
+		}
+		jobs = nil
+		assert.Nil(b.jobRepo.GetByOrderId(ctx, orderId, &jobs))
+		assert.Len(jobs, 3)
+		byTask := make(map[string]Job, len(jobs))
+		for _, job := range jobs {
+			byTask[job.TaskId] = job
+		}
+		assert.True(byTask[first].Completed)
+		assert.Equal(Body{"a": "1"}, byTask[first].Output)
+		assert.True(byTask[second].Completed)
+		assert.True(byTask[third].Failed)
+		assert.False(byTask[third].Completed)
+		assert.Nil(byTask[third].Output)
+		assert.Equal(string(domain.ErrCancelled), byTask[third].ErrorCode)
+		assert.Equal(2, byTask[third].ReadyNum)
+
+		assert.Nil(b.jobRepo.ResetJob(ctx, third, orderId))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.jobRepo.ResetJob(ctx, first, orderId)))
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.jobRepo.CancelJob(ctx, first, orderId)))
+	})
+}
+
+// Retried job gets all the attempts again, so its next failure is retried rather than failed by the scheduler
+func TestConformance_RetryFailedJob(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+		process := testConformanceProcess(t, b)
+		first := process.Tasks[0].Id
+		order := &Order{ProcessId: process.Id}
+		assert.Nil(b.orderRepo.Create(ctx, order))
+		orderId := order.Id
+		span, spanCtx := opentracing.StartSpanFromContext(ctx, "test")
+		defer span.Finish()
+		assert.Nil(b.execTx(spanCtx, func(txCtx context.Context) error {
+			return b.jobRepo.CreateJobs(txCtx, orderId, process)
+		}))
+
+		// Job is failed after the second attempt
+		var jobs []Job
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		assert.Len(testOrderJobs(jobs, orderId), 1)
+		assert.Nil(b.jobRepo.RetryJob(ctx, &JobFailure{TaskId: first, OrderId: orderId, Attempt: 1},
+			time.Now().Add(-time.Second)))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(2, jobs[0].Attempt)
+		}
+		assert.Nil(b.jobRepo.FailJob(ctx, &JobFailure{TaskId: first, OrderId: orderId, Attempt: 2,
+			ErrorCode: "APP-0004"}))
+
+		// Retried job starts from the first attempt, so its failure is retried again
+		assert.Nil(b.jobRepo.ResetJob(ctx, first, orderId))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetByOrderId(ctx, orderId, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.NotEmpty(jobs) {
+			assert.Equal(0, jobs[0].Attempt)
+			assert.False(jobs[0].Failed)
+		}
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(1, jobs[0].Attempt)
+		}
+		assert.Nil(b.jobRepo.RetryJob(ctx, &JobFailure{TaskId: first, OrderId: orderId, Attempt: 1},
+			time.Now().Add(-time.Second)))
+		jobs = nil
+		assert.Nil(b.jobRepo.GetReadyJobs(ctx, 100, &jobs))
+		jobs = testOrderJobs(jobs, orderId)
+		if assert.Len(jobs, 1) {
+			assert.Equal(2, jobs[0].Attempt)
+			assert.False(jobs[0].Failed)
+		}
+	})
+}
+
+func TestConformance_Integrity(t *testing.T) {
+	testConformance(t, func(t *testing.T, b *testBackend) {
+		assert := assert.New(t)
+		ctx := context.Background()
+		process := testConformanceProcess(t, b)
+		mappingId := process.Tasks[0].ReadMappingId
+		order := &Order{ProcessId: process.Id}
+		assert.Nil(b.orderRepo.Create(ctx, order))
+		span, spanCtx := opentracing.StartSpanFromContext(ctx, "test")
+		defer span.Finish()
+		assert.Nil(b.execTx(spanCtx, func(txCtx context.Context) error {
+			return b.jobRepo.CreateJobs(txCtx, order.Id, process)
+		}))
+
+		// Mapping which is used by tasks isn't deleted
+		var count int
+		assert.Nil(b.readMappingRepo.GetTaskCount(ctx, mappingId, &count))
+		assert.Equal(3, count)
+		assert.Equal(domain.ErrConflict, domain.ECode(b.readMappingRepo.DeleteById(ctx, mappingId)))
+
+		// Order is running until every job is either completed or failed
+		assert.Nil(b.processRepo.GetRunningOrderCount(ctx, process.Id, &count))
+		assert.Equal(1, count)
+		for _, task := range process.Tasks {
+			assert.Nil(b.jobRepo.CancelJob(ctx, task.Id, order.Id))
+		}
+		assert.Nil(b.processRepo.GetRunningOrderCount(ctx, process.Id, &count))
+		assert.Equal(0, count)
+
+		// Orders and jobs are deleted along with the process, so the mapping isn't used anymore
+		assert.Nil(b.execTx(ctx, func(txCtx context.Context) error {
+			return b.processRepo.DeleteById(txCtx, process.Id)
+		}))
+		var result Order
+		assert.Equal(domain.ErrNotFound, domain.ECode(b.orderRepo.GetById(ctx, order.Id, &result)))
+		var jobs []Job
+		assert.Nil(b.jobRepo.GetByOrderId(ctx, order.Id, &jobs))
+		assert.Empty(jobs)
+		assert.Nil(b.readMappingRepo.GetTaskCount(ctx, mappingId, &count))
+		assert.Equal(0, count)
+		assert.Nil(b.readMappingRepo.DeleteById(ctx, mappingId))
+	})
+}
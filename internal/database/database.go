@@ -9,9 +9,12 @@ import (
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx"
 	_ "github.com/jackc/pgx/stdlib"
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
 )
 
 type NewUUIDFunc func() (uuid.UUID, error)
@@ -37,12 +40,53 @@ func (b Body) Value() (driver.Value, error) {
 	return json.Marshal(b)
 }
 
+// Map of the destination is reset, sqlx allocates it before the scan, so JSON null is scanned as nil
 func (b *Body) Scan(value interface{}) error {
+	*b = nil
+	if value == nil {
+		return nil
+	}
 	bodyBytes, ok := value.([]byte)
 	if !ok {
 		return errors.New("can't convert body to bytes")
 	}
-	return json.Unmarshal(bodyBytes, &b)
+	return json.Unmarshal(bodyBytes, b)
+}
+
+// List of the strings stored as JSON array
+type Strings []string
+
+func (s Strings) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *Strings) Scan(value interface{}) error {
+	*s = nil
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("can't convert strings to bytes")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+type HttpTaskConfig domain.HttpTaskConfig
+
+func (c HttpTaskConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+func (c *HttpTaskConfig) Scan(value interface{}) error {
+	bodyBytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("can't convert http task config to bytes")
+	}
+	return json.Unmarshal(bodyBytes, c)
 }
 
 // For more usages of sqlx see https://jmoiron.github.io/sqlx/
@@ -63,6 +107,66 @@ func Connect(cfg domain.DbConfig) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// Foreign key violation of Postgres or SQLite, e.g. the row is referenced by another one with ON DELETE RESTRICT
+func isForeignKeyViolation(err error) bool {
+	switch e := err.(type) {
+	case pgx.PgError:
+		return e.Code == "23503"
+	case *pgx.PgError:
+		return e.Code == "23503"
+	}
+	return sqliteForeignKeyViolation(err)
+}
+
+// Unique violation of Postgres or SQLite, e.g. the duplicate idempotency key of the order
+func isUniqueViolation(err error) bool {
+	switch e := err.(type) {
+	case pgx.PgError:
+		return e.Code == "23505"
+	case *pgx.PgError:
+		return e.Code == "23505"
+	}
+	return sqliteUniqueViolation(err)
+}
+
+// Rows of the multi-row statements are limited, so the number of the parameters is fine for Postgres and SQLite
+const defaultBatchSize = 500
+
+// Insert the rows by the multi-row statements of up to batchSize rows (default one if it isn't positive),
+// e.g. "INSERT INTO pp_task_rel (process_id, parent_id, child_id) VALUES " with the rows of 3 values
+func execBatchInsert(ctx context.Context, tx Tx, insert string, rows [][]interface{}, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var query strings.Builder
+		query.WriteString(insert)
+		var args []interface{}
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("(")
+			for j, value := range row {
+				if j > 0 {
+					query.WriteString(", ")
+				}
+				args = append(args, value)
+				query.WriteString("$" + strconv.Itoa(len(args)))
+			}
+			query.WriteString(")")
+		}
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type txContextKey string
 
 const txKey txContextKey = "transaction"
@@ -84,10 +188,15 @@ func ExecTx(ctx context.Context, db DB, f domain.TxFunc) error {
 	if err != nil {
 		return domain.E(op, "can't begin transaction", err)
 	}
-	var tx Tx = sqlTx
+	return execInTx(ctx, sqlTx, f)
+}
+
+// Execute function in the transaction which has been begun, it's committed or rolled back
+func execInTx(ctx context.Context, tx Tx, f domain.TxFunc) error {
+	const op = "Transaction.Exec"
 
 	txCtx := WithTransaction(ctx, tx)
-	if err = f(txCtx); err != nil {
+	if err := f(txCtx); err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
 			return domain.E(op, "can't rollback transaction", rbErr)
 		}
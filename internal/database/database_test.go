@@ -25,17 +25,17 @@ type MockDB struct {
 	mock.Mock
 }
 
-func (m MockDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+func (m *MockDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	arguments := m.Called(ctx, dest, query, args)
 	return arguments.Error(0)
 }
 
-func (m MockDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+func (m *MockDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	arguments := m.Called(ctx, dest, query, args)
 	return arguments.Error(0)
 }
 
-func (m MockDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+func (m *MockDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	arguments := m.Called(ctx, query, args)
 	result := arguments.Get(0)
 	if result != nil {
@@ -45,7 +45,7 @@ func (m MockDB) ExecContext(ctx context.Context, query string, args ...interface
 	}
 }
 
-func (m MockDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+func (m *MockDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
 	arguments := m.Called(ctx, opts)
 	result := arguments.Get(0)
 	if result != nil {
@@ -55,12 +55,12 @@ func (m MockDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, er
 	}
 }
 
-func (m MockDB) Commit() error {
+func (m *MockDB) Commit() error {
 	arguments := m.Called()
 	return arguments.Error(0)
 }
 
-func (m MockDB) Rollback() error {
+func (m *MockDB) Rollback() error {
 	arguments := m.Called()
 	return arguments.Error(0)
 }
@@ -69,12 +69,12 @@ type MockResult struct {
 	mock.Mock
 }
 
-func (m MockResult) LastInsertId() (int64, error) {
+func (m *MockResult) LastInsertId() (int64, error) {
 	arguments := m.Called()
 	return int64(arguments.Int(0)), arguments.Error(1)
 }
 
-func (m MockResult) RowsAffected() (int64, error) {
+func (m *MockResult) RowsAffected() (int64, error) {
 	arguments := m.Called()
 	return int64(arguments.Int(0)), arguments.Error(1)
 }
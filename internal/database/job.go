@@ -2,21 +2,48 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"example.com/oligzeev/pp-gin/internal/tracing"
 	"fmt"
-	"github.com/jmoiron/sqlx"
+	"github.com/jackc/pgx/pgtype"
+	"time"
 )
 
 const (
 	createJobs = `INSERT INTO pp_job
-(process_id, task_id, category, action, order_id, read_mapping_id, started, completed, ready_num, ready_req, trace)
-VALUES ($1, $2, $3, $4, $5, $6, FALSE, FALSE, 0, $7, $8)`
-	getReadyJobs = `UPDATE pp_job SET started = TRUE
+(process_id, task_id, category, action, sync, http, order_id, read_mapping_id, started, completed, ready_num, ready_req,
+attempt, trace) VALUES `
+	getReadyJobs = `UPDATE pp_job SET started = TRUE, attempt = attempt + 1
 WHERE (task_id, order_id) IN (
-  SELECT task_id, order_id FROM pp_job WHERE ready_num >= ready_req AND started = FALSE LIMIT $1
-) RETURNING task_id, category, action, order_id, read_mapping_id, trace`
-	completeJob         = `UPDATE pp_job SET completed = TRUE WHERE completed = FALSE AND task_id = $1 and order_id = $2`
+  SELECT task_id, order_id FROM pp_job WHERE ready_num >= ready_req AND started = FALSE AND failed = FALSE
+  AND category <> $3 AND (start_after IS NULL OR start_after <= $2) LIMIT $1
+) RETURNING task_id, category, action, sync, http, order_id, read_mapping_id, attempt, start_after, output, trace`
+	getJobsByOrderId = `SELECT task_id, category, action, sync, order_id, read_mapping_id, started, completed, failed,
+ready_num, ready_req, attempt, start_after, error_code, response, output, locked_until FROM pp_job WHERE order_id = $1`
+	fetchJobs = `UPDATE pp_job SET started = TRUE, attempt = attempt + 1, locked_until = $5
+WHERE (task_id, order_id) IN (
+  SELECT task_id, order_id FROM pp_job WHERE category = $1 AND action = ANY($2) AND ready_num >= ready_req
+  AND completed = FALSE AND failed = FALSE AND (started = FALSE OR locked_until < $4)
+  AND (start_after IS NULL OR start_after <= $4) LIMIT $3 FOR UPDATE SKIP LOCKED
+) RETURNING task_id, category, action, order_id, read_mapping_id, attempt, locked_until, trace`
+	extendJobLock = `UPDATE pp_job SET locked_until = $4
+WHERE completed = FALSE AND failed = FALSE AND started = TRUE AND task_id = $1 AND order_id = $2 AND attempt = $3`
+	getJobState = `SELECT attempt, completed, failed, error_code FROM pp_job
+WHERE task_id = $1 AND order_id = $2 FOR UPDATE`
+	completeJob = `UPDATE pp_job SET completed = TRUE, failed = FALSE, output = $3
+WHERE completed = FALSE AND task_id = $1 and order_id = $2`
+	retryJob = `UPDATE pp_job SET started = FALSE, start_after = $4, error_code = $5, response = $6, output = $7
+WHERE completed = FALSE AND task_id = $1 AND order_id = $2 AND attempt = $3`
+	deferJob = `UPDATE pp_job SET started = FALSE, attempt = attempt - 1, start_after = $4
+WHERE completed = FALSE AND task_id = $1 AND order_id = $2 AND attempt = $3`
+	failJob = `UPDATE pp_job SET failed = TRUE, error_code = $4, response = $5
+WHERE completed = FALSE AND task_id = $1 AND order_id = $2 AND attempt = $3`
+	resetJob = `UPDATE pp_job SET started = FALSE, failed = FALSE, attempt = 0, start_after = NULL, locked_until = NULL,
+output = NULL
+WHERE completed = FALSE AND task_id = $1 AND order_id = $2`
+	cancelJob = `UPDATE pp_job SET failed = TRUE, error_code = $3
+WHERE completed = FALSE AND task_id = $1 AND order_id = $2`
 	completeRelatedJobs = `UPDATE pp_job t
 SET ready_num = t.ready_num + 1
 WHERE t.completed = FALSE AND t.task_id IN (
@@ -25,26 +52,71 @@ WHERE t.completed = FALSE AND t.task_id IN (
 )
 
 type Job struct {
-	TaskId        string `db:"task_id"`
-	Category      int    `db:"category"`
-	Action        string `db:"action"`
-	OrderId       string `db:"order_id"`
-	ReadMappingId string `db:"read_mapping_id"`
-	Trace         string `db:"trace"`
+	TaskId        string          `db:"task_id"`
+	Category      int             `db:"category"`
+	Action        string          `db:"action"`
+	Sync          bool            `db:"sync"`
+	Http          *HttpTaskConfig `db:"http"`
+	OrderId       string          `db:"order_id"`
+	ReadMappingId string          `db:"read_mapping_id"`
+	Started       bool            `db:"started"`
+	Completed     bool            `db:"completed"`
+	Failed        bool            `db:"failed"`
+	ReadyNum      int             `db:"ready_num"`
+	ReadyReq      int             `db:"ready_req"`
+	Attempt       int             `db:"attempt"`
+	StartAfter    *time.Time      `db:"start_after"`
+	ErrorCode     string          `db:"error_code"`
+	Response      string          `db:"response"`
+	Output        Body            `db:"output"`
+	LockedUntil   *time.Time      `db:"locked_until"`
+	Trace         string          `db:"trace"`
+}
+
+// Failure of the job attempt, output of the synchronous job which is started, but isn't completed, is stored
+// by the retry, so the next attempt retries only the completion
+type JobFailure struct {
+	TaskId    string
+	OrderId   string
+	Attempt   int
+	ErrorCode string
+	Response  string
+	Output    Body
+}
+
+type JobState struct {
+	Attempt   int    `db:"attempt"`
+	Completed bool   `db:"completed"`
+	Failed    bool   `db:"failed"`
+	ErrorCode string `db:"error_code"`
+}
+
+// Cancelled job isn't completed by the late completion (it'd start the children of the job)
+func (s JobState) cancelled() bool {
+	return s.Failed && s.ErrorCode == string(domain.ErrCancelled)
 }
 
 type JobRepo interface {
 	CreateJobs(ctx context.Context, orderId string, process *Process) error
 	GetReadyJobs(ctx context.Context, jobLimit int, jobs *[]Job) error
-	CompleteJob(ctx context.Context, taskId, orderId string) error
+	GetByOrderId(ctx context.Context, orderId string, jobs *[]Job) error
+	CompleteJob(ctx context.Context, taskId, orderId, attemptId string, output Body) error
+	RetryJob(ctx context.Context, failure *JobFailure, startAfter time.Time) error
+	DeferJob(ctx context.Context, taskId, orderId string, attempt int, startAfter time.Time) error
+	FailJob(ctx context.Context, failure *JobFailure) error
+	FetchJobs(ctx context.Context, topics []string, jobLimit int, lockedUntil time.Time, jobs *[]Job) error
+	ExtendJobLock(ctx context.Context, taskId, orderId string, attempt int, lockedUntil time.Time) error
+	ResetJob(ctx context.Context, taskId, orderId string) error
+	CancelJob(ctx context.Context, taskId, orderId string) error
 }
 
 type RDBJobRepo struct {
-	db *sqlx.DB
+	db        DB
+	batchSize int
 }
 
-func NewRDBJobRepo(db *sqlx.DB) JobRepo {
-	return &RDBJobRepo{db: db}
+func NewRDBJobRepo(db DB) JobRepo {
+	return &RDBJobRepo{db: db, batchSize: defaultBatchSize}
 }
 
 func (s RDBJobRepo) CreateJobs(ctx context.Context, orderId string, process *Process) error {
@@ -55,19 +127,14 @@ func (s RDBJobRepo) CreateJobs(ctx context.Context, orderId string, process *Pro
 		if err != nil {
 			return domain.E(op, "can't get span string", err)
 		}
-		for _, task := range process.Tasks {
-			// Calculate count of relations
-			readyRequired := 0
-			for _, relation := range process.TaskRelations {
-				if relation.ChildId == task.Id {
-					readyRequired = readyRequired + 1
-				}
-			}
-			if _, err := tx.ExecContext(ctx, createJobs, process.Id, task.Id, task.Category, task.Action, orderId,
-				task.ReadMappingId, readyRequired, jobTraceStr); err != nil {
-
-				return domain.E(op, fmt.Sprintf("can't create job (%s)", task.Id), err)
-			}
+		readyRequired := process.ReadyRequired()
+		rows := make([][]interface{}, len(process.Tasks))
+		for i, task := range process.Tasks {
+			rows[i] = []interface{}{process.Id, task.Id, task.Category, task.Action, task.Sync, task.Http, orderId,
+				task.ReadMappingId, false, false, 0, readyRequired[task.Id], 0, jobTraceStr}
+		}
+		if err := execBatchInsert(ctx, tx, createJobs, rows, s.batchSize); err != nil {
+			return domain.E(op, fmt.Sprintf("can't create jobs (%s)", orderId), err)
 		}
 		return nil
 	}
@@ -77,22 +144,46 @@ func (s RDBJobRepo) CreateJobs(ctx context.Context, orderId string, process *Pro
 func (s RDBJobRepo) GetReadyJobs(ctx context.Context, jobLimit int, jobs *[]Job) error {
 	const op = "JobRepo.GetReadyJobs"
 
-	if err := s.db.SelectContext(ctx, jobs, getReadyJobs, jobLimit); err != nil {
+	if err := s.db.SelectContext(ctx, jobs, getReadyJobs, jobLimit, time.Now(), domain.ExternalTaskCategory); err != nil {
 		return domain.E(op, err)
 	}
 	return nil
 }
 
-func (s RDBJobRepo) CompleteJob(ctx context.Context, taskId, orderId string) error {
+func (s RDBJobRepo) GetByOrderId(ctx context.Context, orderId string, jobs *[]Job) error {
+	const op = "JobRepo.GetByOrderId"
+
+	if err := s.db.SelectContext(ctx, jobs, getJobsByOrderId, orderId); err != nil {
+		return domain.E(op, fmt.Sprintf("can't select jobs (%s)", orderId), err)
+	}
+	return nil
+}
+
+// Complete job of the given attempt, empty attempt id matches any attempt.
+// Duplicate completion of the same attempt is acknowledged without any changes,
+// completion of a stale attempt is rejected with ErrStaleAttempt and completion of a cancelled job with ErrCancelled
+func (s RDBJobRepo) CompleteJob(ctx context.Context, taskId, orderId, attemptId string, output Body) error {
 	const op = "JobRepo.CompleteJob"
 
 	if tx, ok := TransactionFromContext(ctx); ok {
-		result, err := tx.ExecContext(ctx, completeJob, taskId, orderId)
-		if err != nil {
-			return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", taskId, orderId), err)
+		var state JobState
+		if err := tx.GetContext(ctx, &state, getJobState, taskId, orderId); err != nil {
+			if err == sql.ErrNoRows {
+				return domain.E(op, domain.ErrNotFound)
+			}
+			return domain.E(op, fmt.Sprintf("can't get job state (%s, %s)", taskId, orderId), err)
+		}
+		if attemptId != "" && attemptId != domain.JobAttemptId(taskId, orderId, state.Attempt) {
+			return domain.E(op, domain.ErrStaleAttempt, fmt.Sprintf("stale attempt (%s)", attemptId))
+		}
+		if state.Completed {
+			return nil
 		}
-		if count, _ := result.RowsAffected(); count == 0 {
-			return domain.E(op, domain.ErrNotFound)
+		if state.cancelled() {
+			return domain.E(op, domain.ErrCancelled, fmt.Sprintf("job is cancelled (%s, %s)", taskId, orderId))
+		}
+		if _, err := tx.ExecContext(ctx, completeJob, taskId, orderId, output); err != nil {
+			return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", taskId, orderId), err)
 		}
 		if _, err := tx.ExecContext(ctx, completeRelatedJobs, taskId, orderId); err != nil {
 			return domain.E(op, fmt.Sprintf("can't complete related jobs (%s, %s)", taskId, orderId), err)
@@ -101,3 +192,105 @@ func (s RDBJobRepo) CompleteJob(ctx context.Context, taskId, orderId string) err
 	}
 	return domain.E(op, "there's no active transaction")
 }
+
+// Reschedule failed attempt of the job, ErrStaleAttempt is returned if the job has been already restarted
+// or completed
+func (s RDBJobRepo) RetryJob(ctx context.Context, failure *JobFailure, startAfter time.Time) error {
+	const op = "JobRepo.RetryJob"
+
+	result, err := s.db.ExecContext(ctx, retryJob, failure.TaskId, failure.OrderId, failure.Attempt, startAfter,
+		failure.ErrorCode, failure.Response, failure.Output)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't retry job (%s, %s)", failure.TaskId, failure.OrderId), err)
+	}
+	return checkAttempt(op, result, failure.TaskId, failure.OrderId, failure.Attempt)
+}
+
+// Reschedule attempt of the job which hasn't been started, so the attempt isn't counted
+func (s RDBJobRepo) DeferJob(ctx context.Context, taskId, orderId string, attempt int, startAfter time.Time) error {
+	const op = "JobRepo.DeferJob"
+
+	if _, err := s.db.ExecContext(ctx, deferJob, taskId, orderId, attempt, startAfter); err != nil {
+		return domain.E(op, fmt.Sprintf("can't defer job (%s, %s)", taskId, orderId), err)
+	}
+	return nil
+}
+
+// Mark the job as failed, it won't be started again
+func (s RDBJobRepo) FailJob(ctx context.Context, failure *JobFailure) error {
+	const op = "JobRepo.FailJob"
+
+	result, err := s.db.ExecContext(ctx, failJob, failure.TaskId, failure.OrderId, failure.Attempt,
+		failure.ErrorCode, failure.Response)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't fail job (%s, %s)", failure.TaskId, failure.OrderId), err)
+	}
+	return checkAttempt(op, result, failure.TaskId, failure.OrderId, failure.Attempt)
+}
+
+// Lock ready jobs of external tasks with the given topics (actions), expired locks are taken over
+func (s RDBJobRepo) FetchJobs(ctx context.Context, topics []string, jobLimit int, lockedUntil time.Time,
+	jobs *[]Job) error {
+
+	const op = "JobRepo.FetchJobs"
+
+	var topicArray pgtype.VarcharArray
+	if err := topicArray.Set(topics); err != nil {
+		return domain.E(op, "can't convert topics", err)
+	}
+	if err := s.db.SelectContext(ctx, jobs, fetchJobs, domain.ExternalTaskCategory, &topicArray, jobLimit,
+		time.Now(), lockedUntil); err != nil {
+
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Extend lock of the external job, ErrStaleAttempt is returned if the lock has been taken over
+func (s RDBJobRepo) ExtendJobLock(ctx context.Context, taskId, orderId string, attempt int,
+	lockedUntil time.Time) error {
+
+	const op = "JobRepo.ExtendJobLock"
+
+	result, err := s.db.ExecContext(ctx, extendJobLock, taskId, orderId, attempt, lockedUntil)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't extend job lock (%s, %s)", taskId, orderId), err)
+	}
+	return checkAttempt(op, result, taskId, orderId, attempt)
+}
+
+// Make the job (which isn't completed) ready to start from the first attempt, ErrNotFound is returned if there's
+// no such job
+func (s RDBJobRepo) ResetJob(ctx context.Context, taskId, orderId string) error {
+	const op = "JobRepo.ResetJob"
+
+	result, err := s.db.ExecContext(ctx, resetJob, taskId, orderId)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't reset job (%s, %s)", taskId, orderId), err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return domain.E(op, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Fail the job (which isn't completed) with ErrCancelled code, ErrNotFound is returned if there's no such job
+func (s RDBJobRepo) CancelJob(ctx context.Context, taskId, orderId string) error {
+	const op = "JobRepo.CancelJob"
+
+	result, err := s.db.ExecContext(ctx, cancelJob, taskId, orderId, string(domain.ErrCancelled))
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't cancel job (%s, %s)", taskId, orderId), err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return domain.E(op, domain.ErrNotFound)
+	}
+	return nil
+}
+
+func checkAttempt(op domain.ErrOp, result sql.Result, taskId, orderId string, attempt int) error {
+	if count, _ := result.RowsAffected(); count == 0 {
+		return staleAttempt(op, taskId, orderId, attempt)
+	}
+	return nil
+}
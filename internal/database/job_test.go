@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/internal/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func mockJobState(mockDB *MockDB, taskId, orderId string, state JobState) {
+	mockDB.On("GetContext", mock.Anything, mock.AnythingOfType("*database.JobState"), getJobState,
+		[]interface{}{taskId, orderId}).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(1).(*JobState) = state
+	})
+}
+
+func TestJobRepo_CompleteJob_Success(t *testing.T) {
+	const (
+		taskId  = "1"
+		orderId = "2"
+	)
+	assert := assert.New(t)
+
+	mockDB := new(MockDB)
+	txCtx := WithTransaction(testCtx, mockDB)
+	mockJobState(mockDB, taskId, orderId, JobState{Attempt: 1})
+	mockDB.On("ExecContext", txCtx, completeJob, []interface{}{taskId, orderId, Body(nil)}).Return(nil, nil)
+	mockDB.On("ExecContext", txCtx, completeRelatedJobs, []interface{}{taskId, orderId}).Return(nil, nil)
+
+	repo := RDBJobRepo{}
+	err := repo.CompleteJob(txCtx, taskId, orderId, domain.JobAttemptId(taskId, orderId, 1), nil)
+	assert.Nil(err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestJobRepo_CompleteJob_Duplicate(t *testing.T) {
+	const (
+		taskId  = "1"
+		orderId = "2"
+	)
+	assert := assert.New(t)
+
+	mockDB := new(MockDB)
+	txCtx := WithTransaction(testCtx, mockDB)
+	mockJobState(mockDB, taskId, orderId, JobState{Attempt: 1, Completed: true})
+
+	repo := RDBJobRepo{}
+	err := repo.CompleteJob(txCtx, taskId, orderId, domain.JobAttemptId(taskId, orderId, 1), nil)
+	assert.Nil(err)
+	mockDB.AssertNotCalled(t, "ExecContext", txCtx, completeJob, []interface{}{taskId, orderId, Body(nil)})
+}
+
+func TestJobRepo_CompleteJob_StaleAttempt(t *testing.T) {
+	const (
+		op      = "JobRepo.CompleteJob"
+		taskId  = "1"
+		orderId = "2"
+	)
+	assert := assert.New(t)
+
+	mockDB := new(MockDB)
+	txCtx := WithTransaction(testCtx, mockDB)
+	mockJobState(mockDB, taskId, orderId, JobState{Attempt: 2})
+
+	repo := RDBJobRepo{}
+	err := repo.CompleteJob(txCtx, taskId, orderId, domain.JobAttemptId(taskId, orderId, 1), nil)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal(op, string(domainErr.Op))
+	assert.Equal(domain.ErrStaleAttempt, domainErr.Code)
+}
+
+func TestJobRepo_CompleteJob_NotFound(t *testing.T) {
+	const (
+		op      = "JobRepo.CompleteJob"
+		taskId  = "1"
+		orderId = "2"
+	)
+	assert := assert.New(t)
+
+	mockDB := new(MockDB)
+	txCtx := WithTransaction(testCtx, mockDB)
+	mockDB.On("GetContext", txCtx, mock.Anything, getJobState, []interface{}{taskId, orderId}).Return(sql.ErrNoRows)
+
+	repo := RDBJobRepo{}
+	err := repo.CompleteJob(txCtx, taskId, orderId, "", nil)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal(op, string(domainErr.Op))
+	assert.Equal(domain.ErrNotFound, domainErr.Code)
+}
+
+func TestJobRepo_CompleteJob_NoTx(t *testing.T) {
+	const op = "JobRepo.CompleteJob"
+	assert := assert.New(t)
+
+	repo := RDBJobRepo{}
+	err := repo.CompleteJob(testCtx, "1", "2", "", nil)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal("there's no active transaction", domainErr.Msg)
+}
+
+func TestJobRepo_ExtendJobLock_Success(t *testing.T) {
+	assert := assert.New(t)
+
+	lockedUntil := time.Now()
+	mockDB := new(MockDB)
+	mockResult := new(MockResult)
+	mockResult.On("RowsAffected").Return(1, nil)
+	mockDB.On("ExecContext", testCtx, extendJobLock, []interface{}{"1", "2", 3, lockedUntil}).Return(mockResult, nil)
+
+	repo := RDBJobRepo{db: mockDB}
+	assert.Nil(repo.ExtendJobLock(testCtx, "1", "2", 3, lockedUntil))
+	mockDB.AssertExpectations(t)
+}
+
+func TestJobRepo_ExtendJobLock_StaleAttempt(t *testing.T) {
+	const op = "JobRepo.ExtendJobLock"
+	assert := assert.New(t)
+
+	lockedUntil := time.Now()
+	mockDB := new(MockDB)
+	mockResult := new(MockResult)
+	mockResult.On("RowsAffected").Return(0, nil)
+	mockDB.On("ExecContext", testCtx, extendJobLock, []interface{}{"1", "2", 3, lockedUntil}).Return(mockResult, nil)
+
+	repo := RDBJobRepo{db: mockDB}
+	err := repo.ExtendJobLock(testCtx, "1", "2", 3, lockedUntil)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal(domain.ErrStaleAttempt, domainErr.Code)
+}
+
+func TestJobRepo_RetryJob_StaleAttempt(t *testing.T) {
+	const op = "JobRepo.RetryJob"
+	assert := assert.New(t)
+
+	startAfter := time.Now()
+	failure := JobFailure{TaskId: "1", OrderId: "2", Attempt: 3, ErrorCode: "APP-0004"}
+	mockDB := new(MockDB)
+	mockResult := new(MockResult)
+	mockResult.On("RowsAffected").Return(0, nil)
+	mockDB.On("ExecContext", testCtx, retryJob, []interface{}{"1", "2", 3, startAfter, "APP-0004", "", Body(nil)}).
+		Return(mockResult, nil)
+
+	repo := RDBJobRepo{db: mockDB}
+	err := repo.RetryJob(testCtx, &failure, startAfter)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal(domain.ErrStaleAttempt, domainErr.Code)
+}
+
+func TestJobRepo_CancelJob_NotFound(t *testing.T) {
+	const op = "JobRepo.CancelJob"
+	assert := assert.New(t)
+
+	mockDB := new(MockDB)
+	mockResult := new(MockResult)
+	mockResult.On("RowsAffected").Return(0, nil)
+	mockDB.On("ExecContext", testCtx, cancelJob, []interface{}{"1", "2", string(domain.ErrCancelled)}).
+		Return(mockResult, nil)
+
+	repo := RDBJobRepo{db: mockDB}
+	err := repo.CancelJob(testCtx, "1", "2")
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal(domain.ErrNotFound, domainErr.Code)
+}
+
+func TestJobRepo_CreateJobs_Batch(t *testing.T) {
+	const orderId = "o1"
+	assert := assert.New(t)
+
+	span, spanCtx := opentracing.StartSpanFromContext(testCtx, "test")
+	defer span.Finish()
+	trace, err := tracing.SpanStrFromContext(spanCtx)
+	assert.Nil(err)
+
+	// Third task waits for both parents
+	mockDB := new(MockDB)
+	txCtx := WithTransaction(spanCtx, mockDB)
+	mockDB.On("ExecContext", txCtx, createJobs+"($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14), "+
+		"($15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)", []interface{}{
+		"p1", "t1", 0, "http://first", false, (*HttpTaskConfig)(nil), orderId, "m1", false, false, 0, 0, 0, trace,
+		"p1", "t2", 0, "http://second", false, (*HttpTaskConfig)(nil), orderId, "m1", false, false, 0, 0, 0, trace,
+	}).Return(nil, nil)
+	mockDB.On("ExecContext", txCtx, createJobs+"($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		[]interface{}{
+			"p1", "t3", 0, "http://third", false, (*HttpTaskConfig)(nil), orderId, "m1", false, false, 0, 2, 0, trace,
+		}).Return(nil, nil)
+
+	process := &Process{
+		Id: "p1",
+		Tasks: []Task{
+			{Id: "t1", Action: "http://first", ReadMappingId: "m1"},
+			{Id: "t2", Action: "http://second", ReadMappingId: "m1"},
+			{Id: "t3", Action: "http://third", ReadMappingId: "m1"},
+		},
+		TaskRelations: []TaskRelation{{ParentId: "t1", ChildId: "t3"}, {ParentId: "t2", ChildId: "t3"}},
+	}
+	repo := RDBJobRepo{db: mockDB, batchSize: 2}
+	assert.Nil(repo.CreateJobs(txCtx, orderId, process))
+	mockDB.AssertExpectations(t)
+}
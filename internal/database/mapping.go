@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
 )
 
 const (
@@ -11,8 +12,14 @@ const (
 	createReadMapping     = `INSERT INTO pp_read_mapping (read_mapping_id, body) VALUES ($1, $2)`
 	getReadMappingById    = `SELECT read_mapping_id, body FROM pp_read_mapping WHERE read_mapping_id = $1`
 	deleteReadMappingById = `DELETE FROM pp_read_mapping WHERE read_mapping_id = $1`
+	getTaskCountByMapping = `SELECT count(*) FROM pp_task WHERE read_mapping_id = $1`
+	getReadMappingPage    = `SELECT read_mapping_id, body FROM pp_read_mapping`
+	getReadMappingCount   = `SELECT count(*) FROM pp_read_mapping`
 )
 
+// Fields of the read mapping sort along with their columns
+var readMappingSortFields = [][2]string{{"id", "read_mapping_id"}}
+
 type ReadMapping struct {
 	Id   string `db:"read_mapping_id"`
 	Body Body   `db:"body"`
@@ -20,9 +27,11 @@ type ReadMapping struct {
 
 type ReadMappingRepo interface {
 	GetAll(ctx context.Context, result *[]ReadMapping) error
+	GetPage(ctx context.Context, query *domain.PageQuery, result *[]ReadMapping, page *domain.Page) error
 	Create(ctx context.Context, order *ReadMapping) error
 	GetById(ctx context.Context, id string, result *ReadMapping) error
 	DeleteById(ctx context.Context, id string) error
+	GetTaskCount(ctx context.Context, id string, result *int) error
 }
 
 type RDBReadMappingRepo struct {
@@ -43,6 +52,44 @@ func (s RDBReadMappingRepo) GetAll(ctx context.Context, result *[]ReadMapping) e
 	return nil
 }
 
+// Page of the read mappings along with the total count of them
+func (s RDBReadMappingRepo) GetPage(ctx context.Context, query *domain.PageQuery, result *[]ReadMapping,
+	page *domain.Page) error {
+
+	const op = "ReadMappingRepo.GetPage"
+
+	sort, err := parsePageSort(query, readMappingSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(query, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	var total int
+	if err := s.db.GetContext(ctx, &total, getReadMappingCount); err != nil {
+		return domain.E(op, "can't count read mappings", err)
+	}
+
+	var q listQuery
+	if cursor != nil {
+		sort.after(&q, "read_mapping_id", cursor.Value, cursor.Id)
+	}
+	limit := pageLimit(query)
+	var rows []ReadMapping
+	selectQuery := getReadMappingPage + q.whereClause() + sort.orderBy("read_mapping_id") + " LIMIT " + q.arg(limit+1)
+	if err := s.db.SelectContext(ctx, &rows, selectQuery, q.args...); err != nil {
+		return domain.E(op, "can't select read mappings", err)
+	}
+	*page = domain.Page{Total: total}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = encodeCursor(sort, rows[limit-1].Id, rows[limit-1].Id)
+	}
+	*result = rows
+	return nil
+}
+
 func (s RDBReadMappingRepo) Create(ctx context.Context, result *ReadMapping) error {
 	const op = "ReadMappingRepo.Create"
 
@@ -52,7 +99,13 @@ func (s RDBReadMappingRepo) Create(ctx context.Context, result *ReadMapping) err
 	}
 	result.Id = id.String()
 
-	if _, err := s.db.ExecContext(ctx, createReadMapping, result.Id, result.Body); err != nil {
+	// Mapping could be created along with the process (e.g. by the definition import)
+	if tx, ok := TransactionFromContext(ctx); ok {
+		_, err = tx.ExecContext(ctx, createReadMapping, result.Id, result.Body)
+	} else {
+		_, err = s.db.ExecContext(ctx, createReadMapping, result.Id, result.Body)
+	}
+	if err != nil {
 		return domain.E(op, err)
 	}
 	return nil
@@ -75,6 +128,9 @@ func (s RDBReadMappingRepo) DeleteById(ctx context.Context, id string) error {
 
 	result, err := s.db.ExecContext(ctx, deleteReadMappingById, id)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return domain.E(op, domain.ErrConflict, fmt.Sprintf("read mapping is used by tasks (%s)", id))
+		}
 		return domain.E(op, err)
 	}
 	if count, _ := result.RowsAffected(); count == 0 {
@@ -82,3 +138,13 @@ func (s RDBReadMappingRepo) DeleteById(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// Number of the tasks which use the mapping
+func (s RDBReadMappingRepo) GetTaskCount(ctx context.Context, id string, result *int) error {
+	const op = "ReadMappingRepo.GetTaskCount"
+
+	if err := s.db.GetContext(ctx, result, getTaskCountByMapping, id); err != nil {
+		return domain.E(op, fmt.Sprintf("can't count tasks (%s)", id), err)
+	}
+	return nil
+}
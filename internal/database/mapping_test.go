@@ -64,6 +64,27 @@ func TestReadMappingRepo_Create_Success(t *testing.T) {
 	assert.Equal(mockBody, readMapping.Body)
 }
 
+func TestReadMappingRepo_Create_Tx(t *testing.T) {
+	assert := assert.New(t)
+
+	readMapping := &ReadMapping{Body: Body{"key1": "val1"}}
+	mockUUID, _ := uuid.NewUUID()
+
+	mockDB := new(MockDB)
+	mockTx := new(MockDB)
+	txCtx := WithTransaction(testCtx, mockTx)
+	mockTx.On("ExecContext", txCtx, createReadMapping,
+		[]interface{}{mockUUID.String(), readMapping.Body}).Return(nil, nil)
+
+	repo := RDBReadMappingRepo{db: mockDB, newUUIDFunc: func() (uuid.UUID, error) {
+		return mockUUID, nil
+	}}
+	err := repo.Create(txCtx, readMapping)
+	assert.Nil(err)
+	mockTx.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "ExecContext")
+}
+
 func TestReadMappingRepo_Create_UUID(t *testing.T) {
 	const op = "ReadMappingRepo.Create"
 
@@ -0,0 +1,628 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memTxContextKey string
+
+const memTxKey memTxContextKey = "memoryTransaction"
+
+type memJobKey struct {
+	taskId  string
+	orderId string
+}
+
+type memJob struct {
+	job      Job
+	children []string // Tasks which depend on the job's task
+	seq      int      // Order of creation
+}
+
+// Tables of the store, the rows are replaced as a whole, so the snapshot is a shallow copy of the maps
+type memData struct {
+	readMappings map[string]ReadMapping
+	processes    map[string]Process
+	orders       map[string]Order
+	jobs         map[memJobKey]memJob
+	seq          int
+}
+
+func (d *memData) clone() *memData {
+	result := &memData{
+		readMappings: make(map[string]ReadMapping, len(d.readMappings)),
+		processes:    make(map[string]Process, len(d.processes)),
+		orders:       make(map[string]Order, len(d.orders)),
+		jobs:         make(map[memJobKey]memJob, len(d.jobs)),
+		seq:          d.seq,
+	}
+	for k, v := range d.readMappings {
+		result.readMappings[k] = v
+	}
+	for k, v := range d.processes {
+		result.processes[k] = v
+	}
+	for k, v := range d.orders {
+		result.orders[k] = v
+	}
+	for k, v := range d.jobs {
+		result.jobs[k] = v
+	}
+	return result
+}
+
+// In-memory storage of the repositories (embedded mode and tests). Transactions are serialized by the lock
+// of the store and rolled back by restoring the snapshot taken at the beginning
+type MemStore struct {
+	mu   sync.RWMutex
+	data *memData
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{data: (&memData{}).clone()}
+}
+
+// Execute function in a transaction of the store, the function joins the transaction which is already active
+func (s *MemStore) ExecTx(ctx context.Context, f domain.TxFunc) error {
+	const op = "Transaction.Exec"
+
+	if s.inTx(ctx) {
+		return f(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	if err := f(context.WithValue(ctx, memTxKey, s)); err != nil {
+		s.data = snapshot
+		return domain.E(op, "transaction has rolled back", err)
+	}
+	return nil
+}
+
+func (s *MemStore) inTx(ctx context.Context) bool {
+	store, ok := ctx.Value(memTxKey).(*MemStore)
+	return ok && store == s
+}
+
+func (s *MemStore) read(ctx context.Context, f func(d *memData) error) error {
+	if !s.inTx(ctx) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return f(s.data)
+}
+
+func (s *MemStore) write(ctx context.Context, f func(d *memData) error) error {
+	if !s.inTx(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return f(s.data)
+}
+
+// Deep copy via json, so the stored rows aren't shared with the callers (like the rows of the database).
+// Target is reset, otherwise unmarshal merges into its maps
+func memCopy(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(to).Elem()
+	target.Set(reflect.Zero(target.Type()))
+	return json.Unmarshal(data, to)
+}
+
+type MemReadMappingRepo struct {
+	store       *MemStore
+	newUUIDFunc NewUUIDFunc
+}
+
+func NewMemReadMappingRepo(store *MemStore, newUUIDFunc NewUUIDFunc) ReadMappingRepo {
+	return &MemReadMappingRepo{store: store, newUUIDFunc: newUUIDFunc}
+}
+
+func (s MemReadMappingRepo) GetAll(ctx context.Context, result *[]ReadMapping) error {
+	const op = "ReadMappingRepo.GetAll"
+
+	return s.store.read(ctx, func(d *memData) error {
+		rows := make([]ReadMapping, 0, len(d.readMappings))
+		for _, mapping := range d.readMappings {
+			rows = append(rows, mapping)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Id < rows[j].Id })
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+// Page of the read mappings along with the total count of them
+func (s MemReadMappingRepo) GetPage(ctx context.Context, query *domain.PageQuery, result *[]ReadMapping,
+	page *domain.Page) error {
+
+	const op = "ReadMappingRepo.GetPage"
+
+	sort, err := parsePageSort(query, readMappingSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(query, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return s.store.read(ctx, func(d *memData) error {
+		var mappings []ReadMapping
+		var keys []memPageRow
+		for _, mapping := range d.readMappings {
+			keys = append(keys, memPageRow{value: mapping.Id, id: mapping.Id, index: len(mappings)})
+			mappings = append(mappings, mapping)
+		}
+		indexes := memPage(keys, sort, cursor, pageLimit(query), page)
+		rows := make([]ReadMapping, len(indexes))
+		for i, index := range indexes {
+			rows[i] = mappings[index]
+		}
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemReadMappingRepo) Create(ctx context.Context, result *ReadMapping) error {
+	const op = "ReadMappingRepo.Create"
+
+	id, err := s.newUUIDFunc()
+	if err != nil {
+		return domain.E(op, "can't generate uuid", err)
+	}
+	result.Id = id.String()
+
+	var mapping ReadMapping
+	if err := memCopy(result, &mapping); err != nil {
+		return domain.E(op, err)
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.readMappings[mapping.Id]; ok {
+			return domain.E(op, fmt.Sprintf("duplicate read mapping (%s)", mapping.Id))
+		}
+		d.readMappings[mapping.Id] = mapping
+		return nil
+	})
+}
+
+func (s MemReadMappingRepo) GetById(ctx context.Context, id string, result *ReadMapping) error {
+	const op = "ReadMappingRepo.GetById"
+
+	return s.store.read(ctx, func(d *memData) error {
+		mapping, ok := d.readMappings[id]
+		if !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if err := memCopy(mapping, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemReadMappingRepo) DeleteById(ctx context.Context, id string) error {
+	const op = "ReadMappingRepo.DeleteById"
+
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.readMappings[id]; !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if memTaskCount(d, id) > 0 {
+			return domain.E(op, domain.ErrConflict, fmt.Sprintf("read mapping is used by tasks (%s)", id))
+		}
+		delete(d.readMappings, id)
+		return nil
+	})
+}
+
+// Number of the tasks which use the mapping
+func (s MemReadMappingRepo) GetTaskCount(ctx context.Context, id string, result *int) error {
+	return s.store.read(ctx, func(d *memData) error {
+		*result = memTaskCount(d, id)
+		return nil
+	})
+}
+
+func memTaskCount(d *memData, readMappingId string) int {
+	count := 0
+	for _, process := range d.processes {
+		for _, task := range process.Tasks {
+			if task.ReadMappingId == readMappingId {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+type MemProcessRepo struct {
+	store       *MemStore
+	newUUIDFunc NewUUIDFunc
+}
+
+func NewMemProcessRepo(store *MemStore, newUUIDFunc NewUUIDFunc) ProcessRepo {
+	return &MemProcessRepo{store: store, newUUIDFunc: newUUIDFunc}
+}
+
+func (s MemProcessRepo) GetAll(ctx context.Context, result *[]Process) error {
+	const op = "ProcessRepo.GetAll"
+
+	return s.store.read(ctx, func(d *memData) error {
+		rows := make([]Process, 0, len(d.processes))
+		for _, process := range d.processes {
+			rows = append(rows, process)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Id < rows[j].Id })
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+// Page of the processes along with the total count of them
+func (s MemProcessRepo) GetPage(ctx context.Context, query *domain.PageQuery, result *[]Process,
+	page *domain.Page) error {
+
+	const op = "ProcessRepo.GetPage"
+
+	sort, err := parsePageSort(query, processSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(query, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return s.store.read(ctx, func(d *memData) error {
+		var processes []Process
+		var keys []memPageRow
+		for _, process := range d.processes {
+			keys = append(keys, memPageRow{value: processSortValue(&process, sort), id: process.Id,
+				index: len(processes)})
+			processes = append(processes, process)
+		}
+		indexes := memPage(keys, sort, cursor, pageLimit(query), page)
+		rows := make([]Process, len(indexes))
+		for i, index := range indexes {
+			rows[i] = processes[index]
+		}
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemProcessRepo) Create(ctx context.Context, process *Process) error {
+	const op = "ProcessRepo.Create"
+
+	if !s.store.inTx(ctx) {
+		return domain.E(op, "there's no active transaction")
+	}
+	id, err := s.newUUIDFunc()
+	if err != nil {
+		return domain.E(op, "can't generate uuid", err)
+	}
+	process.Id = id.String()
+
+	var stored Process
+	if err := memCopy(process, &stored); err != nil {
+		return domain.E(op, err)
+	}
+	for i := range stored.Tasks {
+		stored.Tasks[i].ProcessId = stored.Id
+	}
+	for i := range stored.TaskRelations {
+		stored.TaskRelations[i].ProcessId = stored.Id
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.processes[stored.Id]; ok {
+			return domain.E(op, fmt.Sprintf("duplicate process (%s)", stored.Id))
+		}
+		d.processes[stored.Id] = stored
+		return nil
+	})
+}
+
+func (s MemProcessRepo) GetById(ctx context.Context, id string, result *Process) error {
+	const op = "ProcessRepo.GetById"
+
+	return s.store.read(ctx, func(d *memData) error {
+		process, ok := d.processes[id]
+		if !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if err := memCopy(process, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemProcessRepo) DeleteById(ctx context.Context, id string) error {
+	const op = "ProcessRepo.DeleteById"
+
+	if !s.store.inTx(ctx) {
+		return domain.E(op, "there's no active transaction")
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.processes[id]; !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		delete(d.processes, id)
+
+		// Orders and their jobs are deleted along with the process (ON DELETE CASCADE)
+		deleted := make(map[string]bool)
+		for orderId, order := range d.orders {
+			if order.ProcessId == id {
+				deleted[orderId] = true
+				delete(d.orders, orderId)
+			}
+		}
+		for key := range d.jobs {
+			if deleted[key.orderId] {
+				delete(d.jobs, key)
+			}
+		}
+		return nil
+	})
+}
+
+// Number of the orders of the process which have jobs neither completed nor failed
+func (s MemProcessRepo) GetRunningOrderCount(ctx context.Context, id string, result *int) error {
+	return s.store.read(ctx, func(d *memData) error {
+		running := make(map[string]bool)
+		for _, job := range d.jobs {
+			if order, ok := d.orders[job.job.OrderId]; ok && order.ProcessId == id && !job.job.Completed &&
+				!job.job.Failed {
+
+				running[order.Id] = true
+			}
+		}
+		*result = len(running)
+		return nil
+	})
+}
+
+type MemOrderRepo struct {
+	store       *MemStore
+	newUUIDFunc NewUUIDFunc
+}
+
+func NewMemOrderRepo(store *MemStore, newUUIDFunc NewUUIDFunc) OrderRepo {
+	return &MemOrderRepo{store: store, newUUIDFunc: newUUIDFunc}
+}
+
+func (s MemOrderRepo) GetAll(ctx context.Context, result *[]Order) error {
+	const op = "OrderRepo.GetAll"
+
+	return s.store.read(ctx, func(d *memData) error {
+		rows := make([]Order, 0, len(d.orders))
+		for _, order := range d.orders {
+			rows = append(rows, order)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Id < rows[j].Id })
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemOrderRepo) CountByProcess(ctx context.Context, result *map[string]int) error {
+	return s.store.read(ctx, func(d *memData) error {
+		*result = make(map[string]int)
+		for _, order := range d.orders {
+			(*result)[order.ProcessId]++
+		}
+		return nil
+	})
+}
+
+// Page of the orders matched by the query along with the total count of them
+func (s MemOrderRepo) GetPage(ctx context.Context, query *domain.OrderQuery, result *[]Order,
+	page *domain.Page) error {
+
+	const op = "OrderRepo.GetPage"
+
+	sort, err := parsePageSort(&query.PageQuery, orderSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(&query.PageQuery, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	if err := checkOrderStatus(query.Status); err != nil {
+		return domain.E(op, err)
+	}
+	return s.store.read(ctx, func(d *memData) error {
+		statuses := memOrderStatuses(d)
+		var orders []Order
+		var keys []memPageRow
+		for _, order := range d.orders {
+			if memOrderMatches(&order, query, statuses[order.Id]) {
+				keys = append(keys, memPageRow{value: orderSortValue(&order, sort), id: order.Id, index: len(orders)})
+				orders = append(orders, order)
+			}
+		}
+		indexes := memPage(keys, sort, cursor, pageLimit(&query.PageQuery), page)
+		rows := make([]Order, len(indexes))
+		for i, index := range indexes {
+			rows[i] = orders[index]
+		}
+		if err := memCopy(rows, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+// Statuses of the orders by their jobs, failed orders have no running jobs
+func memOrderStatuses(d *memData) map[string]string {
+	running := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, job := range d.jobs {
+		switch {
+		case job.job.Failed:
+			failed[job.job.OrderId] = true
+		case !job.job.Completed:
+			running[job.job.OrderId] = true
+		}
+	}
+	result := make(map[string]string, len(d.orders))
+	for id := range d.orders {
+		switch {
+		case running[id]:
+			result[id] = domain.OrderRunning
+		case failed[id]:
+			result[id] = domain.OrderFailed
+		default:
+			result[id] = domain.OrderCompleted
+		}
+	}
+	return result
+}
+
+func memOrderMatches(order *Order, query *domain.OrderQuery, status string) bool {
+	if query.ProcessId != "" && order.ProcessId != query.ProcessId ||
+		query.Status != "" && status != query.Status {
+
+		return false
+	}
+	if query.CreatedFrom != nil && (order.CreatedAt == nil || order.CreatedAt.Before(*query.CreatedFrom)) ||
+		query.CreatedTo != nil && (order.CreatedAt == nil || !order.CreatedAt.Before(*query.CreatedTo)) {
+
+		return false
+	}
+	for key, value := range query.Body {
+		field, ok := order.Body[key]
+		if !ok || field == nil {
+			return false
+		}
+		text, isString := field.(string)
+		if !isString {
+			data, _ := json.Marshal(field)
+			text = string(data)
+		}
+		if text != value {
+			return false
+		}
+	}
+	if len(query.Contains) > 0 {
+		// Values are compared as the decoded JSON ones (numbers are float64)
+		body, _ := json.Marshal(order.Body)
+		for _, doc := range query.Contains {
+			data, _ := json.Marshal(doc)
+			if !jsonDocContains(body, data) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s MemOrderRepo) Create(ctx context.Context, obj *Order) error {
+	const op = "OrderRepo.Create"
+
+	id, err := s.newUUIDFunc()
+	if err != nil {
+		return domain.E(op, "can't generate uuid", err)
+	}
+	obj.Id = id.String()
+	createdAt := time.Now().UTC()
+	obj.CreatedAt = &createdAt
+
+	var order Order
+	if err := memCopy(obj, &order); err != nil {
+		return domain.E(op, fmt.Errorf("can't create order (%s)", obj.ProcessId), err)
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.orders[order.Id]; ok {
+			return domain.E(op, fmt.Sprintf("duplicate order (%s)", order.Id))
+		}
+		if order.IdempotencyKey != nil && memOrderByIdempotencyKey(d, order.ProcessId, *order.IdempotencyKey) != nil {
+			return domain.E(op, domain.ErrConflict, fmt.Sprintf("duplicate idempotency key (%s)", order.ProcessId))
+		}
+		d.orders[order.Id] = order
+		return nil
+	})
+}
+
+func (s MemOrderRepo) GetByIdempotencyKey(ctx context.Context, processId, key string, result *Order) error {
+	const op = "OrderRepo.GetByIdempotencyKey"
+
+	return s.store.read(ctx, func(d *memData) error {
+		order := memOrderByIdempotencyKey(d, processId, key)
+		if order == nil {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if err := memCopy(order, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+func (s MemOrderRepo) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	return s.store.write(ctx, func(d *memData) error {
+		if order, ok := d.orders[id]; ok {
+			order.IdempotencyKey = nil
+			d.orders[id] = order
+		}
+		return nil
+	})
+}
+
+func memOrderByIdempotencyKey(d *memData, processId, key string) *Order {
+	for _, order := range d.orders {
+		if order.ProcessId == processId && order.IdempotencyKey != nil && *order.IdempotencyKey == key {
+			return &order
+		}
+	}
+	return nil
+}
+
+func (s MemOrderRepo) GetById(ctx context.Context, id string, result *Order) error {
+	const op = "OrderRepo.GetById"
+
+	return s.store.read(ctx, func(d *memData) error {
+		order, ok := d.orders[id]
+		if !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if err := memCopy(order, result); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+// Delete order by Id
+func (s MemOrderRepo) DeleteById(ctx context.Context, id string) error {
+	const op = "OrderRepo.DeleteById"
+
+	return s.store.write(ctx, func(d *memData) error {
+		if _, ok := d.orders[id]; !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		delete(d.orders, id)
+		return nil
+	})
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/internal/tracing"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// JobRepo of the in-memory store, conditions of the updates follow the statements of RDBJobRepo
+type MemJobRepo struct {
+	store *MemStore
+}
+
+func NewMemJobRepo(store *MemStore) JobRepo {
+	return &MemJobRepo{store: store}
+}
+
+func (s MemJobRepo) CreateJobs(ctx context.Context, orderId string, process *Process) error {
+	const op = "JobRepo.CreateJobs"
+
+	if !s.store.inTx(ctx) {
+		return domain.E(op, "there's no active transaction")
+	}
+	jobTraceStr, err := tracing.SpanStrFromContext(ctx)
+	if err != nil {
+		return domain.E(op, "can't get span string", err)
+	}
+	readyRequired := process.ReadyRequired()
+	children := make(map[string][]string, len(process.Tasks))
+	for _, relation := range process.TaskRelations {
+		children[relation.ParentId] = append(children[relation.ParentId], relation.ChildId)
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		for _, task := range process.Tasks {
+			key := memJobKey{taskId: task.Id, orderId: orderId}
+			if _, ok := d.jobs[key]; ok {
+				return domain.E(op, fmt.Sprintf("can't create job (%s)", task.Id), "duplicate job")
+			}
+			job := memJob{job: Job{
+				TaskId:        task.Id,
+				Category:      task.Category,
+				Action:        task.Action,
+				Sync:          task.Sync,
+				OrderId:       orderId,
+				ReadMappingId: task.ReadMappingId,
+				ReadyReq:      readyRequired[task.Id],
+				Trace:         jobTraceStr,
+			}, children: children[task.Id]}
+			if task.Http != nil {
+				if err := memCopy(task.Http, &job.job.Http); err != nil {
+					return domain.E(op, fmt.Sprintf("can't create job (%s)", task.Id), err)
+				}
+			}
+			d.seq++
+			job.seq = d.seq
+			d.jobs[key] = job
+		}
+		return nil
+	})
+}
+
+func (s MemJobRepo) GetReadyJobs(ctx context.Context, jobLimit int, jobs *[]Job) error {
+	const op = "JobRepo.GetReadyJobs"
+
+	now := time.Now()
+	return s.updateJobs(ctx, op, jobLimit, jobs, func(job *Job) bool {
+		if job.ReadyNum < job.ReadyReq || job.Started || job.Failed || job.Category == domain.ExternalTaskCategory ||
+			job.StartAfter != nil && job.StartAfter.After(now) {
+
+			return false
+		}
+		job.Started = true
+		job.Attempt++
+		return true
+	})
+}
+
+func (s MemJobRepo) GetByOrderId(ctx context.Context, orderId string, jobs *[]Job) error {
+	const op = "JobRepo.GetByOrderId"
+
+	return s.store.read(ctx, func(d *memData) error {
+		var rows []Job
+		for _, job := range sortedJobs(d) {
+			if job.job.OrderId == orderId {
+				rows = append(rows, job.job)
+			}
+		}
+		if err := memCopy(rows, jobs); err != nil {
+			return domain.E(op, fmt.Sprintf("can't select jobs (%s)", orderId), err)
+		}
+		return nil
+	})
+}
+
+// Complete job of the given attempt, empty attempt id matches any attempt.
+// Duplicate completion of the same attempt is acknowledged without any changes,
+// completion of a stale attempt is rejected with ErrStaleAttempt and completion of a cancelled job with ErrCancelled
+func (s MemJobRepo) CompleteJob(ctx context.Context, taskId, orderId, attemptId string, output Body) error {
+	const op = "JobRepo.CompleteJob"
+
+	if !s.store.inTx(ctx) {
+		return domain.E(op, "there's no active transaction")
+	}
+	var stored Body
+	if err := memCopy(output, &stored); err != nil {
+		return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", taskId, orderId), err)
+	}
+	return s.store.write(ctx, func(d *memData) error {
+		key := memJobKey{taskId: taskId, orderId: orderId}
+		job, ok := d.jobs[key]
+		if !ok {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		if attemptId != "" && attemptId != domain.JobAttemptId(taskId, orderId, job.job.Attempt) {
+			return domain.E(op, domain.ErrStaleAttempt, fmt.Sprintf("stale attempt (%s)", attemptId))
+		}
+		if job.job.Completed {
+			return nil
+		}
+		state := JobState{Failed: job.job.Failed, ErrorCode: job.job.ErrorCode}
+		if state.cancelled() {
+			return domain.E(op, domain.ErrCancelled, fmt.Sprintf("job is cancelled (%s, %s)", taskId, orderId))
+		}
+		job.job.Completed = true
+		job.job.Failed = false
+		job.job.Output = stored
+		d.jobs[key] = job
+
+		for _, childId := range job.children {
+			childKey := memJobKey{taskId: childId, orderId: orderId}
+			if child, ok := d.jobs[childKey]; ok && !child.job.Completed {
+				child.job.ReadyNum++
+				d.jobs[childKey] = child
+			}
+		}
+		return nil
+	})
+}
+
+// Reschedule failed attempt of the job, ErrStaleAttempt is returned if the job has been already restarted
+// or completed
+func (s MemJobRepo) RetryJob(ctx context.Context, failure *JobFailure, startAfter time.Time) error {
+	const op = "JobRepo.RetryJob"
+
+	var output Body
+	if err := memCopy(failure.Output, &output); err != nil {
+		return domain.E(op, fmt.Sprintf("can't retry job (%s, %s)", failure.TaskId, failure.OrderId), err)
+	}
+	return s.updateAttempt(ctx, op, failure.TaskId, failure.OrderId, failure.Attempt, func(job *Job) {
+		job.Started = false
+		job.StartAfter = &startAfter
+		job.ErrorCode = failure.ErrorCode
+		job.Response = failure.Response
+		job.Output = output
+	})
+}
+
+// Reschedule attempt of the job which hasn't been started, so the attempt isn't counted
+func (s MemJobRepo) DeferJob(ctx context.Context, taskId, orderId string, attempt int, startAfter time.Time) error {
+	const op = "JobRepo.DeferJob"
+
+	err := s.updateAttempt(ctx, op, taskId, orderId, attempt, func(job *Job) {
+		job.Started = false
+		job.Attempt--
+		job.StartAfter = &startAfter
+	})
+	if err != nil && domain.ECode(err) != domain.ErrStaleAttempt {
+		return err
+	}
+	return nil
+}
+
+// Mark the job as failed, it won't be started again
+func (s MemJobRepo) FailJob(ctx context.Context, failure *JobFailure) error {
+	const op = "JobRepo.FailJob"
+
+	return s.updateAttempt(ctx, op, failure.TaskId, failure.OrderId, failure.Attempt, func(job *Job) {
+		job.Failed = true
+		job.ErrorCode = failure.ErrorCode
+		job.Response = failure.Response
+	})
+}
+
+// Lock ready jobs of external tasks with the given topics (actions), expired locks are taken over
+func (s MemJobRepo) FetchJobs(ctx context.Context, topics []string, jobLimit int, lockedUntil time.Time,
+	jobs *[]Job) error {
+
+	const op = "JobRepo.FetchJobs"
+
+	actions := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		actions[topic] = true
+	}
+	now := time.Now()
+	return s.updateJobs(ctx, op, jobLimit, jobs, func(job *Job) bool {
+		if job.Category != domain.ExternalTaskCategory || !actions[job.Action] || job.ReadyNum < job.ReadyReq ||
+			job.Completed || job.Failed || job.Started && (job.LockedUntil == nil || !job.LockedUntil.Before(now)) ||
+			job.StartAfter != nil && job.StartAfter.After(now) {
+
+			return false
+		}
+		job.Started = true
+		job.Attempt++
+		job.LockedUntil = &lockedUntil
+		return true
+	})
+}
+
+// Extend lock of the external job, ErrStaleAttempt is returned if the lock has been taken over
+func (s MemJobRepo) ExtendJobLock(ctx context.Context, taskId, orderId string, attempt int,
+	lockedUntil time.Time) error {
+
+	const op = "JobRepo.ExtendJobLock"
+
+	return s.store.write(ctx, func(d *memData) error {
+		key := memJobKey{taskId: taskId, orderId: orderId}
+		job, ok := d.jobs[key]
+		if !ok || job.job.Completed || job.job.Failed || !job.job.Started || job.job.Attempt != attempt {
+			return staleAttempt(op, taskId, orderId, attempt)
+		}
+		job.job.LockedUntil = &lockedUntil
+		d.jobs[key] = job
+		return nil
+	})
+}
+
+// Make the job (which isn't completed) ready to start from the first attempt, ErrNotFound is returned if there's
+// no such job
+func (s MemJobRepo) ResetJob(ctx context.Context, taskId, orderId string) error {
+	const op = "JobRepo.ResetJob"
+
+	return s.updateJob(ctx, op, taskId, orderId, func(job *Job) {
+		job.Started = false
+		job.Failed = false
+		job.Attempt = 0
+		job.StartAfter = nil
+		job.LockedUntil = nil
+		job.Output = nil
+	})
+}
+
+// Fail the job (which isn't completed) with ErrCancelled code, ErrNotFound is returned if there's no such job
+func (s MemJobRepo) CancelJob(ctx context.Context, taskId, orderId string) error {
+	const op = "JobRepo.CancelJob"
+
+	return s.updateJob(ctx, op, taskId, orderId, func(job *Job) {
+		job.Failed = true
+		job.ErrorCode = string(domain.ErrCancelled)
+	})
+}
+
+// Update up to the limit of the jobs (in the order of creation) accepted by the function, updated jobs are returned
+func (s MemJobRepo) updateJobs(ctx context.Context, op domain.ErrOp, jobLimit int, jobs *[]Job,
+	f func(job *Job) bool) error {
+
+	return s.store.write(ctx, func(d *memData) error {
+		var rows []Job
+		for _, job := range sortedJobs(d) {
+			if len(rows) >= jobLimit {
+				break
+			}
+			if f(&job.job) {
+				d.jobs[memJobKey{taskId: job.job.TaskId, orderId: job.job.OrderId}] = job
+				rows = append(rows, job.job)
+			}
+		}
+		if err := memCopy(rows, jobs); err != nil {
+			return domain.E(op, err)
+		}
+		return nil
+	})
+}
+
+// Update the job which isn't completed, ErrNotFound is returned otherwise
+func (s MemJobRepo) updateJob(ctx context.Context, op domain.ErrOp, taskId, orderId string, f func(job *Job)) error {
+	return s.store.write(ctx, func(d *memData) error {
+		key := memJobKey{taskId: taskId, orderId: orderId}
+		job, ok := d.jobs[key]
+		if !ok || job.job.Completed {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		f(&job.job)
+		d.jobs[key] = job
+		return nil
+	})
+}
+
+// Update the given attempt of the job which isn't completed, ErrStaleAttempt is returned otherwise
+func (s MemJobRepo) updateAttempt(ctx context.Context, op domain.ErrOp, taskId, orderId string, attempt int,
+	f func(job *Job)) error {
+
+	return s.store.write(ctx, func(d *memData) error {
+		key := memJobKey{taskId: taskId, orderId: orderId}
+		job, ok := d.jobs[key]
+		if !ok || job.job.Completed || job.job.Attempt != attempt {
+			return staleAttempt(op, taskId, orderId, attempt)
+		}
+		f(&job.job)
+		d.jobs[key] = job
+		return nil
+	})
+}
+
+func sortedJobs(d *memData) []memJob {
+	result := make([]memJob, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		result = append(result, job)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].seq < result[j].seq })
+	return result
+}
+
+func staleAttempt(op domain.ErrOp, taskId, orderId string, attempt int) error {
+	return domain.E(op, domain.ErrStaleAttempt,
+		fmt.Sprintf("stale attempt (%s)", domain.JobAttemptId(taskId, orderId, attempt)))
+}
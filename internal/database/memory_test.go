@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemStore_NoTransaction(t *testing.T) {
+	assert := assert.New(t)
+	store := NewMemStore()
+
+	assert.NotNil(NewMemProcessRepo(store, uuid.NewUUID).DeleteById(context.Background(), "p1"))
+	assert.NotNil(NewMemJobRepo(store).CompleteJob(context.Background(), "t1", "o1", "", nil))
+}
+
+func TestMemReadMappingRepo_Copy(t *testing.T) {
+	assert := assert.New(t)
+	repo := NewMemReadMappingRepo(NewMemStore(), uuid.NewUUID)
+
+	mapping := &ReadMapping{Body: Body{"id": "$.id"}}
+	assert.Nil(repo.Create(context.Background(), mapping))
+
+	// Stored mapping isn't shared with the caller
+	mapping.Body["id"] = "$.other"
+	var result ReadMapping
+	assert.Nil(repo.GetById(context.Background(), mapping.Id, &result))
+	assert.Equal(Body{"id": "$.id"}, result.Body)
+	result.Body["name"] = "$.name"
+	assert.Nil(repo.GetById(context.Background(), mapping.Id, &result))
+	assert.Equal(Body{"id": "$.id"}, result.Body)
+	assert.Equal(domain.ErrNotFound, domain.ECode(repo.GetById(context.Background(), "m2", &result)))
+}
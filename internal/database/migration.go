@@ -0,0 +1,661 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"time"
+)
+
+// Schema migration, statements of Up (Down) are executed in a transaction along with the insert (delete)
+// of the version in pp_schema_version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migration of the current schema (or the one which isn't known), AppliedAt is nil if it's pending
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+const (
+	createSchemaVersion = `CREATE TABLE IF NOT EXISTS pp_schema_version
+(
+    version integer NOT NULL,
+    name varchar(255) NOT NULL,
+    applied_at timestamp NOT NULL,
+    CONSTRAINT pp_schema_version_pkey PRIMARY KEY (version)
+)`
+	getSchemaVersions   = "SELECT version, name, applied_at FROM pp_schema_version ORDER BY version"
+	insertSchemaVersion = "INSERT INTO pp_schema_version (version, name, applied_at) VALUES ($1, $2, $3)"
+	deleteSchemaVersion = "DELETE FROM pp_schema_version WHERE version = $1"
+
+	// Migrations of replicas are serialized by the session-level advisory lock of Postgres
+	migrationLockId = 8342001
+	lockMigrations  = "SELECT pg_advisory_lock($1)"
+	unlockMigration = "SELECT pg_advisory_unlock($1)"
+)
+
+// Initial schema is the one of the former config/database.sql, tables are created if they don't exist, so the
+// databases which were created by the script get the first version without any changes
+const (
+	postgresSchemaV1 = `
+-- Process
+CREATE TABLE IF NOT EXISTS pp_process
+(
+    process_id uuid NOT NULL,
+    name varchar(255) NOT NULL,
+    CONSTRAINT pp_process_pkey PRIMARY KEY (process_id)
+);
+
+-- Task
+CREATE TABLE IF NOT EXISTS pp_task
+(
+    process_id uuid NOT NULL,
+    task_id uuid NOT NULL,
+    name varchar(255) NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    read_mapping_id uuid NOT NULL,
+    CONSTRAINT pp_task_pkey PRIMARY KEY (task_id)
+);
+CREATE INDEX IF NOT EXISTS pp_task_1 ON pp_task(process_id);
+
+-- Task relation
+CREATE TABLE IF NOT EXISTS pp_task_rel
+(
+    process_id uuid NOT NULL,
+    parent_id uuid NOT NULL,
+    child_id uuid NOT NULL,
+    CONSTRAINT pp_task_rel_pkey PRIMARY KEY (parent_id, child_id)
+);
+CREATE INDEX IF NOT EXISTS pp_task_rel_1 ON pp_task_rel(process_id);
+
+-- Read mapping
+CREATE TABLE IF NOT EXISTS pp_read_mapping
+(
+    read_mapping_id uuid NOT NULL,
+    body jsonb,
+    CONSTRAINT pp_read_mapping_pkey PRIMARY KEY (read_mapping_id)
+);
+
+-- Order
+CREATE TABLE IF NOT EXISTS pp_order
+(
+    order_id uuid NOT NULL,
+    process_id uuid NOT NULL,
+    body jsonb,
+    CONSTRAINT pp_order_pkey PRIMARY KEY (order_id)
+);
+
+-- Job
+CREATE TABLE IF NOT EXISTS pp_job
+(
+    process_id uuid NOT NULL,
+    task_id uuid NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    order_id uuid NOT NULL,
+    read_mapping_id uuid NOT NULL,
+    started boolean NOT NULL,
+    completed boolean NOT NULL,
+    ready_num integer NOT NULL,
+    ready_req integer NOT NULL,
+    trace varchar(510) NOT NULL,
+    CONSTRAINT pp_job_pkey PRIMARY KEY (task_id, order_id)
+);
+CREATE INDEX IF NOT EXISTS pp_job_1 ON pp_job(task_id, order_id, completed);
+CREATE INDEX IF NOT EXISTS pp_job_2 ON pp_job(ready_num, ready_req, started, task_id, order_id);`
+	sqliteSchemaV1 = `CREATE TABLE IF NOT EXISTS pp_process
+(` + sqliteProcessColumns + `
+);
+CREATE TABLE IF NOT EXISTS pp_task
+(` + sqliteTaskColumnsV1 + `
+);
+CREATE INDEX IF NOT EXISTS pp_task_1 ON pp_task(process_id);
+CREATE TABLE IF NOT EXISTS pp_task_rel
+(` + sqliteTaskRelColumns + `
+);
+CREATE INDEX IF NOT EXISTS pp_task_rel_1 ON pp_task_rel(process_id);
+CREATE TABLE IF NOT EXISTS pp_read_mapping
+(
+    read_mapping_id text NOT NULL,
+    body blob,
+    CONSTRAINT pp_read_mapping_pkey PRIMARY KEY (read_mapping_id)
+);
+CREATE TABLE IF NOT EXISTS pp_order
+(` + sqliteOrderColumns + `
+);
+CREATE TABLE IF NOT EXISTS pp_job
+(` + sqliteJobColumnsV1 + `
+);` + sqliteJobIndexesV1
+	dropSchemaV1 = `
+DROP TABLE IF EXISTS pp_job;
+DROP TABLE IF EXISTS pp_order;
+DROP TABLE IF EXISTS pp_read_mapping;
+DROP TABLE IF EXISTS pp_task_rel;
+DROP TABLE IF EXISTS pp_task;
+DROP TABLE IF EXISTS pp_process;`
+	sqliteTaskColumnsV1 = `
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    read_mapping_id text NOT NULL,
+    CONSTRAINT pp_task_pkey PRIMARY KEY (task_id)`
+	sqliteJobColumnsV1 = `
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    order_id text NOT NULL,
+    read_mapping_id text NOT NULL,
+    started boolean NOT NULL,
+    completed boolean NOT NULL,
+    ready_num integer NOT NULL,
+    ready_req integer NOT NULL,
+    trace varchar(510) NOT NULL,
+    CONSTRAINT pp_job_pkey PRIMARY KEY (task_id, order_id)`
+	sqliteJobIndexesV1 = `
+CREATE INDEX IF NOT EXISTS pp_job_1 ON pp_job(task_id, order_id, completed);
+CREATE INDEX IF NOT EXISTS pp_job_2 ON pp_job(ready_num, ready_req, started, task_id, order_id);`
+)
+
+// Columns of the attempts, failures, synchronous completions, HTTP requests and locks of the jobs. Columns are
+// added if they don't exist, so the databases which were created by the later versions of the script get
+// the second version without any changes. SQLite appends the columns, so the rebuilds select them by name
+const (
+	postgresSchemaV2 = `
+ALTER TABLE pp_task ADD COLUMN IF NOT EXISTS sync boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_task ADD COLUMN IF NOT EXISTS http jsonb;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS sync boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS http jsonb;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS attempt integer NOT NULL DEFAULT 0;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS failed boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS start_after timestamp;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS error_code varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS response text NOT NULL DEFAULT '';
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS output jsonb;
+ALTER TABLE pp_job ADD COLUMN IF NOT EXISTS locked_until timestamp;
+CREATE INDEX IF NOT EXISTS pp_job_3 ON pp_job(order_id);`
+	postgresDropSchemaV2 = `
+DROP INDEX IF EXISTS pp_job_3;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS output;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS response;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS error_code;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS start_after;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS failed;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS attempt;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS http;
+ALTER TABLE pp_job DROP COLUMN IF EXISTS sync;
+ALTER TABLE pp_task DROP COLUMN IF EXISTS http;
+ALTER TABLE pp_task DROP COLUMN IF EXISTS sync;`
+	sqliteSchemaV2 = `
+ALTER TABLE pp_task ADD COLUMN sync boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_task ADD COLUMN http blob;
+ALTER TABLE pp_job ADD COLUMN sync boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_job ADD COLUMN http blob;
+ALTER TABLE pp_job ADD COLUMN attempt integer NOT NULL DEFAULT 0;
+ALTER TABLE pp_job ADD COLUMN failed boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE pp_job ADD COLUMN start_after timestamp;
+ALTER TABLE pp_job ADD COLUMN error_code varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE pp_job ADD COLUMN response text NOT NULL DEFAULT '';
+ALTER TABLE pp_job ADD COLUMN output blob;
+ALTER TABLE pp_job ADD COLUMN locked_until timestamp;
+CREATE INDEX pp_job_3 ON pp_job(order_id);`
+	sqliteTaskSelect = "process_id, task_id, name, category, action, sync, http, read_mapping_id"
+	sqliteJobSelect  = "process_id, task_id, category, action, sync, http, order_id, read_mapping_id, started, " +
+		"completed, ready_num, ready_req, attempt, failed, start_after, error_code, response, output, locked_until, trace"
+)
+
+var sqliteDropSchemaV2 = sqliteRebuildSelect("pp_task", sqliteTaskColumnsV1,
+	"process_id, task_id, name, category, action, read_mapping_id", "CREATE INDEX pp_task_1 ON pp_task(process_id);") +
+	sqliteRebuildSelect("pp_job", sqliteJobColumnsV1, "process_id, task_id, category, action, order_id, "+
+		"read_mapping_id, started, completed, ready_num, ready_req, trace", sqliteJobIndexesV1)
+
+// Foreign keys of Postgres don't validate the existing rows (NOT VALID), the orphans are reported and repaired
+// by the consistency check. Tasks, relations, orders and jobs are deleted along with the process, mapping which
+// is used by tasks can't be deleted
+const (
+	postgresSchemaV3 = `
+ALTER TABLE pp_task ADD CONSTRAINT pp_task_process_fkey FOREIGN KEY (process_id)
+    REFERENCES pp_process (process_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_task ADD CONSTRAINT pp_task_read_mapping_fkey FOREIGN KEY (read_mapping_id)
+    REFERENCES pp_read_mapping (read_mapping_id) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE pp_task_rel ADD CONSTRAINT pp_task_rel_process_fkey FOREIGN KEY (process_id)
+    REFERENCES pp_process (process_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_task_rel ADD CONSTRAINT pp_task_rel_parent_fkey FOREIGN KEY (parent_id)
+    REFERENCES pp_task (task_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_task_rel ADD CONSTRAINT pp_task_rel_child_fkey FOREIGN KEY (child_id)
+    REFERENCES pp_task (task_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_order ADD CONSTRAINT pp_order_process_fkey FOREIGN KEY (process_id)
+    REFERENCES pp_process (process_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_job ADD CONSTRAINT pp_job_order_fkey FOREIGN KEY (order_id)
+    REFERENCES pp_order (order_id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE pp_job ADD CONSTRAINT pp_job_task_fkey FOREIGN KEY (task_id)
+    REFERENCES pp_task (task_id) ON DELETE CASCADE NOT VALID;
+CREATE INDEX IF NOT EXISTS pp_task_2 ON pp_task(read_mapping_id);
+CREATE INDEX IF NOT EXISTS pp_task_rel_2 ON pp_task_rel(child_id);
+CREATE INDEX IF NOT EXISTS pp_order_1 ON pp_order(process_id);`
+	postgresDropSchemaV3 = `
+DROP INDEX IF EXISTS pp_order_1;
+DROP INDEX IF EXISTS pp_task_rel_2;
+DROP INDEX IF EXISTS pp_task_2;
+ALTER TABLE pp_job DROP CONSTRAINT IF EXISTS pp_job_task_fkey;
+ALTER TABLE pp_job DROP CONSTRAINT IF EXISTS pp_job_order_fkey;
+ALTER TABLE pp_order DROP CONSTRAINT IF EXISTS pp_order_process_fkey;
+ALTER TABLE pp_task_rel DROP CONSTRAINT IF EXISTS pp_task_rel_child_fkey;
+ALTER TABLE pp_task_rel DROP CONSTRAINT IF EXISTS pp_task_rel_parent_fkey;
+ALTER TABLE pp_task_rel DROP CONSTRAINT IF EXISTS pp_task_rel_process_fkey;
+ALTER TABLE pp_task DROP CONSTRAINT IF EXISTS pp_task_read_mapping_fkey;
+ALTER TABLE pp_task DROP CONSTRAINT IF EXISTS pp_task_process_fkey;`
+)
+
+// SQLite can't add constraints to the existing tables, so the tables are rebuilt (foreign keys are disabled
+// during the migrations, so the orphans are kept as they are)
+const (
+	sqliteTaskColumns = `
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    sync boolean NOT NULL DEFAULT FALSE,
+    http blob,
+    read_mapping_id text NOT NULL,
+    CONSTRAINT pp_task_pkey PRIMARY KEY (task_id)`
+	sqliteTaskForeignKeys = `,
+    CONSTRAINT pp_task_process_fkey FOREIGN KEY (process_id)
+        REFERENCES pp_process (process_id) ON DELETE CASCADE,
+    CONSTRAINT pp_task_read_mapping_fkey FOREIGN KEY (read_mapping_id)
+        REFERENCES pp_read_mapping (read_mapping_id) ON DELETE RESTRICT`
+	sqliteTaskRelColumns = `
+    process_id text NOT NULL,
+    parent_id text NOT NULL,
+    child_id text NOT NULL,
+    CONSTRAINT pp_task_rel_pkey PRIMARY KEY (parent_id, child_id)`
+	sqliteTaskRelForeignKeys = `,
+    CONSTRAINT pp_task_rel_process_fkey FOREIGN KEY (process_id)
+        REFERENCES pp_process (process_id) ON DELETE CASCADE,
+    CONSTRAINT pp_task_rel_parent_fkey FOREIGN KEY (parent_id) REFERENCES pp_task (task_id) ON DELETE CASCADE,
+    CONSTRAINT pp_task_rel_child_fkey FOREIGN KEY (child_id) REFERENCES pp_task (task_id) ON DELETE CASCADE`
+	sqliteOrderColumns = `
+    order_id text NOT NULL,
+    process_id text NOT NULL,
+    body blob,
+    CONSTRAINT pp_order_pkey PRIMARY KEY (order_id)`
+	sqliteOrderForeignKeys = `,
+    CONSTRAINT pp_order_process_fkey FOREIGN KEY (process_id)
+        REFERENCES pp_process (process_id) ON DELETE CASCADE`
+	sqliteJobColumns = `
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    sync boolean NOT NULL DEFAULT FALSE,
+    http blob,
+    order_id text NOT NULL,
+    read_mapping_id text NOT NULL,
+    started boolean NOT NULL,
+    completed boolean NOT NULL,
+    ready_num integer NOT NULL,
+    ready_req integer NOT NULL,
+    attempt integer NOT NULL DEFAULT 0,
+    failed boolean NOT NULL DEFAULT FALSE,
+    start_after timestamp,
+    error_code varchar(32) NOT NULL DEFAULT '',
+    response text NOT NULL DEFAULT '',
+    output blob,
+    locked_until timestamp,
+    trace varchar(510) NOT NULL,
+    CONSTRAINT pp_job_pkey PRIMARY KEY (task_id, order_id)`
+	sqliteJobForeignKeys = `,
+    CONSTRAINT pp_job_order_fkey FOREIGN KEY (order_id) REFERENCES pp_order (order_id) ON DELETE CASCADE,
+    CONSTRAINT pp_job_task_fkey FOREIGN KEY (task_id) REFERENCES pp_task (task_id) ON DELETE CASCADE`
+
+	sqliteTaskIndexes    = "CREATE INDEX pp_task_1 ON pp_task(process_id);"
+	sqliteTaskRelIndexes = "CREATE INDEX pp_task_rel_1 ON pp_task_rel(process_id);"
+	sqliteJobIndexes     = `CREATE INDEX pp_job_2 ON pp_job(ready_num, ready_req, started, task_id, order_id);
+CREATE INDEX pp_job_3 ON pp_job(order_id);`
+	sqliteIndexesV3 = `
+CREATE INDEX pp_task_2 ON pp_task(read_mapping_id);
+CREATE INDEX pp_task_rel_2 ON pp_task_rel(child_id);
+CREATE INDEX pp_order_1 ON pp_order(process_id);`
+)
+
+var (
+	sqliteSchemaV3 = sqliteRebuildSelect("pp_task", sqliteTaskColumns+sqliteTaskForeignKeys, sqliteTaskSelect,
+		sqliteTaskIndexes) +
+		sqliteRebuild("pp_task_rel", sqliteTaskRelColumns+sqliteTaskRelForeignKeys, sqliteTaskRelIndexes) +
+		sqliteRebuild("pp_order", sqliteOrderColumns+sqliteOrderForeignKeys, "") +
+		sqliteRebuildSelect("pp_job", sqliteJobColumns+sqliteJobForeignKeys, sqliteJobSelect, sqliteJobIndexes) +
+		sqliteIndexesV3
+	sqliteDropSchemaV3 = sqliteRebuild("pp_task", sqliteTaskColumns, sqliteTaskIndexes) +
+		sqliteRebuild("pp_task_rel", sqliteTaskRelColumns, sqliteTaskRelIndexes) +
+		sqliteRebuild("pp_order", sqliteOrderColumns, "") +
+		sqliteRebuild("pp_job", sqliteJobColumns, sqliteJobIndexes)
+)
+
+// Table is copied to the new one with the given columns, indexes are dropped along with the former table
+func sqliteRebuild(table, columns, indexes string) string {
+	return sqliteRebuildSelect(table, columns, "*", indexes)
+}
+
+// Table is copied to the new one with the selected columns of the former table (the dropped ones are skipped)
+func sqliteRebuildSelect(table, columns, selected, indexes string) string {
+	return fmt.Sprintf(`
+CREATE TABLE %[1]s_new
+(%[2]s
+);
+INSERT INTO %[1]s_new SELECT %[3]s FROM %[1]s;
+DROP TABLE %[1]s;
+ALTER TABLE %[1]s_new RENAME TO %[1]s;
+%[4]s`, table, columns, selected, indexes)
+}
+
+// Idempotency keys of the orders are unique per process (NULL keys aren't unique), SQLite can't drop the columns,
+// so the table is rebuilt by the revert
+const (
+	postgresSchemaV4 = `
+ALTER TABLE pp_order ADD COLUMN IF NOT EXISTS idempotency_key varchar(255);
+ALTER TABLE pp_order ADD COLUMN IF NOT EXISTS created_at timestamp;
+CREATE UNIQUE INDEX IF NOT EXISTS pp_order_2 ON pp_order(process_id, idempotency_key);`
+	postgresDropSchemaV4 = `
+DROP INDEX IF EXISTS pp_order_2;
+ALTER TABLE pp_order DROP COLUMN IF EXISTS created_at;
+ALTER TABLE pp_order DROP COLUMN IF EXISTS idempotency_key;`
+	sqliteSchemaV4 = `
+ALTER TABLE pp_order ADD COLUMN idempotency_key varchar(255);
+ALTER TABLE pp_order ADD COLUMN created_at timestamp;
+CREATE UNIQUE INDEX pp_order_2 ON pp_order(process_id, idempotency_key);`
+)
+
+var sqliteDropSchemaV4 = sqliteRebuildSelect("pp_order", sqliteOrderColumns+sqliteOrderForeignKeys,
+	"order_id, process_id, body", "CREATE INDEX pp_order_1 ON pp_order(process_id);")
+
+// JSON Schemas of the order bodies (process) and the task outputs
+const (
+	postgresSchemaV5 = `
+ALTER TABLE pp_process ADD COLUMN IF NOT EXISTS order_schema jsonb;
+ALTER TABLE pp_task ADD COLUMN IF NOT EXISTS output_schema jsonb;`
+	postgresDropSchemaV5 = `
+ALTER TABLE pp_task DROP COLUMN IF EXISTS output_schema;
+ALTER TABLE pp_process DROP COLUMN IF EXISTS order_schema;`
+	sqliteSchemaV5 = `
+ALTER TABLE pp_process ADD COLUMN order_schema blob;
+ALTER TABLE pp_task ADD COLUMN output_schema blob;`
+	sqliteProcessColumns = `
+    process_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    CONSTRAINT pp_process_pkey PRIMARY KEY (process_id)`
+)
+
+var sqliteDropSchemaV5 = sqliteRebuildSelect("pp_process", sqliteProcessColumns, "process_id, name", "") +
+	sqliteRebuildSelect("pp_task", sqliteTaskColumns+sqliteTaskForeignKeys,
+		sqliteTaskSelect,
+		"CREATE INDEX pp_task_1 ON pp_task(process_id);\nCREATE INDEX pp_task_2 ON pp_task(read_mapping_id);")
+
+// Orders are paged by the created time, so it's set for the orders created before it was introduced
+// (it isn't reset by the revert)
+const (
+	postgresSchemaV6 = `
+UPDATE pp_order SET created_at = now() AT TIME ZONE 'UTC' WHERE created_at IS NULL;
+CREATE INDEX IF NOT EXISTS pp_order_3 ON pp_order(created_at, order_id);
+CREATE INDEX IF NOT EXISTS pp_order_4 ON pp_order(process_id, created_at, order_id);
+CREATE INDEX IF NOT EXISTS pp_process_1 ON pp_process(name, process_id);`
+	sqliteSchemaV6 = `
+UPDATE pp_order SET created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') WHERE created_at IS NULL;
+CREATE INDEX pp_order_3 ON pp_order(created_at, order_id);
+CREATE INDEX pp_order_4 ON pp_order(process_id, created_at, order_id);
+CREATE INDEX pp_process_1 ON pp_process(name, process_id);`
+	dropSchemaV6 = `
+DROP INDEX IF EXISTS pp_process_1;
+DROP INDEX IF EXISTS pp_order_4;
+DROP INDEX IF EXISTS pp_order_3;`
+)
+
+// Searchable paths of the order bodies (per process), bodies are searched by the containment which is supported
+// by GIN index of jsonb_path_ops. SQLite has no GIN indexes, so the search scans the orders of the process
+const (
+	postgresSchemaV7 = `
+ALTER TABLE pp_process ADD COLUMN IF NOT EXISTS search_paths jsonb;
+CREATE INDEX IF NOT EXISTS pp_order_5 ON pp_order USING GIN (body jsonb_path_ops);`
+	postgresDropSchemaV7 = `
+DROP INDEX IF EXISTS pp_order_5;
+ALTER TABLE pp_process DROP COLUMN IF EXISTS search_paths;`
+	sqliteSchemaV7 = `
+ALTER TABLE pp_process ADD COLUMN search_paths blob;`
+	sqliteProcessColumnsV5 = `
+    process_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    order_schema blob,
+    CONSTRAINT pp_process_pkey PRIMARY KEY (process_id)`
+)
+
+var sqliteDropSchemaV7 = sqliteRebuildSelect("pp_process", sqliteProcessColumnsV5, "process_id, name, order_schema",
+	"CREATE INDEX pp_process_1 ON pp_process(name, process_id);")
+
+// Time columns are stored with the time zone. Order times were written in UTC, job times were written in the local time
+// of the service, so they're converted in the session time zone. SQLite stores the times as text with the offset,
+// so it has nothing to do
+const (
+	postgresSchemaV8 = `
+ALTER TABLE pp_order ALTER COLUMN created_at TYPE timestamptz USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE pp_job ALTER COLUMN start_after TYPE timestamptz;
+ALTER TABLE pp_job ALTER COLUMN locked_until TYPE timestamptz;
+ALTER TABLE pp_schema_version ALTER COLUMN applied_at TYPE timestamptz;`
+	postgresDropSchemaV8 = `
+ALTER TABLE pp_schema_version ALTER COLUMN applied_at TYPE timestamp;
+ALTER TABLE pp_job ALTER COLUMN locked_until TYPE timestamp;
+ALTER TABLE pp_job ALTER COLUMN start_after TYPE timestamp;
+ALTER TABLE pp_order ALTER COLUMN created_at TYPE timestamp USING created_at AT TIME ZONE 'UTC';`
+	sqliteSchemaV8 = ``
+)
+
+var postgresMigrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: postgresSchemaV1, Down: dropSchemaV1},
+	{Version: 2, Name: "job columns", Up: postgresSchemaV2, Down: postgresDropSchemaV2},
+	{Version: 3, Name: "foreign keys", Up: postgresSchemaV3, Down: postgresDropSchemaV3},
+	{Version: 4, Name: "order idempotency keys", Up: postgresSchemaV4, Down: postgresDropSchemaV4},
+	{Version: 5, Name: "schemas", Up: postgresSchemaV5, Down: postgresDropSchemaV5},
+	{Version: 6, Name: "list indexes", Up: postgresSchemaV6, Down: dropSchemaV6},
+	{Version: 7, Name: "order search", Up: postgresSchemaV7, Down: postgresDropSchemaV7},
+	{Version: 8, Name: "time zones", Up: postgresSchemaV8, Down: postgresDropSchemaV8},
+}
+
+var sqliteMigrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: sqliteSchemaV1, Down: dropSchemaV1},
+	{Version: 2, Name: "job columns", Up: sqliteSchemaV2, Down: sqliteDropSchemaV2},
+	{Version: 3, Name: "foreign keys", Up: sqliteSchemaV3, Down: sqliteDropSchemaV3},
+	{Version: 4, Name: "order idempotency keys", Up: sqliteSchemaV4, Down: sqliteDropSchemaV4},
+	{Version: 5, Name: "schemas", Up: sqliteSchemaV5, Down: sqliteDropSchemaV5},
+	{Version: 6, Name: "list indexes", Up: sqliteSchemaV6, Down: dropSchemaV6},
+	{Version: 7, Name: "order search", Up: sqliteSchemaV7, Down: sqliteDropSchemaV7},
+	{Version: 8, Name: "time zones", Up: sqliteSchemaV8, Down: sqliteSchemaV8},
+}
+
+// Migrator applies (reverts) the migrations in the order of the versions
+type Migrator struct {
+	db                 *sql.DB
+	migrations         []Migration
+	advisoryLock       bool
+	disableForeignKeys bool
+}
+
+func NewPostgresMigrator(db *sqlx.DB) *Migrator {
+	return &Migrator{db: db.DB, migrations: postgresMigrations, advisoryLock: true}
+}
+
+// SQLite transactions are immediate, so concurrent migrations fail on the insert of the same version
+func NewSQLiteMigrator(db *SQLiteDB) *Migrator {
+	return &Migrator{db: db.DB.DB, migrations: sqliteMigrations, disableForeignKeys: true}
+}
+
+// Status of the migrations, the versions which have been applied but aren't known are included as well
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	const op = "Migrator.Status"
+
+	var result []MigrationStatus
+	err := m.withConn(ctx, func(conn *sql.Conn, applied map[int]MigrationStatus) error {
+		for _, migration := range m.migrations {
+			status, ok := applied[migration.Version]
+			if !ok {
+				status = MigrationStatus{Version: migration.Version, Name: migration.Name}
+			}
+			delete(applied, migration.Version)
+			result = append(result, status)
+		}
+		for _, status := range applied {
+			result = append(result, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, domain.E(op, err)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// Apply the pending migrations, number of the applied ones is returned
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	const op = "Migrator.Up"
+
+	count := 0
+	err := m.withConn(ctx, func(conn *sql.Conn, applied map[int]MigrationStatus) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := execMigration(ctx, conn, migration.Up, insertSchemaVersion, migration.Version, migration.Name,
+				time.Now()); err != nil {
+
+				return domain.E(op, fmt.Sprintf("can't apply migration (%d)", migration.Version), err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, domain.E(op, err)
+	}
+	return count, nil
+}
+
+// Revert up to the given number of the latest applied migrations, number of the reverted ones is returned
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	const op = "Migrator.Down"
+
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	count := 0
+	err := m.withConn(ctx, func(conn *sql.Conn, applied map[int]MigrationStatus) error {
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		for _, version := range versions {
+			if count >= steps {
+				break
+			}
+			migration, ok := known[version]
+			if !ok {
+				return domain.E(op, fmt.Sprintf("unknown migration (%d)", version))
+			}
+			if err := execMigration(ctx, conn, migration.Down, deleteSchemaVersion, version); err != nil {
+				return domain.E(op, fmt.Sprintf("can't revert migration (%d)", version), err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, domain.E(op, err)
+	}
+	return count, nil
+}
+
+// Execute function on a dedicated connection (which holds the advisory lock) with the applied migrations
+func (m *Migrator) withConn(ctx context.Context, f func(conn *sql.Conn, applied map[int]MigrationStatus) error) error {
+	const op = "Migrator.Conn"
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return domain.E(op, "can't get connection", err)
+	}
+	defer conn.Close()
+
+	if m.advisoryLock {
+		if _, err := conn.ExecContext(ctx, lockMigrations, migrationLockId); err != nil {
+			return domain.E(op, "can't lock migrations", err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), unlockMigration, migrationLockId)
+		}()
+	}
+	if m.disableForeignKeys {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return domain.E(op, "can't disable foreign keys", err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+		}()
+	}
+	if _, err := conn.ExecContext(ctx, createSchemaVersion); err != nil {
+		return domain.E(op, "can't create schema version table", err)
+	}
+	applied, err := selectSchemaVersions(ctx, conn)
+	if err != nil {
+		return domain.E(op, "can't select schema versions", err)
+	}
+	return f(conn, applied)
+}
+
+func selectSchemaVersions(ctx context.Context, conn *sql.Conn) (map[int]MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, getSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		status.AppliedAt = &appliedAt
+		result[status.Version] = status
+	}
+	return result, rows.Err()
+}
+
+func execMigration(ctx context.Context, conn *sql.Conn, statements, query string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
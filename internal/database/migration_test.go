@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrator_SQLite(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "pp-gin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator := NewSQLiteMigrator(db)
+	n := len(sqliteMigrations)
+	migrator.migrations = append(append([]Migration{}, sqliteMigrations...), Migration{Version: n + 1,
+		Name: "test", Up: "CREATE TABLE pp_test (id integer)", Down: "DROP TABLE pp_test"})
+
+	status, err := migrator.Status(ctx)
+	assert.Nil(err)
+	if assert.Len(status, n+1) {
+		assert.Nil(status[0].AppliedAt)
+		assert.Nil(status[n].AppliedAt)
+	}
+
+	// Pending migrations are applied once
+	count, err := migrator.Up(ctx)
+	assert.Nil(err)
+	assert.Equal(n+1, count)
+	count, err = migrator.Up(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+	_, err = db.Exec("INSERT INTO pp_test (id) VALUES (1)")
+	assert.Nil(err)
+
+	status, err = migrator.Status(ctx)
+	assert.Nil(err)
+	if assert.Len(status, n+1) {
+		assert.Equal("initial schema", status[0].Name)
+		assert.NotNil(status[0].AppliedAt)
+		assert.NotNil(status[n].AppliedAt)
+	}
+
+	// Latest migration is reverted
+	count, err = migrator.Down(ctx, 1)
+	assert.Nil(err)
+	assert.Equal(1, count)
+	_, err = db.Exec("INSERT INTO pp_test (id) VALUES (1)")
+	assert.NotNil(err)
+
+	// Failed migration is rolled back along with its version
+	migrator.migrations[n].Up = "CREATE TABLE pp_test (id integer); INSERT INTO pp_unknown VALUES (1)"
+	_, err = migrator.Up(ctx)
+	assert.NotNil(err)
+	status, err = migrator.Status(ctx)
+	assert.Nil(err)
+	if assert.Len(status, n+1) {
+		assert.NotNil(status[0].AppliedAt)
+		assert.Nil(status[n].AppliedAt)
+	}
+
+	// Version which isn't known (applied by a newer release) is reported, but it can't be reverted
+	_, err = db.Exec(sqliteQuery(insertSchemaVersion), n+2, "unknown", time.Now())
+	assert.Nil(err)
+	status, err = migrator.Status(ctx)
+	assert.Nil(err)
+	if assert.Len(status, n+2) {
+		assert.Equal("unknown", status[n+1].Name)
+	}
+	_, err = migrator.Down(ctx, 1)
+	assert.NotNil(err)
+}
+
+// Schema of the former config/database.sql (in the types of SQLite)
+const testBaselineSchema = `
+CREATE TABLE pp_process
+(
+    process_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    CONSTRAINT pp_process_pkey PRIMARY KEY (process_id)
+);
+CREATE TABLE pp_task
+(
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    name varchar(255) NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    read_mapping_id text NOT NULL,
+    CONSTRAINT pp_task_pkey PRIMARY KEY (task_id)
+);
+CREATE INDEX pp_task_1 ON pp_task(process_id);
+CREATE TABLE pp_task_rel
+(
+    process_id text NOT NULL,
+    parent_id text NOT NULL,
+    child_id text NOT NULL,
+    CONSTRAINT pp_task_rel_pkey PRIMARY KEY (parent_id, child_id)
+);
+CREATE INDEX pp_task_rel_1 ON pp_task_rel(process_id);
+CREATE TABLE pp_read_mapping
+(
+    read_mapping_id text NOT NULL,
+    body blob,
+    CONSTRAINT pp_read_mapping_pkey PRIMARY KEY (read_mapping_id)
+);
+CREATE TABLE pp_order
+(
+    order_id text NOT NULL,
+    process_id text NOT NULL,
+    body blob,
+    CONSTRAINT pp_order_pkey PRIMARY KEY (order_id)
+);
+CREATE TABLE pp_job
+(
+    process_id text NOT NULL,
+    task_id text NOT NULL,
+    category integer NOT NULL,
+    action varchar(255) NOT NULL,
+    order_id text NOT NULL,
+    read_mapping_id text NOT NULL,
+    started boolean NOT NULL,
+    completed boolean NOT NULL,
+    ready_num integer NOT NULL,
+    ready_req integer NOT NULL,
+    trace varchar(510) NOT NULL,
+    CONSTRAINT pp_job_pkey PRIMARY KEY (task_id, order_id)
+);
+CREATE INDEX pp_job_1 ON pp_job(task_id, order_id, completed);
+CREATE INDEX pp_job_2 ON pp_job(ready_num, ready_req, started, task_id, order_id);
+INSERT INTO pp_read_mapping VALUES ('m1', '{"id": "$.id"}');
+INSERT INTO pp_process VALUES ('p1', 'process');
+INSERT INTO pp_task VALUES ('p1', 't1', 'task', 0, 'http://localhost/task', 'm1');
+INSERT INTO pp_order VALUES ('o1', 'p1', '{"id": 1}');
+INSERT INTO pp_job VALUES ('p1', 't1', 0, 'http://localhost/task', 'o1', 'm1', FALSE, FALSE, 0, 0, '');`
+
+func TestMigrator_SQLite_Baseline(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "pp-gin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(testBaselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	migrator := NewSQLiteMigrator(db)
+	n := len(sqliteMigrations)
+	jobRepo := NewSQLiteJobRepo(db)
+
+	// Database of the script is migrated along with its rows
+	count, err := migrator.Up(ctx)
+	assert.Nil(err)
+	assert.Equal(n, count)
+	var jobs []Job
+	if assert.Nil(jobRepo.GetByOrderId(ctx, "o1", &jobs)) && assert.Len(jobs, 1) {
+		assert.Equal("t1", jobs[0].TaskId)
+		assert.Equal(0, jobs[0].Attempt)
+	}
+	var readyJobs []Job
+	if assert.Nil(jobRepo.GetReadyJobs(ctx, 10, &readyJobs)) && assert.Len(readyJobs, 1) {
+		assert.Equal("o1", readyJobs[0].OrderId)
+	}
+
+	// Migrations are reverted up to the initial schema and applied again
+	count, err = migrator.Down(ctx, n-1)
+	assert.Nil(err)
+	assert.Equal(n-1, count)
+	_, err = db.Exec("SELECT attempt FROM pp_job")
+	assert.NotNil(err)
+	count, err = migrator.Up(ctx)
+	assert.Nil(err)
+	assert.Equal(n-1, count)
+	jobs = nil
+	if assert.Nil(jobRepo.GetByOrderId(ctx, "o1", &jobs)) {
+		assert.Len(jobs, 1)
+	}
+}
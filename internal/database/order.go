@@ -5,25 +5,57 @@ import (
 	"database/sql"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"fmt"
+	"time"
 )
 
 const (
-	getOrders       = `SELECT order_id, process_id, body FROM pp_order`
-	createOrder     = `INSERT INTO pp_order (order_id, process_id, body) VALUES ($1, $2, $3)`
-	getOrderById    = `SELECT order_id, process_id, body FROM pp_order WHERE order_id = $1`
-	deleteOrderById = `DELETE FROM pp_order WHERE order_id = $1`
+	getOrders   = `SELECT order_id, process_id, body, idempotency_key, created_at FROM pp_order`
+	createOrder = `INSERT INTO pp_order (order_id, process_id, body, idempotency_key, created_at)
+VALUES ($1, $2, $3, $4, $5)`
+	getOrderById = `SELECT order_id, process_id, body, idempotency_key, created_at FROM pp_order
+WHERE order_id = $1`
+	getOrderByIdempotencyKey = `SELECT order_id, process_id, body, idempotency_key, created_at FROM pp_order
+WHERE process_id = $1 AND idempotency_key = $2`
+	releaseIdempotencyKey = `UPDATE pp_order SET idempotency_key = NULL WHERE order_id = $1`
+	deleteOrderById       = `DELETE FROM pp_order WHERE order_id = $1`
+
+	getOrderPage           = `SELECT o.order_id, o.process_id, o.body, o.idempotency_key, o.created_at FROM pp_order o`
+	getOrderCount          = `SELECT count(*) FROM pp_order o`
+	getOrderCountByProcess = `SELECT process_id, count(*) AS count FROM pp_order GROUP BY process_id`
+
+	// Statuses of the orders by their jobs, failed orders have no running jobs
+	orderRunning = `EXISTS (SELECT 1 FROM pp_job j WHERE j.order_id = o.order_id AND j.completed = FALSE
+AND j.failed = FALSE)`
+	orderFailed    = `EXISTS (SELECT 1 FROM pp_job j WHERE j.order_id = o.order_id AND j.failed = TRUE)`
+	orderCompleted = `NOT EXISTS (SELECT 1 FROM pp_job j WHERE j.order_id = o.order_id AND j.completed = FALSE)`
 )
 
+// Fields of the order sort along with their columns
+var orderSortFields = [][2]string{{"createdAt", "o.created_at"}, {"id", "o.order_id"}}
+
+// Idempotency key is unique per process
 type Order struct {
-	Id        string `db:"order_id"`
+	Id             string     `db:"order_id"`
+	ProcessId      string     `db:"process_id"`
+	Body           Body       `db:"body"`
+	IdempotencyKey *string    `db:"idempotency_key"`
+	CreatedAt      *time.Time `db:"created_at"`
+}
+
+type OrderCount struct {
 	ProcessId string `db:"process_id"`
-	Body      Body   `db:"body"`
+	Count     int    `db:"count"`
 }
 
 type OrderRepo interface {
 	Create(ctx context.Context, obj *Order) error
 	GetAll(ctx context.Context, result *[]Order) error
+	GetPage(ctx context.Context, query *domain.OrderQuery, result *[]Order, page *domain.Page) error
+	// Counts of the orders per process, processes without orders are absent
+	CountByProcess(ctx context.Context, result *map[string]int) error
 	GetById(ctx context.Context, id string, result *Order) error
+	GetByIdempotencyKey(ctx context.Context, processId, key string, result *Order) error
+	ReleaseIdempotencyKey(ctx context.Context, id string) error
 	DeleteById(ctx context.Context, id string) error
 }
 
@@ -45,6 +77,116 @@ func (s RDBOrderRepo) GetAll(ctx context.Context, result *[]Order) error {
 	return nil
 }
 
+// Page of the orders matched by the query along with the total count of them
+func (s RDBOrderRepo) GetPage(ctx context.Context, query *domain.OrderQuery, result *[]Order,
+	page *domain.Page) error {
+
+	const op = "OrderRepo.GetPage"
+
+	sort, err := parsePageSort(&query.PageQuery, orderSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(&query.PageQuery, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	var q listQuery
+	if err := s.filter(query, &q); err != nil {
+		return domain.E(op, err)
+	}
+	var total int
+	if err := s.db.GetContext(ctx, &total, getOrderCount+q.whereClause(), q.args...); err != nil {
+		return domain.E(op, "can't count orders", err)
+	}
+
+	if cursor != nil {
+		var value interface{} = cursor.Value
+		if sort.field == "createdAt" {
+			if value, err = time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+				return domain.E(op, domain.ErrValidation, "invalid cursor", err)
+			}
+		}
+		sort.after(&q, "o.order_id", value, cursor.Id)
+	}
+	limit := pageLimit(&query.PageQuery)
+	var rows []Order
+	selectQuery := getOrderPage + q.whereClause() + sort.orderBy("o.order_id") + " LIMIT " + q.arg(limit+1)
+	if err := s.db.SelectContext(ctx, &rows, selectQuery, q.args...); err != nil {
+		return domain.E(op, "can't select orders", err)
+	}
+	*page = domain.Page{Total: total}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = encodeCursor(sort, orderSortValue(&rows[limit-1], sort), rows[limit-1].Id)
+	}
+	*result = rows
+	return nil
+}
+
+func (s RDBOrderRepo) CountByProcess(ctx context.Context, result *map[string]int) error {
+	const op = "OrderRepo.CountByProcess"
+
+	var rows []OrderCount
+	if err := s.db.SelectContext(ctx, &rows, getOrderCountByProcess); err != nil {
+		return domain.E(op, "can't count orders", err)
+	}
+	*result = make(map[string]int, len(rows))
+	for _, row := range rows {
+		(*result)[row.ProcessId] = row.Count
+	}
+	return nil
+}
+
+// Conditions of the filters of the query
+func (s RDBOrderRepo) filter(query *domain.OrderQuery, q *listQuery) error {
+	if err := checkOrderStatus(query.Status); err != nil {
+		return err
+	}
+	if query.ProcessId != "" {
+		q.where("o.process_id = " + q.arg(query.ProcessId))
+	}
+	switch query.Status {
+	case domain.OrderRunning:
+		q.where(orderRunning)
+	case domain.OrderFailed:
+		q.where("NOT " + orderRunning + " AND " + orderFailed)
+	case domain.OrderCompleted:
+		q.where(orderCompleted)
+	}
+	if query.CreatedFrom != nil {
+		q.where("o.created_at >= " + q.arg(query.CreatedFrom.UTC()))
+	}
+	if query.CreatedTo != nil {
+		q.where("o.created_at < " + q.arg(query.CreatedTo.UTC()))
+	}
+	for _, key := range sortedKeys(query.Body) {
+		q.where(jsonFieldEquals(s.db, "o.body", key, query.Body[key], q))
+	}
+	for _, doc := range query.Contains {
+		q.where(jsonContainsCondition(s.db, "o.body", Body(doc), q))
+	}
+	return nil
+}
+
+func checkOrderStatus(status string) error {
+	const op = "OrderRepo.CheckStatus"
+
+	switch status {
+	case "", domain.OrderRunning, domain.OrderFailed, domain.OrderCompleted:
+		return nil
+	}
+	return domain.E(op, domain.ErrValidation, fmt.Sprintf("unknown order status (%s)", status))
+}
+
+// Value of the sort field which is stored by the cursor
+func orderSortValue(order *Order, sort pageSort) string {
+	if sort.field == "createdAt" {
+		return cursorTime(order.CreatedAt)
+	}
+	return order.Id
+}
+
 func (s RDBOrderRepo) Create(ctx context.Context, obj *Order) error {
 	const op = "OrderRepo.Create"
 
@@ -53,13 +195,19 @@ func (s RDBOrderRepo) Create(ctx context.Context, obj *Order) error {
 		return domain.E(op, "can't generate uuid", err)
 	}
 	obj.Id = id.String()
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	obj.CreatedAt = &createdAt
 
+	args := []interface{}{obj.Id, obj.ProcessId, Body(obj.Body), obj.IdempotencyKey, obj.CreatedAt}
 	if tx, ok := TransactionFromContext(ctx); ok {
-		_, err = tx.ExecContext(ctx, createOrder, obj.Id, obj.ProcessId, Body(obj.Body))
+		_, err = tx.ExecContext(ctx, createOrder, args...)
 	} else {
-		_, err = s.db.ExecContext(ctx, createOrder, obj.Id, obj.ProcessId, Body(obj.Body))
+		_, err = s.db.ExecContext(ctx, createOrder, args...)
 	}
 	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.E(op, domain.ErrConflict, fmt.Sprintf("duplicate idempotency key (%s)", obj.ProcessId), err)
+		}
 		return domain.E(op, fmt.Errorf("can't create order (%s)", obj.ProcessId), err)
 	}
 	return nil
@@ -77,6 +225,40 @@ func (s RDBOrderRepo) GetById(ctx context.Context, id string, result *Order) err
 	return nil
 }
 
+func (s RDBOrderRepo) GetByIdempotencyKey(ctx context.Context, processId, key string, result *Order) error {
+	const op = "OrderRepo.GetByIdempotencyKey"
+
+	var err error
+	if tx, ok := TransactionFromContext(ctx); ok {
+		err = tx.GetContext(ctx, result, getOrderByIdempotencyKey, processId, key)
+	} else {
+		err = s.db.GetContext(ctx, result, getOrderByIdempotencyKey, processId, key)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.E(op, domain.ErrNotFound)
+		}
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Key of the order is released, so it could be used by another order of the process
+func (s RDBOrderRepo) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	const op = "OrderRepo.ReleaseIdempotencyKey"
+
+	var err error
+	if tx, ok := TransactionFromContext(ctx); ok {
+		_, err = tx.ExecContext(ctx, releaseIdempotencyKey, id)
+	} else {
+		_, err = s.db.ExecContext(ctx, releaseIdempotencyKey, id)
+	}
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
 // Delete order by Id
 func (s RDBOrderRepo) DeleteById(ctx context.Context, id string) error {
 	const op = "OrderRepo.DeleteById"
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Times of the cursors have the fixed width, so they're sorted as the strings
+const cursorTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func cursorTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(cursorTimeLayout)
+}
+
+// Conditions of the list query along with their arguments, placeholders are numbered by the arguments
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Placeholder of the argument
+func (q *listQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *listQuery) where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// Sort of the list by the field, rows with the same value are sorted by the id
+type pageSort struct {
+	field  string
+	column string
+	desc   bool
+}
+
+// Sort of the query ("-" prefix is descending) by one of the fields (the first one is the default)
+func parsePageSort(query *domain.PageQuery, fields [][2]string) (pageSort, error) {
+	const op = "Page.ParseSort"
+
+	field := strings.TrimPrefix(query.Sort, "-")
+	if field == "" {
+		field = fields[0][0]
+	}
+	for _, f := range fields {
+		if f[0] == field {
+			return pageSort{field: field, column: f[1], desc: strings.HasPrefix(query.Sort, "-")}, nil
+		}
+	}
+	return pageSort{}, domain.E(op, domain.ErrValidation, fmt.Sprintf("unknown sort field (%s)", field))
+}
+
+func (s pageSort) name() string {
+	if s.desc {
+		return "-" + s.field
+	}
+	return s.field
+}
+
+func (s pageSort) orderBy(idColumn string) string {
+	direction := ""
+	if s.desc {
+		direction = " DESC"
+	}
+	if s.column == idColumn {
+		return " ORDER BY " + idColumn + direction
+	}
+	return " ORDER BY " + s.column + direction + ", " + idColumn + direction
+}
+
+// Condition of the rows after the cursor (value and id of the last row)
+func (s pageSort) after(q *listQuery, idColumn string, value interface{}, id string) {
+	op := ">"
+	if s.desc {
+		op = "<"
+	}
+	if s.column == idColumn {
+		q.where(idColumn + " " + op + " " + q.arg(id))
+		return
+	}
+	q.where(fmt.Sprintf("(%s, %s) %s (%s, %s)", s.column, idColumn, op, q.arg(value), q.arg(id)))
+}
+
+// Cursor is bound to the sort, so it isn't applied to another one
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Id    string `json:"i"`
+}
+
+func encodeCursor(s pageSort, value, id string) string {
+	data, _ := json.Marshal(pageCursor{Sort: s.name(), Value: value, Id: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Value and id of the last row of the previous page, nil cursor is returned for the first page
+func decodeCursor(query *domain.PageQuery, s pageSort) (*pageCursor, error) {
+	const op = "Page.DecodeCursor"
+
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil {
+		return nil, domain.E(op, domain.ErrValidation, "invalid cursor", err)
+	}
+	if cursor.Sort != s.name() {
+		return nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("cursor of another sort (%s)", cursor.Sort))
+	}
+	return &cursor, nil
+}
+
+func pageLimit(query *domain.PageQuery) int {
+	switch {
+	case query.Limit <= 0:
+		return domain.DefaultPageLimit
+	case query.Limit > domain.MaxPageLimit:
+		return domain.MaxPageLimit
+	}
+	return query.Limit
+}
+
+// Condition of the text of the top-level field of the JSON column, SQLite uses the function of the driver
+func jsonFieldEquals(db DB, column, key, value string, q *listQuery) string {
+	if _, ok := db.(*SQLiteDB); ok {
+		return fmt.Sprintf("pp_json_field_equals(%s, %s, %s)", column, q.arg(key), q.arg(value))
+	}
+	return fmt.Sprintf("%s->>%s = %s", column, q.arg(key), q.arg(value))
+}
+
+// Keys of the body filters are sorted, so the queries are the same for the same filters
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Sort key of the in-memory row
+type memPageRow struct {
+	value string
+	id    string
+	index int
+}
+
+// Indexes of the rows of the page, the rows are sorted by the value and id and they're after the cursor
+func memPage(rows []memPageRow, s pageSort, cursor *pageCursor, limit int, page *domain.Page) []int {
+	less := func(a, b memPageRow) bool {
+		if a.value != b.value {
+			return a.value < b.value
+		}
+		return a.id < b.id
+	}
+	if s.desc {
+		asc := less
+		less = func(a, b memPageRow) bool { return asc(b, a) }
+	}
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+
+	*page = domain.Page{Total: len(rows)}
+	start := 0
+	if cursor != nil {
+		last := memPageRow{value: cursor.Value, id: cursor.Id}
+		for start < len(rows) && !less(last, rows[start]) {
+			start++
+		}
+	}
+	end := start + limit
+	if end < len(rows) {
+		page.NextCursor = encodeCursor(s, rows[end-1].value, rows[end-1].id)
+	} else {
+		end = len(rows)
+	}
+	result := make([]int, 0, end-start)
+	for _, row := range rows[start:end] {
+		result = append(result, row.index)
+	}
+	return result
+}
@@ -5,26 +5,57 @@ import (
 	"database/sql"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"fmt"
+	"strings"
 )
 
 const (
-	createProcess                  = `INSERT INTO pp_process (process_id, name) VALUES ($1, $2)`
-	getProcesses                   = `SELECT process_id, name FROM pp_process`
-	getProcessById                 = `SELECT process_id, name FROM pp_process WHERE process_id = $1 LIMIT 1`
-	deleteProcessById              = `DELETE FROM pp_process WHERE process_id = $1`
-	createTask                     = `INSERT INTO pp_task (process_id, task_id, name, category, action, read_mapping_id) VALUES ($1, $2, $3, $4, $5, $6)`
-	createTaskRelation             = `INSERT INTO pp_task_rel (process_id, parent_id, child_id) VALUES ($1, $2, $3)`
-	getTasks                       = `SELECT process_id, task_id, name, category, action, read_mapping_id FROM pp_task`
-	getTaskRelations               = `SELECT process_id, parent_id, child_id FROM pp_task_rel`
-	getTasksByProcessId            = `SELECT task_id, name, category, action, read_mapping_id FROM pp_task WHERE process_id = $1`
-	getTaskRelationsByProcessId    = `SELECT parent_id, child_id FROM pp_task_rel WHERE process_id = $1`
+	createProcess       = `INSERT INTO pp_process (process_id, name, order_schema, search_paths) VALUES ($1, $2, $3, $4)`
+	deleteProcessById   = `DELETE FROM pp_process WHERE process_id = $1`
+	createTasks         = `INSERT INTO pp_task (process_id, task_id, name, category, action, sync, http, read_mapping_id, output_schema) VALUES `
+	createTaskRelations = `INSERT INTO pp_task_rel (process_id, parent_id, child_id) VALUES `
+
+	// Processes along with the tasks and relations are selected by the single query (kind of the row), tasks are
+	// the first ones, so Postgres resolves the types of NULL columns without casts (except the search paths which are
+	// NULL in both tasks and relations). Schema is the output schema of the task or the order schema of the process
+	getProcessRows = `SELECT 1 AS kind, process_id, name, task_id, category, action, sync, http, read_mapping_id,
+output_schema AS schema, NULL AS parent_id, NULL AS child_id, CAST(NULL AS jsonb) AS search_paths FROM pp_task
+UNION ALL SELECT 2, process_id, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, parent_id, child_id, NULL
+FROM pp_task_rel
+UNION ALL SELECT 0, process_id, name, NULL, NULL, NULL, NULL, NULL, NULL, order_schema, NULL, NULL, search_paths
+FROM pp_process`
+	getProcessRowsById = `SELECT 1 AS kind, process_id, name, task_id, category, action, sync, http, read_mapping_id,
+output_schema AS schema, NULL AS parent_id, NULL AS child_id, CAST(NULL AS jsonb) AS search_paths FROM pp_task
+WHERE process_id = $1
+UNION ALL SELECT 2, process_id, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, parent_id, child_id, NULL
+FROM pp_task_rel WHERE process_id = $1
+UNION ALL SELECT 0, process_id, name, NULL, NULL, NULL, NULL, NULL, NULL, order_schema, NULL, NULL, search_paths
+FROM pp_process WHERE process_id = $1`
+
+	// Rows of the processes of the page (the list of the ids)
+	getProcessRowsByIds = `SELECT 1 AS kind, process_id, name, task_id, category, action, sync, http, read_mapping_id,
+output_schema AS schema, NULL AS parent_id, NULL AS child_id, CAST(NULL AS jsonb) AS search_paths FROM pp_task
+WHERE process_id IN (%[1]s)
+UNION ALL SELECT 2, process_id, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, parent_id, child_id, NULL
+FROM pp_task_rel WHERE process_id IN (%[1]s)
+UNION ALL SELECT 0, process_id, name, NULL, NULL, NULL, NULL, NULL, NULL, order_schema, NULL, NULL, search_paths
+FROM pp_process WHERE process_id IN (%[1]s)`
+	getProcessPage  = `SELECT process_id, name FROM pp_process`
+	getProcessCount = `SELECT count(*) FROM pp_process`
+
 	deleteTasksByProcessId         = `DELETE FROM pp_task WHERE process_id = $1`
 	deleteTaskRelationsByProcessId = `DELETE FROM pp_task_rel WHERE process_id = $1`
+	getRunningOrderCount           = `SELECT count(DISTINCT o.order_id) FROM pp_order o
+JOIN pp_job j ON j.order_id = o.order_id WHERE o.process_id = $1 AND j.completed = FALSE AND j.failed = FALSE`
 )
 
+// Fields of the process sort along with their columns
+var processSortFields = [][2]string{{"name", "name"}, {"id", "process_id"}}
+
 type Process struct {
-	Id            string `db:"process_id"`
-	Name          string `db:"name"`
+	Id            string  `db:"process_id"`
+	Name          string  `db:"name"`
+	OrderSchema   Body    `db:"order_schema"`
+	SearchPaths   Strings `db:"search_paths"`
 	Tasks         []Task
 	TaskRelations []TaskRelation
 }
@@ -37,13 +68,25 @@ func (p *Process) AddTaskRelation(taskRelation *TaskRelation) {
 	p.TaskRelations = append(p.TaskRelations, *taskRelation)
 }
 
+// Count of the parent tasks (by child task id) which have to be completed before the task's job is ready
+func (p *Process) ReadyRequired() map[string]int {
+	result := make(map[string]int, len(p.Tasks))
+	for _, relation := range p.TaskRelations {
+		result[relation.ChildId]++
+	}
+	return result
+}
+
 type Task struct {
-	ProcessId     string `db:"process_id"`
-	Id            string `db:"task_id"`
-	Name          string `db:"name"`
-	Category      int    `db:"category"`
-	Action        string `db:"action"`
-	ReadMappingId string `db:"read_mapping_id"`
+	ProcessId     string          `db:"process_id"`
+	Id            string          `db:"task_id"`
+	Name          string          `db:"name"`
+	Category      int             `db:"category"`
+	Action        string          `db:"action"`
+	Sync          bool            `db:"sync"`
+	Http          *HttpTaskConfig `db:"http"`
+	ReadMappingId string          `db:"read_mapping_id"`
+	OutputSchema  Body            `db:"output_schema"`
 }
 
 type TaskRelation struct {
@@ -52,56 +95,132 @@ type TaskRelation struct {
 	ChildId   string `db:"child_id"`
 }
 
-// TBD It could be improved by storing jsonb or batch execution
+// Row of the process, its task or task relation (kind) of the single query
+type processRow struct {
+	Kind          int             `db:"kind"`
+	ProcessId     string          `db:"process_id"`
+	Name          sql.NullString  `db:"name"`
+	TaskId        sql.NullString  `db:"task_id"`
+	Category      sql.NullInt64   `db:"category"`
+	Action        sql.NullString  `db:"action"`
+	Sync          sql.NullBool    `db:"sync"`
+	Http          *HttpTaskConfig `db:"http"`
+	ReadMappingId sql.NullString  `db:"read_mapping_id"`
+	Schema        Body            `db:"schema"`
+	ParentId      sql.NullString  `db:"parent_id"`
+	ChildId       sql.NullString  `db:"child_id"`
+	SearchPaths   Strings         `db:"search_paths"`
+}
+
+const (
+	processRowKind = iota
+	taskRowKind
+	taskRelationRowKind
+)
+
 // ProcessRepo via postgres database
 type ProcessRepo interface {
 	GetAll(ctx context.Context, result *[]Process) error
+	GetPage(ctx context.Context, query *domain.PageQuery, result *[]Process, page *domain.Page) error
 	Create(ctx context.Context, obj *Process) error
 	GetById(ctx context.Context, id string, result *Process) error
 	DeleteById(ctx context.Context, id string) error
+	GetRunningOrderCount(ctx context.Context, id string, result *int) error
 }
 
+// Tasks and relations are inserted by multi-row statements of up to batchSize rows
 type RDBProcessRepo struct {
 	db          DB
 	newUUIDFunc NewUUIDFunc
+	batchSize   int
 }
 
 func NewRDBProcessRepo(db DB, newUUIDFunc NewUUIDFunc) ProcessRepo {
-	return &RDBProcessRepo{db: db, newUUIDFunc: newUUIDFunc}
+	return &RDBProcessRepo{db: db, newUUIDFunc: newUUIDFunc, batchSize: defaultBatchSize}
 }
 
 func (s RDBProcessRepo) GetAll(ctx context.Context, processes *[]Process) error {
 	const op = "ProcessRepo.GetAll"
 
-	if err := s.db.SelectContext(ctx, processes, getProcesses); err != nil {
+	var rows []processRow
+	if err := s.db.SelectContext(ctx, &rows, getProcessRows); err != nil {
 		return domain.E(op, "can't select processes", err)
 	}
-	if processes == nil {
+	*processes = toProcessesFromRows(rows)
+	return nil
+}
+
+// Page of the processes along with the total count of them, tasks and relations are selected for the page
+func (s RDBProcessRepo) GetPage(ctx context.Context, query *domain.PageQuery, result *[]Process,
+	page *domain.Page) error {
+
+	const op = "ProcessRepo.GetPage"
+
+	sort, err := parsePageSort(query, processSortFields)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	cursor, err := decodeCursor(query, sort)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	var total int
+	if err := s.db.GetContext(ctx, &total, getProcessCount); err != nil {
+		return domain.E(op, "can't count processes", err)
+	}
+
+	var q listQuery
+	if cursor != nil {
+		sort.after(&q, "process_id", cursor.Value, cursor.Id)
+	}
+	limit := pageLimit(query)
+	var keys []Process
+	selectQuery := getProcessPage + q.whereClause() + sort.orderBy("process_id") + " LIMIT " + q.arg(limit+1)
+	if err := s.db.SelectContext(ctx, &keys, selectQuery, q.args...); err != nil {
+		return domain.E(op, "can't select processes", err)
+	}
+	*page = domain.Page{Total: total}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		page.NextCursor = encodeCursor(sort, processSortValue(&keys[limit-1], sort), keys[limit-1].Id)
+	}
+	*result = make([]Process, 0, len(keys))
+	if len(keys) == 0 {
 		return nil
 	}
-	var tasks []Task
-	if err := s.db.SelectContext(ctx, &tasks, getTasks); err != nil {
-		return domain.E(op, "can't select tasks", err)
+
+	var ids listQuery
+	placeholders := make([]string, len(keys))
+	for i, key := range keys {
+		placeholders[i] = ids.arg(key.Id)
+	}
+	var rows []processRow
+	rowsQuery := fmt.Sprintf(getProcessRowsByIds, strings.Join(placeholders, ", "))
+	if err := s.db.SelectContext(ctx, &rows, rowsQuery, ids.args...); err != nil {
+		return domain.E(op, "can't select processes", err)
 	}
-	var relations []TaskRelation
-	if err := s.db.SelectContext(ctx, &relations, getTaskRelations); err != nil {
-		return domain.E(op, "can't select task relations", err)
+
+	// Processes are in the order of the page
+	processes := make(map[string]Process, len(keys))
+	for _, process := range toProcessesFromRows(rows) {
+		processes[process.Id] = process
 	}
-	for i, process := range *processes {
-		for _, task := range tasks {
-			if task.ProcessId == process.Id {
-				(*processes)[i].AddTask(&task)
-			}
-		}
-		for _, relation := range relations {
-			if relation.ProcessId == process.Id {
-				(*processes)[i].AddTaskRelation(&relation)
-			}
+	for _, key := range keys {
+		if process, ok := processes[key.Id]; ok {
+			*result = append(*result, process)
 		}
 	}
 	return nil
 }
 
+// Value of the sort field which is stored by the cursor
+func processSortValue(process *Process, sort pageSort) string {
+	if sort.field == "name" {
+		return process.Name
+	}
+	return process.Id
+}
+
 func (s RDBProcessRepo) Create(ctx context.Context, process *Process) error {
 	const op = "ProcessRepo.Create"
 
@@ -112,21 +231,24 @@ func (s RDBProcessRepo) Create(ctx context.Context, process *Process) error {
 		}
 		process.Id = id.String()
 
-		if _, err := tx.ExecContext(ctx, createProcess, process.Id, process.Name); err != nil {
+		_, err = tx.ExecContext(ctx, createProcess, process.Id, process.Name, process.OrderSchema, process.SearchPaths)
+		if err != nil {
 			return domain.E(op, "can't insert process", err)
 		}
-		for _, task := range process.Tasks {
-			if _, err := tx.ExecContext(ctx, createTask, process.Id, task.Id, task.Name, task.Category, task.Action,
-				task.ReadMappingId); err != nil {
-
-				return domain.E(op, fmt.Sprintf("can't insert task (%s, %s)", process.Id, task.Id), err)
-			}
+		tasks := make([][]interface{}, len(process.Tasks))
+		for i, task := range process.Tasks {
+			tasks[i] = []interface{}{process.Id, task.Id, task.Name, task.Category, task.Action, task.Sync, task.Http,
+				task.ReadMappingId, task.OutputSchema}
+		}
+		if err := execBatchInsert(ctx, tx, createTasks, tasks, s.batchSize); err != nil {
+			return domain.E(op, fmt.Sprintf("can't insert tasks (%s)", process.Id), err)
 		}
-		for _, rel := range process.TaskRelations {
-			if _, err := tx.ExecContext(ctx, createTaskRelation, process.Id, rel.ParentId, rel.ChildId); err != nil {
-				return domain.E(op, fmt.Sprintf("can't insert task relation (%s, %s, %s)", process.Id,
-					rel.ParentId, rel.ChildId), err)
-			}
+		relations := make([][]interface{}, len(process.TaskRelations))
+		for i, rel := range process.TaskRelations {
+			relations[i] = []interface{}{process.Id, rel.ParentId, rel.ChildId}
+		}
+		if err := execBatchInsert(ctx, tx, createTaskRelations, relations, s.batchSize); err != nil {
+			return domain.E(op, fmt.Sprintf("can't insert task relations (%s)", process.Id), err)
 		}
 		return nil
 	}
@@ -136,21 +258,58 @@ func (s RDBProcessRepo) Create(ctx context.Context, process *Process) error {
 func (s RDBProcessRepo) GetById(ctx context.Context, id string, result *Process) error {
 	const op = "ProcessRepo.GetById"
 
-	if err := s.db.GetContext(ctx, result, getProcessById, id); err != nil {
-		if err == sql.ErrNoRows {
-			return domain.E(op, domain.ErrNotFound)
-		}
+	var rows []processRow
+	if err := s.db.SelectContext(ctx, &rows, getProcessRowsById, id); err != nil {
 		return domain.E(op, fmt.Sprintf("can't select process (%s)", id), err)
 	}
-	if err := s.db.SelectContext(ctx, &result.Tasks, getTasksByProcessId, id); err != nil {
-		return domain.E(op, fmt.Sprintf("can't select tasks (%s)", id), err)
-	}
-	if err := s.db.SelectContext(ctx, &result.TaskRelations, getTaskRelationsByProcessId, id); err != nil {
-		return domain.E(op, fmt.Sprintf("can't select task relations (%s)", id), err)
+	processes := toProcessesFromRows(rows)
+	if len(processes) == 0 {
+		return domain.E(op, domain.ErrNotFound)
 	}
+	*result = processes[0]
 	return nil
 }
 
+// Processes are assembled in the order of the rows, tasks and relations of the missing processes are skipped
+func toProcessesFromRows(rows []processRow) []Process {
+	var result []Process
+	indexes := make(map[string]int)
+	for _, row := range rows {
+		if row.Kind == processRowKind {
+			indexes[row.ProcessId] = len(result)
+			result = append(result, Process{Id: row.ProcessId, Name: row.Name.String, OrderSchema: row.Schema,
+				SearchPaths: row.SearchPaths})
+		}
+	}
+	for _, row := range rows {
+		i, ok := indexes[row.ProcessId]
+		if !ok {
+			continue
+		}
+		switch row.Kind {
+		case taskRowKind:
+			result[i].AddTask(&Task{
+				ProcessId:     row.ProcessId,
+				Id:            row.TaskId.String,
+				Name:          row.Name.String,
+				Category:      int(row.Category.Int64),
+				Action:        row.Action.String,
+				Sync:          row.Sync.Bool,
+				Http:          row.Http,
+				ReadMappingId: row.ReadMappingId.String,
+				OutputSchema:  row.Schema,
+			})
+		case taskRelationRowKind:
+			result[i].AddTaskRelation(&TaskRelation{
+				ProcessId: row.ProcessId,
+				ParentId:  row.ParentId.String,
+				ChildId:   row.ChildId.String,
+			})
+		}
+	}
+	return result
+}
+
 func (s RDBProcessRepo) DeleteById(ctx context.Context, id string) error {
 	const op = "ProcessRepo.DeleteById"
 
@@ -172,3 +331,19 @@ func (s RDBProcessRepo) DeleteById(ctx context.Context, id string) error {
 	}
 	return domain.E(op, "there's no active transaction")
 }
+
+// Number of the orders of the process which have jobs neither completed nor failed
+func (s RDBProcessRepo) GetRunningOrderCount(ctx context.Context, id string, result *int) error {
+	const op = "ProcessRepo.GetRunningOrderCount"
+
+	var err error
+	if tx, ok := TransactionFromContext(ctx); ok {
+		err = tx.GetContext(ctx, result, getRunningOrderCount, id)
+	} else {
+		err = s.db.GetContext(ctx, result, getRunningOrderCount, id)
+	}
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't count running orders (%s)", id), err)
+	}
+	return nil
+}
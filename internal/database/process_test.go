@@ -1,29 +1,83 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
+func mockProcessRows(mockDB *MockDB, query string, args []interface{}, rows []processRow) {
+	mockDB.On("SelectContext", testCtx, mock.AnythingOfType("*[]database.processRow"), query, args).Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]processRow) = rows
+		})
+}
+
+func testProcessRows() []processRow {
+	return []processRow{
+		{Kind: taskRowKind, ProcessId: "1", Name: sql.NullString{String: "first", Valid: true},
+			TaskId: sql.NullString{String: "t1", Valid: true}, Action: sql.NullString{String: "http://first", Valid: true},
+			Sync: sql.NullBool{Bool: true, Valid: true}, Http: &HttpTaskConfig{Method: "PUT"},
+			ReadMappingId: sql.NullString{String: "m1", Valid: true}, Schema: Body{"type": "object"}},
+		{Kind: taskRowKind, ProcessId: "1", Name: sql.NullString{String: "second", Valid: true},
+			TaskId: sql.NullString{String: "t2", Valid: true}, Category: sql.NullInt64{Int64: 2, Valid: true},
+			Action: sql.NullString{String: "topic", Valid: true}, ReadMappingId: sql.NullString{String: "m1", Valid: true}},
+		{Kind: taskRowKind, ProcessId: "3", Name: sql.NullString{String: "orphan", Valid: true}},
+		{Kind: taskRelationRowKind, ProcessId: "1", ParentId: sql.NullString{String: "t1", Valid: true},
+			ChildId: sql.NullString{String: "t2", Valid: true}},
+		{Kind: processRowKind, ProcessId: "1", Name: sql.NullString{String: "process", Valid: true},
+			Schema: Body{"required": []interface{}{"id"}}},
+		{Kind: processRowKind, ProcessId: "2", Name: sql.NullString{String: "empty", Valid: true}},
+	}
+}
+
 func TestProcessRepo_GetAll_Success(t *testing.T) {
 	assert := assert.New(t)
 
+	mockDB := new(MockDB)
+	mockProcessRows(mockDB, getProcessRows, []interface{}(nil), testProcessRows())
+
 	var processes []Process
-	var tasks []Task
-	var relations []TaskRelation
+	repo := RDBProcessRepo{db: mockDB}
+	err := repo.GetAll(testCtx, &processes)
+	assert.Nil(err)
+	assert.Equal([]Process{
+		{Id: "1", Name: "process", OrderSchema: Body{"required": []interface{}{"id"}},
+			Tasks: []Task{
+				{ProcessId: "1", Id: "t1", Name: "first", Action: "http://first", Sync: true,
+					Http: &HttpTaskConfig{Method: "PUT"}, ReadMappingId: "m1", OutputSchema: Body{"type": "object"}},
+				{ProcessId: "1", Id: "t2", Name: "second", Category: 2, Action: "topic", ReadMappingId: "m1"},
+			},
+			TaskRelations: []TaskRelation{{ProcessId: "1", ParentId: "t1", ChildId: "t2"}},
+		},
+		{Id: "2", Name: "empty"},
+	}, processes)
+}
+
+func TestProcessRepo_GetAll_Error(t *testing.T) {
+	const op = "ProcessRepo.GetAll"
+	assert := assert.New(t)
 
 	mockDB := new(MockDB)
-	mockDB.On("SelectContext", testCtx, &processes, getProcesses, []interface{}(nil)).Return(nil)
-	mockDB.On("SelectContext", testCtx, &tasks, getTasks, []interface{}(nil)).Return(nil)
-	mockDB.On("SelectContext", testCtx, &relations, getTaskRelations, []interface{}(nil)).Return(nil)
+	mockDB.On("SelectContext", testCtx, mock.Anything, getProcessRows, []interface{}(nil)).
+		Return(errors.New("mock error"))
 
+	var processes []Process
 	repo := RDBProcessRepo{db: mockDB}
 	err := repo.GetAll(testCtx, &processes)
-	assert.Nil(err)
+	assert.NotNil(err)
+	domainErr := toError(t, op, err)
+	assert.Equal("can't select processes", domainErr.Msg)
 }
 
 func TestProcessRepo_GetById_Success(t *testing.T) {
@@ -31,14 +85,15 @@ func TestProcessRepo_GetById_Success(t *testing.T) {
 	assert := assert.New(t)
 
 	mockDB := new(MockDB)
-	process := Process{}
-	mockDB.On("GetContext", testCtx, &process, getProcessById, []interface{}{id}).Return(nil)
-	mockDB.On("SelectContext", testCtx, &process.Tasks, getTasksByProcessId, []interface{}{id}).Return(nil)
-	mockDB.On("SelectContext", testCtx, &process.TaskRelations, getTaskRelationsByProcessId, []interface{}{id}).Return(nil)
+	mockProcessRows(mockDB, getProcessRowsById, []interface{}{id}, testProcessRows()[:5])
 
+	var process Process
 	repo := RDBProcessRepo{db: mockDB}
 	err := repo.GetById(testCtx, id, &process)
 	assert.Nil(err)
+	assert.Equal("process", process.Name)
+	assert.Len(process.Tasks, 2)
+	assert.Len(process.TaskRelations, 1)
 }
 
 func TestProcessRepo_GetById_NotFound(t *testing.T) {
@@ -49,9 +104,9 @@ func TestProcessRepo_GetById_NotFound(t *testing.T) {
 	assert := assert.New(t)
 
 	mockDB := new(MockDB)
-	process := Process{}
-	mockDB.On("GetContext", testCtx, &process, getProcessById, []interface{}{id}).Return(sql.ErrNoRows)
+	mockProcessRows(mockDB, getProcessRowsById, []interface{}{id}, nil)
 
+	var process Process
 	repo := RDBProcessRepo{db: mockDB}
 	err := repo.GetById(testCtx, id, &process)
 	assert.NotNil(err)
@@ -70,9 +125,9 @@ func TestProcessRepo_GetById_ProcessError(t *testing.T) {
 	mockErr := errors.New(mockErrMsg)
 
 	mockDB := new(MockDB)
-	process := Process{}
-	mockDB.On("GetContext", testCtx, &process, getProcessById, []interface{}{id}).Return(mockErr)
+	mockDB.On("SelectContext", testCtx, mock.Anything, getProcessRowsById, []interface{}{id}).Return(mockErr)
 
+	var process Process
 	repo := RDBProcessRepo{db: mockDB}
 	err := repo.GetById(testCtx, id, &process)
 	assert.NotNil(err)
@@ -83,53 +138,44 @@ func TestProcessRepo_GetById_ProcessError(t *testing.T) {
 	assert.Equal(mockErrMsg, domainErr.Err.Error())
 }
 
-func TestProcessRepo_GetById_TaskError(t *testing.T) {
-	const (
-		id         = "1"
-		op         = "ProcessRepo.GetById"
-		mockErrMsg = "mock error"
-	)
-	assert := assert.New(t)
-	mockErr := errors.New(mockErrMsg)
-
-	mockDB := new(MockDB)
-	process := Process{}
-	mockDB.On("GetContext", testCtx, &process, getProcessById, []interface{}{id}).Return(nil)
-	mockDB.On("SelectContext", testCtx, &process.Tasks, getTasksByProcessId, []interface{}{id}).Return(mockErr)
-
-	repo := RDBProcessRepo{db: mockDB}
-	err := repo.GetById(testCtx, id, &process)
-	assert.NotNil(err)
-	domainErr := toError(t, op, err)
-	assert.Equal(op, string(domainErr.Op))
-	assert.Equal(fmt.Sprintf("can't select tasks (%s)", id), domainErr.Msg)
-	assert.NotNil(domainErr.Err)
-	assert.Equal(mockErrMsg, domainErr.Err.Error())
-}
-
-func TestProcessRepo_GetById_RelationError(t *testing.T) {
-	const (
-		id         = "1"
-		op         = "ProcessRepo.GetById"
-		mockErrMsg = "mock error"
-	)
+func TestProcessRepo_Create_Batch(t *testing.T) {
+	const processId = "00000000-0000-0000-0000-000000000001"
 	assert := assert.New(t)
-	mockErr := errors.New(mockErrMsg)
 
 	mockDB := new(MockDB)
-	process := Process{}
-	mockDB.On("GetContext", testCtx, &process, getProcessById, []interface{}{id}).Return(nil)
-	mockDB.On("SelectContext", testCtx, &process.Tasks, getTasksByProcessId, []interface{}{id}).Return(nil)
-	mockDB.On("SelectContext", testCtx, &process.TaskRelations, getTaskRelationsByProcessId, []interface{}{id}).Return(mockErr)
-
-	repo := RDBProcessRepo{db: mockDB}
-	err := repo.GetById(testCtx, id, &process)
-	assert.NotNil(err)
-	domainErr := toError(t, op, err)
-	assert.Equal(op, string(domainErr.Op))
-	assert.Equal(fmt.Sprintf("can't select task relations (%s)", id), domainErr.Msg)
-	assert.NotNil(domainErr.Err)
-	assert.Equal(mockErrMsg, domainErr.Err.Error())
+	txCtx := WithTransaction(testCtx, mockDB)
+	orderSchema := Body{"type": "object"}
+	outputSchema := Body{"required": []interface{}{"id"}}
+	searchPaths := Strings{"$.id"}
+	mockDB.On("ExecContext", txCtx, createProcess, []interface{}{processId, "process", orderSchema, searchPaths}).
+		Return(nil, nil)
+	mockDB.On("ExecContext", txCtx,
+		createTasks+"($1, $2, $3, $4, $5, $6, $7, $8, $9), ($10, $11, $12, $13, $14, $15, $16, $17, $18)",
+		[]interface{}{processId, "t1", "first", 0, "http://first", false, (*HttpTaskConfig)(nil), "m1", outputSchema,
+			processId, "t2", "second", 0, "http://second", false, (*HttpTaskConfig)(nil), "m1", Body(nil)}).
+		Return(nil, nil)
+	mockDB.On("ExecContext", txCtx, createTasks+"($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		[]interface{}{processId, "t3", "third", 0, "http://third", false, (*HttpTaskConfig)(nil), "m1", Body(nil)}).
+		Return(nil, nil)
+	mockDB.On("ExecContext", txCtx, createTaskRelations+"($1, $2, $3), ($4, $5, $6)",
+		[]interface{}{processId, "t1", "t3", processId, "t2", "t3"}).Return(nil, nil)
+
+	process := &Process{
+		Name:        "process",
+		OrderSchema: orderSchema,
+		SearchPaths: searchPaths,
+		Tasks: []Task{
+			{Id: "t1", Name: "first", Action: "http://first", ReadMappingId: "m1", OutputSchema: outputSchema},
+			{Id: "t2", Name: "second", Action: "http://second", ReadMappingId: "m1"},
+			{Id: "t3", Name: "third", Action: "http://third", ReadMappingId: "m1"},
+		},
+		TaskRelations: []TaskRelation{{ParentId: "t1", ChildId: "t3"}, {ParentId: "t2", ChildId: "t3"}},
+	}
+	repo := RDBProcessRepo{db: mockDB, newUUIDFunc: func() (uuid.UUID, error) {
+		return uuid.MustParse(processId), nil
+	}, batchSize: 2}
+	assert.Nil(repo.Create(txCtx, process))
+	mockDB.AssertExpectations(t)
 }
 
 func TestProcessRepo_DeleteById_Success(t *testing.T) {
@@ -271,3 +317,99 @@ func TestProcessRepo_DeleteById_RelationError(t *testing.T) {
 	assert.NotNil(domainErr.Err)
 	assert.Equal(mockErrMsg, domainErr.Err.Error())
 }
+
+// go test -run none -bench ProcessRepo -benchmem example.com/oligzeev/pp-gin/internal/database
+// Processes of hundreds of tasks are created by single-row (batch=1) and multi-row statements of SQLite
+// (and Postgres if PP_TEST_POSTGRES is set)
+func BenchmarkProcessRepo_Create(b *testing.B) {
+	for _, tasks := range []int{100, 500} {
+		for _, batchSize := range []int{1, defaultBatchSize} {
+			b.Run(fmt.Sprintf("tasks=%d/batch=%d", tasks, batchSize), func(b *testing.B) {
+				benchProcessRepo(b, func(b *testing.B, repo *RDBProcessRepo, execTx domain.ExecTxFunc) {
+					repo.batchSize = batchSize
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						benchProcess(b, repo, execTx, tasks)
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkProcessRepo_GetById(b *testing.B) {
+	for _, tasks := range []int{100, 500} {
+		b.Run(fmt.Sprintf("tasks=%d", tasks), func(b *testing.B) {
+			benchProcessRepo(b, func(b *testing.B, repo *RDBProcessRepo, execTx domain.ExecTxFunc) {
+				process := benchProcess(b, repo, execTx, tasks)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					var result Process
+					if err := repo.GetById(context.Background(), process.Id, &result); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func benchProcessRepo(b *testing.B, bench func(b *testing.B, repo *RDBProcessRepo, execTx domain.ExecTxFunc)) {
+	dir, err := ioutil.TempDir("", "pp-gin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sqliteDB, err := ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sqliteDB.Close()
+	if _, err := NewSQLiteMigrator(sqliteDB).Up(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("sqlite", func(b *testing.B) {
+		bench(b, &RDBProcessRepo{db: sqliteDB, newUUIDFunc: uuid.NewUUID}, sqliteDB.ExecTx)
+	})
+
+	cs := os.Getenv(testPostgresEnv)
+	if cs == "" {
+		return
+	}
+	pgDB, err := sqlx.Connect("pgx", cs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pgDB.Close()
+	if _, err := NewPostgresMigrator(pgDB).Up(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("postgres", func(b *testing.B) {
+		bench(b, &RDBProcessRepo{db: pgDB, newUUIDFunc: uuid.NewUUID}, func(ctx context.Context, f domain.TxFunc) error {
+			return ExecTx(ctx, pgDB, f)
+		})
+	})
+}
+
+// Chain of the tasks which use the same mapping
+func benchProcess(b *testing.B, repo *RDBProcessRepo, execTx domain.ExecTxFunc, tasks int) *Process {
+	ctx := context.Background()
+	mapping := &ReadMapping{Body: Body{"id": "$.id"}}
+	if err := NewRDBReadMappingRepo(repo.db, uuid.NewUUID).Create(ctx, mapping); err != nil {
+		b.Fatal(err)
+	}
+	process := &Process{Name: "bench"}
+	for i := 0; i < tasks; i++ {
+		process.AddTask(&Task{Id: uuid.New().String(), Name: fmt.Sprintf("task%d", i),
+			Action: "http://localhost", ReadMappingId: mapping.Id})
+		if i > 0 {
+			process.AddTaskRelation(&TaskRelation{ParentId: process.Tasks[i-1].Id, ChildId: process.Tasks[i].Id})
+		}
+	}
+	if err := execTx(ctx, func(txCtx context.Context) error {
+		return repo.Create(txCtx, process)
+	}); err != nil {
+		b.Fatal(err)
+	}
+	return process
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Condition of the containment of the document by the JSON column (GIN index of Postgres is used by @>),
+// SQLite uses the function of the driver
+func jsonContainsCondition(db DB, column string, doc Body, q *listQuery) string {
+	if _, ok := db.(*SQLiteDB); ok {
+		return fmt.Sprintf("pp_json_contains(%s, %s)", column, q.arg(doc))
+	}
+	return fmt.Sprintf("%s @> %s", column, q.arg(doc))
+}
+
+// Containment of the decoded JSON values as jsonb @> of Postgres: objects contain the fields of the document,
+// arrays contain every element of the document by any of their elements, scalars are equal
+func jsonContains(value, doc interface{}) bool {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, field := range d {
+			if vField, ok := v[key]; !ok || !jsonContains(vField, field) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, element := range d {
+			found := false
+			for _, vElement := range v {
+				if found = jsonContains(vElement, element); found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return value == doc
+}
+
+// Containment of the documents (JSON of the columns), invalid documents contain nothing
+func jsonDocContains(value, doc []byte) bool {
+	var v, d interface{}
+	if json.Unmarshal(value, &v) != nil || json.Unmarshal(doc, &d) != nil {
+		return false
+	}
+	return jsonContains(v, d)
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJsonDocContains(t *testing.T) {
+	value := `{"id": 1, "customer": {"id": "c1", "tags": ["a", "b"]}, "items": [{"id": "p1", "count": 2}, {"id": "p2"}]}`
+	for doc, expected := range map[string]bool{
+		`{}`:                                      true,
+		`{"id": 1}`:                               true,
+		`{"id": 1.0}`:                             true,
+		`{"id": "1"}`:                             false,
+		`{"customer": {"id": "c1"}}`:              true,
+		`{"customer": {"tags": ["b"]}}`:           true,
+		`{"customer": {"tags": ["b", "c"]}}`:      false,
+		`{"customer": {"tags": "a"}}`:             false,
+		`{"items": [{"id": "p2"}, {"id": "p1"}]}`: true,
+		`{"items": [{"id": "p2", "count": 2}]}`:   false,
+		`{"items": {"id": "p1"}}`:                 false,
+		`{"unknown": null}`:                       false,
+		`invalid`:                                 false,
+	} {
+		assert.Equal(t, expected, jsonDocContains([]byte(value), []byte(doc)), doc)
+	}
+}
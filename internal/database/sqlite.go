@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+)
+
+// Postgres placeholders ($1) are named parameters in SQLite (numbered by the order of appearance),
+// so they're replaced by the numbered ones (?1)
+var sqlitePlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+func sqliteQuery(query string) string {
+	return sqlitePlaceholder.ReplaceAllString(query, "?$1")
+}
+
+// SQLite database which executes the statements of the RDB repositories, transactions take the write lock
+// at the beginning (immediate), so there're no concurrent updates of the jobs
+type SQLiteDB struct {
+	*sqlx.DB
+}
+
+func ConnectSQLite(cfg domain.DbConfig) (*SQLiteDB, error) {
+	const op = "Database.ConnectSQLite"
+
+	cs := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate&_foreign_keys=1", cfg.File)
+	log.Debugf("Connect to database: %s", cs)
+
+	db, err := sqlx.Connect(sqliteDriver, cs)
+	if err != nil {
+		return nil, domain.E(op, "can't establish database connection", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MaxIdleConnections)
+	return &SQLiteDB{DB: db}, nil
+}
+
+func (db *SQLiteDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return db.DB.SelectContext(ctx, dest, sqliteQuery(query), args...)
+}
+
+func (db *SQLiteDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return db.DB.GetContext(ctx, dest, sqliteQuery(query), args...)
+}
+
+func (db *SQLiteDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, sqliteQuery(query), args...)
+}
+
+// Execute function in a database transaction
+func (db *SQLiteDB) ExecTx(ctx context.Context, f domain.TxFunc) error {
+	const op = "Transaction.Exec"
+
+	sqlTx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.E(op, "can't begin transaction", err)
+	}
+	return execInTx(ctx, &sqliteTx{Tx: sqlTx}, f)
+}
+
+type sqliteTx struct {
+	*sqlx.Tx
+}
+
+func (tx *sqliteTx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.Tx.SelectContext(ctx, dest, sqliteQuery(query), args...)
+}
+
+func (tx *sqliteTx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.Tx.GetContext(ctx, dest, sqliteQuery(query), args...)
+}
+
+func (tx *sqliteTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, sqliteQuery(query), args...)
+}
@@ -0,0 +1,69 @@
+//go:build cgo
+// +build cgo
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Driver with the functions of the queries (JSON1 extension isn't built without sqlite_json tag)
+const sqliteDriver = "sqlite3_pp"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("pp_json_field_equals", sqliteJsonFieldEquals, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("pp_json_contains", sqliteJsonContains, true)
+		},
+	})
+}
+
+// Text of the top-level field of the JSON document (as ->> of Postgres) equals the value, null field isn't equal
+func sqliteJsonFieldEquals(doc interface{}, key, value string) bool {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(sqliteBytes(doc), &fields); err != nil {
+		return false
+	}
+	switch field := fields[key].(type) {
+	case nil:
+		return false
+	case string:
+		return field == value
+	default:
+		text, _ := json.Marshal(field)
+		return string(text) == value
+	}
+}
+
+// JSON column contains the document (as @> of Postgres)
+func sqliteJsonContains(doc, contained interface{}) bool {
+	return jsonDocContains(sqliteBytes(doc), sqliteBytes(contained))
+}
+
+// Blob or text argument of the function, nil otherwise (NULL)
+func sqliteBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+// Violation of ON DELETE RESTRICT is reported as the trigger constraint, there're no other triggers in the schema
+func sqliteForeignKeyViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintTrigger)
+}
+
+func sqliteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLite doesn't support UPDATE ... RETURNING and locking clauses, so the jobs are selected and updated
+// in the transaction, timestamps are compared by julianday() since they're stored as text
+const (
+	sqliteGetReadyJobs = `SELECT task_id, category, action, sync, http, order_id, read_mapping_id, attempt, start_after,
+output, trace FROM pp_job WHERE ready_num >= ready_req AND started = FALSE AND failed = FALSE AND category <> $3
+AND (start_after IS NULL OR julianday(start_after) <= julianday($2)) LIMIT $1`
+	sqliteStartJob = `UPDATE pp_job SET started = TRUE, attempt = attempt + 1
+WHERE task_id = $1 AND order_id = $2`
+	sqliteFetchJobs = `SELECT task_id, category, action, order_id, read_mapping_id, attempt, locked_until, trace
+FROM pp_job WHERE category = $1 AND action IN (%s) AND ready_num >= ready_req AND completed = FALSE AND failed = FALSE
+AND (started = FALSE OR julianday(locked_until) < julianday($3))
+AND (start_after IS NULL OR julianday(start_after) <= julianday($3)) LIMIT $2`
+	sqliteLockJob = `UPDATE pp_job SET started = TRUE, attempt = attempt + 1, locked_until = $3
+WHERE task_id = $1 AND order_id = $2`
+	sqliteGetJobState = `SELECT attempt, completed, failed, error_code FROM pp_job
+WHERE task_id = $1 AND order_id = $2`
+	sqliteCompleteRelatedJobs = `UPDATE pp_job SET ready_num = ready_num + 1
+WHERE completed = FALSE AND task_id IN (
+  SELECT r.child_id FROM pp_task_rel r WHERE r.parent_id = $1
+) AND order_id = $2`
+)
+
+// JobRepo via SQLite database, statements which are portable are executed by RDBJobRepo
+type SQLiteJobRepo struct {
+	RDBJobRepo
+	db *SQLiteDB
+}
+
+func NewSQLiteJobRepo(db *SQLiteDB) JobRepo {
+	return &SQLiteJobRepo{RDBJobRepo: RDBJobRepo{db: db, batchSize: defaultBatchSize}, db: db}
+}
+
+func (s SQLiteJobRepo) GetReadyJobs(ctx context.Context, jobLimit int, jobs *[]Job) error {
+	const op = "JobRepo.GetReadyJobs"
+
+	err := s.db.ExecTx(ctx, func(txCtx context.Context) error {
+		tx, _ := TransactionFromContext(txCtx)
+		*jobs = nil
+		if err := tx.SelectContext(txCtx, jobs, sqliteGetReadyJobs, jobLimit, time.Now(),
+			domain.ExternalTaskCategory); err != nil {
+
+			return err
+		}
+		for i := range *jobs {
+			job := &(*jobs)[i]
+			if _, err := tx.ExecContext(txCtx, sqliteStartJob, job.TaskId, job.OrderId); err != nil {
+				return domain.E(op, fmt.Sprintf("can't start job (%s, %s)", job.TaskId, job.OrderId), err)
+			}
+			job.Attempt++
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Lock ready jobs of external tasks with the given topics (actions), expired locks are taken over
+func (s SQLiteJobRepo) FetchJobs(ctx context.Context, topics []string, jobLimit int, lockedUntil time.Time,
+	jobs *[]Job) error {
+
+	const op = "JobRepo.FetchJobs"
+
+	if len(topics) == 0 {
+		*jobs = nil
+		return nil
+	}
+	args := []interface{}{domain.ExternalTaskCategory, jobLimit, time.Now()}
+	placeholders := make([]string, len(topics))
+	for i, topic := range topics {
+		args = append(args, topic)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	query := fmt.Sprintf(sqliteFetchJobs, strings.Join(placeholders, ", "))
+
+	err := s.db.ExecTx(ctx, func(txCtx context.Context) error {
+		tx, _ := TransactionFromContext(txCtx)
+		*jobs = nil
+		if err := tx.SelectContext(txCtx, jobs, query, args...); err != nil {
+			return err
+		}
+		for i := range *jobs {
+			job := &(*jobs)[i]
+			if _, err := tx.ExecContext(txCtx, sqliteLockJob, job.TaskId, job.OrderId, lockedUntil); err != nil {
+				return domain.E(op, fmt.Sprintf("can't lock job (%s, %s)", job.TaskId, job.OrderId), err)
+			}
+			job.Attempt++
+			job.LockedUntil = &lockedUntil
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Complete job of the given attempt, empty attempt id matches any attempt.
+// Duplicate completion of the same attempt is acknowledged without any changes,
+// completion of a stale attempt is rejected with ErrStaleAttempt and completion of a cancelled job with ErrCancelled
+func (s SQLiteJobRepo) CompleteJob(ctx context.Context, taskId, orderId, attemptId string, output Body) error {
+	const op = "JobRepo.CompleteJob"
+
+	if tx, ok := TransactionFromContext(ctx); ok {
+		var state JobState
+		if err := tx.GetContext(ctx, &state, sqliteGetJobState, taskId, orderId); err != nil {
+			if err == sql.ErrNoRows {
+				return domain.E(op, domain.ErrNotFound)
+			}
+			return domain.E(op, fmt.Sprintf("can't get job state (%s, %s)", taskId, orderId), err)
+		}
+		if attemptId != "" && attemptId != domain.JobAttemptId(taskId, orderId, state.Attempt) {
+			return domain.E(op, domain.ErrStaleAttempt, fmt.Sprintf("stale attempt (%s)", attemptId))
+		}
+		if state.Completed {
+			return nil
+		}
+		if state.cancelled() {
+			return domain.E(op, domain.ErrCancelled, fmt.Sprintf("job is cancelled (%s, %s)", taskId, orderId))
+		}
+		if _, err := tx.ExecContext(ctx, completeJob, taskId, orderId, output); err != nil {
+			return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", taskId, orderId), err)
+		}
+		if _, err := tx.ExecContext(ctx, sqliteCompleteRelatedJobs, taskId, orderId); err != nil {
+			return domain.E(op, fmt.Sprintf("can't complete related jobs (%s, %s)", taskId, orderId), err)
+		}
+		return nil
+	}
+	return domain.E(op, "there's no active transaction")
+}
@@ -0,0 +1,16 @@
+//go:build !cgo
+// +build !cgo
+
+package database
+
+// Driver of mattn/go-sqlite3 fails to connect without cgo
+const sqliteDriver = "sqlite3"
+
+// SQLite driver is a stub without cgo, so there're no errors of SQLite
+func sqliteForeignKeyViolation(err error) bool {
+	return false
+}
+
+func sqliteUniqueViolation(err error) bool {
+	return false
+}
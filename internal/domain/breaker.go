@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+)
+
+const (
+	BreakerClosed   = api.BreakerClosed
+	BreakerHalfOpen = api.BreakerHalfOpen
+	BreakerOpen     = api.BreakerOpen
+)
+
+type Breaker = api.Breaker
+
+type BreakerService interface {
+	GetAll(ctx context.Context, result *[]Breaker) error
+	Reset(ctx context.Context, host string) error
+}
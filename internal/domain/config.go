@@ -6,6 +6,7 @@ type ServerRestConfig struct {
 	Host               string        `yaml:"host"`
 	Port               int           `yaml:"port"`
 	SwaggerUrl         string        `yaml:"swaggerUrl"`
+	DashboardUrl       string        `yaml:"dashboardUrl"` // Empty url disables the dashboard
 	MetricsUrl         string        `yaml:"metricsUrl"`
 	ReadTimeoutSec     time.Duration `yaml:"readTimeoutSec"`
 	WriteTimeoutSec    time.Duration `yaml:"writeTimeoutSec"`
@@ -22,12 +23,21 @@ type RestConfig struct {
 	Client ClientRestConfig `yaml:"client"`
 }
 
+const (
+	DbDriverPostgres = "postgres"
+	DbDriverSqlite   = "sqlite" // Requires cgo
+	DbDriverMemory   = "memory" // Data isn't persisted, e.g. for local runs and tests
+)
+
 type DbConfig struct {
+	Driver             string `yaml:"driver"` // Postgres by default
 	Host               string `yaml:"host"`
 	Port               int    `yaml:"port"`
 	User               string `yaml:"user"`
 	Password           string `yaml:"password"`
 	DbName             string `yaml:"dbName"`
+	File               string `yaml:"file"`    // Database file of SQLite
+	Migrate            bool   `yaml:"migrate"` // Apply pending migrations at startup
 	MaxConnections     int    `yaml:"maxConnections"`
 	MaxIdleConnections int    `yaml:"maxIdleConnections"`
 }
@@ -36,6 +46,13 @@ type CacheConfig struct {
 	DefaultEntityCount int `yaml:"defaultEntityCount"`
 }
 
+type OrderConfig struct {
+	BulkChunkSize           int           `yaml:"bulkChunkSize"`           // Orders of the bulk submission per transaction
+	BulkMaxOrders           int           `yaml:"bulkMaxOrders"`           // 0 means unlimited
+	BulkMaxAtomicOrders     int           `yaml:"bulkMaxAtomicOrders"`     // Atomic ones, 0 means unlimited
+	IdempotencyRetentionSec time.Duration `yaml:"idempotencyRetentionSec"` // 0 means keys are kept forever
+}
+
 type TracingConfig struct {
 	ServiceName string `yaml:"serviceName"`
 }
@@ -52,13 +69,44 @@ type BalanceConfig struct {
 }
 
 type SchedulerConfig struct {
-	Enabled   bool          `yaml:"enabled"`
-	PeriodSec time.Duration `yaml:"periodSec"`
-	JobLimit  int           `yaml:"jobLimit"`
+	Enabled       bool          `yaml:"enabled"`
+	PeriodSec     time.Duration `yaml:"periodSec"`
+	JobLimit      int           `yaml:"jobLimit"`
+	RetriesMax    int           `yaml:"retriesMax"`
+	RetryDelaySec time.Duration `yaml:"retryDelaySec"`
+}
+
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate"` // Requests per second, 0 means unlimited
+	Burst int     `yaml:"burst"`
+}
+
+type BreakerConfig struct {
+	Enabled           bool                       `yaml:"enabled"`
+	FailureThreshold  int                        `yaml:"failureThreshold"`  // Consecutive failures to open breaker (5)
+	OpenTimeoutSec    time.Duration              `yaml:"openTimeoutSec"`    // Delay before half-open state
+	HalfOpenSuccesses int                        `yaml:"halfOpenSuccesses"` // Successful probes to close breaker (1)
+	RateLimit         RateLimitConfig            `yaml:"rateLimit"`         // Default rate limit per host
+	Hosts             map[string]RateLimitConfig `yaml:"hosts"`             // Rate limits per host
+}
+
+type NatsConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Url             string        `yaml:"url"`
+	Stream          string        `yaml:"stream"`          // JetStream stream of start and complete messages
+	Subjects        []string      `yaml:"subjects"`        // Subjects of the stream, they have to cover actions of nats tasks
+	CompleteSubject string        `yaml:"completeSubject"` // Subject of complete messages
+	Durable         string        `yaml:"durable"`         // Durable consumer of complete messages
+	BatchSize       int           `yaml:"batchSize"`
+	MaxDeliver      int           `yaml:"maxDeliver"`
+	TimeoutSec      time.Duration `yaml:"timeoutSec"`
 }
 
 type StubConfig struct {
-	ResponseUrl string `yaml:"responseUrl"`
+	ServerUrl       string        `yaml:"serverUrl"`
+	Topics          []string      `yaml:"topics"` // Topics of external tasks handled by the pull loop
+	MaxJobs         int           `yaml:"maxJobs"`
+	LockDurationSec time.Duration `yaml:"lockDurationSec"`
 }
 
 // Possible tags in https://github.com/kelseyhightower/envconfig
@@ -66,9 +114,12 @@ type ApplicationConfig struct {
 	Rest      RestConfig      `yaml:"rest"`
 	DB        DbConfig        `yaml:"db"`
 	Cache     CacheConfig     `yaml:"cache"`
+	Order     OrderConfig     `yaml:"order"`
 	Tracing   TracingConfig   `yaml:"tracing"`
 	Logging   LoggingConfig   `yaml:"logging"`
 	Balance   BalanceConfig   `yaml:"balance"`
 	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Breaker   BreakerConfig   `yaml:"breaker"`
+	Nats      NatsConfig      `yaml:"nats"`
 	Stub      StubConfig      `yaml:"stub"`
 }
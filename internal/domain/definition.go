@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+)
+
+var (
+	TaskCategoryNames = map[int]string{
+		HttpTaskCategory:     "http",
+		NatsTaskCategory:     "nats",
+		ExternalTaskCategory: "external",
+		TimerTaskCategory:    "timer",
+	}
+)
+
+type (
+	ProcessDefinition = api.ProcessDefinition
+	TaskDefinition    = api.TaskDefinition
+)
+
+type ProcessDefinitionService interface {
+	Import(ctx context.Context, def *ProcessDefinition, result *Process) error
+	Export(ctx context.Context, processId string, result *ProcessDefinition) error
+	ImportBpmn(ctx context.Context, data []byte, result *Process) error
+}
@@ -1,8 +1,11 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+)
 
-type Body map[string]interface{}
+type Body = api.Body
 
 type TxFunc func(txCtx context.Context) error
 type ExecTxFunc func(ctx context.Context, f TxFunc) error
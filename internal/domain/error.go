@@ -1,94 +1,87 @@
 package domain
 
 import (
-	"bytes"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors of the API, see api package
+type (
+	ErrCode     = api.ErrCode
+	ErrOp       = api.ErrOp
+	Error       = api.Error
+	RemoteError = api.RemoteError
 )
 
 const (
-	ErrPrefix   ErrCode = "APP-"
-	ErrInternal         = ErrPrefix + "0001"
-	ErrNotFound         = ErrPrefix + "0002"
+	ErrPrefix          = api.ErrPrefix
+	ErrInternal        = api.ErrInternal
+	ErrNotFound        = api.ErrNotFound
+	ErrStaleAttempt    = api.ErrStaleAttempt
+	ErrRemoteRetryable = api.ErrRemoteRetryable
+	ErrRemotePermanent = api.ErrRemotePermanent
+	ErrValidation      = api.ErrValidation
+	ErrDeferred        = api.ErrDeferred
+	ErrCancelled       = api.ErrCancelled
+	ErrConflict        = api.ErrConflict
 )
 
-type ErrCode string
-type ErrOp string
-type Error struct {
-	Code ErrCode `json:"code"`
-	Op   ErrOp   `json:"op"`
-	Msg  string  `json:"msg"`
-	Err  error   `json:"err"`
+// Call which hasn't been performed (e.g. circuit breaker is open), it could be repeated after the delay
+type DeferredError struct {
+	Delay time.Duration
 }
 
-func (e *Error) Error() string {
-	var buf bytes.Buffer
-	buf.WriteString(string(e.Op))
-	if e.Code != "" {
-		buf.WriteString("|")
-		buf.WriteString(string(e.Code))
-	}
-	if e.Msg != "" {
-		buf.WriteString("|")
-		buf.WriteString(e.Msg)
-	}
-	if e.Err != nil {
-		buf.WriteString(", ")
-		buf.WriteString(e.Err.Error())
-	}
-	return buf.String()
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("deferred for %v", e.Delay)
+}
+
+// Violations of JSON Schema (e.g. of the order body), every violation describes the field and the reason
+type SchemaError struct {
+	Violations []string
+}
+
+func (e *SchemaError) Error() string {
+	return strings.Join(e.Violations, ", ")
 }
 
 func E(op ErrOp, args ...interface{}) error {
-	e := &Error{Op: op}
-	for _, arg := range args {
-		switch arg := arg.(type) {
-		case error:
-			e.Err = arg
-		case ErrCode:
-			e.Code = arg
-		case string:
-			e.Msg = arg
-		}
-	}
-	return e
+	return api.E(op, args...)
 }
 
 func ECode(err error) ErrCode {
-	if err == nil {
-		return ""
-	} else if e, ok := err.(*Error); ok && e.Code != "" {
-		return e.Code
-	} else if ok && e.Err != nil {
-		return ECode(e.Err)
-	}
-	return ErrInternal
+	return api.ECode(err)
 }
 
 func EOps(err error) []ErrOp {
-	if e, ok := err.(*Error); ok {
-		result := []ErrOp{e.Op}
-		nextOps := EOps(e.Err)
-		if nextOps != nil {
-			return append(result, nextOps...)
-		}
-		return result
+	return api.EOps(err)
+}
+
+func EMsgs(err error) []string {
+	return api.EMsgs(err)
+}
+
+func ERemote(err error) *RemoteError {
+	return api.ERemote(err)
+}
+
+func EDeferred(err error) *DeferredError {
+	switch e := err.(type) {
+	case *DeferredError:
+		return e
+	case *Error:
+		return EDeferred(e.Err)
 	}
 	return nil
 }
 
-func EMsgs(err error) []string {
-	if e, ok := err.(*Error); ok {
-		var result []string
-		msg := e.Msg
-		if msg != "" {
-			result = append(result, msg)
-		}
-		if e.Err != nil {
-			next := EMsgs(e.Err)
-			if next != nil {
-				result = append(result, next...)
-			}
-		}
-		return result
+func ESchema(err error) *SchemaError {
+	switch e := err.(type) {
+	case *SchemaError:
+		return e
+	case *Error:
+		return ESchema(e.Err)
 	}
-	return []string{err.Error()}
+	return nil
 }
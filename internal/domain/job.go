@@ -1,26 +1,83 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
-	HttpTaskCategory int = iota
+	HttpTaskCategory     = api.HttpTaskCategory
+	NatsTaskCategory     = api.NatsTaskCategory
+	ExternalTaskCategory = api.ExternalTaskCategory
+	TimerTaskCategory    = api.TimerTaskCategory
 )
 
-type JobStartMessage struct {
-	TaskId  string `json:"taskId"`
-	OrderId string `json:"orderId"`
-	Body    Body   `json:"body"`
-}
+const (
+	JobWaiting   = api.JobWaiting
+	JobReady     = api.JobReady
+	JobStarted   = api.JobStarted
+	JobCompleted = api.JobCompleted
+	JobFailed    = api.JobFailed
+	JobCancelled = api.JobCancelled
+)
 
-type JobCompleteMessage struct {
-	TaskId  string `json:"taskId"`
-	OrderId string `json:"orderId"`
-}
+type (
+	Job                    = api.Job
+	JobStartMessage        = api.JobStartMessage
+	JobCompleteMessage     = api.JobCompleteMessage
+	JobRef                 = api.JobRef
+	JobFetchRequest        = api.JobFetchRequest
+	LockedJob              = api.LockedJob
+	JobLockCompleteMessage = api.JobLockCompleteMessage
+	JobLockFailMessage     = api.JobLockFailMessage
+	JobLockExtendMessage   = api.JobLockExtendMessage
+)
 
 type JobCompleteClient interface {
 	Complete(ctx context.Context, msg *JobCompleteMessage) error
 }
 
+// Options of the start request resolved from the task configuration, zero values mean client's defaults
+type JobStartOptions struct {
+	Method     string
+	Headers    map[string]string
+	Query      map[string]string
+	Timeout    time.Duration
+	RetriesMax *int
+}
+
 type JobStartClient interface {
-	Start(ctx context.Context, dest string, msg *JobStartMessage) error
+	// Returns decoded response body (if any), it's the output of synchronous tasks
+	Start(ctx context.Context, dest string, msg *JobStartMessage, opts *JobStartOptions) (Body, error)
+}
+
+// Attempt id is the same for all retries of one start attempt, so it's used as an idempotency key
+func JobAttemptId(taskId, orderId string, attempt int) string {
+	return fmt.Sprintf("%s.%s.%d", orderId, taskId, attempt)
+}
+
+// Parse attempt id (it's also the lock id of external jobs) into task id, order id and attempt
+func ParseJobAttemptId(attemptId string) (string, string, int, error) {
+	const op = "ParseJobAttemptId"
+
+	parts := strings.Split(attemptId, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", 0, E(op, ErrValidation, fmt.Sprintf("invalid attempt id (%s)", attemptId))
+	}
+	attempt, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", "", 0, E(op, ErrValidation, fmt.Sprintf("invalid attempt id (%s)", attemptId), err)
+	}
+	return parts[1], parts[0], attempt, nil
+}
+
+type ExternalJobService interface {
+	FetchAndLock(ctx context.Context, req *JobFetchRequest, result *[]LockedJob) error
+	Complete(ctx context.Context, lockId string, msg *JobLockCompleteMessage) error
+	Fail(ctx context.Context, lockId string, msg *JobLockFailMessage) error
+	ExtendLock(ctx context.Context, lockId string, msg *JobLockExtendMessage) error
 }
@@ -20,6 +20,8 @@ type ReadMapping struct {
 
 type ReadMappingService interface {
 	GetAll(ctx context.Context, result *[]ReadMapping) error
+	// Mappings are sorted by id
+	GetPage(ctx context.Context, query *PageQuery, result *[]ReadMapping, page *Page) error
 	Create(ctx context.Context, order *ReadMapping) error
 	GetById(ctx context.Context, id string, result *ReadMapping) error
 	DeleteById(ctx context.Context, id string) error
@@ -2,19 +2,19 @@ package domain
 
 import (
 	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"io"
 )
 
 func CloneOrder(from, to *Order) {
 	to.Id = from.Id
 	to.ProcessId = from.ProcessId
 	to.Body = from.Body
+	to.IdempotencyKey = from.IdempotencyKey
+	to.CreatedAt = from.CreatedAt
 }
 
-type Order struct {
-	Id        string `json:"id"`
-	ProcessId string `json:"processId"`
-	Body      Body   `json:"body"`
-}
+type Order = api.Order
 
 /* TBD Structure stored in jsonb as-is
 type OrderItem struct {
@@ -31,9 +31,44 @@ type Specification struct {
 	Id string
 }*/
 
+// Source of the bulk submission, the orders are read one by one (e.g. decoded from the stream of the request),
+// io.EOF is returned after the last one
+type OrderReader interface {
+	Next(order *Order) error
+}
+
+// Reader of the orders which are already in memory
+type OrderSlice []Order
+
+func (s *OrderSlice) Next(order *Order) error {
+	if len(*s) == 0 {
+		return io.EOF
+	}
+	*order = (*s)[0]
+	*s = (*s)[1:]
+	return nil
+}
+
+type (
+	BulkOrderResult = api.BulkOrderResult
+	BulkOrderItem   = api.BulkOrderItem
+)
+
 type OrderService interface {
 	SubmitOrder(ctx context.Context, order *Order, processId string) error
+	// Orders are read and submitted by the chunks of the separate transactions, failed orders are reported
+	// by the items. Atomic submission uses the single transaction, error is returned if any order is failed.
+	// Items of the orders handled so far are reported along with the error as well
+	SubmitOrders(ctx context.Context, orders OrderReader, processId string, atomic bool, result *BulkOrderResult) error
 	GetOrders(ctx context.Context, result *[]Order) error
+	GetOrdersPage(ctx context.Context, query *OrderQuery, result *[]Order, page *Page) error
+	// Counts of the orders per process id, processes without orders are absent
+	CountOrdersByProcess(ctx context.Context, result *map[string]int) error
+	// Orders of the process (process id of the query is required) which match all the predicates
+	SearchOrders(ctx context.Context, query *OrderQuery, search *OrderSearch, result *[]Order, page *Page) error
 	GetOrderById(ctx context.Context, id string, result *Order) error
-	CompleteJob(ctx context.Context, taskId, orderId string) error
+	GetOrderJobs(ctx context.Context, orderId string, result *[]Job) error
+	CompleteJob(ctx context.Context, msg *JobCompleteMessage) error
+	RetryJob(ctx context.Context, ref *JobRef) error
+	CancelJob(ctx context.Context, ref *JobRef) error
 }
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"time"
+)
+
+const (
+	DefaultPageLimit = api.DefaultPageLimit
+	MaxPageLimit     = api.MaxPageLimit
+)
+
+const (
+	OrderRunning   = api.OrderRunning
+	OrderCompleted = api.OrderCompleted
+	OrderFailed    = api.OrderFailed
+)
+
+type (
+	PageQuery       = api.PageQuery
+	Page            = api.Page
+	SearchPredicate = api.SearchPredicate
+	OrderSearch     = api.OrderSearch
+)
+
+// Filters of the orders (see api.OrderQuery) along with the containments of the search
+type OrderQuery struct {
+	PageQuery
+	ProcessId   string
+	Status      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Body        map[string]string
+
+	// Body contains every document (as jsonb containment), the documents are built by the search predicates
+	Contains []Body
+}
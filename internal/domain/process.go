@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
 )
 
 func CloneProcess(from, to *Process) {
@@ -9,30 +10,21 @@ func CloneProcess(from, to *Process) {
 	to.Name = from.Name
 	to.Tasks = from.Tasks
 	to.TaskRelations = from.TaskRelations
+	to.OrderSchema = from.OrderSchema
+	to.SearchPaths = from.SearchPaths
 }
 
-type Process struct {
-	Id            string         `json:"id"`
-	Name          string         `json:"name"`
-	Tasks         []Task         `json:"tasks"`
-	TaskRelations []TaskRelation `json:"taskRelations"`
-}
-
-type Task struct {
-	Id            string `json:"id"`
-	Name          string `json:"name"`
-	Category      int    `json:"category"` // TBD Return string value instead of integer
-	Action        string `json:"action"`
-	ReadMappingId string `json:"readMappingId"`
-}
-
-type TaskRelation struct {
-	ParentId string `json:"parentId"`
-	ChildId  string `json:"childId"`
-}
+type (
+	Process        = api.Process
+	Task           = api.Task
+	HttpTaskConfig = api.HttpTaskConfig
+	TaskRelation   = api.TaskRelation
+)
 
 type ProcessService interface {
 	GetAll(ctx context.Context, result *[]Process) error
+	// Processes are sorted by name (by default) or id
+	GetPage(ctx context.Context, query *PageQuery, result *[]Process, page *Page) error
 	Create(ctx context.Context, obj *Process) error
 	GetById(ctx context.Context, id string, result *Process) error
 	DeleteById(ctx context.Context, id string) error
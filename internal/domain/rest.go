@@ -1,10 +1,18 @@
 package domain
 
-import "github.com/gin-gonic/gin"
+import (
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"github.com/gin-gonic/gin"
+)
 
 const (
-	HeaderContentType          = "Content-Type"
-	ContentTypeApplicationJson = "application/json"
+	HeaderContentType            = api.HeaderContentType
+	HeaderIdempotencyKey         = api.HeaderIdempotencyKey
+	HeaderTotalCount             = api.HeaderTotalCount
+	HeaderNextCursor             = api.HeaderNextCursor
+	ContentTypeApplicationJson   = api.ContentTypeApplicationJson
+	ContentTypeApplicationYaml   = api.ContentTypeApplicationYaml
+	ContentTypeApplicationNdjson = api.ContentTypeApplicationNdjson
 )
 
 type RestHandler interface {
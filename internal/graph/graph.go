@@ -0,0 +1,107 @@
+// Package graph renders processes (and progress of their orders) as Graphviz DOT, Mermaid and SVG.
+package graph
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"io"
+)
+
+const (
+	FormatDot     = "dot"
+	FormatMermaid = "mermaid"
+	FormatSvg     = "svg"
+)
+
+var (
+	contentTypes = map[string]string{
+		FormatDot:     "text/vnd.graphviz; charset=utf-8",
+		FormatMermaid: "text/plain; charset=utf-8",
+		FormatSvg:     "image/svg+xml",
+	}
+	// Fill colors of the tasks by job statuses
+	statusColors = map[string]string{
+		"":                  "#ffffff",
+		domain.JobWaiting:   "#eeeeee",
+		domain.JobReady:     "#bbdefb",
+		domain.JobStarted:   "#fff59d",
+		domain.JobCompleted: "#c8e6c9",
+		domain.JobFailed:    "#ef9a9a",
+		domain.JobCancelled: "#bdbdbd",
+	}
+)
+
+type node struct {
+	id     string
+	label  string
+	detail string // Category and action of the task, status of the job
+	status string
+	layer  int
+	row    int
+}
+
+type edge struct {
+	parent int
+	child  int
+}
+
+// Graph of the process tasks, the nodes are in the order of the tasks
+type graph struct {
+	name  string
+	nodes []node
+	edges []edge
+}
+
+func newGraph(process *domain.Process, statuses map[string]string) *graph {
+	g := &graph{name: process.Name, nodes: make([]node, len(process.Tasks))}
+	indexes := make(map[string]int, len(process.Tasks))
+	for i, task := range process.Tasks {
+		indexes[task.Id] = i
+		category, ok := domain.TaskCategoryNames[task.Category]
+		if !ok {
+			category = fmt.Sprint(task.Category)
+		}
+		g.nodes[i] = node{id: task.Id, label: task.Name, detail: category + ": " + task.Action}
+		if statuses != nil {
+			g.nodes[i].status = statuses[task.Id]
+			if g.nodes[i].status == "" {
+				g.nodes[i].status = domain.JobWaiting
+			}
+			g.nodes[i].detail = g.nodes[i].status
+		}
+	}
+	for _, rel := range process.TaskRelations {
+		parent, parentOk := indexes[rel.ParentId]
+		child, childOk := indexes[rel.ChildId]
+		if parentOk && childOk {
+			g.edges = append(g.edges, edge{parent: parent, child: child})
+		}
+	}
+	return g
+}
+
+// Render process, statuses of the jobs by task ids color the tasks (nil means the process without an order)
+func Render(w io.Writer, format string, process *domain.Process, statuses map[string]string) error {
+	const op = "Graph.Render"
+
+	g := newGraph(process, statuses)
+	var err error
+	switch format {
+	case FormatDot:
+		err = g.dot(w)
+	case FormatMermaid:
+		err = g.mermaid(w)
+	case FormatSvg:
+		err = g.svg(w)
+	default:
+		return domain.E(op, domain.ErrValidation, fmt.Sprintf("unsupported format (%s)", format))
+	}
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+func ContentType(format string) string {
+	return contentTypes[format]
+}
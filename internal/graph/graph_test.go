@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"bytes"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func testProcess() *domain.Process {
+	return &domain.Process{
+		Name: `Order "A"`,
+		Tasks: []domain.Task{
+			{Id: "t1", Name: "Reserve", Category: domain.HttpTaskCategory, Action: "http://stock/reserve"},
+			{Id: "t2", Name: "Approve", Category: domain.ExternalTaskCategory, Action: "user"},
+			{Id: "t3", Name: "Close", Category: domain.TimerTaskCategory, Action: "1m0s"},
+		},
+		TaskRelations: []domain.TaskRelation{
+			{ParentId: "t1", ChildId: "t3"},
+			{ParentId: "t2", ChildId: "t3"},
+			{ParentId: "t2", ChildId: "absent"},
+		},
+	}
+}
+
+func TestRender_Dot(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatDot, testProcess(), map[string]string{"t1": domain.JobCompleted}))
+	assert.Equal(`digraph "Order \"A\"" {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor="#ffffff", fontname="Helvetica"];
+  "t1" [label="Reserve\ncompleted", fillcolor="#c8e6c9"];
+  "t2" [label="Approve\nwaiting", fillcolor="#eeeeee"];
+  "t3" [label="Close\nwaiting", fillcolor="#eeeeee"];
+  "t1" -> "t3";
+  "t2" -> "t3";
+}
+`, buf.String())
+}
+
+func TestRender_Mermaid(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatMermaid, testProcess(), nil))
+	assert.Equal(`flowchart LR
+  t0["Reserve<br/><small>http: http://stock/reserve</small>"]
+  t1["Approve<br/><small>external: user</small>"]
+  t2["Close<br/><small>timer: 1m0s</small>"]
+  t0 --> t2
+  t1 --> t2
+`, buf.String())
+}
+
+func TestRender_Svg(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatSvg, testProcess(), map[string]string{"t2": domain.JobFailed}))
+	svg := buf.String()
+	assert.True(strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="500" height="160"`))
+	assert.Contains(svg, "<title>Order &#34;A&#34;</title>")
+	// Roots are in the first layer, their child is in the second one
+	assert.Contains(svg, `<rect x="20" y="20" width="200" height="50" rx="8" fill="#eeeeee"`)
+	assert.Contains(svg, `<rect x="20" y="90" width="200" height="50" rx="8" fill="#ef9a9a"`)
+	assert.Contains(svg, `<rect x="280" y="20" width="200" height="50" rx="8" fill="#eeeeee"`)
+	assert.Equal(2, strings.Count(svg, "<line "))
+}
+
+func TestRender_Cycle(t *testing.T) {
+	assert := assert.New(t)
+
+	process := testProcess()
+	process.TaskRelations = append(process.TaskRelations, domain.TaskRelation{ParentId: "t3", ChildId: "t1"})
+	g := newGraph(process, nil)
+	width, _ := g.layout()
+	assert.Equal([]int{1, 0, 1}, []int{g.nodes[0].layer, g.nodes[1].layer, g.nodes[2].layer})
+	assert.Equal(500, width)
+}
+
+func TestRender_UnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := Render(&buf, "png", testProcess(), nil)
+	assert.Equal(t, domain.ErrValidation, domain.ECode(err))
+}
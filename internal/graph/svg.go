@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const (
+	nodeWidth  = 200
+	nodeHeight = 50
+	layerGap   = 60
+	rowGap     = 20
+	margin     = 20
+)
+
+// Layers of the nodes by the longest path from the roots (Kahn's algorithm), nodes of the cycles (if any) are placed
+// into the last layer
+func (g *graph) layout() (int, int) {
+	indegree := make([]int, len(g.nodes))
+	children := make([][]int, len(g.nodes))
+	for _, e := range g.edges {
+		indegree[e.child]++
+		children[e.parent] = append(children[e.parent], e.child)
+	}
+	var queue []int
+	for i := range g.nodes {
+		g.nodes[i].layer = -1
+		if indegree[i] == 0 {
+			g.nodes[i].layer = 0
+			queue = append(queue, i)
+		}
+	}
+	layers := 1
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, child := range children[i] {
+			if g.nodes[i].layer+1 > g.nodes[child].layer {
+				g.nodes[child].layer = g.nodes[i].layer + 1
+			}
+			if indegree[child]--; indegree[child] == 0 {
+				queue = append(queue, child)
+				if g.nodes[child].layer+1 > layers {
+					layers = g.nodes[child].layer + 1
+				}
+			}
+		}
+	}
+	rows := make([]int, layers+1)
+	for i := range g.nodes {
+		if indegree[i] > 0 {
+			g.nodes[i].layer = layers
+		}
+		g.nodes[i].row = rows[g.nodes[i].layer]
+		rows[g.nodes[i].layer]++
+	}
+	maxLayer, maxRows := 0, 0
+	for layer, count := range rows {
+		if count > 0 && layer+1 > maxLayer {
+			maxLayer = layer + 1
+		}
+		if count > maxRows {
+			maxRows = count
+		}
+	}
+	width := 2*margin + maxLayer*nodeWidth + (maxLayer-1)*layerGap
+	height := 2*margin + maxRows*nodeHeight + (maxRows-1)*rowGap
+	if len(g.nodes) == 0 {
+		width, height = 2*margin, 2*margin
+	}
+	return width, height
+}
+
+func (n *node) position() (int, int) {
+	return margin + n.layer*(nodeWidth+layerGap), margin + n.row*(nodeHeight+rowGap)
+}
+
+func (g *graph) svg(w io.Writer) error {
+	width, height := g.layout()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="Helvetica, Arial, sans-serif">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(g.name))
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" ` +
+		`markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#555555"/></marker></defs>` + "\n")
+	for _, e := range g.edges {
+		parentX, parentY := g.nodes[e.parent].position()
+		childX, childY := g.nodes[e.child].position()
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#555555" marker-end="url(#arrow)"/>`+"\n",
+			parentX+nodeWidth, parentY+nodeHeight/2, childX, childY+nodeHeight/2)
+	}
+	for _, n := range g.nodes {
+		x, y := n.position()
+		fmt.Fprintf(&b, `<g><title>%s</title>`, html.EscapeString(n.id))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="8" fill="%s" stroke="#555555"/>`,
+			x, y, nodeWidth, nodeHeight, statusColors[n.status])
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="14">%s</text>`,
+			x+nodeWidth/2, y+20, html.EscapeString(truncate(n.label, 24)))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="11" fill="#555555">%s</text>`,
+			x+nodeWidth/2, y+38, html.EscapeString(truncate(n.detail, 32)))
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length-1]) + "…"
+}
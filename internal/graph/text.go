@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	dotEscaper     = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", " ")
+)
+
+func (g *graph) dot(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", dotEscaper.Replace(g.name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\", fontname=\"Helvetica\"];\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\\n%s\"", dotEscaper.Replace(n.id), dotEscaper.Replace(n.label),
+			dotEscaper.Replace(n.detail))
+		if n.status != "" {
+			fmt.Fprintf(&b, ", fillcolor=\"%s\"", statusColors[n.status])
+		}
+		b.WriteString("];\n")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\";\n", dotEscaper.Replace(g.nodes[e.parent].id),
+			dotEscaper.Replace(g.nodes[e.child].id))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Nodes are identified by indexes of the tasks, the statuses are classes of the nodes
+func (g *graph) mermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.nodes {
+		fmt.Fprintf(&b, "  t%d[\"%s<br/><small>%s</small>\"]\n", i, mermaidEscaper.Replace(n.label),
+			mermaidEscaper.Replace(n.detail))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&b, "  t%d --> t%d\n", e.parent, e.child)
+	}
+	classes := make(map[string][]string)
+	var statuses []string
+	for i, n := range g.nodes {
+		if n.status == "" {
+			continue
+		}
+		if _, ok := classes[n.status]; !ok {
+			statuses = append(statuses, n.status)
+		}
+		classes[n.status] = append(classes[n.status], fmt.Sprintf("t%d", i))
+	}
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", status, statusColors[status])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[status], ","), status)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,160 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	natsio "github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Prefixes of JetStream message ids, start and complete messages of the attempt share the stream,
+// so their ids are namespaced by the kind of the message
+const (
+	startMsgIdPrefix    = "start."
+	completeMsgIdPrefix = "complete."
+)
+
+// Id of the message of the attempt, messages without the attempt id aren't deduplicated
+func msgId(prefix, attemptId string) string {
+	if attemptId == "" {
+		return ""
+	}
+	return prefix + attemptId
+}
+
+// Start message is published to the subject of the task action, attempt id is used as JetStream message id,
+// so duplicates are dropped by the server
+type JobStartNatsClient struct {
+	js natsio.JetStreamContext
+}
+
+func NewJobStartNatsClient(js natsio.JetStreamContext) domain.JobStartClient {
+	return &JobStartNatsClient{js: js}
+}
+
+func (c JobStartNatsClient) Start(ctx context.Context, dest string, msg *domain.JobStartMessage,
+	_ *domain.JobStartOptions) (domain.Body, error) {
+
+	const op = "JobStartNatsClient.Start"
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil, domain.E(op, fmt.Sprintf("can't marshal message (%s, %s)", msg.TaskId, msg.OrderId), err)
+	}
+	header := natsio.Header{}
+	header.Set(domain.HeaderIdempotencyKey, msg.AttemptId)
+	id := msgId(startMsgIdPrefix, msg.AttemptId)
+	if err := Publish(ctx, c.js, dest, header, msgBytes, natsio.MsgId(id)); err != nil {
+		return nil, domain.E(op, domain.ErrRemoteRetryable,
+			fmt.Sprintf("can't send message (%s, %s)", msg.TaskId, msg.OrderId), err)
+	}
+	return nil, nil
+}
+
+type JobCompleteNatsClient struct {
+	js      natsio.JetStreamContext
+	subject string
+}
+
+func NewJobCompleteNatsClient(js natsio.JetStreamContext, subject string) domain.JobCompleteClient {
+	return &JobCompleteNatsClient{js: js, subject: subject}
+}
+
+func (c JobCompleteNatsClient) Complete(ctx context.Context, msg *domain.JobCompleteMessage) error {
+	const op = "JobCompleteNatsClient.Complete"
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't marshal message (%s, %s)", msg.TaskId, msg.OrderId), err)
+	}
+	header := natsio.Header{}
+	header.Set(domain.HeaderIdempotencyKey, msg.AttemptId)
+	id := msgId(completeMsgIdPrefix, msg.AttemptId)
+	if err := Publish(ctx, c.js, c.subject, header, msgBytes, natsio.MsgId(id)); err != nil {
+		return domain.E(op, fmt.Sprintf("can't send message (%s, %s)", msg.TaskId, msg.OrderId), err)
+	}
+	return nil
+}
+
+// Durable consumer of complete messages, message is acknowledged after the job completion
+// and redelivered if the completion fails
+type JobCompleteNatsConsumer struct {
+	cfg          domain.NatsConfig
+	js           natsio.JetStreamContext
+	orderService domain.OrderService
+}
+
+func NewJobCompleteNatsConsumer(cfg domain.NatsConfig, js natsio.JetStreamContext,
+	orderService domain.OrderService) *JobCompleteNatsConsumer {
+
+	return &JobCompleteNatsConsumer{cfg: cfg, js: js, orderService: orderService}
+}
+
+func (c JobCompleteNatsConsumer) Start(groupCtx context.Context) error {
+	const op = "JobCompleteNatsConsumer.Start"
+
+	opts := []natsio.SubOpt{natsio.ManualAck(), natsio.AckExplicit()}
+	if c.cfg.MaxDeliver > 0 {
+		opts = append(opts, natsio.MaxDeliver(c.cfg.MaxDeliver))
+	}
+	sub, err := c.js.PullSubscribe(c.cfg.CompleteSubject, c.cfg.Durable, opts...)
+	if err != nil {
+		return domain.E(op, fmt.Sprintf("can't subscribe (%s)", c.cfg.CompleteSubject), err)
+	}
+	batchSize := c.cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	log.Tracef("%s: starting", op)
+	for {
+		select {
+		case <-groupCtx.Done():
+			log.Tracef("%s: exit", op)
+			return groupCtx.Err()
+		default:
+			msgs, err := sub.Fetch(batchSize, natsio.MaxWait(time.Second))
+			if err != nil && err != natsio.ErrTimeout {
+				log.Error(domain.E(op, "can't fetch messages", err))
+				time.Sleep(time.Second)
+			}
+			for _, msg := range msgs {
+				c.handle(groupCtx, msg)
+			}
+		}
+	}
+}
+
+func (c JobCompleteNatsConsumer) handle(ctx context.Context, msg *natsio.Msg) {
+	const op = "JobCompleteNatsConsumer.Handle"
+
+	span, spanCtx := StartSpanFromMsg(ctx, msg)
+	defer span.Finish()
+
+	var obj domain.JobCompleteMessage
+	if err := json.Unmarshal(msg.Data, &obj); err != nil {
+		log.Error(domain.E(op, "can't unmarshal message, skip it", err))
+		_ = msg.Term()
+		return
+	}
+	if obj.AttemptId == "" {
+		obj.AttemptId = msg.Header.Get(domain.HeaderIdempotencyKey)
+	}
+	if err := c.orderService.CompleteJob(spanCtx, &obj); err != nil {
+		switch domain.ECode(err) {
+		case domain.ErrNotFound, domain.ErrStaleAttempt, domain.ErrCancelled, domain.ErrValidation:
+			log.Warn(domain.E(op, fmt.Sprintf("can't complete job, skip message (%s, %s)", obj.TaskId, obj.OrderId), err))
+			_ = msg.Term()
+		default:
+			log.Error(domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", obj.TaskId, obj.OrderId), err))
+			_ = msg.Nak()
+		}
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		log.Error(domain.E(op, fmt.Sprintf("can't ack message (%s, %s)", obj.TaskId, obj.OrderId), err))
+	}
+}
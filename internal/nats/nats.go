@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// Connect to the server and create JetStream stream (if it doesn't exist)
+func Connect(cfg domain.NatsConfig) (*natsio.Conn, natsio.JetStreamContext, error) {
+	const op = "Nats.Connect"
+
+	conn, err := natsio.Connect(cfg.Url, natsio.Timeout(cfg.TimeoutSec*time.Second))
+	if err != nil {
+		return nil, nil, domain.E(op, fmt.Sprintf("can't connect (%s)", cfg.Url), err)
+	}
+	js, err := conn.JetStream(natsio.MaxWait(cfg.TimeoutSec * time.Second))
+	if err != nil {
+		conn.Close()
+		return nil, nil, domain.E(op, "can't get jetstream context", err)
+	}
+	if _, err = js.StreamInfo(cfg.Stream); err != nil {
+		_, err = js.AddStream(&natsio.StreamConfig{Name: cfg.Stream, Subjects: cfg.Subjects})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, domain.E(op, fmt.Sprintf("can't create stream (%s)", cfg.Stream), err)
+	}
+	return conn, js, nil
+}
+
+// opentracing.GlobalTracer() have to be initialized
+func Publish(ctx context.Context, js natsio.JetStreamContext, subject string, header natsio.Header,
+	msgBytes []byte, opts ...natsio.PubOpt) error {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "PUBLISH "+subject)
+	defer span.Finish()
+
+	msg := natsio.NewMsg(subject)
+	msg.Data = msgBytes
+	for key, values := range header {
+		for _, value := range values {
+			msg.Header.Add(key, value)
+		}
+	}
+	msg.Header.Set(domain.HeaderContentType, domain.ContentTypeApplicationJson)
+
+	tracer := opentracing.GlobalTracer()
+	err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(msg.Header))
+	if err != nil {
+		return errors.Wrapf(err, "can't propagate tracing context (%s)", subject)
+	}
+	if _, err := js.PublishMsg(msg, opts...); err != nil {
+		return errors.Wrapf(err, "can't publish message (%s)", subject)
+	}
+	return nil
+}
+
+// Start span of the received message (it follows the span from message headers if any)
+func StartSpanFromMsg(ctx context.Context, msg *natsio.Msg) (opentracing.Span, context.Context) {
+	tracer := opentracing.GlobalTracer()
+	operationName := "RECEIVE " + msg.Subject
+	var span opentracing.Span
+	spanContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header(msg.Header)))
+	if err != nil {
+		span = tracer.StartSpan(operationName)
+	} else {
+		span = tracer.StartSpan(operationName, ext.RPCServerOption(spanContext))
+	}
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
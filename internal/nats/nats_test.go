@@ -0,0 +1,191 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/nats-io/nats-server/v2/server"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testServer(t *testing.T) (domain.NatsConfig, func()) {
+	storeDir, err := ioutil.TempDir("", "nats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: storeDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server isn't ready")
+	}
+	cfg := domain.NatsConfig{
+		Url:             s.ClientURL(),
+		Stream:          "PP",
+		Subjects:        []string{"pp.>"},
+		CompleteSubject: "pp.job.complete",
+		Durable:         "pp-gin",
+		BatchSize:       10,
+		TimeoutSec:      5,
+	}
+	return cfg, func() {
+		s.Shutdown()
+		_ = os.RemoveAll(storeDir)
+	}
+}
+
+type testOrderService struct {
+	domain.OrderService
+	err       error
+	completed chan *domain.JobCompleteMessage
+}
+
+func (s *testOrderService) CompleteJob(_ context.Context, msg *domain.JobCompleteMessage) error {
+	s.completed <- msg
+	return s.err
+}
+
+func TestJobStartNatsClient_Start(t *testing.T) {
+	assert := assert.New(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	cfg, shutdown := testServer(t)
+	defer shutdown()
+	conn, js, err := Connect(cfg)
+	assert.Nil(err)
+	defer conn.Close()
+
+	sub, err := js.SubscribeSync("pp.task.test")
+	assert.Nil(err)
+
+	msg := domain.JobStartMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1", Body: domain.Body{"key1": "value1"}}
+	client := NewJobStartNatsClient(js)
+	for i := 0; i < 2; i++ {
+		output, err := client.Start(context.Background(), "pp.task.test", &msg, nil)
+		assert.Nil(err)
+		assert.Nil(output)
+	}
+
+	received, err := sub.NextMsg(5 * time.Second)
+	assert.Nil(err)
+	var receivedMsg domain.JobStartMessage
+	assert.Nil(json.Unmarshal(received.Data, &receivedMsg))
+	assert.Equal(msg, receivedMsg)
+	assert.Equal("o1.t1.1", received.Header.Get(domain.HeaderIdempotencyKey))
+
+	span, _ := StartSpanFromMsg(context.Background(), received)
+	span.Finish()
+	assert.Equal(tracer.FinishedSpans()[0].SpanContext.TraceID, span.(*mocktracer.MockSpan).SpanContext.TraceID)
+
+	// Duplicate of the attempt is dropped by the server
+	_, err = sub.NextMsg(500 * time.Millisecond)
+	assert.Equal(natsio.ErrTimeout, err)
+}
+
+func TestJobCompleteNatsConsumer_Start(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, shutdown := testServer(t)
+	defer shutdown()
+	conn, js, err := Connect(cfg)
+	assert.Nil(err)
+	defer conn.Close()
+
+	orderService := &testOrderService{completed: make(chan *domain.JobCompleteMessage, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewJobCompleteNatsConsumer(cfg, js, orderService).Start(ctx)
+	}()
+
+	msg := domain.JobCompleteMessage{TaskId: "t1", OrderId: "o1", Body: domain.Body{"key1": "value1"}}
+	client := NewJobCompleteNatsClient(js, cfg.CompleteSubject)
+	assert.Nil(client.Complete(context.Background(), &msg))
+
+	select {
+	case completed := <-orderService.completed:
+		assert.Equal(msg, *completed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("job isn't completed")
+	}
+
+	cancel()
+	assert.Equal(context.Canceled, <-done)
+}
+
+func TestJobCompleteNatsConsumer_Start_Redelivery(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, shutdown := testServer(t)
+	defer shutdown()
+	conn, js, err := Connect(cfg)
+	assert.Nil(err)
+	defer conn.Close()
+
+	orderService := &testOrderService{
+		err:       domain.E("Test", domain.ErrInternal),
+		completed: make(chan *domain.JobCompleteMessage, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewJobCompleteNatsConsumer(cfg, js, orderService).Start(ctx)
+	}()
+
+	msg := domain.JobCompleteMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1"}
+	client := NewJobCompleteNatsClient(js, cfg.CompleteSubject)
+	assert.Nil(client.Complete(context.Background(), &msg))
+
+	// Failed completion is redelivered
+	for i := 0; i < 2; i++ {
+		select {
+		case completed := <-orderService.completed:
+			assert.Equal(msg, *completed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message isn't redelivered")
+		}
+	}
+}
+
+// Start and complete messages of the attempt share the stream, the complete one isn't dropped as the duplicate
+func TestJobCompleteNatsConsumer_Start_AfterJobStart(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, shutdown := testServer(t)
+	defer shutdown()
+	conn, js, err := Connect(cfg)
+	assert.Nil(err)
+	defer conn.Close()
+
+	orderService := &testOrderService{completed: make(chan *domain.JobCompleteMessage, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewJobCompleteNatsConsumer(cfg, js, orderService).Start(ctx)
+	}()
+
+	start := domain.JobStartMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1"}
+	_, err = NewJobStartNatsClient(js).Start(context.Background(), "pp.task.test", &start, nil)
+	assert.Nil(err)
+	msg := domain.JobCompleteMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1"}
+	assert.Nil(NewJobCompleteNatsClient(js, cfg.CompleteSubject).Complete(context.Background(), &msg))
+
+	select {
+	case completed := <-orderService.completed:
+		assert.Equal(msg, *completed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion is lost")
+	}
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const (
+	ParamHost = "host"
+)
+
+type BreakerRestHandler struct {
+	breakerService domain.BreakerService
+}
+
+func NewBreakerRestHandler(breakerService domain.BreakerService) *BreakerRestHandler {
+	return &BreakerRestHandler{breakerService: breakerService}
+}
+
+func (h BreakerRestHandler) Register(router *gin.Engine) {
+	group := router.Group("/admin/breaker")
+	group.GET("/", h.getBreakers)
+	group.POST("/:"+ParamHost+"/reset", h.resetBreaker)
+}
+
+// GetBreakers godoc
+// @Summary Get Circuit Breakers
+// @Description Method to get circuit breakers of task destinations
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {array} api.Breaker
+// @Failure 500 {object} api.Error
+// @Router /admin/breaker [get]
+func (h BreakerRestHandler) getBreakers(c *gin.Context) {
+	var results []domain.Breaker
+	if err := h.breakerService.GetAll(c.Request.Context(), &results); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// ResetBreaker godoc
+// @Summary Reset Circuit Breaker
+// @Description Method to close circuit breaker of the host
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param host path string true "Host"
+// @Success 200
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /admin/breaker/{host}/reset [post]
+func (h BreakerRestHandler) resetBreaker(c *gin.Context) {
+	host := c.Param(ParamHost)
+	if err := h.breakerService.Reset(c.Request.Context(), host); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
+	}
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"fmt"
+	"github.com/hashicorp/go-retryablehttp"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxResponseLength = 1024
+
+	// Key of the response value which isn't a json object
+	ResponseValueKey = "value"
+)
+
+// Retryable http client of the config (see api.NewClient)
+func NewClient(cfg domain.ClientRestConfig) *retryablehttp.Client {
+	return api.NewClient(cfg.RetriesMax, cfg.TimeoutSec*time.Second)
+}
+
+func isRetryableStatus(status int) bool {
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusTooEarly, status == http.StatusTooManyRequests:
+		return true
+	case status >= 500 && status != http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// Check status code of the response, non-2xx response is returned as an error with ErrRemoteRetryable or
+// ErrRemotePermanent code which wraps domain.RemoteError with (truncated) response body
+func CheckResponse(op domain.ErrOp, response *http.Response) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseLength))
+	remoteErr := &domain.RemoteError{Status: response.StatusCode, Response: string(body)}
+	code := domain.ErrRemotePermanent
+	if isRetryableStatus(response.StatusCode) {
+		code = domain.ErrRemoteRetryable
+		remoteErr.RetryAfter, _ = api.ParseRetryAfter(response)
+	}
+	return domain.E(op, code, fmt.Sprintf("unexpected status code (%d)", response.StatusCode), remoteErr)
+}
+
+// Decode json of the response, other content is skipped. Value which isn't an object (array, string, etc.)
+// is wrapped by the object with ResponseValueKey, so it isn't lost as the output of synchronous tasks
+func DecodeResponse(op domain.ErrOp, response *http.Response) domain.Body {
+	if !strings.HasPrefix(response.Header.Get(domain.HeaderContentType), domain.ContentTypeApplicationJson) {
+		return nil
+	}
+	var result interface{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		if err != io.EOF {
+			log.Warn(domain.E(op, "can't decode response, skip response body", err))
+		}
+		return nil
+	}
+	switch value := result.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return value
+	default:
+		return domain.Body{ResponseValueKey: value}
+	}
+}
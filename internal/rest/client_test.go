@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testServer(status int, retryAfter, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		if strings.HasPrefix(body, "{") {
+			w.Header().Set(domain.HeaderContentType, domain.ContentTypeApplicationJson)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestJobStartRestClient_Start_Success(t *testing.T) {
+	assert := assert.New(t)
+
+	server := testServer(http.StatusOK, "", "")
+	defer server.Close()
+
+	client := NewJobStartRestClient(domain.ClientRestConfig{})
+	_, err := client.Start(context.Background(), server.URL, &domain.JobStartMessage{}, nil)
+	assert.Nil(err)
+}
+
+func TestJobStartRestClient_Start_Output(t *testing.T) {
+	assert := assert.New(t)
+
+	server := testServer(http.StatusOK, "", `{"key1": "value1"}`)
+	defer server.Close()
+
+	client := NewJobStartRestClient(domain.ClientRestConfig{})
+	output, err := client.Start(context.Background(), server.URL, &domain.JobStartMessage{}, nil)
+	assert.Nil(err)
+	assert.Equal(domain.Body{"key1": "value1"}, output)
+}
+
+func TestDecodeResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		contentType string
+		body        string
+		expected    domain.Body
+	}{
+		{domain.ContentTypeApplicationJson, `{"key1": "value1"}`, domain.Body{"key1": "value1"}},
+		{domain.ContentTypeApplicationJson, `["value1", "value2"]`,
+			domain.Body{ResponseValueKey: []interface{}{"value1", "value2"}}},
+		{domain.ContentTypeApplicationJson, `"value1"`, domain.Body{ResponseValueKey: "value1"}},
+		{domain.ContentTypeApplicationJson, `null`, nil},
+		{domain.ContentTypeApplicationJson, ``, nil},
+		{domain.ContentTypeApplicationJson, `{"key1"`, nil},
+		{"text/plain", `{"key1": "value1"}`, nil},
+	}
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(test.body))}
+		response.Header.Set(domain.HeaderContentType, test.contentType)
+		assert.Equal(test.expected, DecodeResponse("Test", response), test.body)
+	}
+}
+
+func TestJobStartRestClient_Start_Options(t *testing.T) {
+	assert := assert.New(t)
+
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+	}))
+	defer server.Close()
+
+	retriesMax := 0
+	client := NewJobStartRestClient(domain.ClientRestConfig{RetriesMax: 2})
+	_, err := client.Start(context.Background(), server.URL+"/start?a=1", &domain.JobStartMessage{AttemptId: "1"},
+		&domain.JobStartOptions{
+			Method:     http.MethodPut,
+			Headers:    map[string]string{"X-Customer": "123"},
+			Query:      map[string]string{"b": "2"},
+			Timeout:    time.Second,
+			RetriesMax: &retriesMax,
+		})
+	assert.Nil(err)
+	assert.Equal(http.MethodPut, request.Method)
+	assert.Equal("/start", request.URL.Path)
+	assert.Equal("1", request.URL.Query().Get("a"))
+	assert.Equal("2", request.URL.Query().Get("b"))
+	assert.Equal("123", request.Header.Get("X-Customer"))
+	assert.Equal("1", request.Header.Get(domain.HeaderIdempotencyKey))
+}
+
+func TestJobStartRestClient_Start_NoBody(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	client := NewJobStartRestClient(domain.ClientRestConfig{})
+	msg := &domain.JobStartMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1", Body: domain.Body{"id": "1"}}
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodPut} {
+		_, err := client.Start(context.Background(), server.URL, msg, &domain.JobStartOptions{Method: method,
+			Query: map[string]string{"id": "1"}})
+		assert.Nil(err, method)
+	}
+	if assert.Len(requests, 4) {
+		for i, request := range requests[:3] {
+			assert.Empty(bodies[i], request.Method)
+			assert.Empty(request.Header.Get(domain.HeaderContentType), request.Method)
+			assert.Equal("o1.t1.1", request.Header.Get(domain.HeaderIdempotencyKey), request.Method)
+			assert.Equal("1", request.URL.Query().Get("id"), request.Method)
+		}
+		assert.Contains(bodies[3], `"attemptId":"o1.t1.1"`)
+		assert.Equal(domain.ContentTypeApplicationJson, requests[3].Header.Get(domain.HeaderContentType))
+	}
+}
+
+func TestJobStartRestClient_Start_Permanent(t *testing.T) {
+	const op = "JobStartRestClient.Start"
+	assert := assert.New(t)
+
+	server := testServer(http.StatusNotFound, "", strings.Repeat("x", 2*maxResponseLength))
+	defer server.Close()
+
+	client := NewJobStartRestClient(domain.ClientRestConfig{RetriesMax: 2})
+	_, err := client.Start(context.Background(), server.URL, &domain.JobStartMessage{}, nil)
+	assert.NotNil(err)
+	assert.Equal(domain.ErrRemotePermanent, domain.ECode(err))
+	assert.Equal([]domain.ErrOp{op}, domain.EOps(err))
+
+	remoteErr := domain.ERemote(err)
+	assert.NotNil(remoteErr)
+	assert.Equal(http.StatusNotFound, remoteErr.Status)
+	assert.Equal(maxResponseLength, len(remoteErr.Response))
+}
+
+func TestJobStartRestClient_Start_RetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	server := testServer(http.StatusServiceUnavailable, "120", "busy")
+	defer server.Close()
+
+	client := NewJobStartRestClient(domain.ClientRestConfig{RetriesMax: 2})
+	_, err := client.Start(context.Background(), server.URL, &domain.JobStartMessage{}, nil)
+	assert.NotNil(err)
+	assert.Equal(domain.ErrRemoteRetryable, domain.ECode(err))
+
+	remoteErr := domain.ERemote(err)
+	assert.NotNil(remoteErr)
+	assert.Equal(http.StatusServiceUnavailable, remoteErr.Status)
+	assert.Equal(120*time.Second, remoteErr.RetryAfter)
+	assert.Equal("busy", remoteErr.Response)
+}
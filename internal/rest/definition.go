@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	maxBpmnSize = 10 << 20
+)
+
+type ProcessDefinitionRestHandler struct {
+	definitionService domain.ProcessDefinitionService
+}
+
+func NewProcessDefinitionRestHandler(definitionService domain.ProcessDefinitionService) *ProcessDefinitionRestHandler {
+	return &ProcessDefinitionRestHandler{definitionService: definitionService}
+}
+
+func (h ProcessDefinitionRestHandler) Register(router *gin.Engine) {
+	group := router.Group("/process")
+	group.POST("/import", h.importProcess)
+	group.POST("/bpmn", h.importBpmn)
+	group.GET("/:"+ParamId+"/definition", h.exportProcess)
+}
+
+// ImportProcess godoc
+// @Summary Import Process Definition
+// @Description Method to create process and its read mappings from the definition with name-based references
+// @Tags Process
+// @Accept json,application/x-yaml
+// @Produce json
+// @Param definition body api.ProcessDefinition true "Process Definition"
+// @Success 200 {object} api.Process
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /process/import [post]
+func (h ProcessDefinitionRestHandler) importProcess(c *gin.Context) {
+	var obj domain.ProcessDefinition
+	bind := c.BindJSON
+	if c.ContentType() == domain.ContentTypeApplicationYaml {
+		bind = c.BindYAML
+	}
+	if err := bind(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	var result domain.Process
+	if err := h.definitionService.Import(c.Request.Context(), &obj, &result); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrValidation {
+			c.JSON(http.StatusBadRequest, E(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ImportBpmn godoc
+// @Summary Import BPMN Process
+// @Description Method to create process from BPMN 2.0 document (raw body or "file" field of multipart form),
+// @Description unsupported elements are reported with their lines
+// @Tags Process
+// @Accept xml,mpfd
+// @Produce json
+// @Param file formData file false "BPMN document"
+// @Success 200 {object} api.Process
+// @Failure 400 {object} api.Error
+// @Failure 413 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /process/bpmn [post]
+func (h ProcessDefinitionRestHandler) importBpmn(c *gin.Context) {
+	const op = "ProcessDefinitionRestHandler.ImportBpmn"
+
+	body := c.Request.Body
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		file, err := c.FormFile("file")
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusBadRequest, E(domain.E(op, domain.ErrValidation, "file is required", err)))
+			return
+		}
+		if body, err = file.Open(); err != nil {
+			log.Error(err)
+			c.JSON(http.StatusInternalServerError, E(err))
+			return
+		}
+		defer body.Close()
+	}
+	// Document is read up to the limit plus a byte, so the larger one is rejected instead of being truncated
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxBpmnSize+1))
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if len(data) > maxBpmnSize {
+		err := domain.E(op, domain.ErrValidation, fmt.Sprintf("document is too large (max %d bytes)", maxBpmnSize))
+		log.Error(err)
+		c.JSON(http.StatusRequestEntityTooLarge, E(err))
+		return
+	}
+	var result domain.Process
+	if err := h.definitionService.ImportBpmn(c.Request.Context(), data, &result); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrValidation {
+			c.JSON(http.StatusBadRequest, E(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportProcess godoc
+// @Summary Export Process Definition
+// @Description Method to get definition of the process, read mappings are named by their ids
+// @Tags Process
+// @Accept json
+// @Produce json,application/x-yaml
+// @Param id path string true "Process Id"
+// @Success 200 {object} api.ProcessDefinition
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /process/{id}/definition [get]
+func (h ProcessDefinitionRestHandler) exportProcess(c *gin.Context) {
+	id := c.Param(ParamId)
+	var result domain.ProcessDefinition
+	if err := h.definitionService.Export(c.Request.Context(), id, &result); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if c.NegotiateFormat(domain.ContentTypeApplicationJson, domain.ContentTypeApplicationYaml) ==
+		domain.ContentTypeApplicationYaml {
+
+		c.YAML(http.StatusOK, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const (
+	ParamLockId = "lock_id"
+)
+
+type ExternalJobRestHandler struct {
+	externalJobService domain.ExternalJobService
+}
+
+func NewExternalJobRestHandler(externalJobService domain.ExternalJobService) *ExternalJobRestHandler {
+	return &ExternalJobRestHandler{externalJobService: externalJobService}
+}
+
+func (h ExternalJobRestHandler) Register(router *gin.Engine) {
+	group := router.Group("/job")
+	group.POST("/fetch", h.fetchJobs)
+	group.POST("/lock/:"+ParamLockId+"/complete", h.completeJob)
+	group.POST("/lock/:"+ParamLockId+"/fail", h.failJob)
+	group.POST("/lock/:"+ParamLockId+"/extend", h.extendLock)
+}
+
+// FetchJobs godoc
+// @Summary Fetch and Lock Jobs
+// @Description Method to fetch and lock ready jobs of external tasks by topics
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param fetch_request body api.JobFetchRequest true "Fetch Request"
+// @Success 200 {array} api.LockedJob
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /job/fetch [post]
+func (h ExternalJobRestHandler) fetchJobs(c *gin.Context) {
+	var obj domain.JobFetchRequest
+	if err := c.BindJSON(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	var results []domain.LockedJob
+	if err := h.externalJobService.FetchAndLock(c.Request.Context(), &obj, &results); err != nil {
+		externalJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// CompleteLockedJob godoc
+// @Summary Complete Locked Job
+// @Description Method to complete job locked by the external worker
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param lock_id path string true "Lock Id"
+// @Param complete_message body api.JobLockCompleteMessage true "Complete Message"
+// @Success 200
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /job/lock/{lock_id}/complete [post]
+func (h ExternalJobRestHandler) completeJob(c *gin.Context) {
+	var obj domain.JobLockCompleteMessage
+	if err := c.BindJSON(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if err := h.externalJobService.Complete(c.Request.Context(), c.Param(ParamLockId), &obj); err != nil {
+		externalJobError(c, err)
+	}
+}
+
+// FailLockedJob godoc
+// @Summary Fail Locked Job
+// @Description Method to report failure of job locked by the external worker, job is retried unless it's permanent
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param lock_id path string true "Lock Id"
+// @Param fail_message body api.JobLockFailMessage true "Fail Message"
+// @Success 200
+// @Failure 400 {object} api.Error
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /job/lock/{lock_id}/fail [post]
+func (h ExternalJobRestHandler) failJob(c *gin.Context) {
+	var obj domain.JobLockFailMessage
+	if err := c.BindJSON(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if err := h.externalJobService.Fail(c.Request.Context(), c.Param(ParamLockId), &obj); err != nil {
+		externalJobError(c, err)
+	}
+}
+
+// ExtendJobLock godoc
+// @Summary Extend Job Lock
+// @Description Method to extend lock of the job by the external worker
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param lock_id path string true "Lock Id"
+// @Param extend_message body api.JobLockExtendMessage true "Extend Message"
+// @Success 200
+// @Failure 400 {object} api.Error
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /job/lock/{lock_id}/extend [post]
+func (h ExternalJobRestHandler) extendLock(c *gin.Context) {
+	var obj domain.JobLockExtendMessage
+	if err := c.BindJSON(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if err := h.externalJobService.ExtendLock(c.Request.Context(), c.Param(ParamLockId), &obj); err != nil {
+		externalJobError(c, err)
+	}
+}
+
+func externalJobError(c *gin.Context, err error) {
+	log.Error(err)
+	switch domain.ECode(err) {
+	case domain.ErrValidation:
+		c.JSON(http.StatusBadRequest, E(err))
+	case domain.ErrNotFound:
+		c.Status(http.StatusNotFound)
+	case domain.ErrStaleAttempt, domain.ErrCancelled:
+		c.JSON(http.StatusConflict, E(err))
+	default:
+		c.JSON(http.StatusInternalServerError, E(err))
+	}
+}
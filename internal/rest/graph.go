@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"bytes"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/internal/graph"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const (
+	QueryFormat = "format"
+)
+
+type GraphRestHandler struct {
+	processService domain.ProcessService
+	orderService   domain.OrderService
+}
+
+func NewGraphRestHandler(processService domain.ProcessService, orderService domain.OrderService) *GraphRestHandler {
+	return &GraphRestHandler{processService: processService, orderService: orderService}
+}
+
+func (h GraphRestHandler) Register(router *gin.Engine) {
+	router.GET("/process/:"+ParamId+"/graph", h.getProcessGraph)
+	router.GET("/order/:"+ParamId+"/graph", h.getOrderGraph)
+}
+
+// GetProcessGraph godoc
+// @Summary Get Process Graph
+// @Description Method to render process as Graphviz DOT, Mermaid or SVG
+// @Tags Process
+// @Produce plain,image/svg+xml
+// @Param id path string true "Process Id"
+// @Param format query string false "Format (dot, mermaid or svg)" default(svg)
+// @Success 200 {string} string
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /process/{id}/graph [get]
+func (h GraphRestHandler) getProcessGraph(c *gin.Context) {
+	var process domain.Process
+	if err := h.processService.GetById(c.Request.Context(), c.Param(ParamId), &process); err != nil {
+		graphError(c, err)
+		return
+	}
+	renderGraph(c, &process, nil)
+}
+
+// GetOrderGraph godoc
+// @Summary Get Order Graph
+// @Description Method to render process of the order as Graphviz DOT, Mermaid or SVG, tasks are colored
+// @Description by the statuses of their jobs
+// @Tags Order
+// @Produce plain,image/svg+xml
+// @Param id path string true "Order Id"
+// @Param format query string false "Format (dot, mermaid or svg)" default(svg)
+// @Success 200 {string} string
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /order/{id}/graph [get]
+func (h GraphRestHandler) getOrderGraph(c *gin.Context) {
+	ctx := c.Request.Context()
+	orderId := c.Param(ParamId)
+
+	var order domain.Order
+	if err := h.orderService.GetOrderById(ctx, orderId, &order); err != nil {
+		graphError(c, err)
+		return
+	}
+	var process domain.Process
+	if err := h.processService.GetById(ctx, order.ProcessId, &process); err != nil {
+		graphError(c, err)
+		return
+	}
+	var jobs []domain.Job
+	if err := h.orderService.GetOrderJobs(ctx, orderId, &jobs); err != nil {
+		graphError(c, err)
+		return
+	}
+	statuses := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		statuses[job.TaskId] = job.Status
+	}
+	renderGraph(c, &process, statuses)
+}
+
+func renderGraph(c *gin.Context, process *domain.Process, statuses map[string]string) {
+	format := c.DefaultQuery(QueryFormat, graph.FormatSvg)
+	var buf bytes.Buffer
+	if err := graph.Render(&buf, format, process, statuses); err != nil {
+		graphError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, graph.ContentType(format), buf.Bytes())
+}
+
+func graphError(c *gin.Context, err error) {
+	log.Error(err)
+	switch domain.ECode(err) {
+	case domain.ErrNotFound:
+		c.Status(http.StatusNotFound)
+	case domain.ErrValidation:
+		c.JSON(http.StatusBadRequest, E(err))
+	default:
+		c.JSON(http.StatusInternalServerError, E(err))
+	}
+}
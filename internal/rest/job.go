@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/pkg/api"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/hashicorp/go-retryablehttp"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
 type JobRestHandler struct {
@@ -22,6 +26,8 @@ func NewJobRestHandler(orderService domain.OrderService) *JobRestHandler {
 func (h JobRestHandler) Register(router *gin.Engine) {
 	group := router.Group("/job")
 	group.POST("/complete", h.completeJob)
+	group.POST("/retry", h.retryJob)
+	group.POST("/cancel", h.cancelJob)
 }
 
 // CompleteJob godoc
@@ -30,9 +36,13 @@ func (h JobRestHandler) Register(router *gin.Engine) {
 // @Tags Job
 // @Accept json
 // @Produce json
-// @Param complete_job_message body domain.JobCompleteMessage true "Complete Job Message"
+// @Param complete_job_message body api.JobCompleteMessage true "Complete Job Message"
+// @Param Idempotency-Key header string false "Attempt Id (if it's absent in the message)"
 // @Success 200
-// @Failure 500 {object} domain.Error
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /job/complete [post]
 func (h JobRestHandler) completeJob(c *gin.Context) {
 	var obj domain.JobCompleteMessage
@@ -41,9 +51,77 @@ func (h JobRestHandler) completeJob(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, E(err))
 		return
 	}
-	if err := h.orderService.CompleteJob(c.Request.Context(), obj.TaskId, obj.OrderId); err != nil {
+	if obj.AttemptId == "" {
+		obj.AttemptId = c.GetHeader(domain.HeaderIdempotencyKey)
+	}
+	if err := h.orderService.CompleteJob(c.Request.Context(), &obj); err != nil {
+		log.Error(err)
+		switch domain.ECode(err) {
+		case domain.ErrNotFound:
+			c.Status(http.StatusNotFound)
+		case domain.ErrStaleAttempt, domain.ErrCancelled:
+			c.JSON(http.StatusConflict, E(err))
+		case domain.ErrValidation:
+			c.JSON(http.StatusBadRequest, E(err))
+		default:
+			c.JSON(http.StatusInternalServerError, E(err))
+		}
+	}
+}
+
+// RetryJob godoc
+// @Summary Retry Job
+// @Description Method to restart job which isn't completed (e.g. failed one)
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param job_ref body api.JobRef true "Job Reference"
+// @Success 200
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /job/retry [post]
+func (h JobRestHandler) retryJob(c *gin.Context) {
+	var obj domain.JobRef
+	if err := c.BindJSON(&obj); err != nil {
 		log.Error(err)
 		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if err := h.orderService.RetryJob(c.Request.Context(), &obj); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
+	}
+}
+
+// CancelJob godoc
+// @Summary Cancel Job
+// @Description Method to cancel job which isn't completed, it won't be started again
+// @Tags Job
+// @Accept json
+// @Produce json
+// @Param job_ref body api.JobRef true "Job Reference"
+// @Success 200
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /job/cancel [post]
+func (h JobRestHandler) cancelJob(c *gin.Context) {
+	var obj domain.JobRef
+	if err := c.BindJSON(&obj); err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, E(err))
+		return
+	}
+	if err := h.orderService.CancelJob(c.Request.Context(), &obj); err != nil {
+		log.Error(err)
+		if domain.ECode(err) == domain.ErrNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, E(err))
 	}
 }
 
@@ -64,40 +142,101 @@ func (c JobCompleteRestClient) Complete(ctx context.Context, msg *domain.JobComp
 		return domain.E(op, fmt.Sprintf("can't marshal request (%s, %s)", msg.TaskId, msg.OrderId), err)
 	}
 
-	response, err := Send(ctx, c.client, c.baseUrl+"/complete/", http.MethodPost, msgBytes)
+	header := http.Header{}
+	header.Set(domain.HeaderIdempotencyKey, msg.AttemptId)
+	response, err := api.Send(ctx, c.client, c.baseUrl+"/complete/", http.MethodPost, header, msgBytes)
 	if err != nil {
 		return domain.E(op, fmt.Sprintf("can't send request (%s, %s)", msg.TaskId, msg.OrderId), err)
 	}
 	defer response.Body.Close()
 
-	// TDB Check status code
-
-	return nil
+	return CheckResponse(op, response)
 }
 
 type JobStartRestClient struct {
-	client *retryablehttp.Client
+	cfg     domain.ClientRestConfig
+	clients *sync.Map // Clients per retries and timeout overrides of the tasks
 }
 
-func NewJobStartRestClient(client *retryablehttp.Client) domain.JobStartClient {
-	return &JobStartRestClient{client: client}
+func NewJobStartRestClient(cfg domain.ClientRestConfig) domain.JobStartClient {
+	return &JobStartRestClient{cfg: cfg, clients: &sync.Map{}}
 }
 
-func (c JobStartRestClient) Start(ctx context.Context, dest string, msg *domain.JobStartMessage) error {
+func (c JobStartRestClient) Start(ctx context.Context, dest string, msg *domain.JobStartMessage,
+	opts *domain.JobStartOptions) (domain.Body, error) {
+
 	const op = "JobStartRestClient.Start"
 
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
-		return domain.E(op, fmt.Sprintf("can't marshal request (%s, %s)", msg.TaskId, msg.OrderId), err)
+		return nil, domain.E(op, fmt.Sprintf("can't marshal request (%s, %s)", msg.TaskId, msg.OrderId), err)
 	}
 
-	response, err := Send(ctx, c.client, dest, http.MethodPost, msgBytes)
+	method := http.MethodPost
+	header := http.Header{}
+	cfg := c.cfg
+	if opts != nil {
+		if opts.Method != "" {
+			method = opts.Method
+		}
+		for key, value := range opts.Headers {
+			header.Set(key, value)
+		}
+		if len(opts.Query) > 0 {
+			if dest, err = withQuery(dest, opts.Query); err != nil {
+				return nil, domain.E(op, fmt.Sprintf("can't build url (%s, %s)", msg.TaskId, msg.OrderId), err)
+			}
+		}
+		if opts.Timeout > 0 {
+			cfg.TimeoutSec = opts.Timeout / time.Second
+		}
+		if opts.RetriesMax != nil {
+			cfg.RetriesMax = *opts.RetriesMax
+		}
+	}
+	if !hasBody(method) {
+		msgBytes = nil
+	}
+	header.Set(domain.HeaderIdempotencyKey, msg.AttemptId)
+	response, err := api.Send(ctx, c.client(cfg), dest, method, header, msgBytes)
 	if err != nil {
-		return domain.E(op, fmt.Sprintf("can't send request (%s, %s)", msg.TaskId, msg.OrderId), err)
+		return nil, domain.E(op, fmt.Sprintf("can't send request (%s, %s)", msg.TaskId, msg.OrderId), err)
 	}
 	defer response.Body.Close()
 
-	// TDB Check status code
+	if err := CheckResponse(op, response); err != nil {
+		return nil, err
+	}
+	return DecodeResponse(op, response), nil
+}
+
+func (c JobStartRestClient) client(cfg domain.ClientRestConfig) *retryablehttp.Client {
+	if client, ok := c.clients.Load(cfg); ok {
+		return client.(*retryablehttp.Client)
+	}
+	client, _ := c.clients.LoadOrStore(cfg, NewClient(cfg))
+	return client.(*retryablehttp.Client)
+}
 
-	return nil
+// Requests of these methods have no body, the job is identified by the idempotency key (attempt id) and the fields
+// of the order are passed by the query templates
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return false
+	}
+	return true
+}
+
+func withQuery(dest string, query map[string]string) (string, error) {
+	destUrl, err := url.Parse(dest)
+	if err != nil {
+		return "", err
+	}
+	values := destUrl.Query()
+	for key, value := range query {
+		values.Set(key, value)
+	}
+	destUrl.RawQuery = values.Encode()
+	return destUrl.String(), nil
 }
@@ -30,8 +30,8 @@ func (h MappingRestHandler) Register(router *gin.Engine) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Read Mapping Id"
-// @Success 200 {object} domain.ReadMapping
-// @Failure 500 {object} domain.Error
+// @Success 200 {object} api.ReadMapping
+// @Failure 500 {object} api.Error
 // @Router /mapping/{id} [get]
 func (h MappingRestHandler) getReadMappingById(c *gin.Context) {
 	id := c.Param(ParamId)
@@ -48,22 +48,34 @@ func (h MappingRestHandler) getReadMappingById(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// GetReadMappingById godoc
+// GetReadMappings godoc
 // @Summary Get Read Mappings
-// @Description Method to get all read mappings
+// @Description Method to get the page of the read mappings
 // @Tags Read Mapping
 // @Accept json
 // @Produce json
-// @Success 200 {array} domain.ReadMapping
-// @Failure 500 {object} domain.Error
+// @Param limit query int false "Page limit (100 by default, 1000 at most)"
+// @Param cursor query string false "Cursor of the page (X-Next-Cursor of the previous one)"
+// @Param sort query string false "Sort field: id (by default), '-' prefix is descending"
+// @Success 200 {array} api.ReadMapping
+// @Header 200 {integer} X-Total-Count "Count of the read mappings"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page (absent on the last page)"
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /mapping [get]
 func (h MappingRestHandler) getReadMappings(c *gin.Context) {
+	var query domain.PageQuery
+	if err := bindPageQuery(c, &query); err != nil {
+		listError(c, err)
+		return
+	}
 	var results []domain.ReadMapping
-	if err := h.readMappingService.GetAll(c.Request.Context(), &results); err != nil {
-		log.Error(err)
-		c.JSON(http.StatusInternalServerError, E(err))
+	var page domain.Page
+	if err := h.readMappingService.GetPage(c.Request.Context(), &query, &results, &page); err != nil {
+		listError(c, err)
 		return
 	}
+	setPageHeaders(c, &page)
 	c.JSON(http.StatusOK, results)
 }
 
@@ -75,17 +87,21 @@ func (h MappingRestHandler) getReadMappings(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Read Mapping Id"
 // @Success 200
-// @Failure 500 {object} domain.Error
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /mapping/{id} [delete]
 func (h MappingRestHandler) deleteReadMappingById(c *gin.Context) {
 	id := c.Param(ParamId)
 	if err := h.readMappingService.DeleteById(c.Request.Context(), id); err != nil {
 		log.Error(err)
-		if domain.ECode(err) == domain.ErrNotFound {
+		switch domain.ECode(err) {
+		case domain.ErrNotFound:
 			c.Status(http.StatusNotFound)
-			return
+		case domain.ErrConflict:
+			c.JSON(http.StatusConflict, E(err))
+		default:
+			c.JSON(http.StatusInternalServerError, E(err))
 		}
-		c.JSON(http.StatusInternalServerError, E(err))
 	}
 }
 
@@ -95,9 +111,9 @@ func (h MappingRestHandler) deleteReadMappingById(c *gin.Context) {
 // @Tags Read Mapping
 // @Accept json
 // @Produce json
-// @Param read_mapping body domain.ReadMapping true "Read Mapping (without id)"
-// @Success 200 {object} domain.ReadMapping
-// @Failure 500 {object} domain.Error
+// @Param read_mapping body api.ReadMapping true "Read Mapping (without id)"
+// @Success 200 {object} api.ReadMapping
+// @Failure 500 {object} api.Error
 // @Router /mapping [post]
 func (h MappingRestHandler) createReadMapping(c *gin.Context) {
 	var obj domain.ReadMapping
@@ -1,10 +1,26 @@
 package rest
 
 import (
+	"encoding/json"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	QueryAtomic      = "atomic"
+	QueryStatus      = "status"
+	QueryCreatedFrom = "created_from"
+	QueryCreatedTo   = "created_to"
+
+	// Prefix of the body field filters, e.g. body.customerId=1
+	QueryBodyPrefix = "body."
 )
 
 type OrderRestHandler struct {
@@ -19,7 +35,10 @@ func (h OrderRestHandler) Register(router *gin.Engine) {
 	group := router.Group("/order")
 	group.GET("/:"+ParamId, h.getOrderById)
 	group.GET("/", h.getOrders)
+	group.GET("/:"+ParamId+"/jobs", h.getOrderJobs)
 	group.POST("/:"+ParamProcessId, h.submitOrder)
+	group.POST("/:"+ParamProcessId+"/bulk", h.submitOrders)
+	group.POST("/:"+ParamProcessId+"/search", h.searchOrders)
 }
 
 // GetOrderById godoc
@@ -29,8 +48,8 @@ func (h OrderRestHandler) Register(router *gin.Engine) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Order Id"
-// @Success 200 {object} domain.Order
-// @Failure 500 {object} domain.Error
+// @Success 200 {object} api.Order
+// @Failure 500 {object} api.Error
 // @Router /order/{id} [get]
 func (h OrderRestHandler) getOrderById(c *gin.Context) {
 	id := c.Param(ParamId)
@@ -47,19 +66,89 @@ func (h OrderRestHandler) getOrderById(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// GetOrderById godoc
+// GetOrders godoc
 // @Summary Get Orders
-// @Description Method to get all orders
+// @Description Method to get the page of the orders, body fields are filtered by body.{field} parameters
 // @Tags Order
 // @Accept json
 // @Produce json
-// @Success 200 {array} domain.Order
-// @Failure 500 {object} domain.Error
+// @Param limit query int false "Page limit (100 by default, 1000 at most)"
+// @Param cursor query string false "Cursor of the page (X-Next-Cursor of the previous one)"
+// @Param sort query string false "Sort field: createdAt (by default) or id, '-' prefix is descending"
+// @Param process_id query string false "Process Id"
+// @Param status query string false "Status: running, completed or failed"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Success 200 {array} api.Order
+// @Header 200 {integer} X-Total-Count "Count of the orders matched by the filters"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page (absent on the last page)"
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /order [get]
 func (h OrderRestHandler) getOrders(c *gin.Context) {
+	var query domain.OrderQuery
+	if err := bindOrderQuery(c, &query); err != nil {
+		listError(c, err)
+		return
+	}
 	var results []domain.Order
-	if err := h.orderService.GetOrders(c.Request.Context(), &results); err != nil {
+	var page domain.Page
+	if err := h.orderService.GetOrdersPage(c.Request.Context(), &query, &results, &page); err != nil {
+		listError(c, err)
+		return
+	}
+	setPageHeaders(c, &page)
+	c.JSON(http.StatusOK, results)
+}
+
+func bindOrderQuery(c *gin.Context, query *domain.OrderQuery) error {
+	const op = "OrderRestHandler.BindQuery"
+
+	if err := bindPageQuery(c, &query.PageQuery); err != nil {
+		return err
+	}
+	query.ProcessId = c.Query(ParamProcessId)
+	query.Status = c.Query(QueryStatus)
+	for name, t := range map[string]**time.Time{QueryCreatedFrom: &query.CreatedFrom, QueryCreatedTo: &query.CreatedTo} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return domain.E(op, domain.ErrValidation, fmt.Sprintf("%s has to be RFC 3339 time", name), err)
+			}
+			*t = &parsed
+		}
+	}
+	for name, values := range c.Request.URL.Query() {
+		if strings.HasPrefix(name, QueryBodyPrefix) && len(values) > 0 {
+			if query.Body == nil {
+				query.Body = make(map[string]string)
+			}
+			query.Body[strings.TrimPrefix(name, QueryBodyPrefix)] = values[0]
+		}
+	}
+	return nil
+}
+
+// GetOrderJobs godoc
+// @Summary Get Order Jobs
+// @Description Method to get jobs of the order
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param id path string true "Order Id"
+// @Success 200 {array} api.Job
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /order/{id}/jobs [get]
+func (h OrderRestHandler) getOrderJobs(c *gin.Context) {
+	id := c.Param(ParamId)
+	var results []domain.Job
+	if err := h.orderService.GetOrderJobs(c.Request.Context(), id, &results); err != nil {
 		log.Error(err)
+		if domain.ECode(err) == domain.ErrNotFound {
+			c.Status(http.StatusNotFound)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, E(err))
 		return
 	}
@@ -68,16 +157,22 @@ func (h OrderRestHandler) getOrders(c *gin.Context) {
 
 // SubmitOrder godoc
 // @Summary Submit Order
-// @Description Method to submit order
+// @Description Method to submit order. Resubmission with the same idempotency key (header or field of the order)
+// @Description returns the original order
 // @Tags Order
 // @Accept json
 // @Produce json
 // @Param process_id path string true "Process Id"
-// @Param order body domain.Order true "Order (without id)"
-// @Success 200 {object} domain.Order
-// @Failure 500 {object} domain.Error
-// @Router /order [post]
+// @Param Idempotency-Key header string false "Idempotency key (unique per process)"
+// @Param order body api.Order true "Order (without id)"
+// @Success 200 {object} api.Order
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /order/{process_id} [post]
 func (h OrderRestHandler) submitOrder(c *gin.Context) {
+	const op = "OrderRestHandler.SubmitOrder"
+
 	processId := c.Param(ParamProcessId)
 	var obj domain.Order
 	if err := c.BindJSON(&obj); err != nil {
@@ -85,10 +180,176 @@ func (h OrderRestHandler) submitOrder(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, E(err))
 		return
 	}
+	if key := c.GetHeader(domain.HeaderIdempotencyKey); key != "" {
+		if obj.IdempotencyKey != "" && obj.IdempotencyKey != key {
+			c.JSON(http.StatusBadRequest, E(domain.E(op, domain.ErrValidation,
+				"idempotency key of the header and the order are different")))
+			return
+		}
+		obj.IdempotencyKey = key
+	}
 	if err := h.orderService.SubmitOrder(c.Request.Context(), &obj, processId); err != nil {
 		log.Error(err)
-		c.JSON(http.StatusInternalServerError, E(err))
+		switch domain.ECode(err) {
+		case domain.ErrNotFound:
+			c.Status(http.StatusNotFound)
+		case domain.ErrValidation:
+			c.JSON(http.StatusBadRequest, E(err))
+		default:
+			c.JSON(http.StatusInternalServerError, E(err))
+		}
 		return
 	}
 	c.JSON(http.StatusOK, obj)
 }
+
+// SubmitOrders godoc
+// @Summary Submit Orders
+// @Description Method to submit array (or NDJSON stream) of orders, orders are decoded and created by chunks
+// @Description of transactions. Atomic submission creates either all orders or none of them (422 with the error
+// @Description and the items of the orders). Items of the orders submitted before the error (e.g. the limit
+// @Description of the orders is exceeded) are returned by 422 as well
+// @Tags Order
+// @Accept json,application/x-ndjson
+// @Produce json
+// @Param process_id path string true "Process Id"
+// @Param atomic query bool false "All or nothing" default(false)
+// @Param orders body []api.Order true "Orders (without id)"
+// @Success 200 {object} api.BulkOrderResult
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 422 {object} api.Error
+// @Failure 500 {object} api.Error
+// @Router /order/{process_id}/bulk [post]
+func (h OrderRestHandler) submitOrders(c *gin.Context) {
+	const op = "OrderRestHandler.SubmitOrders"
+
+	processId := c.Param(ParamProcessId)
+	atomic, err := strconv.ParseBool(c.DefaultQuery(QueryAtomic, "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, E(domain.E(op, domain.ErrValidation, "atomic has to be boolean", err)))
+		return
+	}
+	orders := newOrderDecoder(c.Request.Body, c.ContentType() == domain.ContentTypeApplicationNdjson)
+	var result domain.BulkOrderResult
+	if err := h.orderService.SubmitOrders(c.Request.Context(), orders, processId, atomic, &result); err != nil {
+		log.Error(err)
+
+		// Orders handled before the error (or rolled back ones of the atomic submission) are reported by the items
+		if len(result.Items) > 0 {
+			restErr := E(err)
+			restErr.Items = result.Items
+			c.JSON(http.StatusUnprocessableEntity, restErr)
+			return
+		}
+		switch domain.ECode(err) {
+		case domain.ErrNotFound:
+			c.Status(http.StatusNotFound)
+		case domain.ErrValidation:
+			c.JSON(http.StatusBadRequest, E(err))
+		default:
+			c.JSON(http.StatusInternalServerError, E(err))
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SearchOrders godoc
+// @Summary Search Orders
+// @Description Method to get the page of the orders of the process which match all the predicates (path and value).
+// @Description Paths are jsonpath-style ($.customer.id, $.items[*].productId) and they have to be the search paths
+// @Description of the process, values are required. Search paths are set by the creation (or the import) of the
+// @Description process only. Query parameters are the ones of the order list (except process_id)
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param process_id path string true "Process Id"
+// @Param limit query int false "Page limit (100 by default, 1000 at most)"
+// @Param cursor query string false "Cursor of the page (X-Next-Cursor of the previous one)"
+// @Param sort query string false "Sort field: createdAt (by default) or id, '-' prefix is descending"
+// @Param status query string false "Status: running, completed or failed"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param search body api.OrderSearch true "Predicates of the search"
+// @Success 200 {array} api.Order
+// @Header 200 {integer} X-Total-Count "Count of the orders matched by the predicates and the filters"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page (absent on the last page)"
+// @Failure 400 {object} api.Error
+// @Failure 404
+// @Failure 500 {object} api.Error
+// @Router /order/{process_id}/search [post]
+func (h OrderRestHandler) searchOrders(c *gin.Context) {
+	const op = "OrderRestHandler.SearchOrders"
+
+	var query domain.OrderQuery
+	if err := bindOrderQuery(c, &query); err != nil {
+		listError(c, err)
+		return
+	}
+	query.ProcessId = c.Param(ParamProcessId)
+	var search domain.OrderSearch
+	if err := json.NewDecoder(c.Request.Body).Decode(&search); err != nil {
+		listError(c, domain.E(op, domain.ErrValidation, "can't decode search", err))
+		return
+	}
+	var results []domain.Order
+	var page domain.Page
+	if err := h.orderService.SearchOrders(c.Request.Context(), &query, &search, &results, &page); err != nil {
+		if domain.ECode(err) == domain.ErrNotFound {
+			log.Error(err)
+			c.Status(http.StatusNotFound)
+			return
+		}
+		listError(c, err)
+		return
+	}
+	setPageHeaders(c, &page)
+	c.JSON(http.StatusOK, results)
+}
+
+// Orders of JSON array or NDJSON stream (one order per line) are decoded one by one, so the request isn't read
+// into memory at once
+type orderDecoder struct {
+	decoder *json.Decoder
+	ndjson  bool
+	started bool
+	done    bool
+	index   int
+}
+
+func newOrderDecoder(r io.Reader, ndjson bool) *orderDecoder {
+	return &orderDecoder{decoder: json.NewDecoder(r), ndjson: ndjson}
+}
+
+func (d *orderDecoder) Next(order *domain.Order) error {
+	const op = "OrderRestHandler.DecodeOrder"
+
+	if d.done {
+		return io.EOF
+	}
+	if !d.ndjson {
+		if !d.started {
+			if token, err := d.decoder.Token(); err != nil || token != json.Delim('[') {
+				return domain.E(op, domain.ErrValidation, "orders have to be json array", err)
+			}
+			d.started = true
+		}
+		if !d.decoder.More() {
+			if _, err := d.decoder.Token(); err != nil {
+				return domain.E(op, domain.ErrValidation, "json array of the orders isn't closed", err)
+			}
+			d.done = true
+			return io.EOF
+		}
+	}
+	*order = domain.Order{}
+	if err := d.decoder.Decode(order); err == io.EOF && d.ndjson {
+		d.done = true
+		return io.EOF
+	} else if err != nil {
+		return domain.E(op, domain.ErrValidation, fmt.Sprintf("can't decode order %d", d.index), err)
+	}
+	d.index++
+	return nil
+}
@@ -30,8 +30,8 @@ func (h ProcessRestHandler) Register(router *gin.Engine) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Process Id"
-// @Success 200 {object} domain.Process
-// @Failure 500 {object} domain.Error
+// @Success 200 {object} api.Process
+// @Failure 500 {object} api.Error
 // @Router /process/{id} [get]
 func (h ProcessRestHandler) getProcessById(c *gin.Context) {
 	id := c.Param(ParamId)
@@ -51,21 +51,32 @@ func (h ProcessRestHandler) getProcessById(c *gin.Context) {
 
 // GetProcesses godoc
 // @Summary Get Processes
-// @Description Method to get all processes
+// @Description Method to get the page of the processes
 // @Tags Process
 // @Accept json
 // @Produce json
-// @Success 200 {array} domain.Process
-// @Failure 500 {object} domain.Error
+// @Param limit query int false "Page limit (100 by default, 1000 at most)"
+// @Param cursor query string false "Cursor of the page (X-Next-Cursor of the previous one)"
+// @Param sort query string false "Sort field: name (by default) or id, '-' prefix is descending"
+// @Success 200 {array} api.Process
+// @Header 200 {integer} X-Total-Count "Count of the processes"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page (absent on the last page)"
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /process [get]
 func (h ProcessRestHandler) getProcesses(c *gin.Context) {
+	var query domain.PageQuery
+	if err := bindPageQuery(c, &query); err != nil {
+		listError(c, err)
+		return
+	}
 	var results []domain.Process
-	err := h.processService.GetAll(c.Request.Context(), &results)
-	if err != nil {
-		log.Error(err)
-		c.JSON(http.StatusInternalServerError, E(err))
+	var page domain.Page
+	if err := h.processService.GetPage(c.Request.Context(), &query, &results, &page); err != nil {
+		listError(c, err)
 		return
 	}
+	setPageHeaders(c, &page)
 	c.JSON(http.StatusOK, results)
 }
 
@@ -77,18 +88,22 @@ func (h ProcessRestHandler) getProcesses(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Process Id"
 // @Success 200
-// @Failure 500 {object} domain.Error
+// @Failure 409 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /process/{id} [delete]
 func (h ProcessRestHandler) deleteProcessById(c *gin.Context) {
 	id := c.Param(ParamId)
 	err := h.processService.DeleteById(c.Request.Context(), id)
 	if err != nil {
 		log.Error(err)
-		if domain.ECode(err) == domain.ErrNotFound {
+		switch domain.ECode(err) {
+		case domain.ErrNotFound:
 			c.Status(http.StatusNotFound)
-			return
+		case domain.ErrConflict:
+			c.JSON(http.StatusConflict, E(err))
+		default:
+			c.JSON(http.StatusInternalServerError, E(err))
 		}
-		c.JSON(http.StatusInternalServerError, E(err))
 	}
 }
 
@@ -98,9 +113,10 @@ func (h ProcessRestHandler) deleteProcessById(c *gin.Context) {
 // @Tags Process
 // @Accept json
 // @Produce json
-// @Param process body domain.Process true "Process (without id)"
-// @Success 200 {object} domain.Process
-// @Failure 500 {object} domain.Error
+// @Param process body api.Process true "Process (without id)"
+// @Success 200 {object} api.Process
+// @Failure 400 {object} api.Error
+// @Failure 500 {object} api.Error
 // @Router /process [post]
 func (h ProcessRestHandler) createProcess(c *gin.Context) {
 	var obj domain.Process
@@ -112,6 +128,10 @@ func (h ProcessRestHandler) createProcess(c *gin.Context) {
 	err := h.processService.Create(c.Request.Context(), &obj)
 	if err != nil {
 		log.Error(err)
+		if domain.ECode(err) == domain.ErrValidation {
+			c.JSON(http.StatusBadRequest, E(err))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, E(err))
 		return
 	}
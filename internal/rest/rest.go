@@ -1,13 +1,10 @@
 package rest
 
 import (
-	"bytes"
 	"context"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/pkg/api"
 	"github.com/gin-gonic/gin"
-	"github.com/hashicorp/go-retryablehttp"
-	"github.com/opentracing/opentracing-go"
-	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"strconv"
@@ -17,43 +14,57 @@ import (
 const (
 	ParamId        = "id"
 	ParamProcessId = "process_id"
-)
 
-type Error struct {
-	Ops      []domain.ErrOp `json:"ops"`
-	Messages []string       `json:"messages"`
-}
+	QueryLimit  = "limit"
+	QueryCursor = "cursor"
+	QuerySort   = "sort"
+)
 
-func E(err error) *Error {
-	return &Error{
+// Body of the failed response
+func E(err error) *api.ErrorResponse {
+	result := &api.ErrorResponse{
+		Code:     domain.ECode(err),
 		Ops:      domain.EOps(err),
 		Messages: domain.EMsgs(err),
 	}
+	if schemaErr := domain.ESchema(err); schemaErr != nil {
+		result.Violations = schemaErr.Violations
+	}
+	return result
 }
 
-// opentracing.GlobalTracer() have to be initialized
-func Send(ctx context.Context, client *retryablehttp.Client, url, method string, msgBytes []byte) (*http.Response, error) {
-	span, spanCtx := opentracing.StartSpanFromContext(ctx, method+" "+url)
-	defer span.Finish()
+// Page of the list by the query parameters
+func bindPageQuery(c *gin.Context, query *domain.PageQuery) error {
+	const op = "Rest.BindPageQuery"
 
-	request, err := retryablehttp.NewRequest(method, url, bytes.NewBuffer(msgBytes))
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't create http request (%s %s)", method, url)
+	if limit := c.Query(QueryLimit); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value <= 0 {
+			return domain.E(op, domain.ErrValidation, "limit has to be positive integer")
+		}
+		query.Limit = value
 	}
-	request.WithContext(spanCtx)
+	query.Cursor = c.Query(QueryCursor)
+	query.Sort = c.Query(QuerySort)
+	return nil
+}
 
-	tracer := opentracing.GlobalTracer()
-	err = tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(request.Header))
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't propagate tracing context (%s %s)", method, url)
+// Total count and the cursor of the next page are returned by the headers, so the body is the list itself
+func setPageHeaders(c *gin.Context, page *domain.Page) {
+	c.Header(domain.HeaderTotalCount, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header(domain.HeaderNextCursor, page.NextCursor)
 	}
+}
 
-	request.Header.Set(domain.HeaderContentType, domain.ContentTypeApplicationJson)
-	response, err := client.Do(request)
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't send http request (%s %s)", method, url)
+// List error of the invalid query is 400
+func listError(c *gin.Context, err error) {
+	log.Error(err)
+	if domain.ECode(err) == domain.ErrValidation {
+		c.JSON(http.StatusBadRequest, E(err))
+		return
 	}
-	return response, nil
+	c.JSON(http.StatusInternalServerError, E(err))
 }
 
 type Server struct {
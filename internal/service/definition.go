@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/bpmn"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"sort"
+)
+
+type ProcessDefinitionService struct {
+	processRepo     database.ProcessRepo
+	readMappingRepo database.ReadMappingRepo
+	newUUIDFunc     database.NewUUIDFunc
+	execTxFunc      domain.ExecTxFunc
+}
+
+func NewProcessDefinitionService(processRepo database.ProcessRepo, readMappingRepo database.ReadMappingRepo,
+	newUUIDFunc database.NewUUIDFunc, execTxFunc domain.ExecTxFunc) *ProcessDefinitionService {
+
+	return &ProcessDefinitionService{
+		processRepo:     processRepo,
+		readMappingRepo: readMappingRepo,
+		newUUIDFunc:     newUUIDFunc,
+		execTxFunc:      execTxFunc,
+	}
+}
+
+// Create read mappings and the process of the definition in a single transaction
+func (s ProcessDefinitionService) Import(ctx context.Context, def *domain.ProcessDefinition,
+	result *domain.Process) error {
+
+	const op = "ProcessDefinitionService.Import"
+
+	process, mappings, err := s.resolve(def)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	if err := validateProcess(process); err != nil {
+		return domain.E(op, err)
+	}
+
+	var repoProcess database.Process
+	err = s.execTxFunc(ctx, func(txCtx context.Context) error {
+		// Only mappings referenced by the tasks are created, shared ones are created once
+		mappingIds := make(map[string]string, len(mappings))
+		for i := range process.Tasks {
+			task := &process.Tasks[i]
+			if id, ok := mappingIds[task.ReadMappingId]; ok {
+				task.ReadMappingId = id
+				continue
+			}
+			mapping := database.ReadMapping{Body: database.Body(mappings[task.ReadMappingId])}
+			if err := s.readMappingRepo.Create(txCtx, &mapping); err != nil {
+				return err
+			}
+			mappingIds[task.ReadMappingId] = mapping.Id
+			task.ReadMappingId = mapping.Id
+		}
+		fromProcess(process, &repoProcess)
+		return s.processRepo.Create(txCtx, &repoProcess)
+	})
+	if err != nil {
+		return domain.E(op, err)
+	}
+
+	// Propagate result
+	toProcess(&repoProcess, result)
+	return nil
+}
+
+// Translate BPMN document into the definition and import it
+func (s ProcessDefinitionService) ImportBpmn(ctx context.Context, data []byte, result *domain.Process) error {
+	const op = "ProcessDefinitionService.ImportBpmn"
+
+	def, err := bpmn.Translate(data)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	if err := s.Import(ctx, def, result); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Build definition of the existing process, read mappings are named by their ids
+func (s ProcessDefinitionService) Export(ctx context.Context, processId string,
+	result *domain.ProcessDefinition) error {
+
+	const op = "ProcessDefinitionService.Export"
+
+	var process database.Process
+	if err := s.processRepo.GetById(ctx, processId, &process); err != nil {
+		return domain.E(op, err)
+	}
+
+	taskNames := make(map[string]string, len(process.Tasks))
+	for _, task := range process.Tasks {
+		taskNames[task.Id] = task.Name
+	}
+	dependsOn := make(map[string][]string)
+	for _, rel := range process.TaskRelations {
+		dependsOn[rel.ChildId] = append(dependsOn[rel.ChildId], taskNames[rel.ParentId])
+	}
+
+	result.Name = process.Name
+	result.OrderSchema = domain.Body(process.OrderSchema)
+	result.SearchPaths = process.SearchPaths
+	result.Mappings = make(map[string]domain.Body)
+	result.Tasks = make([]domain.TaskDefinition, len(process.Tasks))
+	for i, task := range process.Tasks {
+		if _, ok := result.Mappings[task.ReadMappingId]; !ok {
+			var mapping database.ReadMapping
+			if err := s.readMappingRepo.GetById(ctx, task.ReadMappingId, &mapping); err != nil {
+				return domain.E(op, fmt.Sprintf("can't get read mapping (%s)", task.ReadMappingId), err)
+			}
+			result.Mappings[task.ReadMappingId] = domain.Body(mapping.Body)
+		}
+		sort.Strings(dependsOn[task.Id])
+		result.Tasks[i] = domain.TaskDefinition{
+			Name:      task.Name,
+			Category:  domain.TaskCategoryNames[task.Category],
+			Action:    task.Action,
+			Sync:      task.Sync,
+			Http:      (*domain.HttpTaskConfig)(task.Http),
+			Mapping:   task.ReadMappingId,
+			DependsOn: dependsOn[task.Id],
+
+			OutputSchema: domain.Body(task.OutputSchema),
+		}
+	}
+	return nil
+}
+
+// Resolve names of the definition into the process with generated task ids, read mapping ids of the tasks
+// refer to the returned mapping bodies until the mappings are created
+func (s ProcessDefinitionService) resolve(def *domain.ProcessDefinition) (*domain.Process, map[string]domain.Body,
+	error) {
+
+	const op = "ProcessDefinitionService.Resolve"
+
+	if def.Name == "" {
+		return nil, nil, domain.E(op, domain.ErrValidation, "process name is required")
+	}
+	if len(def.Tasks) == 0 {
+		return nil, nil, domain.E(op, domain.ErrValidation, "process has no tasks")
+	}
+	categories := make(map[string]int, len(domain.TaskCategoryNames))
+	for category, name := range domain.TaskCategoryNames {
+		categories[name] = category
+	}
+	for name, body := range def.Mappings {
+		if err := validateMappingBody(body); err != nil {
+			return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid mapping (%s)", name), err)
+		}
+	}
+
+	process := domain.Process{Name: def.Name, Tasks: make([]domain.Task, len(def.Tasks)),
+		OrderSchema: normalizeSchema(def.OrderSchema), SearchPaths: def.SearchPaths}
+	mappings := make(map[string]domain.Body)
+	taskIds := make(map[string]string, len(def.Tasks))
+	for i, taskDef := range def.Tasks {
+		if taskDef.Name == "" {
+			return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("task name is required (%d)", i))
+		}
+		if _, ok := taskIds[taskDef.Name]; ok {
+			return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("duplicate task (%s)", taskDef.Name))
+		}
+		id, err := s.newUUIDFunc()
+		if err != nil {
+			return nil, nil, domain.E(op, "can't generate uuid", err)
+		}
+		taskIds[taskDef.Name] = id.String()
+
+		category := domain.HttpTaskCategory
+		if taskDef.Category != "" {
+			var ok bool
+			if category, ok = categories[taskDef.Category]; !ok {
+				return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("unknown category (%s, %s)",
+					taskDef.Name, taskDef.Category))
+			}
+		}
+
+		// Inlined mapping is named by the task, shared ones are prefixed to avoid collisions
+		var mappingName string
+		switch {
+		case taskDef.Mapping != "" && taskDef.MappingBody != nil:
+			return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("mapping and mapping body are "+
+				"mutually exclusive (%s)", taskDef.Name))
+		case taskDef.Mapping != "":
+			body, ok := def.Mappings[taskDef.Mapping]
+			if !ok {
+				return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("unknown mapping (%s, %s)",
+					taskDef.Name, taskDef.Mapping))
+			}
+			mappingName = "mapping:" + taskDef.Mapping
+			mappings[mappingName] = body
+		case taskDef.MappingBody != nil:
+			if err := validateMappingBody(taskDef.MappingBody); err != nil {
+				return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid mapping body (%s)",
+					taskDef.Name), err)
+			}
+			mappingName = "task:" + taskDef.Name
+			mappings[mappingName] = taskDef.MappingBody
+		default:
+			return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("mapping is required (%s)",
+				taskDef.Name))
+		}
+
+		process.Tasks[i] = domain.Task{
+			Id:            taskIds[taskDef.Name],
+			Name:          taskDef.Name,
+			Category:      category,
+			Action:        taskDef.Action,
+			ReadMappingId: mappingName,
+			Sync:          taskDef.Sync,
+			Http:          taskDef.Http,
+			OutputSchema:  normalizeSchema(taskDef.OutputSchema),
+		}
+	}
+
+	for _, taskDef := range def.Tasks {
+		for _, parent := range taskDef.DependsOn {
+			parentId, ok := taskIds[parent]
+			if !ok {
+				return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("unknown parent task (%s, %s)",
+					taskDef.Name, parent))
+			}
+			if parent == taskDef.Name {
+				return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("task depends on itself (%s)",
+					taskDef.Name))
+			}
+			process.TaskRelations = append(process.TaskRelations, domain.TaskRelation{
+				ParentId: parentId,
+				ChildId:  taskIds[taskDef.Name],
+			})
+		}
+	}
+	if name := findCycle(def.Tasks); name != "" {
+		return nil, nil, domain.E(op, domain.ErrValidation, fmt.Sprintf("cyclic dependency (%s)", name))
+	}
+	return &process, mappings, nil
+}
+
+// Jobs of the tasks within a cycle are never ready, returns name of a task of the cycle
+func findCycle(tasks []domain.TaskDefinition) string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	dependsOn := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		dependsOn[task.Name] = task.DependsOn
+	}
+	states := make(map[string]int, len(tasks))
+	var visit func(name string) string
+	visit = func(name string) string {
+		switch states[name] {
+		case visiting:
+			return name
+		case visited:
+			return ""
+		}
+		states[name] = visiting
+		for _, parent := range dependsOn[name] {
+			if cycle := visit(parent); cycle != "" {
+				return cycle
+			}
+		}
+		states[name] = visited
+		return ""
+	}
+	for _, task := range tasks {
+		if cycle := visit(task.Name); cycle != "" {
+			return cycle
+		}
+	}
+	return ""
+}
+
+// Values of the mapping are jsonpath expressions of the order body
+func validateMappingBody(body domain.Body) error {
+	for key, value := range body {
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("value isn't a string (%s)", key)
+		}
+	}
+	_, err := prepareBody(body)
+	return err
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testProcessRepo struct {
+	database.ProcessRepo
+	created []database.Process
+	process database.Process
+}
+
+func (r *testProcessRepo) Create(_ context.Context, obj *database.Process) error {
+	obj.Id = "p1"
+	r.created = append(r.created, *obj)
+	return nil
+}
+
+func (r *testProcessRepo) GetById(_ context.Context, id string, result *database.Process) error {
+	if id != r.process.Id {
+		return domain.E("testProcessRepo.GetById", domain.ErrNotFound)
+	}
+	*result = r.process
+	return nil
+}
+
+type testReadMappingRepo struct {
+	database.ReadMappingRepo
+	created  []database.ReadMapping
+	mappings map[string]database.Body
+}
+
+func (r *testReadMappingRepo) Create(_ context.Context, obj *database.ReadMapping) error {
+	obj.Id = fmt.Sprintf("m%d", len(r.created)+1)
+	r.created = append(r.created, *obj)
+	return nil
+}
+
+func (r *testReadMappingRepo) GetById(_ context.Context, id string, result *database.ReadMapping) error {
+	result.Id = id
+	result.Body = r.mappings[id]
+	return nil
+}
+
+func testDefinitionService(processRepo database.ProcessRepo, readMappingRepo database.ReadMappingRepo) *ProcessDefinitionService {
+	var count byte
+	newUUIDFunc := func() (uuid.UUID, error) {
+		count++
+		return uuid.UUID{count}, nil
+	}
+	execTxFunc := func(ctx context.Context, f domain.TxFunc) error {
+		return f(ctx)
+	}
+	return NewProcessDefinitionService(processRepo, readMappingRepo, newUUIDFunc, execTxFunc)
+}
+
+func testDefinition() *domain.ProcessDefinition {
+	return &domain.ProcessDefinition{
+		Name:     "process",
+		Mappings: map[string]domain.Body{"shared": {"id": "$.id"}},
+		Tasks: []domain.TaskDefinition{
+			{Name: "first", Action: "http://first", Mapping: "shared"},
+			{Name: "second", Action: "http://second", Mapping: "shared", DependsOn: []string{"first"}},
+			{Name: "third", Category: "external", Action: "topic", MappingBody: domain.Body{"name": "$.name"},
+				DependsOn: []string{"first", "second"}},
+		},
+	}
+}
+
+func TestProcessDefinitionService_Import(t *testing.T) {
+	assert := assert.New(t)
+
+	processRepo := &testProcessRepo{}
+	readMappingRepo := &testReadMappingRepo{}
+	s := testDefinitionService(processRepo, readMappingRepo)
+
+	var result domain.Process
+	err := s.Import(context.Background(), testDefinition(), &result)
+	assert.Nil(err)
+	assert.Equal("p1", result.Id)
+
+	// Shared mapping is created once
+	assert.Equal([]database.ReadMapping{
+		{Id: "m1", Body: database.Body{"id": "$.id"}},
+		{Id: "m2", Body: database.Body{"name": "$.name"}},
+	}, readMappingRepo.created)
+
+	first, second, third := uuid.UUID{1}.String(), uuid.UUID{2}.String(), uuid.UUID{3}.String()
+	assert.Equal([]domain.Task{
+		{Id: first, Name: "first", Category: domain.HttpTaskCategory, Action: "http://first", ReadMappingId: "m1"},
+		{Id: second, Name: "second", Category: domain.HttpTaskCategory, Action: "http://second", ReadMappingId: "m1"},
+		{Id: third, Name: "third", Category: domain.ExternalTaskCategory, Action: "topic", ReadMappingId: "m2"},
+	}, result.Tasks)
+	assert.Equal([]domain.TaskRelation{
+		{ParentId: first, ChildId: second},
+		{ParentId: first, ChildId: third},
+		{ParentId: second, ChildId: third},
+	}, result.TaskRelations)
+}
+
+func TestProcessDefinitionService_Import_Validation(t *testing.T) {
+	tests := map[string]func(def *domain.ProcessDefinition){
+		"no name":           func(def *domain.ProcessDefinition) { def.Name = "" },
+		"duplicate task":    func(def *domain.ProcessDefinition) { def.Tasks[1].Name = "first" },
+		"unknown category":  func(def *domain.ProcessDefinition) { def.Tasks[0].Category = "ftp" },
+		"unknown mapping":   func(def *domain.ProcessDefinition) { def.Tasks[0].Mapping = "absent" },
+		"no mapping":        func(def *domain.ProcessDefinition) { def.Tasks[0].Mapping = "" },
+		"invalid mapping":   func(def *domain.ProcessDefinition) { def.Mappings["shared"]["id"] = 1 },
+		"both mappings":     func(def *domain.ProcessDefinition) { def.Tasks[2].Mapping = "shared" },
+		"unknown parent":    func(def *domain.ProcessDefinition) { def.Tasks[1].DependsOn = []string{"absent"} },
+		"self dependency":   func(def *domain.ProcessDefinition) { def.Tasks[0].DependsOn = []string{"first"} },
+		"cyclic dependency": func(def *domain.ProcessDefinition) { def.Tasks[0].DependsOn = []string{"third"} },
+		"invalid process":   func(def *domain.ProcessDefinition) { def.Tasks[2].Action = "" },
+		"invalid schema":    func(def *domain.ProcessDefinition) { def.OrderSchema = domain.Body{"type": 1} },
+		"invalid path":      func(def *domain.ProcessDefinition) { def.SearchPaths = []string{"customer.id"} },
+		"duplicate path":    func(def *domain.ProcessDefinition) { def.SearchPaths = []string{"$.id", "$.id"} },
+		"timer output": func(def *domain.ProcessDefinition) {
+			def.Tasks[0] = domain.TaskDefinition{Name: "first", Category: "timer", Action: "1s", Mapping: "shared",
+				OutputSchema: domain.Body{"type": "object"}}
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			processRepo := &testProcessRepo{}
+			readMappingRepo := &testReadMappingRepo{}
+			s := testDefinitionService(processRepo, readMappingRepo)
+
+			def := testDefinition()
+			modify(def)
+			var result domain.Process
+			err := s.Import(context.Background(), def, &result)
+			assert.Equal(domain.ErrValidation, domain.ECode(err))
+			assert.Empty(processRepo.created)
+			assert.Empty(readMappingRepo.created)
+		})
+	}
+}
+
+// Schemas decoded from YAML have maps with interface{} keys
+func TestProcessDefinitionService_Import_Schema(t *testing.T) {
+	assert := assert.New(t)
+
+	processRepo := &testProcessRepo{}
+	s := testDefinitionService(processRepo, &testReadMappingRepo{})
+
+	def := testDefinition()
+	def.OrderSchema = domain.Body{"type": "object", "properties": map[interface{}]interface{}{
+		"id": map[interface{}]interface{}{"type": "string"}}}
+	def.Tasks[0].OutputSchema = domain.Body{"required": []interface{}{"status"}}
+	var result domain.Process
+	assert.Nil(s.Import(context.Background(), def, &result))
+	assert.Equal(domain.Body{"type": "object", "properties": map[string]interface{}{
+		"id": map[string]interface{}{"type": "string"}}}, result.OrderSchema)
+	assert.Equal(domain.Body{"required": []interface{}{"status"}}, result.Tasks[0].OutputSchema)
+	if assert.Len(processRepo.created, 1) {
+		assert.Equal(database.Body(result.OrderSchema), processRepo.created[0].OrderSchema)
+	}
+}
+
+func TestProcessDefinitionService_Export(t *testing.T) {
+	assert := assert.New(t)
+
+	processRepo := &testProcessRepo{process: database.Process{
+		Id:   "p1",
+		Name: "process",
+		Tasks: []database.Task{
+			{Id: "t1", Name: "first", Category: domain.HttpTaskCategory, Action: "http://first", ReadMappingId: "m1"},
+			{Id: "t2", Name: "second", Category: domain.NatsTaskCategory, Action: "second", ReadMappingId: "m1"},
+		},
+		TaskRelations: []database.TaskRelation{{ParentId: "t1", ChildId: "t2"}},
+	}}
+	readMappingRepo := &testReadMappingRepo{mappings: map[string]database.Body{"m1": {"id": "$.id"}}}
+	s := testDefinitionService(processRepo, readMappingRepo)
+
+	var result domain.ProcessDefinition
+	err := s.Export(context.Background(), "p1", &result)
+	assert.Nil(err)
+	assert.Equal(domain.ProcessDefinition{
+		Name:     "process",
+		Mappings: map[string]domain.Body{"m1": {"id": "$.id"}},
+		Tasks: []domain.TaskDefinition{
+			{Name: "first", Category: "http", Action: "http://first", Mapping: "m1"},
+			{Name: "second", Category: "nats", Action: "second", Mapping: "m1", DependsOn: []string{"first"}},
+		},
+	}, result)
+
+	err = s.Export(context.Background(), "p2", &result)
+	assert.Equal(domain.ErrNotFound, domain.ECode(err))
+}
+
+func TestProcessDefinitionService_ImportBpmn(t *testing.T) {
+	assert := assert.New(t)
+
+	const data = `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:pp="urn:pp-gin:bpmn">
+  <process id="process">
+    <serviceTask id="first" pp:action="http://first"/>
+    <sequenceFlow id="f1" sourceRef="first" targetRef="wait"/>
+    <intermediateCatchEvent id="wait">
+      <timerEventDefinition><timeDuration>PT5M</timeDuration></timerEventDefinition>
+    </intermediateCatchEvent>
+  </process>
+</definitions>`
+
+	processRepo := &testProcessRepo{}
+	readMappingRepo := &testReadMappingRepo{}
+	s := testDefinitionService(processRepo, readMappingRepo)
+
+	var result domain.Process
+	assert.Nil(s.ImportBpmn(context.Background(), []byte(data), &result))
+	first, wait := uuid.UUID{1}.String(), uuid.UUID{2}.String()
+	assert.Equal([]domain.Task{
+		{Id: first, Name: "first", Category: domain.HttpTaskCategory, Action: "http://first", ReadMappingId: "m1"},
+		{Id: wait, Name: "wait", Category: domain.TimerTaskCategory, Action: "5m0s", ReadMappingId: "m1"},
+	}, result.Tasks)
+	assert.Equal([]domain.TaskRelation{{ParentId: first, ChildId: wait}}, result.TaskRelations)
+	assert.Len(readMappingRepo.created, 1)
+
+	err := s.ImportBpmn(context.Background(), []byte("<definitions/>"), &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Len(processRepo.created, 1)
+}
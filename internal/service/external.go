@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	maxErrorLength   = 1024
+	lockExpiredError = "lock expired, retries are exhausted"
+)
+
+// Jobs of external tasks aren't started by the scheduler, they are fetched and locked by the workers.
+// Lock id is the attempt id of the job, so the lock is lost as soon as the job is fetched again.
+// Expired lock is counted as a failed attempt, so the job is failed if it's taken over after the last retry
+type ExternalJobService struct {
+	jobRepo            database.JobRepo
+	orderService       domain.OrderService
+	readMappingService domain.ReadMappingService
+	jobLimit           int
+	retriesMax         int
+	retryDelay         time.Duration
+}
+
+func NewExternalJobService(
+	cfg domain.SchedulerConfig,
+	jobRepo database.JobRepo,
+	orderService domain.OrderService,
+	readMappingService domain.ReadMappingService,
+) *ExternalJobService {
+	return &ExternalJobService{
+		jobRepo:            jobRepo,
+		orderService:       orderService,
+		readMappingService: readMappingService,
+		jobLimit:           cfg.JobLimit,
+		retriesMax:         cfg.RetriesMax,
+		retryDelay:         cfg.RetryDelaySec * time.Second,
+	}
+}
+
+func (s ExternalJobService) FetchAndLock(ctx context.Context, req *domain.JobFetchRequest,
+	result *[]domain.LockedJob) error {
+
+	const op = "ExternalJobService.FetchAndLock"
+
+	if len(req.Topics) == 0 || req.MaxJobs <= 0 || req.LockDurationSec <= 0 {
+		return domain.E(op, domain.ErrValidation, "topics, max jobs and lock duration are required")
+	}
+	jobLimit := req.MaxJobs
+	if s.jobLimit > 0 && jobLimit > s.jobLimit {
+		jobLimit = s.jobLimit
+	}
+	lockedUntil := time.Now().Add(time.Duration(req.LockDurationSec) * time.Second)
+
+	var jobs []database.Job
+	if err := s.jobRepo.FetchJobs(ctx, req.Topics, jobLimit, lockedUntil, &jobs); err != nil {
+		return domain.E(op, err)
+	}
+
+	// Job which body can't be built is retried (like the failed start of the scheduler)
+	*result = make([]domain.LockedJob, 0, len(jobs))
+	for _, job := range jobs {
+		if job.Attempt > s.retriesMax+1 {
+			log.Warnf("%s: job lock expired, retries are exhausted (%s, %s)", op, job.TaskId, job.OrderId)
+			err := s.failJob(ctx, &job, domain.ErrRemotePermanent, lockExpiredError, true, 0)
+			if err != nil {
+				log.Error(domain.E(op, fmt.Sprintf("can't fail job (%s, %s)", job.TaskId, job.OrderId), err))
+			}
+			continue
+		}
+		body, err := s.getJobBody(ctx, &job)
+		if err != nil {
+			log.Error(err)
+			if err := s.failJob(ctx, &job, domain.ECode(err), err.Error(), false, 0); err != nil {
+				log.Error(domain.E(op, fmt.Sprintf("can't handle job failure (%s, %s)", job.TaskId, job.OrderId), err))
+			}
+			continue
+		}
+		*result = append(*result, domain.LockedJob{
+			LockId:      domain.JobAttemptId(job.TaskId, job.OrderId, job.Attempt),
+			TaskId:      job.TaskId,
+			OrderId:     job.OrderId,
+			Topic:       job.Action,
+			Body:        body,
+			LockedUntil: lockedUntil,
+			Trace:       job.Trace,
+		})
+	}
+	return nil
+}
+
+func (s ExternalJobService) Complete(ctx context.Context, lockId string, msg *domain.JobLockCompleteMessage) error {
+	const op = "ExternalJobService.Complete"
+
+	taskId, orderId, _, err := domain.ParseJobAttemptId(lockId)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	var completeMsg = domain.JobCompleteMessage{
+		TaskId:    taskId,
+		OrderId:   orderId,
+		AttemptId: lockId,
+		Body:      msg.Body,
+	}
+	if err := s.orderService.CompleteJob(ctx, &completeMsg); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+func (s ExternalJobService) Fail(ctx context.Context, lockId string, msg *domain.JobLockFailMessage) error {
+	const op = "ExternalJobService.Fail"
+
+	taskId, orderId, attempt, err := domain.ParseJobAttemptId(lockId)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	job := database.Job{TaskId: taskId, OrderId: orderId, Attempt: attempt}
+	errCode := domain.ErrRemoteRetryable
+	if msg.Permanent {
+		errCode = domain.ErrRemotePermanent
+	}
+	retryDelay := time.Duration(msg.RetryDelaySec) * time.Second
+	if err := s.failJob(ctx, &job, errCode, msg.Error, msg.Permanent, retryDelay); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+func (s ExternalJobService) ExtendLock(ctx context.Context, lockId string, msg *domain.JobLockExtendMessage) error {
+	const op = "ExternalJobService.ExtendLock"
+
+	if msg.LockDurationSec <= 0 {
+		return domain.E(op, domain.ErrValidation, "lock duration is required")
+	}
+	taskId, orderId, attempt, err := domain.ParseJobAttemptId(lockId)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	lockedUntil := time.Now().Add(time.Duration(msg.LockDurationSec) * time.Second)
+	if err := s.jobRepo.ExtendJobLock(ctx, taskId, orderId, attempt, lockedUntil); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+func (s ExternalJobService) getJobBody(ctx context.Context, job *database.Job) (domain.Body, error) {
+	const op = "ExternalJobService.GetJobBody"
+
+	var order domain.Order
+	if err := s.orderService.GetOrderById(ctx, job.OrderId, &order); err != nil {
+		return nil, domain.E(op, fmt.Sprintf("can't get order (%s)", job.OrderId), err)
+	}
+	var mapping domain.ReadMapping
+	if err := s.readMappingService.GetById(ctx, job.ReadMappingId, &mapping); err != nil {
+		return nil, domain.E(op, fmt.Sprintf("can't get read mapping (%s)", job.ReadMappingId), err)
+	}
+	body, err := buildStartJobBody(ctx, &mapping, order.Body)
+	if err != nil {
+		return nil, domain.E(op, fmt.Sprintf("can't build job body (%s, %s)", job.TaskId, job.OrderId), err)
+	}
+	return body, nil
+}
+
+func (s ExternalJobService) failJob(ctx context.Context, job *database.Job, errCode domain.ErrCode, response string,
+	permanent bool, retryDelay time.Duration) error {
+
+	failure := database.JobFailure{
+		TaskId:    job.TaskId,
+		OrderId:   job.OrderId,
+		Attempt:   job.Attempt,
+		ErrorCode: string(errCode),
+		Response:  response,
+	}
+	if len(failure.Response) > maxErrorLength {
+		failure.Response = failure.Response[:maxErrorLength]
+	}
+	delay := s.retryDelay
+	if retryDelay > delay {
+		delay = retryDelay
+	}
+	return handleJobFailure(ctx, s.jobRepo, &failure, permanent, s.retriesMax, delay)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testJobRepo struct {
+	database.JobRepo
+	failed     []database.JobFailure
+	retried    []database.JobFailure
+	startAfter time.Time
+	deferred   []time.Time
+	fetched    []database.Job
+}
+
+func (r *testJobRepo) FetchJobs(_ context.Context, _ []string, _ int, lockedUntil time.Time,
+	jobs *[]database.Job) error {
+
+	*jobs = r.fetched
+	for i := range *jobs {
+		(*jobs)[i].LockedUntil = &lockedUntil
+	}
+	return nil
+}
+
+func (r *testJobRepo) DeferJob(_ context.Context, _, _ string, _ int, startAfter time.Time) error {
+	r.deferred = append(r.deferred, startAfter)
+	return nil
+}
+
+func (r *testJobRepo) FailJob(_ context.Context, failure *database.JobFailure) error {
+	r.failed = append(r.failed, *failure)
+	return nil
+}
+
+func (r *testJobRepo) RetryJob(_ context.Context, failure *database.JobFailure, startAfter time.Time) error {
+	r.retried = append(r.retried, *failure)
+	r.startAfter = startAfter
+	return nil
+}
+
+func testExternalJobService(jobRepo database.JobRepo) *ExternalJobService {
+	cfg := domain.SchedulerConfig{JobLimit: 10, RetriesMax: 2, RetryDelaySec: 30}
+	return NewExternalJobService(cfg, jobRepo, nil, nil)
+}
+
+func TestExternalJobService_FetchAndLock_Validation(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testExternalJobService(&testJobRepo{})
+	var result []domain.LockedJob
+	err := s.FetchAndLock(context.Background(), &domain.JobFetchRequest{MaxJobs: 1, LockDurationSec: 10}, &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+}
+
+func TestExternalJobService_FetchAndLock(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{fetched: []database.Job{
+		{TaskId: "t1", OrderId: "o1", Action: "topic1", ReadMappingId: "m1", Attempt: 1, Trace: "trace"},
+	}}
+	cfg := domain.SchedulerConfig{JobLimit: 10, RetriesMax: 2}
+	s := NewExternalJobService(cfg, jobRepo, &testJobOrderService{}, testReadMappingService{})
+	var result []domain.LockedJob
+	err := s.FetchAndLock(context.Background(), &domain.JobFetchRequest{Topics: []string{"topic1"}, MaxJobs: 1,
+		LockDurationSec: 10}, &result)
+	assert.Nil(err)
+	if assert.Len(result, 1) {
+		assert.Equal("o1.t1.1", result[0].LockId)
+		assert.Equal("topic1", result[0].Topic)
+		assert.Equal(domain.Body{}, result[0].Body)
+		assert.Equal("trace", result[0].Trace)
+		assert.WithinDuration(time.Now().Add(10*time.Second), result[0].LockedUntil, time.Second)
+	}
+	assert.Empty(jobRepo.failed)
+}
+
+func TestExternalJobService_FetchAndLock_Takeover(t *testing.T) {
+	assert := assert.New(t)
+
+	// Expired lock of the attempt before the last retry is taken over, the last one fails the job
+	jobRepo := &testJobRepo{fetched: []database.Job{
+		{TaskId: "t1", OrderId: "o1", Action: "topic1", Attempt: 3},
+		{TaskId: "t2", OrderId: "o1", Action: "topic1", Attempt: 4},
+	}}
+	cfg := domain.SchedulerConfig{JobLimit: 10, RetriesMax: 2}
+	s := NewExternalJobService(cfg, jobRepo, &testJobOrderService{}, testReadMappingService{})
+	var result []domain.LockedJob
+	err := s.FetchAndLock(context.Background(), &domain.JobFetchRequest{Topics: []string{"topic1"}, MaxJobs: 2,
+		LockDurationSec: 10}, &result)
+	assert.Nil(err)
+	if assert.Len(result, 1) {
+		assert.Equal("o1.t1.3", result[0].LockId)
+	}
+	if assert.Len(jobRepo.failed, 1) {
+		assert.Equal("t2", jobRepo.failed[0].TaskId)
+		assert.Equal(4, jobRepo.failed[0].Attempt)
+		assert.Equal(string(domain.ErrRemotePermanent), jobRepo.failed[0].ErrorCode)
+	}
+	assert.Empty(jobRepo.retried)
+}
+
+func TestExternalJobService_Fail_Retry(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{}
+	s := testExternalJobService(jobRepo)
+	err := s.Fail(context.Background(), "o1.t1.2", &domain.JobLockFailMessage{Error: "failure", RetryDelaySec: 60})
+	assert.Nil(err)
+	assert.Equal([]database.JobFailure{{
+		TaskId: "t1", OrderId: "o1", Attempt: 2, ErrorCode: string(domain.ErrRemoteRetryable), Response: "failure",
+	}}, jobRepo.retried)
+	assert.WithinDuration(time.Now().Add(60*time.Second), jobRepo.startAfter, time.Second)
+	assert.Empty(jobRepo.failed)
+}
+
+func TestExternalJobService_Fail_RetriesExhausted(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{}
+	s := testExternalJobService(jobRepo)
+	err := s.Fail(context.Background(), "o1.t1.3", &domain.JobLockFailMessage{Error: "failure"})
+	assert.Nil(err)
+	assert.Len(jobRepo.failed, 1)
+	assert.Empty(jobRepo.retried)
+}
+
+func TestExternalJobService_Fail_Permanent(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{}
+	s := testExternalJobService(jobRepo)
+	err := s.Fail(context.Background(), "o1.t1.1", &domain.JobLockFailMessage{Error: "failure", Permanent: true})
+	assert.Nil(err)
+	assert.Equal(string(domain.ErrRemotePermanent), jobRepo.failed[0].ErrorCode)
+	assert.Empty(jobRepo.retried)
+}
+
+func TestExternalJobService_Fail_InvalidLockId(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testExternalJobService(&testJobRepo{})
+	err := s.Fail(context.Background(), "o1.t1", &domain.JobLockFailMessage{})
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+}
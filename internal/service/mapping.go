@@ -52,6 +52,21 @@ func (s ReadMappingService) GetAll(ctx context.Context, result *[]domain.ReadMap
 	return nil
 }
 
+func (s ReadMappingService) GetPage(ctx context.Context, query *domain.PageQuery, result *[]domain.ReadMapping,
+	page *domain.Page) error {
+
+	const op = "ReadMappingService.GetPage"
+
+	var repoResult []database.ReadMapping
+	if err := s.repo.GetPage(ctx, query, &repoResult, page); err != nil {
+		return domain.E(op, err)
+	}
+
+	// Propagate result
+	*result = toReadMappings(repoResult)
+	return nil
+}
+
 func (s ReadMappingService) Create(ctx context.Context, result *domain.ReadMapping) error {
 	const op = "ReadMappingService.Create"
 
@@ -111,6 +126,14 @@ func prepareBody(body domain.Body) (domain.PreparedBody, error) {
 func (s ReadMappingService) DeleteById(ctx context.Context, id string) error {
 	const op = "ReadMappingService.DeleteById"
 
+	// Mapping which is used by tasks can't be deleted
+	var taskCount int
+	if err := s.repo.GetTaskCount(ctx, id, &taskCount); err != nil {
+		return domain.E(op, err)
+	}
+	if taskCount > 0 {
+		return domain.E(op, domain.ErrConflict, fmt.Sprintf("read mapping is used by %d task(s) (%s)", taskCount, id))
+	}
 	if err := s.repo.DeleteById(ctx, id); err != nil {
 		return domain.E(op, err)
 	}
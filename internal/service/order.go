@@ -4,18 +4,38 @@ import (
 	"context"
 	"example.com/oligzeev/pp-gin/internal/database"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"io"
+	"time"
+)
+
+const (
+	defaultBulkChunkSize    = 100
+	maxIdempotencyKeyLength = 255
 )
 
 func toOrder(from *database.Order, to *domain.Order) {
 	to.Id = from.Id
 	to.ProcessId = from.ProcessId
 	to.Body = domain.Body(from.Body)
+	to.IdempotencyKey = ""
+	if from.IdempotencyKey != nil {
+		to.IdempotencyKey = *from.IdempotencyKey
+	}
+	to.CreatedAt = from.CreatedAt
 }
 
 func fromOrder(from *domain.Order, to *database.Order) {
 	to.Id = from.Id
 	to.ProcessId = from.ProcessId
 	to.Body = database.Body(from.Body)
+	to.IdempotencyKey = nil
+	if from.IdempotencyKey != "" {
+		key := from.IdempotencyKey
+		to.IdempotencyKey = &key
+	}
+	to.CreatedAt = from.CreatedAt
 }
 
 func toOrders(arr []database.Order) []domain.Order {
@@ -26,21 +46,59 @@ func toOrders(arr []database.Order) []domain.Order {
 	return result
 }
 
+func toJob(from *database.Job, to *domain.Job) {
+	to.TaskId = from.TaskId
+	to.OrderId = from.OrderId
+	to.Attempt = from.Attempt
+	to.StartAfter = from.StartAfter
+	to.ErrorCode = domain.ErrCode(from.ErrorCode)
+	to.Response = from.Response
+	to.Output = domain.Body(from.Output)
+	to.LockedUntil = from.LockedUntil
+	switch {
+	case from.Completed:
+		to.Status = domain.JobCompleted
+	case from.Failed && to.ErrorCode == domain.ErrCancelled:
+		to.Status = domain.JobCancelled
+	case from.Failed:
+		to.Status = domain.JobFailed
+	case from.Started:
+		to.Status = domain.JobStarted
+	case from.ReadyNum >= from.ReadyReq:
+		to.Status = domain.JobReady
+	default:
+		to.Status = domain.JobWaiting
+	}
+}
+
+func toJobs(arr []database.Job) []domain.Job {
+	result := make([]domain.Job, len(arr))
+	for i, obj := range arr {
+		toJob(&obj, &result[i])
+	}
+	return result
+}
+
 type OrderService struct {
+	cfg            domain.OrderConfig
 	processService domain.ProcessService
 	orderRepo      database.OrderRepo
 	jobRepo        database.JobRepo
 	execTxFunc     domain.ExecTxFunc
+	schemas        *SchemaCache
 }
 
-func NewOrderService(processService domain.ProcessService, orderRepo database.OrderRepo, jobRepo database.JobRepo,
-	execTxFunc domain.ExecTxFunc) *OrderService {
+// Compiled schemas of the processes are cached by the schema cache (it's shared with the process service)
+func NewOrderService(cfg domain.OrderConfig, processService domain.ProcessService, orderRepo database.OrderRepo,
+	jobRepo database.JobRepo, execTxFunc domain.ExecTxFunc, schemas *SchemaCache) *OrderService {
 
 	return &OrderService{
+		cfg:            cfg,
 		processService: processService,
 		orderRepo:      orderRepo,
 		jobRepo:        jobRepo,
 		execTxFunc:     execTxFunc,
+		schemas:        schemas,
 	}
 }
 
@@ -51,31 +109,235 @@ func (s OrderService) SubmitOrder(ctx context.Context, order *domain.Order, proc
 	if err := s.processService.GetById(ctx, processId, &process); err != nil {
 		return domain.E(op, err)
 	}
-	order.ProcessId = processId
-	err := s.execTxFunc(ctx, func(txCtx context.Context) error {
+	// TBD remove redundant operation 'fromProcess'
+	schemas, err := s.schemas.process(&process)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	schema := schemas.order
+	var repoProcess database.Process
+	fromProcess(&process, &repoProcess)
+	err = s.execTxFunc(ctx, func(txCtx context.Context) error {
+		return s.createOrder(txCtx, order, &repoProcess, schema)
+	})
+
+	// Concurrent submission with the same key has created the order
+	if domain.ECode(err) == domain.ErrConflict && order.IdempotencyKey != "" {
 		var repoOrder database.Order
-		fromOrder(order, &repoOrder)
-		if err := s.orderRepo.Create(txCtx, &repoOrder); err != nil {
-			return err
+		if err = s.orderRepo.GetByIdempotencyKey(ctx, processId, order.IdempotencyKey, &repoOrder); err == nil {
+			toOrder(&repoOrder, order)
+		}
+	}
+	if err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+func (s OrderService) SubmitOrders(ctx context.Context, orders domain.OrderReader, processId string, atomic bool,
+	result *domain.BulkOrderResult) error {
+
+	const op = "OrderService.SubmitOrders"
+
+	*result = domain.BulkOrderResult{}
+	var process domain.Process
+	if err := s.processService.GetById(ctx, processId, &process); err != nil {
+		return domain.E(op, err)
+	}
+	schemas, err := s.schemas.process(&process)
+	if err != nil {
+		return domain.E(op, err)
+	}
+	schema := schemas.order
+	var repoProcess database.Process
+	fromProcess(&process, &repoProcess)
+
+	if atomic {
+		return s.submitAtomic(ctx, orders, &repoProcess, schema, result)
+	}
+	chunkSize := s.cfg.BulkChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultBulkChunkSize
+	}
+	var items []domain.BulkOrderItem
+	chunk := make([]domain.Order, 0, chunkSize)
+	for {
+		// Orders are read by the chunks, so the submission doesn't hold all of them in memory
+		readErr := readOrders(orders, chunkSize, &chunk)
+		if len(chunk) == 0 && readErr == nil {
+			break
+		}
+		if s.cfg.BulkMaxOrders > 0 && len(items)+len(chunk) > s.cfg.BulkMaxOrders {
+			chunk = chunk[:s.cfg.BulkMaxOrders-len(items)]
+			readErr = domain.E(op, domain.ErrValidation,
+				fmt.Sprintf("orders are limited by %d, remaining orders aren't submitted", s.cfg.BulkMaxOrders))
 		}
 
-		// TBD remove redundant operation 'fromProcess'
-		var repoProcess database.Process
-		fromProcess(&process, &repoProcess)
-		if err := s.jobRepo.CreateJobs(txCtx, repoOrder.Id, &repoProcess); err != nil {
+		// Remaining orders aren't submitted, but the submitted ones are reported
+		if err := ctx.Err(); err != nil {
+			setBulkOrderResult(items, result)
+			return domain.E(op, domain.ErrCancelled, "remaining orders aren't submitted", err)
+		}
+		chunkItems := make([]domain.BulkOrderItem, len(chunk))
+		if _, err := s.createOrders(ctx, chunk, chunkItems, &repoProcess, schema); err != nil {
+			// Orders of the failed chunk are submitted one by one, so the failed order doesn't roll back the others
+			for i := range chunk {
+				_, _ = s.createOrders(ctx, chunk[i:i+1], chunkItems[i:i+1], &repoProcess, schema)
+			}
+		}
+		items = append(items, chunkItems...)
+		if readErr != nil {
+			setBulkOrderResult(items, result)
+			return domain.E(op, readErr)
+		}
+	}
+	setBulkOrderResult(items, result)
+	return nil
+}
+
+// Atomic submission reads all the orders (up to the limit) and creates them in the single transaction
+func (s OrderService) submitAtomic(ctx context.Context, orders domain.OrderReader, process *database.Process,
+	schema *gojsonschema.Schema, result *domain.BulkOrderResult) error {
+
+	const op = "OrderService.SubmitOrders"
+
+	// One more order is read to find out whether the limit is exceeded
+	limit, size := s.cfg.BulkMaxAtomicOrders, 0
+	if limit > 0 {
+		size = limit + 1
+	}
+	var chunk []domain.Order
+	if err := readOrders(orders, size, &chunk); err != nil {
+		return domain.E(op, err)
+	}
+	if limit > 0 && len(chunk) > limit {
+		return domain.E(op, domain.ErrValidation, fmt.Sprintf("atomic orders are limited by %d", limit))
+	}
+	items := make([]domain.BulkOrderItem, len(chunk))
+	failed, err := s.createOrders(ctx, chunk, items, process, schema)
+	setBulkOrderResult(items, result)
+	if err != nil {
+		if failed < 0 {
+			return domain.E(op, "orders are rolled back", err)
+		}
+		return domain.E(op, fmt.Sprintf("order %d isn't submitted, orders are rolled back", failed), err)
+	}
+	return nil
+}
+
+// Read the next chunk of the orders (up to the size, all of them if it isn't positive) into the buffer of the
+// chunk, the chunk is empty if there are no orders left
+func readOrders(orders domain.OrderReader, size int, chunk *[]domain.Order) error {
+	*chunk = (*chunk)[:0]
+	for size <= 0 || len(*chunk) < size {
+		var order domain.Order
+		if err := orders.Next(&order); err == io.EOF {
+			return nil
+		} else if err != nil {
 			return err
 		}
+		*chunk = append(*chunk, order)
+	}
+	return nil
+}
+
+func setBulkOrderResult(items []domain.BulkOrderItem, result *domain.BulkOrderResult) {
+	*result = domain.BulkOrderResult{Items: items}
+	for _, item := range items {
+		if item.Code == "" {
+			result.Submitted++
+		} else {
+			result.Failed++
+		}
+	}
+}
 
-		// Propagate generated id
-		order.Id = repoOrder.Id
+// Create the orders in the single transaction, all orders are rolled back if any of them is failed.
+// Index of the failed order is returned (-1 if the transaction isn't committed)
+func (s OrderService) createOrders(ctx context.Context, orders []domain.Order, items []domain.BulkOrderItem,
+	process *database.Process, schema *gojsonschema.Schema) (int, error) {
+
+	failed := -1
+	var failedErr error
+	err := s.execTxFunc(ctx, func(txCtx context.Context) error {
+		for i := range orders {
+			if err := s.createOrder(txCtx, &orders[i], process, schema); err != nil {
+				failed, failedErr = i, err
+				return err
+			}
+		}
 		return nil
 	})
-	if err != nil {
-		return domain.E(op, err)
+	if failed < 0 {
+		failedErr = err
+	}
+	for i := range orders {
+		switch {
+		case err == nil:
+			items[i] = domain.BulkOrderItem{Id: orders[i].Id}
+		case i == failed || failed < 0:
+			orders[i].Id = ""
+			items[i] = domain.BulkOrderItem{Code: domain.ECode(failedErr), Messages: domain.EMsgs(failedErr)}
+		default:
+			orders[i].Id = ""
+			items[i] = domain.BulkOrderItem{Code: domain.ErrCancelled, Messages: []string{"order is rolled back"}}
+		}
+	}
+	return failed, err
+}
+
+// Create the order along with the jobs of the process in the active transaction. Order with the idempotency key
+// of the existing order isn't created, the existing one is returned unless the key is expired. Body of the order
+// is validated by the order schema of the process (if any)
+func (s OrderService) createOrder(txCtx context.Context, order *domain.Order, process *database.Process,
+	schema *gojsonschema.Schema) error {
+
+	const op = "OrderService.CreateOrder"
+
+	if len(order.IdempotencyKey) > maxIdempotencyKeyLength {
+		return domain.E(op, domain.ErrValidation,
+			fmt.Sprintf("idempotency key is longer than %d", maxIdempotencyKeyLength))
+	}
+	if err := validateBody(schema, order.Body); err != nil {
+		return domain.E(op, domain.ErrValidation, "order body doesn't match the schema", err)
+	}
+	if order.IdempotencyKey != "" {
+		var existing database.Order
+		err := s.orderRepo.GetByIdempotencyKey(txCtx, process.Id, order.IdempotencyKey, &existing)
+		switch {
+		case err == nil && !s.idempotencyKeyExpired(&existing):
+			toOrder(&existing, order)
+			return nil
+		case err == nil:
+			if err := s.orderRepo.ReleaseIdempotencyKey(txCtx, existing.Id); err != nil {
+				return err
+			}
+		case domain.ECode(err) != domain.ErrNotFound:
+			return err
+		}
+	}
+
+	order.ProcessId = process.Id
+	var repoOrder database.Order
+	fromOrder(order, &repoOrder)
+	if err := s.orderRepo.Create(txCtx, &repoOrder); err != nil {
+		return err
 	}
+	if err := s.jobRepo.CreateJobs(txCtx, repoOrder.Id, process); err != nil {
+		return err
+	}
+
+	// Propagate generated id
+	order.Id = repoOrder.Id
+	order.CreatedAt = repoOrder.CreatedAt
 	return nil
 }
 
+func (s OrderService) idempotencyKeyExpired(order *database.Order) bool {
+	return s.cfg.IdempotencyRetentionSec > 0 && order.CreatedAt != nil &&
+		time.Since(*order.CreatedAt) > s.cfg.IdempotencyRetentionSec*time.Second
+}
+
 func (s OrderService) GetOrders(ctx context.Context, result *[]domain.Order) error {
 	const op = "OrderService.GetOrders"
 
@@ -89,6 +351,77 @@ func (s OrderService) GetOrders(ctx context.Context, result *[]domain.Order) err
 	return nil
 }
 
+func (s OrderService) GetOrdersPage(ctx context.Context, query *domain.OrderQuery, result *[]domain.Order,
+	page *domain.Page) error {
+
+	const op = "OrderService.GetOrdersPage"
+
+	var repoResult []database.Order
+	if err := s.orderRepo.GetPage(ctx, query, &repoResult, page); err != nil {
+		return domain.E(op, err)
+	}
+
+	// Propagate result
+	*result = toOrders(repoResult)
+	return nil
+}
+
+func (s OrderService) CountOrdersByProcess(ctx context.Context, result *map[string]int) error {
+	const op = "OrderService.CountOrdersByProcess"
+
+	if err := s.orderRepo.CountByProcess(ctx, result); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Predicates are translated into the documents which are contained by the bodies of the orders, paths of the
+// predicates have to be the search paths of the process and the values are required (null isn't searchable)
+func (s OrderService) SearchOrders(ctx context.Context, query *domain.OrderQuery, search *domain.OrderSearch,
+	result *[]domain.Order, page *domain.Page) error {
+
+	const op = "OrderService.SearchOrders"
+
+	if query.ProcessId == "" {
+		return domain.E(op, domain.ErrValidation, "process id is required")
+	}
+	if len(search.Predicates) == 0 {
+		return domain.E(op, domain.ErrValidation, "predicates are required")
+	}
+	var process domain.Process
+	if err := s.processService.GetById(ctx, query.ProcessId, &process); err != nil {
+		return domain.E(op, err)
+	}
+	searchPaths := make(map[string]bool, len(process.SearchPaths))
+	for _, path := range process.SearchPaths {
+		searchPaths[path] = true
+	}
+	repoQuery := *query
+	repoQuery.Contains = make([]domain.Body, len(search.Predicates))
+	for i, predicate := range search.Predicates {
+		if !searchPaths[predicate.Path] {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("path isn't searchable (%s)", predicate.Path))
+		}
+		if predicate.Value == nil {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("value is required (%s)", predicate.Path))
+		}
+		segments, err := parseSearchPath(predicate.Path)
+		if err != nil {
+			return domain.E(op, domain.ErrValidation, err)
+		}
+		repoQuery.Contains[i] = searchDocument(segments, predicate.Value)
+	}
+
+	var repoResult []database.Order
+	if err := s.orderRepo.GetPage(ctx, &repoQuery, &repoResult, page); err != nil {
+		return domain.E(op, err)
+	}
+
+	// Propagate result
+	*result = toOrders(repoResult)
+	return nil
+}
+
 func (s OrderService) GetOrderById(ctx context.Context, id string, result *domain.Order) error {
 	const op = "OrderService.GetOrderById"
 
@@ -103,14 +436,88 @@ func (s OrderService) GetOrderById(ctx context.Context, id string, result *domai
 	return nil
 }
 
-func (s OrderService) CompleteJob(ctx context.Context, taskId, orderId string) error {
+func (s OrderService) GetOrderJobs(ctx context.Context, orderId string, result *[]domain.Job) error {
+	const op = "OrderService.GetOrderJobs"
+
+	var repoResult []database.Job
+	if err := s.jobRepo.GetByOrderId(ctx, orderId, &repoResult); err != nil {
+		return domain.E(op, err)
+	}
+	// Orders have jobs, so the order is checked only if there aren't any (it's unknown or its process has no tasks)
+	if len(repoResult) == 0 {
+		var order database.Order
+		if err := s.orderRepo.GetById(ctx, orderId, &order); err != nil {
+			return domain.E(op, err)
+		}
+	}
+
+	// Propagate result
+	*result = toJobs(repoResult)
+	return nil
+}
+
+func (s OrderService) CompleteJob(ctx context.Context, msg *domain.JobCompleteMessage) error {
 	const op = "OrderService.CompleteJob"
 
+	if err := s.validateJobOutput(ctx, msg); err != nil {
+		return domain.E(op, err)
+	}
 	err := s.execTxFunc(ctx, func(txCtx context.Context) error {
-		return s.jobRepo.CompleteJob(txCtx, taskId, orderId)
+		return s.jobRepo.CompleteJob(txCtx, msg.TaskId, msg.OrderId, msg.AttemptId, database.Body(msg.Body))
 	})
 	if err != nil {
 		return domain.E(op, err)
 	}
 	return nil
 }
+
+// Body of the complete message is validated by the output schema of the task (if any). Order and process are read
+// only if the schemas of the process of the task aren't cached yet
+func (s OrderService) validateJobOutput(ctx context.Context, msg *domain.JobCompleteMessage) error {
+	const op = "OrderService.ValidateJobOutput"
+
+	schemas, ok := s.schemas.task(msg.TaskId)
+	if !ok {
+		var order database.Order
+		if err := s.orderRepo.GetById(ctx, msg.OrderId, &order); err != nil {
+			return domain.E(op, err)
+		}
+		var process domain.Process
+		if err := s.processService.GetById(ctx, order.ProcessId, &process); err != nil {
+			return domain.E(op, err)
+		}
+		var err error
+		if schemas, err = s.schemas.process(&process); err != nil {
+			return domain.E(op, err)
+		}
+	}
+	schema, ok := schemas.outputs[msg.TaskId]
+	if !ok {
+		return nil
+	}
+	if err := validateBody(schema, msg.Body); err != nil {
+		return domain.E(op, domain.ErrValidation,
+			fmt.Sprintf("job output doesn't match the schema (%s, %s)", msg.TaskId, msg.OrderId), err)
+	}
+	return nil
+}
+
+// Restart the job which isn't completed (e.g. failed one) as soon as possible, it gets all the retries again
+func (s OrderService) RetryJob(ctx context.Context, ref *domain.JobRef) error {
+	const op = "OrderService.RetryJob"
+
+	if err := s.jobRepo.ResetJob(ctx, ref.TaskId, ref.OrderId); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
+
+// Fail the job which isn't completed, it won't be started again
+func (s OrderService) CancelJob(ctx context.Context, ref *domain.JobRef) error {
+	const op = "OrderService.CancelJob"
+
+	if err := s.jobRepo.CancelJob(ctx, ref.TaskId, ref.OrderId); err != nil {
+		return domain.E(op, err)
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOrderBackend struct {
+	readMappingRepo database.ReadMappingRepo
+	processRepo     database.ProcessRepo
+	orderRepo       database.OrderRepo
+	jobRepo         database.JobRepo
+	execTx          domain.ExecTxFunc
+}
+
+// Orders with "fail" key are failed
+type testFailOrderRepo struct {
+	database.OrderRepo
+}
+
+func (r testFailOrderRepo) Create(ctx context.Context, obj *database.Order) error {
+	if _, ok := obj.Body["fail"]; ok {
+		return domain.E("OrderRepo.Create", domain.ErrValidation, "order is failed")
+	}
+	return r.OrderRepo.Create(ctx, obj)
+}
+
+func TestOrderService_SubmitOrders(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServices(t)
+	ctx, orderRepo := s.ctx, s.orderRepo
+	orderService := s.newOrderService(domain.OrderConfig{BulkChunkSize: 2, BulkMaxOrders: 5, BulkMaxAtomicOrders: 3},
+		testFailOrderRepo{orderRepo})
+	process := domain.Process{Name: "process", Tasks: []domain.Task{{Id: "t1", Name: "first", Action: "http://first"}}}
+	s.createProcess(t, &process)
+
+	// Failed order doesn't roll back the other orders of its chunk
+	orders := domain.OrderSlice{{Body: domain.Body{"id": "1"}}, {Body: domain.Body{"id": "2"}},
+		{Body: domain.Body{"fail": "3"}}, {Body: domain.Body{"id": "4"}}, {Body: domain.Body{"id": "5"}}}
+	var result domain.BulkOrderResult
+	assert.Nil(orderService.SubmitOrders(ctx, &orders, process.Id, false, &result))
+	assert.Equal(4, result.Submitted)
+	assert.Equal(1, result.Failed)
+	if assert.Len(result.Items, 5) {
+		assert.Equal(domain.BulkOrderItem{Code: domain.ErrValidation, Messages: []string{"order is failed"}},
+			result.Items[2])
+		for _, i := range []int{0, 1, 3, 4} {
+			assert.NotEmpty(result.Items[i].Id)
+		}
+	}
+	var stored []database.Order
+	assert.Nil(orderRepo.GetAll(ctx, &stored))
+	assert.Len(stored, 4)
+
+	// Atomic submission is rolled back entirely
+	orders = domain.OrderSlice{{Body: domain.Body{"id": "6"}}, {Body: domain.Body{"fail": "7"}}}
+	err := orderService.SubmitOrders(ctx, &orders, process.Id, true, &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Equal(0, result.Submitted)
+	assert.Equal(2, result.Failed)
+	if assert.Len(result.Items, 2) {
+		assert.Equal(domain.ErrCancelled, result.Items[0].Code)
+		assert.Equal(domain.BulkOrderItem{Code: domain.ErrValidation, Messages: []string{"order is failed"}},
+			result.Items[1])
+	}
+	stored = nil
+	assert.Nil(orderRepo.GetAll(ctx, &stored))
+	assert.Len(stored, 4)
+
+	// Atomic submission over the limit isn't started
+	orders = make(domain.OrderSlice, 4)
+	err = orderService.SubmitOrders(ctx, &orders, process.Id, true, &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Empty(result.Items)
+
+	// Orders after the limit aren't submitted, the ones before it are reported along with the error
+	orders = make(domain.OrderSlice, 6)
+	err = orderService.SubmitOrders(ctx, &orders, process.Id, false, &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Equal(5, result.Submitted)
+	assert.Len(result.Items, 5)
+
+	// Decoding error of the order stops the submission
+	reader := &testOrderReader{orders: domain.OrderSlice{{Body: domain.Body{"id": "8"}}},
+		err: domain.E("Test", domain.ErrValidation, "can't decode order 1")}
+	err = orderService.SubmitOrders(ctx, reader, process.Id, false, &result)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.Equal(1, result.Submitted)
+
+	orders = make(domain.OrderSlice, 1)
+	err = orderService.SubmitOrders(ctx, &orders, "unknown", false, &result)
+	assert.Equal(domain.ErrNotFound, domain.ECode(err))
+}
+
+// Orders are followed by the error
+type testOrderReader struct {
+	orders domain.OrderSlice
+	err    error
+}
+
+func (r *testOrderReader) Next(order *domain.Order) error {
+	if err := r.orders.Next(order); err != io.EOF {
+		return err
+	}
+	return r.err
+}
+
+// Orders found by the idempotency key are created the day before
+type testAgedOrderRepo struct {
+	database.OrderRepo
+}
+
+func (r testAgedOrderRepo) GetByIdempotencyKey(ctx context.Context, processId, key string,
+	result *database.Order) error {
+
+	if err := r.OrderRepo.GetByIdempotencyKey(ctx, processId, key, result); err != nil {
+		return err
+	}
+	createdAt := result.CreatedAt.Add(-24 * time.Hour)
+	result.CreatedAt = &createdAt
+	return nil
+}
+
+func TestOrderService_SubmitOrder_IdempotencyKey(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServices(t)
+	ctx, orderRepo := s.ctx, s.orderRepo
+	cfg := domain.OrderConfig{IdempotencyRetentionSec: 3600}
+	orderService := s.newOrderService(cfg, orderRepo)
+	process := domain.Process{Name: "process", Tasks: []domain.Task{{Id: "t1", Name: "first", Action: "http://first"}}}
+	s.createProcess(t, &process)
+
+	// Resubmission returns the original order, jobs aren't created again
+	order := domain.Order{Body: domain.Body{"id": "1"}, IdempotencyKey: "key1"}
+	assert.Nil(orderService.SubmitOrder(ctx, &order, process.Id))
+	resubmitted := domain.Order{Body: domain.Body{"id": "2"}, IdempotencyKey: "key1"}
+	assert.Nil(orderService.SubmitOrder(ctx, &resubmitted, process.Id))
+	assert.Equal(order, resubmitted)
+	var orders []database.Order
+	assert.Nil(orderRepo.GetAll(ctx, &orders))
+	assert.Len(orders, 1)
+	var jobs []domain.Job
+	assert.Nil(orderService.GetOrderJobs(ctx, order.Id, &jobs))
+	assert.Len(jobs, 1)
+	assert.Equal(domain.ErrNotFound, domain.ECode(orderService.GetOrderJobs(ctx, "unknown", &jobs)))
+
+	// Bulk submission returns the original order as well
+	var result domain.BulkOrderResult
+	bulk := domain.OrderSlice{{IdempotencyKey: "key1"}, {IdempotencyKey: "key2"}, {IdempotencyKey: "key2"}}
+	assert.Nil(orderService.SubmitOrders(ctx, &bulk, process.Id, false, &result))
+	if assert.Len(result.Items, 3) {
+		assert.Equal(order.Id, result.Items[0].Id)
+		assert.Equal(result.Items[1].Id, result.Items[2].Id)
+	}
+
+	// Expired key is used by the new order
+	agedService := s.newOrderService(cfg, testAgedOrderRepo{orderRepo})
+	resubmitted = domain.Order{Body: domain.Body{"id": "2"}, IdempotencyKey: "key1"}
+	assert.Nil(agedService.SubmitOrder(ctx, &resubmitted, process.Id))
+	assert.NotEqual(order.Id, resubmitted.Id)
+	var original domain.Order
+	assert.Nil(orderService.GetOrderById(ctx, order.Id, &original))
+	assert.Empty(original.IdempotencyKey)
+
+	long := domain.Order{IdempotencyKey: strings.Repeat("k", maxIdempotencyKeyLength+1)}
+	assert.Equal(domain.ErrValidation, domain.ECode(orderService.SubmitOrder(ctx, &long, process.Id)))
+}
+
+// Reads of the orders by id are counted
+type testReadOrderRepo struct {
+	database.OrderRepo
+	reads int
+}
+
+func (r *testReadOrderRepo) GetById(ctx context.Context, id string, result *database.Order) error {
+	r.reads++
+	return r.OrderRepo.GetById(ctx, id, result)
+}
+
+func TestOrderService_Schema(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServices(t)
+	ctx := s.ctx
+	orderRepo := &testReadOrderRepo{OrderRepo: s.orderRepo}
+	orderService := s.newOrderService(domain.OrderConfig{}, orderRepo)
+	process := domain.Process{Name: "process",
+		OrderSchema: domain.Body{"required": []interface{}{"id"}, "properties": map[string]interface{}{
+			"id": map[string]interface{}{"type": "string"}}},
+		Tasks: []domain.Task{
+			{Id: "t1", Name: "first", Action: "http://first",
+				OutputSchema: domain.Body{"required": []interface{}{"status"}}},
+		}}
+	s.createProcess(t, &process)
+
+	// Violations are returned along with the validation error
+	invalid := domain.Order{Body: domain.Body{"id": 1}}
+	err := orderService.SubmitOrder(ctx, &invalid, process.Id)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	if schemaErr := domain.ESchema(err); assert.NotNil(schemaErr) {
+		assert.Len(schemaErr.Violations, 1)
+	}
+	assert.Empty(invalid.Id)
+
+	var result domain.BulkOrderResult
+	orders := domain.OrderSlice{{Body: domain.Body{"id": "1"}}, {Body: domain.Body{}}}
+	assert.Nil(orderService.SubmitOrders(ctx, &orders, process.Id, false, &result))
+	assert.Equal(1, result.Submitted)
+	if assert.Len(result.Items, 2) {
+		assert.Equal(domain.ErrValidation, result.Items[1].Code)
+	}
+
+	order := domain.Order{Body: domain.Body{"id": "1"}}
+	assert.Nil(orderService.SubmitOrder(ctx, &order, process.Id))
+	msg := domain.JobCompleteMessage{TaskId: "t1", OrderId: order.Id, Body: domain.Body{"result": "ok"}}
+	err = orderService.CompleteJob(ctx, &msg)
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	assert.NotNil(domain.ESchema(err))
+	var jobs []domain.Job
+	assert.Nil(orderService.GetOrderJobs(ctx, order.Id, &jobs))
+	assert.Equal(domain.JobReady, jobs[0].Status)
+
+	msg.Body = domain.Body{"status": "ok"}
+	assert.Nil(orderService.CompleteJob(ctx, &msg))
+	assert.Nil(orderService.GetOrderJobs(ctx, order.Id, &jobs))
+	assert.Equal(domain.JobCompleted, jobs[0].Status)
+
+	// Schemas are cached by the submission, so the completion doesn't read the order
+	assert.Equal(0, orderRepo.reads)
+
+	// Schemas which aren't cached yet (e.g. after the restart) are found by the order
+	orderService.schemas = NewSchemaCache()
+	msg.Body = domain.Body{}
+	assert.Equal(domain.ErrValidation, domain.ECode(orderService.CompleteJob(ctx, &msg)))
+	assert.Equal(1, orderRepo.reads)
+
+	// Schemas are removed along with the process
+	deleted := domain.Process{Name: "deleted", Tasks: []domain.Task{{Id: "t2", Name: "first", Action: "http://first"}}}
+	s.createProcess(t, &deleted)
+	_, err = s.schemas.process(&deleted)
+	assert.Nil(err)
+	assert.Nil(s.processService.DeleteById(ctx, deleted.Id))
+	_, ok := s.schemas.task("t2")
+	assert.False(ok)
+	_, ok = s.schemas.task("t1")
+	assert.True(ok)
+}
+
+func TestOrderService_SearchOrders(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServices(t)
+	ctx, orderService := s.ctx, s.orderService
+	process := domain.Process{Name: "process", SearchPaths: []string{"$.customer.id", "$.items[*].productId"},
+		Tasks: []domain.Task{{Id: "t1", Name: "first", Action: "http://first"}}}
+	s.createProcess(t, &process)
+
+	first := domain.Order{Body: domain.Body{"customer": map[string]interface{}{"id": "c1", "name": "n1"},
+		"items": []interface{}{map[string]interface{}{"productId": "p1"}, map[string]interface{}{"productId": 2}}}}
+	second := domain.Order{Body: domain.Body{"customer": map[string]interface{}{"id": "c2", "name": "n1"}}}
+	for _, order := range []*domain.Order{&first, &second} {
+		assert.Nil(orderService.SubmitOrder(ctx, order, process.Id))
+	}
+
+	search := func(predicates ...domain.SearchPredicate) ([]domain.Order, error) {
+		var result []domain.Order
+		var page domain.Page
+		err := orderService.SearchOrders(ctx, &domain.OrderQuery{ProcessId: process.Id},
+			&domain.OrderSearch{Predicates: predicates}, &result, &page)
+		return result, err
+	}
+	result, err := search(domain.SearchPredicate{Path: "$.customer.id", Value: "c2"})
+	assert.Nil(err)
+	if assert.Len(result, 1) {
+		assert.Equal(second.Id, result[0].Id)
+	}
+	result, err = search(domain.SearchPredicate{Path: "$.items[*].productId", Value: 2},
+		domain.SearchPredicate{Path: "$.customer.id", Value: "c1"})
+	assert.Nil(err)
+	if assert.Len(result, 1) {
+		assert.Equal(first.Id, result[0].Id)
+	}
+
+	// Paths which aren't the search paths of the process are rejected
+	_, err = search(domain.SearchPredicate{Path: "$.customer.name", Value: "n1"})
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	_, err = search()
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	_, err = search(domain.SearchPredicate{Path: "$.customer.id"})
+	assert.Equal(domain.ErrValidation, domain.ECode(err))
+	var orders []domain.Order
+	var page domain.Page
+	err = orderService.SearchOrders(ctx, &domain.OrderQuery{ProcessId: "unknown"}, &domain.OrderSearch{
+		Predicates: []domain.SearchPredicate{{Path: "$.customer.id", Value: "c1"}}}, &orders, &page)
+	assert.Equal(domain.ErrNotFound, domain.ECode(err))
+}
+
+// go test -run none -bench SubmitOrder -benchmem example.com/oligzeev/pp-gin/internal/service
+func BenchmarkOrderService_SubmitOrder(b *testing.B) {
+	dir, err := ioutil.TempDir("", "pp-gin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := database.ConnectSQLite(domain.DbConfig{File: filepath.Join(dir, "pp.db"), MaxConnections: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := database.NewSQLiteMigrator(db).Up(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	store := database.NewMemStore()
+
+	backends := []struct {
+		name    string
+		backend testOrderBackend
+	}{
+		{"memory", testOrderBackend{
+			readMappingRepo: database.NewMemReadMappingRepo(store, uuid.NewUUID),
+			processRepo:     database.NewMemProcessRepo(store, uuid.NewUUID),
+			orderRepo:       database.NewMemOrderRepo(store, uuid.NewUUID),
+			jobRepo:         database.NewMemJobRepo(store),
+			execTx:          store.ExecTx,
+		}},
+		{"sqlite", testOrderBackend{
+			readMappingRepo: database.NewRDBReadMappingRepo(db, uuid.NewUUID),
+			processRepo:     database.NewRDBProcessRepo(db, uuid.NewUUID),
+			orderRepo:       database.NewRDBOrderRepo(db, uuid.NewUUID),
+			jobRepo:         database.NewSQLiteJobRepo(db),
+			execTx:          db.ExecTx,
+		}},
+	}
+	for _, backend := range backends {
+		for _, tasks := range []int{10, 50, 200, 500} {
+			b.Run(fmt.Sprintf("%s/tasks=%d", backend.name, tasks), func(b *testing.B) {
+				benchSubmitOrder(b, &backend.backend, tasks)
+			})
+		}
+	}
+}
+
+// Every task (except the first one) waits for the first and the previous tasks
+func benchSubmitOrder(b *testing.B, backend *testOrderBackend, tasks int) {
+	span, ctx := opentracing.StartSpanFromContext(context.Background(), "bench")
+	defer span.Finish()
+
+	mapping := domain.ReadMapping{Body: domain.Body{"id": "$.id"}}
+	if err := NewReadMappingService(backend.readMappingRepo).Create(ctx, &mapping); err != nil {
+		b.Fatal(err)
+	}
+	process := domain.Process{Name: "bench"}
+	for i := 0; i < tasks; i++ {
+		process.Tasks = append(process.Tasks, domain.Task{Id: uuid.New().String(), Name: fmt.Sprintf("task%d", i),
+			Action: "http://localhost", ReadMappingId: mapping.Id})
+		if i > 1 {
+			process.TaskRelations = append(process.TaskRelations,
+				domain.TaskRelation{ParentId: process.Tasks[0].Id, ChildId: process.Tasks[i].Id})
+		}
+		if i > 0 {
+			process.TaskRelations = append(process.TaskRelations,
+				domain.TaskRelation{ParentId: process.Tasks[i-1].Id, ChildId: process.Tasks[i].Id})
+		}
+	}
+	schemas := NewSchemaCache()
+	processService := NewProcessService(backend.processRepo, backend.execTx, schemas)
+	if err := processService.Create(ctx, &process); err != nil {
+		b.Fatal(err)
+	}
+	orderService := NewOrderService(domain.OrderConfig{}, processService, backend.orderRepo, backend.jobRepo,
+		backend.execTx, schemas)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		order := domain.Order{Body: domain.Body{"id": "1"}}
+		if err := orderService.SubmitOrder(ctx, &order, process.Id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
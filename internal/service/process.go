@@ -4,6 +4,20 @@ import (
 	"context"
 	"example.com/oligzeev/pp-gin/internal/database"
 	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	httpTaskMethods = map[string]bool{
+		http.MethodGet:    true,
+		http.MethodPost:   true,
+		http.MethodPut:    true,
+		http.MethodPatch:  true,
+		http.MethodDelete: true,
+	}
 )
 
 func toProcess(from *database.Process, to *domain.Process) {
@@ -11,6 +25,8 @@ func toProcess(from *database.Process, to *domain.Process) {
 	to.Name = from.Name
 	to.Tasks = toTasks(from.Tasks)
 	to.TaskRelations = toTaskRelations(from.TaskRelations)
+	to.OrderSchema = domain.Body(from.OrderSchema)
+	to.SearchPaths = from.SearchPaths
 }
 
 func fromProcess(from *domain.Process, to *database.Process) {
@@ -18,6 +34,8 @@ func fromProcess(from *domain.Process, to *database.Process) {
 	to.Name = from.Name
 	to.Tasks = fromTasks(from.Id, from.Tasks)
 	to.TaskRelations = fromTaskRelations(from.Id, from.TaskRelations)
+	to.OrderSchema = database.Body(from.OrderSchema)
+	to.SearchPaths = from.SearchPaths
 }
 
 func toProcesses(arr []database.Process) []domain.Process {
@@ -35,7 +53,10 @@ func toTasks(arr []database.Task) []domain.Task {
 		result[i].Name = obj.Name
 		result[i].Category = obj.Category
 		result[i].Action = obj.Action
+		result[i].Sync = obj.Sync
+		result[i].Http = (*domain.HttpTaskConfig)(obj.Http)
 		result[i].ReadMappingId = obj.ReadMappingId
+		result[i].OutputSchema = domain.Body(obj.OutputSchema)
 	}
 	return result
 }
@@ -48,7 +69,10 @@ func fromTasks(processId string, arr []domain.Task) []database.Task {
 		result[i].Name = obj.Name
 		result[i].Category = obj.Category
 		result[i].Action = obj.Action
+		result[i].Sync = obj.Sync
+		result[i].Http = (*database.HttpTaskConfig)(obj.Http)
 		result[i].ReadMappingId = obj.ReadMappingId
+		result[i].OutputSchema = database.Body(obj.OutputSchema)
 	}
 	return result
 }
@@ -75,10 +99,14 @@ func fromTaskRelations(processId string, arr []domain.TaskRelation) []database.T
 type ProcessService struct {
 	repo       database.ProcessRepo
 	execTxFunc domain.ExecTxFunc
+	schemas    *SchemaCache
 }
 
-func NewProcessService(processRepo database.ProcessRepo, execTxFunc domain.ExecTxFunc) *ProcessService {
-	return &ProcessService{repo: processRepo, execTxFunc: execTxFunc}
+// Schemas of the process are removed from the cache (it's shared with the order service) along with the process
+func NewProcessService(processRepo database.ProcessRepo, execTxFunc domain.ExecTxFunc,
+	schemas *SchemaCache) *ProcessService {
+
+	return &ProcessService{repo: processRepo, execTxFunc: execTxFunc, schemas: schemas}
 }
 
 func (s ProcessService) GetAll(ctx context.Context, result *[]domain.Process) error {
@@ -94,9 +122,28 @@ func (s ProcessService) GetAll(ctx context.Context, result *[]domain.Process) er
 	return nil
 }
 
+func (s ProcessService) GetPage(ctx context.Context, query *domain.PageQuery, result *[]domain.Process,
+	page *domain.Page) error {
+
+	const op = "ProcessService.GetPage"
+
+	var repoResult []database.Process
+	if err := s.repo.GetPage(ctx, query, &repoResult, page); err != nil {
+		return domain.E(op, err)
+	}
+
+	// Propagate result
+	*result = toProcesses(repoResult)
+	return nil
+}
+
 func (s ProcessService) Create(ctx context.Context, result *domain.Process) error {
 	const op = "ProcessService.Create"
 
+	if err := validateProcess(result); err != nil {
+		return domain.E(op, err)
+	}
+
 	var repoResult database.Process
 	fromProcess(result, &repoResult)
 	err := s.execTxFunc(ctx, func(txCtx context.Context) error {
@@ -127,11 +174,102 @@ func (s ProcessService) GetById(ctx context.Context, id string, result *domain.P
 func (s ProcessService) DeleteById(ctx context.Context, id string) error {
 	const op = "ProcessService.DeleteById"
 
+	// Process which has running orders can't be deleted, the rest of the orders are deleted along with it
 	err := s.execTxFunc(ctx, func(txCtx context.Context) error {
+		var orderCount int
+		if err := s.repo.GetRunningOrderCount(txCtx, id, &orderCount); err != nil {
+			return err
+		}
+		if orderCount > 0 {
+			return domain.E(op, domain.ErrConflict, fmt.Sprintf("process has %d running order(s) (%s)", orderCount, id))
+		}
 		return s.repo.DeleteById(txCtx, id)
 	})
 	if err != nil {
 		return domain.E(op, err)
 	}
+	s.schemas.remove(id)
 	return nil
 }
+
+func validateProcess(process *domain.Process) error {
+	const op = "ProcessService.Validate"
+
+	if _, err := compileSchema(process.OrderSchema); err != nil {
+		return domain.E(op, domain.ErrValidation, "invalid order schema", err)
+	}
+	if err := validateSearchPaths(process.SearchPaths); err != nil {
+		return domain.E(op, domain.ErrValidation, "invalid search paths", err)
+	}
+	for _, task := range process.Tasks {
+		if task.OutputSchema != nil && task.Category == domain.TimerTaskCategory {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("timer task has no output (%s)", task.Name))
+		}
+		if _, err := compileSchema(task.OutputSchema); err != nil {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid output schema (%s)", task.Name), err)
+		}
+		if task.Category != domain.HttpTaskCategory && (task.Sync || task.Http != nil) {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("sync and http are allowed for http tasks only (%s)",
+				task.Name))
+		}
+		if task.Category == domain.NatsTaskCategory && !validNatsSubject(task.Action) {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid nats subject (%s)", task.Name))
+		}
+		if task.Category == domain.ExternalTaskCategory && task.Action == "" {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("topic is required (%s)", task.Name))
+		}
+		if task.Category == domain.TimerTaskCategory {
+			if delay, err := time.ParseDuration(task.Action); err != nil || delay < 0 {
+				return domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid timer duration (%s)", task.Name))
+			}
+		}
+		if task.Http != nil {
+			if err := validateHttpTaskConfig(task.Http); err != nil {
+				return domain.E(op, domain.ErrValidation, fmt.Sprintf("invalid http config (%s)", task.Name), err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHttpTaskConfig(cfg *domain.HttpTaskConfig) error {
+	if cfg.Method != "" && !httpTaskMethods[cfg.Method] {
+		return fmt.Errorf("unsupported method (%s)", cfg.Method)
+	}
+	if cfg.TimeoutSec < 0 {
+		return fmt.Errorf("negative timeout (%d)", cfg.TimeoutSec)
+	}
+	if cfg.RetriesMax != nil && *cfg.RetriesMax < 0 {
+		return fmt.Errorf("negative retries (%d)", *cfg.RetriesMax)
+	}
+	for key, value := range cfg.Headers {
+		if key == "" || strings.ContainsAny(key, " :\t\r\n") {
+			return fmt.Errorf("invalid header name (%s)", key)
+		}
+		if _, err := prepareTemplate(value); err != nil {
+			return err
+		}
+	}
+	for key, value := range cfg.Query {
+		if key == "" {
+			return fmt.Errorf("empty query parameter name")
+		}
+		if _, err := prepareTemplate(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subject of start messages has to be a literal one (without wildcards)
+func validNatsSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, " *>\t\r\n") {
+		return false
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
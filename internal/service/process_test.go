@@ -0,0 +1,29 @@
+package service
+
+import (
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProcessService_DeleteById_Guards(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServices(t)
+	ctx := s.ctx
+	process := domain.Process{Name: "process", Tasks: []domain.Task{{Id: "t1", Name: "first", Action: "http://first"}}}
+	s.createProcess(t, &process)
+	order := domain.Order{}
+	assert.Nil(s.orderService.SubmitOrder(ctx, &order, process.Id))
+
+	// Mapping is used by the task, process has the running order
+	assert.Equal(domain.ErrConflict, domain.ECode(s.readMappingService.DeleteById(ctx, s.mapping.Id)))
+	assert.Equal(domain.ErrConflict, domain.ECode(s.processService.DeleteById(ctx, process.Id)))
+
+	// Cancelled order isn't running, so it's deleted along with the process
+	assert.Nil(s.jobRepo.CancelJob(ctx, "t1", order.Id))
+	assert.Nil(s.processService.DeleteById(ctx, process.Id))
+	var result database.Order
+	assert.Equal(domain.ErrNotFound, domain.ECode(s.orderRepo.GetById(ctx, order.Id, &result)))
+	assert.Nil(s.readMappingService.DeleteById(ctx, s.mapping.Id))
+}
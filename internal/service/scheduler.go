@@ -6,6 +6,7 @@ import (
 	"example.com/oligzeev/pp-gin/internal/domain"
 	"example.com/oligzeev/pp-gin/internal/tracing"
 	"fmt"
+	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -16,7 +17,9 @@ type JobScheduler struct {
 	readMappingService domain.ReadMappingService
 	period             time.Duration
 	jobLimit           int
-	startJobClient     domain.JobStartClient
+	retriesMax         int
+	retryDelay         time.Duration
+	startJobClients    map[int]domain.JobStartClient // Clients per task category
 }
 
 func NewJobScheduler(
@@ -24,7 +27,7 @@ func NewJobScheduler(
 	jobService database.JobRepo,
 	orderService domain.OrderService,
 	readMappingRepo domain.ReadMappingService,
-	startJobClient domain.JobStartClient,
+	startJobClients map[int]domain.JobStartClient,
 ) *JobScheduler {
 	return &JobScheduler{
 		jobRepo:            jobService,
@@ -32,7 +35,9 @@ func NewJobScheduler(
 		readMappingService: readMappingRepo,
 		period:             cfg.PeriodSec,
 		jobLimit:           cfg.JobLimit,
-		startJobClient:     startJobClient,
+		retriesMax:         cfg.RetriesMax,
+		retryDelay:         cfg.RetryDelaySec * time.Second,
+		startJobClients:    startJobClients,
 	}
 }
 
@@ -65,7 +70,12 @@ func (s JobScheduler) schedule() {
 	log.Tracef("%s: jobs execution (%v)", op, len(jobs))
 	for _, job := range jobs {
 		if err := s.processJob(&job); err != nil {
-			log.Error(err)
+			if domain.ECode(err) == domain.ErrDeferred {
+				log.Debug(err)
+			} else {
+				log.Error(err)
+			}
+			s.failJob(&job, err)
 		}
 	}
 	log.Tracef("%s: finished (%v)", op, len(jobs))
@@ -76,49 +86,180 @@ func (s JobScheduler) processJob(job *database.Job) error {
 
 	// Propagate span from job or use background
 	span, spanCtx, err := tracing.StartContextFromSpanStr(context.Background(), "JobScheduler.ProcessJob", job.Trace)
-	defer span.Finish()
 	if err != nil {
-		spanCtx = context.Background()
+		span, spanCtx = opentracing.StartSpanFromContext(context.Background(), "JobScheduler.ProcessJob")
 		log.Warn(domain.E(op, "can't extract span context from job, skip span context", err))
 	}
+	defer span.Finish()
+
+	if job.Category == domain.TimerTaskCategory {
+		return s.processTimerJob(spanCtx, job)
+	}
+
+	// Synchronous job has been started by the former attempt, so only the completion is retried
+	if job.Sync && job.Output != nil {
+		return s.completeSyncJob(spanCtx, job, domain.Body(job.Output))
+	}
 
 	orderId := job.OrderId
 	taskId := job.TaskId
 	mappingId := job.ReadMappingId
 
+	var order domain.Order
+	if err := s.orderService.GetOrderById(spanCtx, orderId, &order); err != nil {
+		return domain.E(op, fmt.Sprintf("can't get order (%s)", orderId), err)
+	}
+
 	// Build start message body
-	body, err := s.buildStartJobBody(spanCtx, orderId, mappingId)
+	body, err := s.getStartJobBody(spanCtx, mappingId, order.Body)
 	if err != nil {
 		return domain.E(op, fmt.Sprintf("can't build start message (%s, %s)", taskId, orderId), err)
 	}
 
 	// Send start message
-	if job.Category == domain.HttpTaskCategory {
-		var startMsg = domain.JobStartMessage{TaskId: job.TaskId, OrderId: job.OrderId, Body: body}
-		if err = s.startJobClient.Start(spanCtx, job.Action, &startMsg); err != nil {
+	if startJobClient, ok := s.startJobClients[job.Category]; ok {
+		var startMsg = domain.JobStartMessage{
+			TaskId:    taskId,
+			OrderId:   orderId,
+			AttemptId: domain.JobAttemptId(taskId, orderId, job.Attempt),
+			Body:      body,
+		}
+		opts, err := buildStartJobOptions(spanCtx, (*domain.HttpTaskConfig)(job.Http), order.Body)
+		if err != nil {
+			return domain.E(op, fmt.Sprintf("can't build start options (%s, %s)", taskId, orderId), err)
+		}
+		output, err := startJobClient.Start(spanCtx, job.Action, &startMsg, opts)
+		if err != nil {
 			return domain.E(op, fmt.Sprintf("can't send start message (%s, %s)", taskId, orderId), err)
 		}
 		log.Tracef("%s: start completed (%s, %s)", op, taskId, orderId)
+
+		// Synchronous task is completed by the response
+		if job.Sync {
+			return s.completeSyncJob(spanCtx, job, output)
+		}
 	} else {
 		log.Tracef("%s: start skipped (%s, %s)", op, taskId, orderId)
 	}
 	return nil
 }
 
-func (s JobScheduler) buildStartJobBody(ctx context.Context, orderId, mappingId string) (domain.Body, error) {
-	const op = "JobScheduler.BuildStartJobMessage"
+// Complete synchronous job by the output of the action. If the completion fails, the output is kept by the job
+// and it's stored by the retry, so the action isn't called again
+func (s JobScheduler) completeSyncJob(ctx context.Context, job *database.Job, output domain.Body) error {
+	const op = "JobScheduler.CompleteSyncJob"
 
-	var order domain.Order
-	if err := s.orderService.GetOrderById(ctx, orderId, &order); err != nil {
-		return nil, domain.E(op, fmt.Sprintf("can't get order (%s)", orderId), err)
+	var completeMsg = domain.JobCompleteMessage{
+		TaskId:    job.TaskId,
+		OrderId:   job.OrderId,
+		AttemptId: domain.JobAttemptId(job.TaskId, job.OrderId, job.Attempt),
+		Body:      output,
 	}
+	if err := s.orderService.CompleteJob(ctx, &completeMsg); err != nil {
+		if output == nil {
+			output = make(domain.Body)
+		}
+		job.Output = database.Body(output)
+		return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", job.TaskId, job.OrderId), err)
+	}
+	log.Tracef("%s: job completed (%s, %s)", op, job.TaskId, job.OrderId)
+	return nil
+}
+
+// Ready timer job is deferred for the duration at first and it's completed when it's started again
+func (s JobScheduler) processTimerJob(ctx context.Context, job *database.Job) error {
+	const op = "JobScheduler.ProcessTimerJob"
+
+	if job.StartAfter == nil {
+		delay, err := time.ParseDuration(job.Action)
+		if err != nil {
+			return domain.E(op, domain.ErrRemotePermanent, fmt.Sprintf("invalid timer duration (%s, %s)",
+				job.TaskId, job.OrderId), err)
+		}
+		return domain.E(op, &domain.DeferredError{Delay: delay})
+	}
+	var completeMsg = domain.JobCompleteMessage{
+		TaskId:    job.TaskId,
+		OrderId:   job.OrderId,
+		AttemptId: domain.JobAttemptId(job.TaskId, job.OrderId, job.Attempt),
+	}
+	if err := s.orderService.CompleteJob(ctx, &completeMsg); err != nil {
+		return domain.E(op, fmt.Sprintf("can't complete job (%s, %s)", job.TaskId, job.OrderId), err)
+	}
+	log.Tracef("%s: timer completed (%s, %s)", op, job.TaskId, job.OrderId)
+	return nil
+}
+
+// Failed job is retried with a delay (or after Retry-After of the response) until the retries are exhausted,
+// permanent remote errors fail the job immediately and deferred jobs are rescheduled without counting the attempt
+func (s JobScheduler) failJob(job *database.Job, cause error) {
+	const op = "JobScheduler.FailJob"
+
+	if deferredErr := domain.EDeferred(cause); deferredErr != nil {
+		log.Tracef("%s: job deferred for %v (%s, %s)", op, deferredErr.Delay, job.TaskId, job.OrderId)
+		err := s.jobRepo.DeferJob(context.Background(), job.TaskId, job.OrderId, job.Attempt,
+			time.Now().Add(deferredErr.Delay))
+		if err != nil {
+			log.Error(domain.E(op, fmt.Sprintf("can't defer job (%s, %s)", job.TaskId, job.OrderId), err))
+		}
+		return
+	}
+
+	failure := database.JobFailure{
+		TaskId:    job.TaskId,
+		OrderId:   job.OrderId,
+		Attempt:   job.Attempt,
+		ErrorCode: string(domain.ECode(cause)),
+		Output:    job.Output,
+	}
+	delay := s.retryDelay
+	if remoteErr := domain.ERemote(cause); remoteErr != nil {
+		failure.Response = remoteErr.Response
+		if remoteErr.RetryAfter > delay {
+			delay = remoteErr.RetryAfter
+		}
+	}
+
+	// Output which doesn't match the schema of the task is permanent as well
+	permanent := domain.ECode(cause) == domain.ErrRemotePermanent || domain.ECode(cause) == domain.ErrValidation
+	err := handleJobFailure(context.Background(), s.jobRepo, &failure, permanent, s.retriesMax, delay)
+	if domain.ECode(err) == domain.ErrStaleAttempt {
+		log.Tracef("%s: job has been restarted or completed (%s, %s)", op, job.TaskId, job.OrderId)
+	} else if err != nil {
+		log.Error(domain.E(op, fmt.Sprintf("can't handle job failure (%s, %s)", job.TaskId, job.OrderId), err))
+	}
+}
+
+// Retry failed attempt after the delay, the job is failed if the failure is permanent or retries are exhausted
+func handleJobFailure(ctx context.Context, jobRepo database.JobRepo, failure *database.JobFailure, permanent bool,
+	retriesMax int, delay time.Duration) error {
+
+	const op = "JobScheduler.HandleJobFailure"
+
+	if permanent || failure.Attempt > retriesMax {
+		log.Tracef("%s: job failed (%s, %s, %d)", op, failure.TaskId, failure.OrderId, failure.Attempt)
+		return jobRepo.FailJob(ctx, failure)
+	}
+	log.Tracef("%s: job retry in %v (%s, %s, %d)", op, delay, failure.TaskId, failure.OrderId, failure.Attempt)
+	return jobRepo.RetryJob(ctx, failure, time.Now().Add(delay))
+}
+
+func (s JobScheduler) getStartJobBody(ctx context.Context, mappingId string, orderBody domain.Body) (domain.Body, error) {
+	const op = "JobScheduler.GetStartJobBody"
+
 	var mapping domain.ReadMapping
 	if err := s.readMappingService.GetById(ctx, mappingId, &mapping); err != nil {
 		return nil, domain.E(op, fmt.Sprintf("can't get read mapping (%s)", mappingId), err)
 	}
+	return buildStartJobBody(ctx, &mapping, orderBody)
+}
+
+func buildStartJobBody(ctx context.Context, mapping *domain.ReadMapping, orderBody domain.Body) (domain.Body, error) {
+	const op = "JobScheduler.BuildStartJobBody"
+
 	var result = make(domain.Body)
 	for key, tasksPath := range mapping.PreparedBody {
-		value, err := tasksPath(ctx, map[string]interface{}(order.Body))
+		value, err := tasksPath(ctx, map[string]interface{}(orderBody))
 		if err != nil {
 			return nil, domain.E(op, fmt.Sprintf("can't evaluate value (%s)", value), err)
 		}
@@ -126,3 +267,33 @@ func (s JobScheduler) buildStartJobBody(ctx context.Context, orderId, mappingId
 	}
 	return result, nil
 }
+
+func buildStartJobOptions(ctx context.Context, cfg *domain.HttpTaskConfig, orderBody domain.Body) (*domain.JobStartOptions, error) {
+	const op = "JobScheduler.BuildStartJobOptions"
+
+	if cfg == nil {
+		return nil, nil
+	}
+	result := domain.JobStartOptions{
+		Method:     cfg.Method,
+		Headers:    make(map[string]string, len(cfg.Headers)),
+		Query:      make(map[string]string, len(cfg.Query)),
+		Timeout:    time.Duration(cfg.TimeoutSec) * time.Second,
+		RetriesMax: cfg.RetriesMax,
+	}
+	for key, value := range cfg.Headers {
+		header, err := evaluateTemplate(ctx, value, orderBody)
+		if err != nil {
+			return nil, domain.E(op, fmt.Sprintf("can't evaluate header (%s)", key), err)
+		}
+		result.Headers[key] = header
+	}
+	for key, value := range cfg.Query {
+		param, err := evaluateTemplate(ctx, value, orderBody)
+		if err != nil {
+			return nil, domain.E(op, fmt.Sprintf("can't evaluate query parameter (%s)", key), err)
+		}
+		result.Query[key] = param
+	}
+	return &result, nil
+}
@@ -3,11 +3,151 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/database"
 	"example.com/oligzeev/pp-gin/internal/domain"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+type testCompleteOrderService struct {
+	domain.OrderService
+	completed []domain.JobCompleteMessage
+}
+
+func (s *testCompleteOrderService) CompleteJob(_ context.Context, msg *domain.JobCompleteMessage) error {
+	s.completed = append(s.completed, *msg)
+	return nil
+}
+
+func TestJobScheduler_TimerJob(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{}
+	orderService := &testCompleteOrderService{}
+	s := NewJobScheduler(domain.SchedulerConfig{}, jobRepo, orderService, nil, nil)
+
+	// Ready timer is deferred for the duration
+	job := database.Job{TaskId: "t1", OrderId: "o1", Category: domain.TimerTaskCategory, Action: "1m", Attempt: 1}
+	err := s.processJob(&job)
+	assert.NotNil(domain.EDeferred(err))
+	s.failJob(&job, err)
+	assert.Len(jobRepo.deferred, 1)
+	assert.WithinDuration(time.Now().Add(time.Minute), jobRepo.deferred[0], time.Second)
+	assert.Empty(orderService.completed)
+
+	// Timer is completed when it's started after the delay
+	job.StartAfter = &jobRepo.deferred[0]
+	assert.Nil(s.processJob(&job))
+	assert.Equal([]domain.JobCompleteMessage{{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1"}},
+		orderService.completed)
+}
+
+// Order service of the jobs, completion fails while there are failures left
+type testJobOrderService struct {
+	testCompleteOrderService
+	failures int
+}
+
+func (s *testJobOrderService) GetOrderById(_ context.Context, id string, result *domain.Order) error {
+	*result = domain.Order{Id: id}
+	return nil
+}
+
+func (s *testJobOrderService) CompleteJob(ctx context.Context, msg *domain.JobCompleteMessage) error {
+	if s.failures > 0 {
+		s.failures--
+		return domain.E("OrderService.CompleteJob", "can't complete job")
+	}
+	return s.testCompleteOrderService.CompleteJob(ctx, msg)
+}
+
+type testReadMappingService struct {
+	domain.ReadMappingService
+}
+
+func (s testReadMappingService) GetById(_ context.Context, id string, result *domain.ReadMapping) error {
+	*result = domain.ReadMapping{Id: id}
+	return nil
+}
+
+func TestJobScheduler_SyncJob(t *testing.T) {
+	assert := assert.New(t)
+
+	jobRepo := &testJobRepo{}
+	orderService := &testJobOrderService{failures: 1}
+	client := &testStartClient{}
+	s := NewJobScheduler(domain.SchedulerConfig{RetriesMax: 3}, jobRepo, orderService, testReadMappingService{},
+		map[int]domain.JobStartClient{domain.HttpTaskCategory: client})
+
+	// Output is kept by the retry if the completion fails
+	job := database.Job{TaskId: "t1", OrderId: "o1", Category: domain.HttpTaskCategory, Sync: true, Attempt: 1}
+	err := s.processJob(&job)
+	assert.NotNil(err)
+	s.failJob(&job, err)
+	assert.Len(client.started, 1)
+	assert.Empty(jobRepo.failed)
+	if assert.Len(jobRepo.retried, 1) {
+		assert.Equal(database.Body{"task": "t1"}, jobRepo.retried[0].Output)
+	}
+
+	// Next attempt completes the job by the stored output without the start
+	job.Attempt = 2
+	assert.Nil(s.processJob(&job))
+	assert.Len(client.started, 1)
+	assert.Equal([]domain.JobCompleteMessage{{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.2",
+		Body: domain.Body{"task": "t1"}}}, orderService.completed)
+}
+
+type testStartClient struct {
+	started []domain.JobStartMessage
+}
+
+func (c *testStartClient) Start(_ context.Context, _ string, msg *domain.JobStartMessage,
+	_ *domain.JobStartOptions) (domain.Body, error) {
+
+	c.started = append(c.started, *msg)
+	return domain.Body{"task": msg.TaskId}, nil
+}
+
+func TestJobScheduler_MemoryFlow(t *testing.T) {
+	assert := assert.New(t)
+
+	services := newTestServices(t)
+	ctx, orderService := services.ctx, services.orderService
+	process := domain.Process{
+		Name: "process",
+		Tasks: []domain.Task{
+			{Id: "t1", Name: "first", Action: "http://first", Sync: true},
+			{Id: "t2", Name: "second", Action: "http://second", Sync: true},
+		},
+		TaskRelations: []domain.TaskRelation{{ParentId: "t1", ChildId: "t2"}},
+	}
+	services.createProcess(t, &process)
+	order := domain.Order{Body: domain.Body{"id": "1"}}
+	assert.Nil(orderService.SubmitOrder(ctx, &order, process.Id))
+
+	client := &testStartClient{}
+	s := NewJobScheduler(domain.SchedulerConfig{JobLimit: 10}, services.jobRepo, orderService,
+		services.readMappingService, map[int]domain.JobStartClient{domain.HttpTaskCategory: client})
+	s.schedule()
+	s.schedule()
+	s.schedule()
+
+	assert.Equal([]domain.JobStartMessage{
+		{TaskId: "t1", OrderId: order.Id, AttemptId: domain.JobAttemptId("t1", order.Id, 1), Body: domain.Body{"id": "1"}},
+		{TaskId: "t2", OrderId: order.Id, AttemptId: domain.JobAttemptId("t2", order.Id, 1), Body: domain.Body{"id": "1"}},
+	}, client.started)
+	var jobs []domain.Job
+	assert.Nil(orderService.GetOrderJobs(ctx, order.Id, &jobs))
+	assert.Len(jobs, 2)
+	for _, job := range jobs {
+		assert.Equal(domain.JobCompleted, job.Status)
+		assert.Equal(domain.Body{"task": job.TaskId}, job.Output)
+	}
+}
+
 const (
 	mappingStr1 = `{
   "id": "3028f11a-46c2-4739-b9c0-fa4024c0f7b3",
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"sync"
+)
+
+// Compiled schemas of the processes (they aren't updated, so the schemas are compiled once per process).
+// Schemas are found by the process id or by the id of its task (task ids are unique across the processes),
+// they're removed along with the process
+type SchemaCache struct {
+	mutex     sync.RWMutex
+	processes map[string]*processSchemas
+	tasks     map[string]*processSchemas
+}
+
+// Order schema and output schemas of the tasks, tasks without the output schema are absent
+type processSchemas struct {
+	order   *gojsonschema.Schema
+	outputs map[string]*gojsonschema.Schema
+	taskIds []string
+}
+
+func NewSchemaCache() *SchemaCache {
+	return &SchemaCache{
+		processes: make(map[string]*processSchemas),
+		tasks:     make(map[string]*processSchemas),
+	}
+}
+
+// Schemas of the process, they're compiled unless they're cached already
+func (c *SchemaCache) process(process *domain.Process) (*processSchemas, error) {
+	const op = "SchemaCache.Process"
+
+	c.mutex.RLock()
+	schemas, ok := c.processes[process.Id]
+	c.mutex.RUnlock()
+	if ok {
+		return schemas, nil
+	}
+
+	order, err := compileSchema(process.OrderSchema)
+	if err != nil {
+		return nil, domain.E(op, "can't compile order schema", err)
+	}
+	schemas = &processSchemas{order: order, outputs: make(map[string]*gojsonschema.Schema)}
+	for _, task := range process.Tasks {
+		schemas.taskIds = append(schemas.taskIds, task.Id)
+		if task.OutputSchema == nil {
+			continue
+		}
+		if schemas.outputs[task.Id], err = compileSchema(task.OutputSchema); err != nil {
+			return nil, domain.E(op, fmt.Sprintf("can't compile output schema (%s)", task.Id), err)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.processes[process.Id] = schemas
+	for _, taskId := range schemas.taskIds {
+		c.tasks[taskId] = schemas
+	}
+	return schemas, nil
+}
+
+// Schemas of the process of the task, false is returned if they aren't cached
+func (c *SchemaCache) task(taskId string) (*processSchemas, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	schemas, ok := c.tasks[taskId]
+	return schemas, ok
+}
+
+func (c *SchemaCache) remove(processId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if schemas, ok := c.processes[processId]; ok {
+		for _, taskId := range schemas.taskIds {
+			delete(c.tasks, taskId)
+		}
+		delete(c.processes, processId)
+	}
+}
+
+// Compile JSON Schema of the order body (task output), nil schema accepts any body
+func compileSchema(schema domain.Body) (*gojsonschema.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+}
+
+// Violations of the schema are returned as domain.SchemaError
+func validateBody(schema *gojsonschema.Schema, body domain.Body) error {
+	if schema == nil {
+		return nil
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(body))
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return nil
+	}
+	violations := make([]string, len(result.Errors()))
+	for i, violation := range result.Errors() {
+		violations[i] = violation.String()
+	}
+	return &domain.SchemaError{Violations: violations}
+}
+
+// Maps of YAML documents have interface{} keys, they're converted to the ones of JSON
+func normalizeSchema(schema domain.Body) domain.Body {
+	if schema == nil {
+		return nil
+	}
+	return normalizeSchemaValue(map[string]interface{}(schema)).(map[string]interface{})
+}
+
+func normalizeSchemaValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[fmt.Sprint(k)] = normalizeSchemaValue(v)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = normalizeSchemaValue(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = normalizeSchemaValue(v)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"strings"
+)
+
+const arrayElements = "[*]"
+
+// Field of the search path along with the number of the nested arrays of its value, e.g. items[*] is the field
+// of the array whose elements are matched
+type searchSegment struct {
+	field  string
+	arrays int
+}
+
+// Parse jsonpath-style path of the body: $ and the fields (.name) which could be followed by [*]
+func parseSearchPath(path string) ([]searchSegment, error) {
+	if !strings.HasPrefix(path, "$.") {
+		return nil, fmt.Errorf("path has to start with $. (%s)", path)
+	}
+	var result []searchSegment
+	for _, part := range strings.Split(path[2:], ".") {
+		segment := searchSegment{field: part}
+		for strings.HasSuffix(segment.field, arrayElements) {
+			segment.field = strings.TrimSuffix(segment.field, arrayElements)
+			segment.arrays++
+		}
+		if segment.field == "" || strings.ContainsAny(segment.field, "[]*$ ") {
+			return nil, fmt.Errorf("invalid field of the path (%s)", path)
+		}
+		result = append(result, segment)
+	}
+	return result, nil
+}
+
+// Document which is contained by the bodies matched by the path and the value, e.g. $.items[*].productId and p1
+// give {"items": [{"productId": "p1"}]}
+func searchDocument(segments []searchSegment, value interface{}) domain.Body {
+	for i := len(segments) - 1; i >= 0; i-- {
+		for j := 0; j < segments[i].arrays; j++ {
+			value = []interface{}{value}
+		}
+		value = map[string]interface{}{segments[i].field: value}
+	}
+	return value.(map[string]interface{})
+}
+
+func validateSearchPaths(paths []string) error {
+	known := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if _, err := parseSearchPath(path); err != nil {
+			return err
+		}
+		if known[path] {
+			return fmt.Errorf("duplicate path (%s)", path)
+		}
+		known[path] = true
+	}
+	return nil
+}
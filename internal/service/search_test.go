@@ -0,0 +1,32 @@
+package service
+
+import (
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSearchDocument(t *testing.T) {
+	tests := map[string]domain.Body{
+		"$.id":                 {"id": "v"},
+		"$.customer.id":        {"customer": map[string]interface{}{"id": "v"}},
+		"$.items[*].productId": {"items": []interface{}{map[string]interface{}{"productId": "v"}}},
+		"$.matrix[*][*]":       {"matrix": []interface{}{[]interface{}{"v"}}},
+		"$.order.tags[*]":      {"order": map[string]interface{}{"tags": []interface{}{"v"}}},
+		"$.a-b.c_d":            {"a-b": map[string]interface{}{"c_d": "v"}},
+	}
+	for path, expected := range tests {
+		segments, err := parseSearchPath(path)
+		if assert.Nil(t, err, path) {
+			assert.Equal(t, expected, searchDocument(segments, "v"), path)
+		}
+	}
+}
+
+func TestParseSearchPath_Invalid(t *testing.T) {
+	for _, path := range []string{"", "$", "$.", "id", "customer.id", "$..id", "$.items[0]", "$.items[*]x",
+		"$.[*]", "$.a b"} {
+		_, err := parseSearchPath(path)
+		assert.NotNil(t, err, path)
+	}
+}
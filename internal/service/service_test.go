@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/database"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"testing"
+)
+
+// Services of the tests on the memory store, the context has the span (it's required by the transactions)
+type testServices struct {
+	ctx                context.Context
+	store              *database.MemStore
+	orderRepo          database.OrderRepo
+	jobRepo            database.JobRepo
+	readMappingService *ReadMappingService
+	processService     *ProcessService
+	orderService       *OrderService
+	schemas            *SchemaCache
+	mapping            domain.ReadMapping
+}
+
+// Services along with the read mapping ({"id": "$.id"}) which is used by the tasks of the test processes
+func newTestServices(t *testing.T) *testServices {
+	span, ctx := opentracing.StartSpanFromContext(context.Background(), "test")
+	t.Cleanup(span.Finish)
+
+	store := database.NewMemStore()
+	schemas := NewSchemaCache()
+	s := &testServices{
+		ctx:                ctx,
+		store:              store,
+		orderRepo:          database.NewMemOrderRepo(store, uuid.NewUUID),
+		jobRepo:            database.NewMemJobRepo(store),
+		readMappingService: NewReadMappingService(database.NewMemReadMappingRepo(store, uuid.NewUUID)),
+		processService:     NewProcessService(database.NewMemProcessRepo(store, uuid.NewUUID), store.ExecTx, schemas),
+		schemas:            schemas,
+	}
+	s.orderService = s.newOrderService(domain.OrderConfig{}, s.orderRepo)
+	s.mapping = domain.ReadMapping{Body: domain.Body{"id": "$.id"}}
+	if err := s.readMappingService.Create(ctx, &s.mapping); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Order service of the config on the order repo (e.g. the one which wraps the repo of the services)
+func (s *testServices) newOrderService(cfg domain.OrderConfig, orderRepo database.OrderRepo) *OrderService {
+	return NewOrderService(cfg, s.processService, orderRepo, s.jobRepo, s.store.ExecTx, s.schemas)
+}
+
+// Create the process, the tasks without the read mapping get the one of the services
+func (s *testServices) createProcess(t *testing.T, process *domain.Process) {
+	for i := range process.Tasks {
+		if process.Tasks[i].ReadMappingId == "" {
+			process.Tasks[i].ReadMappingId = s.mapping.Id
+		}
+	}
+	if err := s.processService.Create(s.ctx, process); err != nil {
+		t.Fatal(err)
+	}
+}
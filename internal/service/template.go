@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"fmt"
+	"github.com/PaesslerAG/gval"
+	"strings"
+	"sync"
+)
+
+const (
+	templateOpen  = "{{"
+	templateClose = "}}"
+)
+
+var (
+	preparedTemplates sync.Map
+)
+
+type templatePart struct {
+	text string
+	path gval.Evaluable
+}
+
+// String value with jsonpath placeholders of the order body, e.g. "Bearer {{$.auth.token}}"
+type valueTemplate []templatePart
+
+func prepareTemplate(value string) (valueTemplate, error) {
+	const op = "Template.Prepare"
+
+	var result valueTemplate
+	for rest := value; rest != ""; {
+		start := strings.Index(rest, templateOpen)
+		if start < 0 {
+			result = append(result, templatePart{text: rest})
+			break
+		}
+		end := strings.Index(rest[start:], templateClose)
+		if end < 0 {
+			return nil, domain.E(op, fmt.Sprintf("unclosed placeholder (%s)", value))
+		}
+		if start > 0 {
+			result = append(result, templatePart{text: rest[:start]})
+		}
+		expr := strings.TrimSpace(rest[start+len(templateOpen) : start+end])
+		path, err := jsonpathLanguage.NewEvaluable(expr)
+		if err != nil {
+			return nil, domain.E(op, fmt.Sprintf("can't create evaluator (%s)", expr), err)
+		}
+		result = append(result, templatePart{path: path})
+		rest = rest[start+end+len(templateClose):]
+	}
+	return result, nil
+}
+
+func (t valueTemplate) evaluate(ctx context.Context, body domain.Body) (string, error) {
+	const op = "Template.Evaluate"
+
+	var b strings.Builder
+	for _, part := range t {
+		if part.path == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		value, err := part.path(ctx, map[string]interface{}(body))
+		if err != nil {
+			return "", domain.E(op, "can't evaluate value", err)
+		}
+		fmt.Fprint(&b, value)
+	}
+	return b.String(), nil
+}
+
+// Evaluate template, prepared templates are cached since there's a limited number of them in the tasks
+func evaluateTemplate(ctx context.Context, value string, body domain.Body) (string, error) {
+	if !strings.Contains(value, templateOpen) {
+		return value, nil
+	}
+	if prepared, ok := preparedTemplates.Load(value); ok {
+		return prepared.(valueTemplate).evaluate(ctx, body)
+	}
+	prepared, err := prepareTemplate(value)
+	if err != nil {
+		return "", err
+	}
+	preparedTemplates.Store(value, prepared)
+	return prepared.evaluate(ctx, body)
+}
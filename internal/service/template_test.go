@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEvaluateTemplate(t *testing.T) {
+	assert := assert.New(t)
+	_, body := unmarshalTD(mappingStr1, bodyStr1)
+
+	result, err := evaluateTemplate(context.Background(), "static", body)
+	assert.Nil(err)
+	assert.Equal("static", result)
+
+	result, err = evaluateTemplate(context.Background(), "{{$.id}}", body)
+	assert.Nil(err)
+	assert.Equal("111", result)
+
+	result, err = evaluateTemplate(context.Background(), "id={{ $.id }}, spec={{$.product.specification.id}};", body)
+	assert.Nil(err)
+	assert.Equal("id=111, spec=333;", result)
+}
+
+func TestEvaluateTemplate_Error(t *testing.T) {
+	assert := assert.New(t)
+	_, body := unmarshalTD(mappingStr1, bodyStr1)
+
+	_, err := evaluateTemplate(context.Background(), "{{$.id", body)
+	assert.NotNil(err)
+
+	_, err = evaluateTemplate(context.Background(), "{{$.unknown}}", body)
+	assert.NotNil(err)
+}
+
+func TestBuildStartJobOptions(t *testing.T) {
+	assert := assert.New(t)
+	_, body := unmarshalTD(mappingStr1, bodyStr1)
+
+	result, err := buildStartJobOptions(context.Background(), &domain.HttpTaskConfig{
+		Method:     "PUT",
+		Headers:    map[string]string{"Authorization": "Bearer {{$.product.id}}"},
+		Query:      map[string]string{"id": "{{$.id}}"},
+		TimeoutSec: 5,
+	}, body)
+	assert.Nil(err)
+	assert.Equal("PUT", result.Method)
+	assert.Equal("Bearer 222", result.Headers["Authorization"])
+	assert.Equal("111", result.Query["id"])
+	assert.Nil(result.RetriesMax)
+}
+
+func TestValidateProcess(t *testing.T) {
+	assert := assert.New(t)
+	retriesMax := -1
+
+	assert.Nil(validateProcess(&domain.Process{Tasks: []domain.Task{
+		{Name: "task1", Http: &domain.HttpTaskConfig{Method: "GET", Headers: map[string]string{"X-Id": "{{$.id}}"}}},
+	}}))
+	for _, cfg := range []domain.HttpTaskConfig{
+		{Method: "CONNECT"},
+		{TimeoutSec: -1},
+		{RetriesMax: &retriesMax},
+		{Headers: map[string]string{"X Id": "1"}},
+		{Query: map[string]string{"id": "{{$.id"}},
+	} {
+		cfg := cfg
+		err := validateProcess(&domain.Process{Tasks: []domain.Task{{Name: "task1", Http: &cfg}}})
+		assert.Equal(domain.ErrValidation, domain.ECode(err))
+	}
+}
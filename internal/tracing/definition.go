@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/opentracing/opentracing-go"
+)
+
+type SpanProcessDefinitionService struct {
+	service domain.ProcessDefinitionService
+}
+
+func NewSpanProcessDefinitionService(service domain.ProcessDefinitionService) *SpanProcessDefinitionService {
+	return &SpanProcessDefinitionService{service: service}
+}
+
+func (s SpanProcessDefinitionService) Import(ctx context.Context, def *domain.ProcessDefinition,
+	result *domain.Process) error {
+
+	const op = "ProcessDefinitionService.Import"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.Import(spanCtx, def, result)
+}
+
+func (s SpanProcessDefinitionService) Export(ctx context.Context, processId string,
+	result *domain.ProcessDefinition) error {
+
+	const op = "ProcessDefinitionService.Export"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.Export(spanCtx, processId, result)
+}
+
+func (s SpanProcessDefinitionService) ImportBpmn(ctx context.Context, data []byte, result *domain.Process) error {
+	const op = "ProcessDefinitionService.ImportBpmn"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.ImportBpmn(spanCtx, data, result)
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"github.com/opentracing/opentracing-go"
+)
+
+type SpanExternalJobService struct {
+	service domain.ExternalJobService
+}
+
+func NewSpanExternalJobService(service domain.ExternalJobService) *SpanExternalJobService {
+	return &SpanExternalJobService{service: service}
+}
+
+func (s SpanExternalJobService) FetchAndLock(ctx context.Context, req *domain.JobFetchRequest,
+	result *[]domain.LockedJob) error {
+
+	const op = "ExternalJobService.FetchAndLock"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.FetchAndLock(spanCtx, req, result)
+}
+
+func (s SpanExternalJobService) Complete(ctx context.Context, lockId string, msg *domain.JobLockCompleteMessage) error {
+	const op = "ExternalJobService.Complete"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.Complete(spanCtx, lockId, msg)
+}
+
+func (s SpanExternalJobService) Fail(ctx context.Context, lockId string, msg *domain.JobLockFailMessage) error {
+	const op = "ExternalJobService.Fail"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.Fail(spanCtx, lockId, msg)
+}
+
+func (s SpanExternalJobService) ExtendLock(ctx context.Context, lockId string, msg *domain.JobLockExtendMessage) error {
+	const op = "ExternalJobService.ExtendLock"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.ExtendLock(spanCtx, lockId, msg)
+}
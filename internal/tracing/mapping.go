@@ -21,6 +21,15 @@ func (s SpanReadMappingService) GetAll(ctx context.Context, result *[]domain.Rea
 	return s.service.GetAll(spanCtx, result)
 }
 
+func (s SpanReadMappingService) GetPage(ctx context.Context, query *domain.PageQuery,
+	result *[]domain.ReadMapping, page *domain.Page) error {
+
+	const op = "ReadMappingService.GetPage"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.GetPage(spanCtx, query, result, page)
+}
+
 func (s SpanReadMappingService) Create(ctx context.Context, result *domain.ReadMapping) error {
 	const op = "ReadMappingService.Create"
 	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
@@ -21,6 +21,15 @@ func (s SpanOrderService) SubmitOrder(ctx context.Context, order *domain.Order,
 	return s.service.SubmitOrder(spanCtx, order, processId)
 }
 
+func (s SpanOrderService) SubmitOrders(ctx context.Context, orders domain.OrderReader, processId string,
+	atomic bool, result *domain.BulkOrderResult) error {
+
+	const op = "OrderService.SubmitOrders"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.SubmitOrders(spanCtx, orders, processId, atomic, result)
+}
+
 func (s SpanOrderService) GetOrders(ctx context.Context, result *[]domain.Order) error {
 	const op = "OrderService.GetOrders"
 	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
@@ -28,6 +37,31 @@ func (s SpanOrderService) GetOrders(ctx context.Context, result *[]domain.Order)
 	return s.service.GetOrders(spanCtx, result)
 }
 
+func (s SpanOrderService) GetOrdersPage(ctx context.Context, query *domain.OrderQuery, result *[]domain.Order,
+	page *domain.Page) error {
+
+	const op = "OrderService.GetOrdersPage"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.GetOrdersPage(spanCtx, query, result, page)
+}
+
+func (s SpanOrderService) CountOrdersByProcess(ctx context.Context, result *map[string]int) error {
+	const op = "OrderService.CountOrdersByProcess"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.CountOrdersByProcess(spanCtx, result)
+}
+
+func (s SpanOrderService) SearchOrders(ctx context.Context, query *domain.OrderQuery, search *domain.OrderSearch,
+	result *[]domain.Order, page *domain.Page) error {
+
+	const op = "OrderService.SearchOrders"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.SearchOrders(spanCtx, query, search, result, page)
+}
+
 func (s SpanOrderService) GetOrderById(ctx context.Context, id string, result *domain.Order) error {
 	const op = "OrderService.GetOrderById"
 	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
@@ -35,9 +69,30 @@ func (s SpanOrderService) GetOrderById(ctx context.Context, id string, result *d
 	return s.service.GetOrderById(spanCtx, id, result)
 }
 
-func (s SpanOrderService) CompleteJob(ctx context.Context, taskId, orderId string) error {
+func (s SpanOrderService) GetOrderJobs(ctx context.Context, orderId string, result *[]domain.Job) error {
+	const op = "OrderService.GetOrderJobs"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.GetOrderJobs(spanCtx, orderId, result)
+}
+
+func (s SpanOrderService) CompleteJob(ctx context.Context, msg *domain.JobCompleteMessage) error {
 	const op = "OrderService.CompleteJob"
 	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
 	defer span.Finish()
-	return s.service.CompleteJob(spanCtx, taskId, orderId)
+	return s.service.CompleteJob(spanCtx, msg)
+}
+
+func (s SpanOrderService) RetryJob(ctx context.Context, ref *domain.JobRef) error {
+	const op = "OrderService.RetryJob"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.RetryJob(spanCtx, ref)
+}
+
+func (s SpanOrderService) CancelJob(ctx context.Context, ref *domain.JobRef) error {
+	const op = "OrderService.CancelJob"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.CancelJob(spanCtx, ref)
 }
@@ -21,6 +21,15 @@ func (s SpanProcessService) GetAll(ctx context.Context, result *[]domain.Process
 	return s.service.GetAll(spanCtx, result)
 }
 
+func (s SpanProcessService) GetPage(ctx context.Context, query *domain.PageQuery, result *[]domain.Process,
+	page *domain.Page) error {
+
+	const op = "ProcessService.GetPage"
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
+	defer span.Finish()
+	return s.service.GetPage(spanCtx, query, result, page)
+}
+
 func (s SpanProcessService) Create(ctx context.Context, obj *domain.Process) error {
 	const op = "ProcessService.Create"
 	span, spanCtx := opentracing.StartSpanFromContext(ctx, op)
@@ -0,0 +1,15 @@
+// Package api contains the types of pp-gin REST API (requests, responses and errors) along with the retryable
+// http client of the API. It's shared by the server and pkg/client, so it doesn't depend on the internal packages.
+package api
+
+const (
+	HeaderContentType            = "Content-Type"
+	HeaderIdempotencyKey         = "Idempotency-Key"
+	HeaderTotalCount             = "X-Total-Count"
+	HeaderNextCursor             = "X-Next-Cursor"
+	ContentTypeApplicationJson   = "application/json"
+	ContentTypeApplicationYaml   = "application/x-yaml"
+	ContentTypeApplicationNdjson = "application/x-ndjson"
+)
+
+type Body map[string]interface{}
@@ -0,0 +1,17 @@
+package api
+
+import "time"
+
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// Circuit breaker of the task destination (host)
+type Breaker struct {
+	Host     string     `json:"host"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
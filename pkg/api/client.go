@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const headerRetryAfter = "Retry-After"
+
+// Retryable http client which also retries 429 responses and honors Retry-After header.
+// Retries are expired with the last response instead of an error, so it could be checked by the caller
+func NewClient(retriesMax int, timeout time.Duration) *retryablehttp.Client {
+	client := retryablehttp.NewClient()
+	client.RetryMax = retriesMax
+	client.HTTPClient.Timeout = timeout
+	client.CheckRetry = retryPolicy(client.RetryWaitMax)
+	client.Backoff = retryAfterBackoff
+	client.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	return client
+}
+
+// Retry-After which is longer than maximum wait time isn't waited by the client, it's up to the caller
+func retryPolicy(retryWaitMax time.Duration) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if err == nil && resp != nil {
+			if retryAfter, ok := ParseRetryAfter(resp); ok && retryAfter > retryWaitMax {
+				return false, nil
+			}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return true, nil
+			}
+		}
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+}
+
+func retryAfterBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if retryAfter, ok := ParseRetryAfter(resp); ok && retryAfter <= max {
+		return retryAfter
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+}
+
+// Retry-After is either delay in seconds or http date
+func ParseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get(headerRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Send json request (nil body is sent without the content type), opentracing.GlobalTracer() have to be initialized
+func Send(ctx context.Context, client *retryablehttp.Client, url, method string, header http.Header,
+	msgBytes []byte) (*http.Response, error) {
+
+	span, spanCtx := opentracing.StartSpanFromContext(ctx, method+" "+url)
+	defer span.Finish()
+
+	var body interface{}
+	if msgBytes != nil {
+		body = bytes.NewReader(msgBytes)
+	}
+	request, err := retryablehttp.NewRequest(method, url, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't create http request (%s %s)", method, url)
+	}
+	request.WithContext(spanCtx)
+
+	tracer := opentracing.GlobalTracer()
+	err = tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(request.Header))
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't propagate tracing context (%s %s)", method, url)
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	if msgBytes != nil {
+		request.Header.Set(HeaderContentType, ContentTypeApplicationJson)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't send http request (%s %s)", method, url)
+	}
+	return response, nil
+}
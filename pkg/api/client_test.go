@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	response := &http.Response{Header: http.Header{}}
+	_, ok := ParseRetryAfter(response)
+	assert.False(ok)
+
+	response.Header.Set(headerRetryAfter, "120")
+	delay, ok := ParseRetryAfter(response)
+	assert.True(ok)
+	assert.Equal(120*time.Second, delay)
+
+	response.Header.Set(headerRetryAfter, "soon")
+	_, ok = ParseRetryAfter(response)
+	assert.False(ok)
+}
+
+func TestParseRetryAfter_Date(t *testing.T) {
+	assert := assert.New(t)
+
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Set(headerRetryAfter, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	delay, ok := ParseRetryAfter(response)
+	assert.True(ok)
+	assert.True(delay > 59*time.Minute && delay <= time.Hour)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+const (
+	ErrPrefix          ErrCode = "APP-"
+	ErrInternal                = ErrPrefix + "0001"
+	ErrNotFound                = ErrPrefix + "0002"
+	ErrStaleAttempt            = ErrPrefix + "0003"
+	ErrRemoteRetryable         = ErrPrefix + "0004"
+	ErrRemotePermanent         = ErrPrefix + "0005"
+	ErrValidation              = ErrPrefix + "0006"
+	ErrDeferred                = ErrPrefix + "0007"
+	ErrCancelled               = ErrPrefix + "0008"
+	ErrConflict                = ErrPrefix + "0009"
+)
+
+type ErrCode string
+type ErrOp string
+type Error struct {
+	Code ErrCode `json:"code"`
+	Op   ErrOp   `json:"op"`
+	Msg  string  `json:"msg"`
+	Err  error   `json:"err"`
+}
+
+func (e *Error) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString(string(e.Op))
+	if e.Code != "" {
+		buf.WriteString("|")
+		buf.WriteString(string(e.Code))
+	}
+	if e.Msg != "" {
+		buf.WriteString("|")
+		buf.WriteString(e.Msg)
+	}
+	if e.Err != nil {
+		buf.WriteString(", ")
+		buf.WriteString(e.Err.Error())
+	}
+	return buf.String()
+}
+
+// Failed response of a remote endpoint (e.g. task action)
+type RemoteError struct {
+	Status     int
+	RetryAfter time.Duration
+	Response   string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("remote status %d: %s", e.Status, e.Response)
+}
+
+// Body of the failed response. Violations are the ones of JSON Schema (e.g. of the order body), items are the ones
+// of the bulk submission handled before the error
+type ErrorResponse struct {
+	Code       ErrCode         `json:"code"`
+	Ops        []ErrOp         `json:"ops"`
+	Messages   []string        `json:"messages"`
+	Violations []string        `json:"violations,omitempty"`
+	Items      []BulkOrderItem `json:"items,omitempty"`
+}
+
+func E(op ErrOp, args ...interface{}) error {
+	e := &Error{Op: op}
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case error:
+			e.Err = arg
+		case ErrCode:
+			e.Code = arg
+		case string:
+			e.Msg = arg
+		}
+	}
+	return e
+}
+
+func ECode(err error) ErrCode {
+	if err == nil {
+		return ""
+	} else if e, ok := err.(*Error); ok && e.Code != "" {
+		return e.Code
+	} else if ok && e.Err != nil {
+		return ECode(e.Err)
+	}
+	return ErrInternal
+}
+
+func EOps(err error) []ErrOp {
+	if e, ok := err.(*Error); ok {
+		result := []ErrOp{e.Op}
+		nextOps := EOps(e.Err)
+		if nextOps != nil {
+			return append(result, nextOps...)
+		}
+		return result
+	}
+	return nil
+}
+
+func EMsgs(err error) []string {
+	if e, ok := err.(*Error); ok {
+		var result []string
+		msg := e.Msg
+		if msg != "" {
+			result = append(result, msg)
+		}
+		if e.Err != nil {
+			next := EMsgs(e.Err)
+			if next != nil {
+				result = append(result, next...)
+			}
+		}
+		return result
+	}
+	return []string{err.Error()}
+}
+
+func ERemote(err error) *RemoteError {
+	switch e := err.(type) {
+	case *RemoteError:
+		return e
+	case *Error:
+		return ERemote(e.Err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import "time"
+
+const (
+	HttpTaskCategory     int = iota
+	NatsTaskCategory         // Action is the subject of start messages
+	ExternalTaskCategory     // Action is the topic, jobs are fetched and locked by external workers
+	TimerTaskCategory        // Action is the duration (e.g. 1h30m), job is completed after the delay
+)
+
+const (
+	JobWaiting   = "waiting"
+	JobReady     = "ready"
+	JobStarted   = "started"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job of the order's task
+type Job struct {
+	TaskId      string     `json:"taskId"`
+	OrderId     string     `json:"orderId"`
+	Status      string     `json:"status"`
+	Attempt     int        `json:"attempt"`
+	StartAfter  *time.Time `json:"startAfter,omitempty"`
+	ErrorCode   ErrCode    `json:"errorCode,omitempty"`
+	Response    string     `json:"response,omitempty"` // Truncated response of the last failed attempt
+	Output      Body       `json:"output,omitempty"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"` // Lock of the external job
+}
+
+type JobStartMessage struct {
+	TaskId    string `json:"taskId"`
+	OrderId   string `json:"orderId"`
+	AttemptId string `json:"attemptId"`
+	Body      Body   `json:"body"`
+}
+
+type JobCompleteMessage struct {
+	TaskId    string `json:"taskId"`
+	OrderId   string `json:"orderId"`
+	AttemptId string `json:"attemptId"`
+	Body      Body   `json:"body"`
+}
+
+// Reference to the job of the order's task
+type JobRef struct {
+	TaskId  string `json:"taskId"`
+	OrderId string `json:"orderId"`
+}
+
+// Request of the external worker to fetch and lock ready jobs of the topics
+type JobFetchRequest struct {
+	Topics          []string `json:"topics"`
+	MaxJobs         int      `json:"maxJobs"`
+	LockDurationSec int      `json:"lockDurationSec"`
+}
+
+// Job locked by the external worker, lock id has to be used to complete, fail or extend the lock
+type LockedJob struct {
+	LockId      string    `json:"lockId"`
+	TaskId      string    `json:"taskId"`
+	OrderId     string    `json:"orderId"`
+	Topic       string    `json:"topic"`
+	Body        Body      `json:"body"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	Trace       string    `json:"trace,omitempty"` // Span context of the order submission
+}
+
+type JobLockCompleteMessage struct {
+	Body Body `json:"body"`
+}
+
+type JobLockFailMessage struct {
+	Error         string `json:"error"`
+	Permanent     bool   `json:"permanent"`     // Job won't be retried
+	RetryDelaySec int    `json:"retryDelaySec"` // Used if it's greater than scheduler.retryDelaySec
+}
+
+type JobLockExtendMessage struct {
+	LockDurationSec int `json:"lockDurationSec"`
+}
@@ -0,0 +1,26 @@
+package api
+
+import "time"
+
+// Resubmission of the order with the same idempotency key (unique per process) returns the original order
+type Order struct {
+	Id             string     `json:"id"`
+	ProcessId      string     `json:"processId"`
+	Body           Body       `json:"body"`
+	IdempotencyKey string     `json:"idempotencyKey,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+}
+
+// Result of the bulk submission, items are in the order of the submitted orders
+type BulkOrderResult struct {
+	Submitted int             `json:"submitted"`
+	Failed    int             `json:"failed"`
+	Items     []BulkOrderItem `json:"items"`
+}
+
+// Id of the submitted order or the error of the failed one
+type BulkOrderItem struct {
+	Id       string   `json:"id,omitempty"`
+	Code     ErrCode  `json:"code,omitempty"`
+	Messages []string `json:"messages,omitempty"`
+}
@@ -0,0 +1,53 @@
+package api
+
+import "time"
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// Page of the list, the rows after the cursor are sorted by the field ("-" prefix is descending) and the id.
+// Default limit is used if it isn't positive, the limit is capped by MaxPageLimit
+type PageQuery struct {
+	Limit  int
+	Cursor string
+	Sort   string
+}
+
+// Total is the count of the rows matched by the filters, next cursor is empty on the last page
+type Page struct {
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Statuses of the orders by their jobs: running orders have jobs neither completed nor failed,
+// failed ones have failed (or cancelled) jobs
+const (
+	OrderRunning   = "running"
+	OrderCompleted = "completed"
+	OrderFailed    = "failed"
+)
+
+// Filters of the orders, created time range excludes its end, body fields (top-level ones) are matched
+// by the text of the value. Orders are sorted by createdAt (by default) or id
+type OrderQuery struct {
+	PageQuery
+	ProcessId   string
+	Status      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Body        map[string]string
+}
+
+// Predicate of the order search: path of the body (one of the search paths of the process) and its value (required).
+// Path is jsonpath-style, i.e. the fields ($.customer.id) and the elements of the arrays ($.items[*].productId)
+type SearchPredicate struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// Orders match all the predicates
+type OrderSearch struct {
+	Predicates []SearchPredicate `json:"predicates"`
+}
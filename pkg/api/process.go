@@ -0,0 +1,74 @@
+package api
+
+// Process isn't updated after the creation, e.g. its search paths are set only by the creation (or the import).
+// Definition of the process is imported again (as the new process) to change them, orders of the old process keep
+// its search paths
+type Process struct {
+	Id            string         `json:"id"`
+	Name          string         `json:"name"`
+	Tasks         []Task         `json:"tasks"`
+	TaskRelations []TaskRelation `json:"taskRelations"`
+	OrderSchema   Body           `json:"orderSchema,omitempty"` // JSON Schema of the order body
+	SearchPaths   []string       `json:"searchPaths,omitempty"` // Paths of the order body which are searchable
+}
+
+type Task struct {
+	Id            string          `json:"id"`
+	Name          string          `json:"name"`
+	Category      int             `json:"category"` // TBD Return string value instead of integer
+	Action        string          `json:"action"`
+	ReadMappingId string          `json:"readMappingId"`
+	Sync          bool            `json:"sync"` // Job is completed by the response of the action
+	Http          *HttpTaskConfig `json:"http,omitempty"`
+	OutputSchema  Body            `json:"outputSchema,omitempty"` // JSON Schema of the body of the completed job
+}
+
+// Http request of the task, values of headers and query parameters could contain
+// jsonpath templates of the order body, e.g. "Bearer {{$.auth.token}}". GET, HEAD and DELETE requests
+// have no body, the job is identified by Idempotency-Key header (attempt id)
+type HttpTaskConfig struct {
+	Method     string            `json:"method,omitempty" yaml:"method,omitempty"` // POST by default
+	Headers    map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Query      map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+	TimeoutSec int               `json:"timeoutSec,omitempty" yaml:"timeoutSec,omitempty"` // Overrides rest.client.timeoutSec
+	RetriesMax *int              `json:"retriesMax,omitempty" yaml:"retriesMax,omitempty"` // Overrides rest.client.retriesMax
+}
+
+type TaskRelation struct {
+	ParentId string `json:"parentId"`
+	ChildId  string `json:"childId"`
+}
+
+// Declarative process, tasks refer to each other and to the read mappings by names
+type ProcessDefinition struct {
+	Name     string           `json:"name" yaml:"name"`
+	Mappings map[string]Body  `json:"mappings,omitempty" yaml:"mappings,omitempty"` // Read mappings shared by tasks
+	Tasks    []TaskDefinition `json:"tasks" yaml:"tasks"`
+
+	// JSON Schema of the order body
+	OrderSchema Body `json:"orderSchema,omitempty" yaml:"orderSchema,omitempty"`
+
+	// Paths of the order body which are searchable, e.g. $.customer.id or $.items[*].productId. They can't be
+	// changed after the import, the definition is imported again as the new process to change them
+	SearchPaths []string `json:"searchPaths,omitempty" yaml:"searchPaths,omitempty"`
+}
+
+type TaskDefinition struct {
+	Name        string          `json:"name" yaml:"name"`
+	Category    string          `json:"category,omitempty" yaml:"category,omitempty"` // http (by default), nats, external or timer
+	Action      string          `json:"action" yaml:"action"`
+	Sync        bool            `json:"sync,omitempty" yaml:"sync,omitempty"`
+	Http        *HttpTaskConfig `json:"http,omitempty" yaml:"http,omitempty"`
+	Mapping     string          `json:"mapping,omitempty" yaml:"mapping,omitempty"`         // Name of the shared read mapping
+	MappingBody Body            `json:"mappingBody,omitempty" yaml:"mappingBody,omitempty"` // Inlined read mapping
+	DependsOn   []string        `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`     // Names of the parent tasks
+
+	// JSON Schema of the body of the completed job
+	OutputSchema Body `json:"outputSchema,omitempty" yaml:"outputSchema,omitempty"`
+}
+
+// Read mapping of the task, values of the body are jsonpath expressions of the order body
+type ReadMapping struct {
+	Id   string `json:"id"`
+	Body Body   `json:"body"`
+}
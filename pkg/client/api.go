@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// *** Read mappings ***
+
+// Get all read mappings, the pages are followed till the last one
+func (c Client) GetReadMappings(ctx context.Context, result *[]ReadMapping) error {
+	return getAll(func(query *PageQuery, page *Page) error {
+		var items []ReadMapping
+		if err := c.GetReadMappingsPage(ctx, query, &items, page); err != nil {
+			return err
+		}
+		*result = append(*result, items...)
+		return nil
+	})
+}
+
+func (c Client) GetReadMappingsPage(ctx context.Context, query *PageQuery, result *[]ReadMapping, page *Page) error {
+	return c.getPage(ctx, "Client.GetReadMappingsPage", http.MethodGet, "/mapping/", pageValues(query), nil,
+		result, page)
+}
+
+func (c Client) GetReadMappingById(ctx context.Context, id string, result *ReadMapping) error {
+	return c.do(ctx, "Client.GetReadMappingById", http.MethodGet, "/mapping/"+escape(id), nil, nil, result)
+}
+
+// Create read mapping, generated id is propagated into the given object
+func (c Client) CreateReadMapping(ctx context.Context, obj *ReadMapping) error {
+	return c.do(ctx, "Client.CreateReadMapping", http.MethodPost, "/mapping/", nil, obj, obj)
+}
+
+func (c Client) DeleteReadMappingById(ctx context.Context, id string) error {
+	return c.do(ctx, "Client.DeleteReadMappingById", http.MethodDelete, "/mapping/"+escape(id), nil, nil, nil)
+}
+
+// *** Processes ***
+
+// Get all processes, the pages are followed till the last one
+func (c Client) GetProcesses(ctx context.Context, result *[]Process) error {
+	return getAll(func(query *PageQuery, page *Page) error {
+		var items []Process
+		if err := c.GetProcessesPage(ctx, query, &items, page); err != nil {
+			return err
+		}
+		*result = append(*result, items...)
+		return nil
+	})
+}
+
+func (c Client) GetProcessesPage(ctx context.Context, query *PageQuery, result *[]Process, page *Page) error {
+	return c.getPage(ctx, "Client.GetProcessesPage", http.MethodGet, "/process/", pageValues(query), nil, result,
+		page)
+}
+
+func (c Client) GetProcessById(ctx context.Context, id string, result *Process) error {
+	return c.do(ctx, "Client.GetProcessById", http.MethodGet, "/process/"+escape(id), nil, nil, result)
+}
+
+// Create process, generated ids are propagated into the given object
+func (c Client) CreateProcess(ctx context.Context, obj *Process) error {
+	return c.do(ctx, "Client.CreateProcess", http.MethodPost, "/process/", nil, obj, obj)
+}
+
+func (c Client) DeleteProcessById(ctx context.Context, id string) error {
+	return c.do(ctx, "Client.DeleteProcessById", http.MethodDelete, "/process/"+escape(id), nil, nil, nil)
+}
+
+// Create process and its read mappings from the definition, created process is propagated into the result
+func (c Client) ImportProcess(ctx context.Context, def *ProcessDefinition, result *Process) error {
+	return c.do(ctx, "Client.ImportProcess", http.MethodPost, "/process/import", nil, def, result)
+}
+
+// Create process from BPMN 2.0 document, created process is propagated into the result
+func (c Client) ImportBpmn(ctx context.Context, data []byte, result *Process) error {
+	return c.do(ctx, "Client.ImportBpmn", http.MethodPost, "/process/bpmn", nil, data, result)
+}
+
+// Render process in the format (dot, mermaid or svg)
+func (c Client) GetProcessGraph(ctx context.Context, id, format string, result *[]byte) error {
+	return c.do(ctx, "Client.GetProcessGraph", http.MethodGet, "/process/"+escape(id)+"/graph?format="+
+		url.QueryEscape(format), nil, nil, result)
+}
+
+func (c Client) ExportProcess(ctx context.Context, id string, result *ProcessDefinition) error {
+	return c.do(ctx, "Client.ExportProcess", http.MethodGet, "/process/"+escape(id)+"/definition", nil, nil,
+		result)
+}
+
+// *** Orders ***
+
+// Get all orders, the pages are followed till the last one
+func (c Client) GetOrders(ctx context.Context, result *[]Order) error {
+	return getAll(func(query *PageQuery, page *Page) error {
+		var items []Order
+		if err := c.GetOrdersPage(ctx, &OrderQuery{PageQuery: *query}, &items, page); err != nil {
+			return err
+		}
+		*result = append(*result, items...)
+		return nil
+	})
+}
+
+// Get page of the orders filtered by the query, the next page is requested with the cursor of the given one
+func (c Client) GetOrdersPage(ctx context.Context, query *OrderQuery, result *[]Order, page *Page) error {
+	values := orderValues(query)
+	setValue(values, "process_id", query.ProcessId)
+	return c.getPage(ctx, "Client.GetOrdersPage", http.MethodGet, "/order/", values, nil, result, page)
+}
+
+// Search all orders of the process which match the predicates, the pages are followed till the last one
+func (c Client) SearchOrders(ctx context.Context, processId string, search *OrderSearch, result *[]Order) error {
+	return getAll(func(pageQuery *PageQuery, page *Page) error {
+		var items []Order
+		query := OrderQuery{PageQuery: *pageQuery, ProcessId: processId}
+		if err := c.SearchOrdersPage(ctx, &query, search, &items, page); err != nil {
+			return err
+		}
+		*result = append(*result, items...)
+		return nil
+	})
+}
+
+// Search page of the orders of the process (process id of the query is required) which match the predicates,
+// paths of the predicates have to be the search paths of the process
+func (c Client) SearchOrdersPage(ctx context.Context, query *OrderQuery, search *OrderSearch, result *[]Order,
+	page *Page) error {
+
+	return c.getPage(ctx, "Client.SearchOrdersPage", http.MethodPost, "/order/"+escape(query.ProcessId)+"/search",
+		orderValues(query), search, result, page)
+}
+
+// Query parameters of the order filters except the process id
+func orderValues(query *OrderQuery) url.Values {
+	values := pageValues(&query.PageQuery)
+	setValue(values, "status", query.Status)
+	if query.CreatedFrom != nil {
+		values.Set("created_from", query.CreatedFrom.Format(time.RFC3339Nano))
+	}
+	if query.CreatedTo != nil {
+		values.Set("created_to", query.CreatedTo.Format(time.RFC3339Nano))
+	}
+	for key, value := range query.Body {
+		values.Set("body."+key, value)
+	}
+	return values
+}
+
+func (c Client) GetOrderById(ctx context.Context, id string, result *Order) error {
+	return c.do(ctx, "Client.GetOrderById", http.MethodGet, "/order/"+escape(id), nil, nil, result)
+}
+
+func (c Client) GetOrderJobs(ctx context.Context, orderId string, result *[]Job) error {
+	return c.do(ctx, "Client.GetOrderJobs", http.MethodGet, "/order/"+escape(orderId)+"/jobs", nil, nil, result)
+}
+
+// Render process of the order in the format (dot, mermaid or svg), tasks are colored by the statuses of the jobs
+func (c Client) GetOrderGraph(ctx context.Context, id, format string, result *[]byte) error {
+	return c.do(ctx, "Client.GetOrderGraph", http.MethodGet, "/order/"+escape(id)+"/graph?format="+
+		url.QueryEscape(format), nil, nil, result)
+}
+
+// Submit order of the process, generated id is propagated into the given object. Order with the idempotency key
+// is safe to resubmit, the original order is returned
+func (c Client) SubmitOrder(ctx context.Context, order *Order, processId string) error {
+	return c.do(ctx, "Client.SubmitOrder", http.MethodPost, "/order/"+escape(processId), nil, order, order)
+}
+
+// Submit orders of the process, the result contains id or error of every order. Atomic submission creates
+// either all orders or none of them, error of the failed order is returned in the latter case. Result contains
+// the items of the orders handled before the error as well
+func (c Client) SubmitOrders(ctx context.Context, orders []Order, processId string, atomic bool,
+	result *BulkOrderResult) error {
+
+	path := "/order/" + escape(processId) + "/bulk"
+	if atomic {
+		path += "?atomic=true"
+	}
+	return c.do(ctx, "Client.SubmitOrders", http.MethodPost, path, nil, orders, result)
+}
+
+// *** Jobs ***
+
+func (c Client) CompleteJob(ctx context.Context, msg *JobCompleteMessage) error {
+	header := http.Header{}
+	if msg.AttemptId != "" {
+		header.Set(HeaderIdempotencyKey, msg.AttemptId)
+	}
+	return c.do(ctx, "Client.CompleteJob", http.MethodPost, "/job/complete", header, msg, nil)
+}
+
+func (c Client) RetryJob(ctx context.Context, ref *JobRef) error {
+	return c.do(ctx, "Client.RetryJob", http.MethodPost, "/job/retry", nil, ref, nil)
+}
+
+func (c Client) CancelJob(ctx context.Context, ref *JobRef) error {
+	return c.do(ctx, "Client.CancelJob", http.MethodPost, "/job/cancel", nil, ref, nil)
+}
+
+func (c Client) FetchJobs(ctx context.Context, req *JobFetchRequest, result *[]LockedJob) error {
+	return c.do(ctx, "Client.FetchJobs", http.MethodPost, "/job/fetch", nil, req, result)
+}
+
+func (c Client) CompleteLockedJob(ctx context.Context, lockId string, msg *JobLockCompleteMessage) error {
+	return c.do(ctx, "Client.CompleteLockedJob", http.MethodPost, "/job/lock/"+escape(lockId)+"/complete",
+		nil, msg, nil)
+}
+
+func (c Client) FailLockedJob(ctx context.Context, lockId string, msg *JobLockFailMessage) error {
+	return c.do(ctx, "Client.FailLockedJob", http.MethodPost, "/job/lock/"+escape(lockId)+"/fail", nil, msg, nil)
+}
+
+func (c Client) ExtendJobLock(ctx context.Context, lockId string, msg *JobLockExtendMessage) error {
+	return c.do(ctx, "Client.ExtendJobLock", http.MethodPost, "/job/lock/"+escape(lockId)+"/extend", nil, msg, nil)
+}
+
+// *** Administration ***
+
+func (c Client) GetBreakers(ctx context.Context, result *[]Breaker) error {
+	return c.do(ctx, "Client.GetBreakers", http.MethodGet, "/admin/breaker/", nil, nil, result)
+}
+
+func (c Client) ResetBreaker(ctx context.Context, host string) error {
+	return c.do(ctx, "Client.ResetBreaker", http.MethodPost, "/admin/breaker/"+escape(host)+"/reset", nil, nil, nil)
+}
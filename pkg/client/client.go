@@ -0,0 +1,192 @@
+// Package client is a client of pp-gin REST API. Requests are retried (connection errors, 429 and 5xx)
+// and propagate tracing context of the given context. Failed responses are returned as errors with
+// the code of the server error (see ECode).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"fmt"
+	"github.com/hashicorp/go-retryablehttp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the client (and of the API it's compatible with)
+const Version = "1.0.0"
+
+const (
+	headerUserAgent   = "User-Agent"
+	maxResponseLength = 1024
+
+	// Error of the bulk submission contains the items of all the orders
+	maxErrorLength = 16 << 20
+)
+
+type Config struct {
+	Url        string // Base url of pp-gin, e.g. http://localhost:8080
+	RetriesMax int
+	Timeout    time.Duration
+}
+
+type Client struct {
+	url    string
+	client *retryablehttp.Client
+}
+
+func New(cfg Config) *Client {
+	client := api.NewClient(cfg.RetriesMax, cfg.Timeout)
+	client.Logger = nil // Failures are returned to the caller
+	return &Client{url: strings.TrimSuffix(cfg.Url, "/"), client: client}
+}
+
+// Send request (nil request means empty body, []byte is sent as-is) and decode response into the result
+// (if it's not nil, *[]byte gets the response as-is)
+func (c Client) do(ctx context.Context, op api.ErrOp, method, path string, header http.Header,
+	request, result interface{}) error {
+
+	_, err := c.send(ctx, op, method, path, header, request, result)
+	return err
+}
+
+// Same as do, but the headers of the response are returned as well
+func (c Client) send(ctx context.Context, op api.ErrOp, method, path string, header http.Header,
+	request, result interface{}) (http.Header, error) {
+
+	msgBytes, raw := request.([]byte)
+	if request != nil && !raw {
+		var err error
+		if msgBytes, err = json.Marshal(request); err != nil {
+			return nil, api.E(op, "can't marshal request", err)
+		}
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(headerUserAgent, "pp-gin-client/"+Version)
+
+	response, err := api.Send(ctx, c.client, c.url+path, method, header, msgBytes)
+	if err != nil {
+		return nil, api.E(op, api.ErrRemoteRetryable, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, decodeError(op, response, result)
+	}
+	if rawResult, ok := result.(*[]byte); ok {
+		if *rawResult, err = ioutil.ReadAll(response.Body); err != nil {
+			return nil, api.E(op, "can't read response", err)
+		}
+	} else if result != nil {
+		if err := json.NewDecoder(response.Body).Decode(result); err != nil && err != io.EOF {
+			return nil, api.E(op, "can't decode response", err)
+		}
+	}
+	return response.Header, nil
+}
+
+// Failed response is decoded into the error with the code of the server error (or the code of the status)
+// which wraps RemoteError. Items of the failed bulk submission are decoded into its result
+func decodeError(op api.ErrOp, response *http.Response, result interface{}) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorLength))
+	remoteErr := &api.RemoteError{Status: response.StatusCode, Response: string(body)}
+	if len(body) > maxResponseLength {
+		remoteErr.Response = string(body[:maxResponseLength])
+	}
+
+	var errResponse api.ErrorResponse
+	if len(body) > 0 {
+		_ = json.NewDecoder(bytes.NewReader(body)).Decode(&errResponse)
+	}
+	if bulkResult, ok := result.(*BulkOrderResult); ok && len(errResponse.Items) > 0 {
+		*bulkResult = BulkOrderResult{Items: errResponse.Items}
+		for _, item := range errResponse.Items {
+			if item.Code == "" {
+				bulkResult.Submitted++
+			} else {
+				bulkResult.Failed++
+			}
+		}
+	}
+	code := errResponse.Code
+	switch {
+	case code != "" && code != api.ErrInternal:
+	case response.StatusCode == http.StatusNotFound:
+		code = api.ErrNotFound
+	case response.StatusCode == http.StatusConflict:
+		code = api.ErrStaleAttempt
+	case response.StatusCode == http.StatusBadRequest:
+		code = api.ErrValidation
+	case code == "":
+		code = api.ErrRemotePermanent
+	}
+	msg := fmt.Sprintf("unexpected status code (%d)", response.StatusCode)
+	if len(errResponse.Messages) > 0 {
+		msg = strings.Join(errResponse.Messages, ", ")
+	}
+	return api.E(op, code, msg, remoteErr)
+}
+
+// Get page of the list (searches are posted along with the request), total count and the cursor of the next page
+// are returned by the headers
+func (c Client) getPage(ctx context.Context, op api.ErrOp, method, path string, values url.Values,
+	request, result interface{}, page *Page) error {
+
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+	header, err := c.send(ctx, op, method, path, nil, request, result)
+	if err != nil {
+		return err
+	}
+	*page = Page{NextCursor: header.Get(api.HeaderNextCursor)}
+	if total := header.Get(api.HeaderTotalCount); total != "" {
+		if page.Total, err = strconv.Atoi(total); err != nil {
+			return api.E(op, "invalid total count", err)
+		}
+	}
+	return nil
+}
+
+// Request the pages (getPage appends the items of the page) till the last one
+func getAll(getPage func(query *PageQuery, page *Page) error) error {
+	query := PageQuery{Limit: api.MaxPageLimit}
+	for {
+		var page Page
+		if err := getPage(&query, &page); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func pageValues(query *PageQuery) url.Values {
+	values := url.Values{}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	setValue(values, "cursor", query.Cursor)
+	setValue(values, "sort", query.Sort)
+	return values
+}
+
+func setValue(values url.Values, key, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
+
+func escape(value string) string {
+	return url.PathEscape(value)
+}
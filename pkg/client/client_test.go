@@ -0,0 +1,546 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/internal/domain"
+	"example.com/oligzeev/pp-gin/internal/rest"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testProcessService struct {
+	domain.ProcessService
+	processes map[string]domain.Process
+}
+
+func (s *testProcessService) GetPage(_ context.Context, _ *domain.PageQuery, result *[]domain.Process,
+	page *domain.Page) error {
+
+	for _, process := range s.processes {
+		*result = append(*result, process)
+	}
+	*page = domain.Page{Total: len(*result)}
+	return nil
+}
+
+func (s *testProcessService) Create(_ context.Context, obj *domain.Process) error {
+	if len(obj.Tasks) == 0 {
+		return domain.E("ProcessService.Create", domain.ErrValidation, "tasks are required")
+	}
+	obj.Id = "p1"
+	s.processes[obj.Id] = *obj
+	return nil
+}
+
+func (s *testProcessService) GetById(_ context.Context, id string, result *domain.Process) error {
+	process, ok := s.processes[id]
+	if !ok {
+		return domain.E("ProcessService.GetById", domain.ErrNotFound)
+	}
+	*result = process
+	return nil
+}
+
+type testDefinitionService struct {
+	domain.ProcessDefinitionService
+	definitions map[string]domain.ProcessDefinition
+}
+
+func (s *testDefinitionService) Import(_ context.Context, def *domain.ProcessDefinition,
+	result *domain.Process) error {
+
+	result.Id = "p1"
+	result.Name = def.Name
+	for _, task := range def.Tasks {
+		result.Tasks = append(result.Tasks, domain.Task{Id: task.Name, Name: task.Name, Action: task.Action})
+	}
+	s.definitions[result.Id] = *def
+	return nil
+}
+
+func (s *testDefinitionService) ImportBpmn(_ context.Context, data []byte, result *domain.Process) error {
+	if !strings.HasPrefix(string(data), "<definitions") {
+		return domain.E("ProcessDefinitionService.ImportBpmn", domain.ErrValidation, "bpmn definitions are expected")
+	}
+	result.Id = "p1"
+	return nil
+}
+
+func (s *testDefinitionService) Export(_ context.Context, processId string, result *domain.ProcessDefinition) error {
+	def, ok := s.definitions[processId]
+	if !ok {
+		return domain.E("ProcessDefinitionService.Export", domain.ErrNotFound)
+	}
+	*result = def
+	return nil
+}
+
+type testOrderService struct {
+	domain.OrderService
+}
+
+func (s *testOrderService) SubmitOrder(_ context.Context, order *domain.Order, processId string) error {
+	order.Id = "o1"
+	order.ProcessId = processId
+	return nil
+}
+
+// Orders with "fail" key are failed, the other orders are rolled back by the failed atomic submission
+func (s *testOrderService) SubmitOrders(_ context.Context, orders domain.OrderReader, processId string,
+	atomic bool, result *domain.BulkOrderResult) error {
+
+	const op = "OrderService.SubmitOrders"
+	if processId != "p1" {
+		return domain.E(op, domain.ErrNotFound)
+	}
+	*result = domain.BulkOrderResult{}
+	for i := 0; ; i++ {
+		var order domain.Order
+		if err := orders.Next(&order); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return domain.E(op, err)
+		}
+		if _, ok := order.Body["fail"]; !ok {
+			result.Submitted++
+			result.Items = append(result.Items, domain.BulkOrderItem{Id: fmt.Sprintf("o%d", i+1)})
+			continue
+		}
+		result.Failed++
+		result.Items = append(result.Items, domain.BulkOrderItem{Code: domain.ErrValidation,
+			Messages: []string{"order is failed"}})
+		if atomic {
+			for j := 0; j < i; j++ {
+				result.Items[j] = domain.BulkOrderItem{Code: domain.ErrCancelled,
+					Messages: []string{"order is rolled back"}}
+			}
+			*result = domain.BulkOrderResult{Failed: len(result.Items), Items: result.Items}
+			return domain.E(op, fmt.Sprintf("order %d isn't submitted, orders are rolled back", i),
+				domain.E("OrderRepo.Create", domain.ErrValidation, "order is failed"))
+		}
+	}
+}
+
+// Orders o1 and o2 are returned by the pages of one order, the query is returned as the body
+func (s *testOrderService) GetOrdersPage(_ context.Context, query *domain.OrderQuery, result *[]domain.Order,
+	page *domain.Page) error {
+
+	if query.Status == "unknown" {
+		return domain.E("OrderService.GetOrdersPage", domain.ErrValidation, "unknown status (unknown)")
+	}
+	body := domain.Body{"processId": query.ProcessId, "status": query.Status, "body": query.Body}
+	if query.CreatedFrom != nil {
+		body["createdFrom"] = query.CreatedFrom.Format(time.RFC3339)
+	}
+	*page = domain.Page{Total: 2}
+	if query.Cursor == "" {
+		*result = []domain.Order{{Id: "o1", Body: body}}
+		page.NextCursor = "c1"
+	} else {
+		*result = []domain.Order{{Id: "o2", Body: body}}
+	}
+	return nil
+}
+
+// Process p1 has the search path $.customer.id, the predicates are returned as the body of the order
+func (s *testOrderService) SearchOrders(_ context.Context, query *domain.OrderQuery, search *domain.OrderSearch,
+	result *[]domain.Order, page *domain.Page) error {
+
+	const op = "OrderService.SearchOrders"
+	if query.ProcessId != "p1" {
+		return domain.E(op, domain.ErrNotFound)
+	}
+	body := domain.Body{"status": query.Status}
+	for _, predicate := range search.Predicates {
+		if predicate.Path != "$.customer.id" {
+			return domain.E(op, domain.ErrValidation, fmt.Sprintf("path isn't searchable (%s)", predicate.Path))
+		}
+		body[predicate.Path] = predicate.Value
+	}
+	*result = []domain.Order{{Id: "o1", ProcessId: query.ProcessId, Body: body}}
+	*page = domain.Page{Total: 1}
+	return nil
+}
+
+func (s *testOrderService) GetOrderById(_ context.Context, id string, result *domain.Order) error {
+	if id != "o1" {
+		return domain.E("OrderService.GetOrderById", domain.ErrNotFound)
+	}
+	*result = domain.Order{Id: id, ProcessId: "p1"}
+	return nil
+}
+
+func (s *testOrderService) GetOrderJobs(_ context.Context, orderId string, result *[]domain.Job) error {
+	if orderId != "o1" {
+		return domain.E("OrderService.GetOrderJobs", domain.ErrNotFound)
+	}
+	*result = []domain.Job{{TaskId: "t1", OrderId: orderId, Status: domain.JobReady}}
+	return nil
+}
+
+// Output with "invalid" key doesn't match the schema
+func (s *testOrderService) CompleteJob(_ context.Context, msg *domain.JobCompleteMessage) error {
+	if msg.AttemptId != "o1.t1.1" {
+		return domain.E("OrderService.CompleteJob", domain.ErrStaleAttempt, "stale attempt")
+	}
+	if _, ok := msg.Body["invalid"]; ok {
+		return domain.E("OrderService.CompleteJob", domain.ErrValidation, "job output doesn't match the schema",
+			&domain.SchemaError{Violations: []string{"(root): status is required"}})
+	}
+	return nil
+}
+
+func testClient() (*Client, func()) {
+	processService := &testProcessService{processes: make(map[string]domain.Process)}
+	server := rest.NewServer(domain.ServerRestConfig{}, []domain.RestHandler{
+		rest.NewProcessRestHandler(processService),
+		rest.NewProcessDefinitionRestHandler(&testDefinitionService{definitions: make(map[string]domain.ProcessDefinition)}),
+		rest.NewOrderRestHandler(&testOrderService{}),
+		rest.NewJobRestHandler(&testOrderService{}),
+		rest.NewGraphRestHandler(processService, &testOrderService{}),
+	})
+	httpServer := httptest.NewServer(server.Router())
+	return New(Config{Url: httpServer.URL}), httpServer.Close
+}
+
+func TestClient_Process(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	process := Process{Name: "process1", Tasks: []Task{{Id: "t1", Name: "task1"}}}
+	assert.Nil(client.CreateProcess(context.Background(), &process))
+	assert.Equal("p1", process.Id)
+
+	var result Process
+	assert.Nil(client.GetProcessById(context.Background(), "p1", &result))
+	assert.Equal(process, result)
+
+	var results []Process
+	assert.Nil(client.GetProcesses(context.Background(), &results))
+	assert.Equal([]Process{process}, results)
+}
+
+func TestClient_Process_NotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	var result Process
+	err := client.GetProcessById(context.Background(), "p2", &result)
+	assert.Equal(ErrNotFound, ECode(err))
+	assert.Equal(http.StatusNotFound, ERemote(err).Status)
+}
+
+func TestClient_Process_Validation(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	err := client.CreateProcess(context.Background(), &Process{Name: "process1"})
+	assert.Equal(ErrValidation, ECode(err))
+	assert.Equal("Client.CreateProcess|APP-0006|tasks are required, remote status 400: "+
+		`{"code":"APP-0006","ops":["ProcessService.Create"],"messages":["tasks are required"]}`, err.Error())
+}
+
+func TestClient_ProcessDefinition(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	def := ProcessDefinition{
+		Name:  "process1",
+		Tasks: []TaskDefinition{{Name: "task1", Action: "http://task1", MappingBody: Body{"id": "$.id"}}},
+	}
+	var process Process
+	assert.Nil(client.ImportProcess(context.Background(), &def, &process))
+	assert.Equal(Process{Id: "p1", Name: "process1", Tasks: []Task{{Id: "task1", Name: "task1",
+		Action: "http://task1"}}}, process)
+
+	var result ProcessDefinition
+	assert.Nil(client.ExportProcess(context.Background(), "p1", &result))
+	assert.Equal(def, result)
+
+	err := client.ExportProcess(context.Background(), "p2", &result)
+	assert.Equal(ErrNotFound, ECode(err))
+}
+
+func TestClient_ProcessDefinition_Yaml(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	const def = `name: process1
+tasks:
+- name: task1
+  action: http://task1
+  mappingBody:
+    id: $.id
+`
+	response, err := http.Post(client.url+"/process/import", domain.ContentTypeApplicationYaml,
+		strings.NewReader(def))
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal(http.StatusOK, response.StatusCode)
+
+	request, _ := http.NewRequest(http.MethodGet, client.url+"/process/p1/definition", nil)
+	request.Header.Set("Accept", domain.ContentTypeApplicationYaml)
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(err)
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(def, string(body))
+}
+
+func TestClient_ImportBpmn(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	var result Process
+	assert.Nil(client.ImportBpmn(context.Background(), []byte("<definitions/>"), &result))
+	assert.Equal("p1", result.Id)
+
+	err := client.ImportBpmn(context.Background(), []byte("<process/>"), &result)
+	assert.Equal(ErrValidation, ECode(err))
+
+	// Document which exceeds the limit isn't truncated
+	large := append([]byte("<definitions>"), bytes.Repeat([]byte(" "), 10<<20)...)
+	err = client.ImportBpmn(context.Background(), large, &result)
+	assert.Equal(ErrValidation, ECode(err))
+	assert.Equal(http.StatusRequestEntityTooLarge, ERemote(err).Status)
+}
+
+func TestClient_Graph(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	process := Process{Name: "process1", Tasks: []Task{{Id: "t1", Name: "task1"}}}
+	assert.Nil(client.CreateProcess(context.Background(), &process))
+
+	var result []byte
+	assert.Nil(client.GetProcessGraph(context.Background(), "p1", "mermaid", &result))
+	assert.Equal("flowchart LR\n  t0[\"task1<br/><small>http: </small>\"]\n", string(result))
+
+	assert.Nil(client.GetOrderGraph(context.Background(), "o1", "dot", &result))
+	assert.Contains(string(result), `"t1" [label="task1\nready", fillcolor="#bbdefb"];`)
+
+	err := client.GetOrderGraph(context.Background(), "o1", "png", &result)
+	assert.Equal(ErrValidation, ECode(err))
+	err = client.GetOrderGraph(context.Background(), "o2", "svg", &result)
+	assert.Equal(ErrNotFound, ECode(err))
+}
+
+func TestClient_Order(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	order := Order{Body: Body{"key1": "value1"}}
+	assert.Nil(client.SubmitOrder(context.Background(), &order, "p1"))
+	assert.Equal(Order{Id: "o1", ProcessId: "p1", Body: Body{"key1": "value1"}}, order)
+
+	var jobs []Job
+	assert.Nil(client.GetOrderJobs(context.Background(), "o1", &jobs))
+	assert.Equal([]Job{{TaskId: "t1", OrderId: "o1", Status: domain.JobReady}}, jobs)
+	assert.Equal(ErrNotFound, ECode(client.GetOrderJobs(context.Background(), "o2", &jobs)))
+}
+
+func TestClient_GetOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	createdFrom := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	query := OrderQuery{
+		PageQuery:   PageQuery{Limit: 1},
+		ProcessId:   "p1",
+		Status:      OrderRunning,
+		CreatedFrom: &createdFrom,
+		Body:        map[string]string{"key1": "value1"},
+	}
+	var orders []Order
+	var page Page
+	assert.Nil(client.GetOrdersPage(context.Background(), &query, &orders, &page))
+	assert.Equal(Page{Total: 2, NextCursor: "c1"}, page)
+	assert.Equal([]Order{{Id: "o1", Body: Body{"processId": "p1", "status": "running",
+		"createdFrom": "2020-01-02T03:04:05Z", "body": map[string]interface{}{"key1": "value1"}}}}, orders)
+
+	orders = nil
+	assert.Nil(client.GetOrders(context.Background(), &orders))
+	assert.Equal(2, len(orders))
+	assert.Equal("o2", orders[1].Id)
+
+	err := client.GetOrdersPage(context.Background(), &OrderQuery{Status: "unknown"}, &orders, &page)
+	assert.Equal(ErrValidation, ECode(err))
+
+	response, err := http.Get(client.url + "/order/?limit=0")
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func TestClient_SearchOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	search := OrderSearch{Predicates: []SearchPredicate{{Path: "$.customer.id", Value: "c1"}}}
+	var orders []Order
+	var page Page
+	assert.Nil(client.SearchOrdersPage(context.Background(), &OrderQuery{ProcessId: "p1", Status: OrderRunning},
+		&search, &orders, &page))
+	assert.Equal(Page{Total: 1}, page)
+	assert.Equal([]Order{{Id: "o1", ProcessId: "p1", Body: Body{"status": "running", "$.customer.id": "c1"}}},
+		orders)
+
+	orders = nil
+	assert.Nil(client.SearchOrders(context.Background(), "p1", &search, &orders))
+	assert.Len(orders, 1)
+
+	err := client.SearchOrders(context.Background(), "p2", &search, &orders)
+	assert.Equal(ErrNotFound, ECode(err))
+	search.Predicates[0].Path = "$.customer.name"
+	err = client.SearchOrders(context.Background(), "p1", &search, &orders)
+	assert.Equal(ErrValidation, ECode(err))
+}
+
+func TestClient_SubmitOrder_IdempotencyKey(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	order := Order{Body: Body{"key1": "value1"}, IdempotencyKey: "k1"}
+	assert.Nil(client.SubmitOrder(context.Background(), &order, "p1"))
+	assert.Equal("k1", order.IdempotencyKey)
+
+	// Key of the header is propagated into the order, it has to be the same as the key of the order
+	for key, status := range map[string]int{"": http.StatusOK, "k1": http.StatusOK, "k2": http.StatusBadRequest} {
+		request, _ := http.NewRequest(http.MethodPost, client.url+"/order/p1",
+			strings.NewReader(`{"body": {}, "idempotencyKey": "`+key+`"}`))
+		request.Header.Set(HeaderIdempotencyKey, "k1")
+		response, err := http.DefaultClient.Do(request)
+		if assert.Nil(err) {
+			var result Order
+			_ = json.NewDecoder(response.Body).Decode(&result)
+			response.Body.Close()
+			assert.Equal(status, response.StatusCode, key)
+			if status == http.StatusOK {
+				assert.Equal("k1", result.IdempotencyKey)
+			}
+		}
+	}
+}
+
+func TestClient_SubmitOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	orders := []Order{{Body: Body{"id": "1"}}, {Body: Body{"fail": "1"}}}
+	var result BulkOrderResult
+	assert.Nil(client.SubmitOrders(context.Background(), orders, "p1", false, &result))
+	assert.Equal(BulkOrderResult{Submitted: 1, Failed: 1, Items: []BulkOrderItem{{Id: "o1"},
+		{Code: ErrValidation, Messages: []string{"order is failed"}}}}, result)
+
+	err := client.SubmitOrders(context.Background(), orders, "p1", true, &result)
+	assert.Equal(http.StatusUnprocessableEntity, ERemote(err).Status)
+	assert.Equal(ErrValidation, ECode(err))
+	assert.Equal("Client.SubmitOrders|APP-0006|order 1 isn't submitted, orders are rolled back, order is failed, "+
+		"remote status 422: "+`{"code":"APP-0006","ops":["OrderService.SubmitOrders","OrderRepo.Create"],`+
+		`"messages":["order 1 isn't submitted, orders are rolled back","order is failed"],`+
+		`"items":[{"code":"APP-0008","messages":["order is rolled back"]},`+
+		`{"code":"APP-0006","messages":["order is failed"]}]}`, err.Error())
+	assert.Equal(BulkOrderResult{Failed: 2, Items: []BulkOrderItem{
+		{Code: ErrCancelled, Messages: []string{"order is rolled back"}},
+		{Code: ErrValidation, Messages: []string{"order is failed"}}}}, result)
+
+	err = client.SubmitOrders(context.Background(), orders, "p2", false, &result)
+	assert.Equal(ErrNotFound, ECode(err))
+}
+
+func TestClient_SubmitOrders_Ndjson(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	const orders = `{"body": {"id": "1"}}
+{"body": {"id": "2"}}
+`
+	response, err := http.Post(client.url+"/order/p1/bulk", domain.ContentTypeApplicationNdjson,
+		strings.NewReader(orders))
+	if assert.Nil(err) {
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.JSONEq(`{"submitted": 2, "failed": 0, "items": [{"id": "o1"}, {"id": "o2"}]}`, string(body))
+	}
+
+	// Orders decoded before the malformed one are submitted
+	response, err = http.Post(client.url+"/order/p1/bulk", domain.ContentTypeApplicationNdjson,
+		strings.NewReader(`{"body": {"id": "1"}}{"body":`))
+	if assert.Nil(err) {
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(http.StatusUnprocessableEntity, response.StatusCode)
+		assert.Contains(string(body), `"messages":["can't decode order 1"`)
+		assert.Contains(string(body), `"items":[{"id":"o1"}]`)
+	}
+
+	response, err = http.Post(client.url+"/order/p1/bulk", "application/json", strings.NewReader(`{}`))
+	if assert.Nil(err) {
+		response.Body.Close()
+		assert.Equal(http.StatusBadRequest, response.StatusCode)
+	}
+}
+
+func TestClient_CompleteJob(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	msg := JobCompleteMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1"}
+	assert.Nil(client.CompleteJob(context.Background(), &msg))
+
+	msg.AttemptId = "o1.t1.0"
+	assert.Equal(ErrStaleAttempt, ECode(client.CompleteJob(context.Background(), &msg)))
+}
+
+func TestClient_CompleteJob_Schema(t *testing.T) {
+	assert := assert.New(t)
+
+	client, closeFunc := testClient()
+	defer closeFunc()
+
+	msg := JobCompleteMessage{TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1", Body: Body{"invalid": true}}
+	err := client.CompleteJob(context.Background(), &msg)
+	assert.Equal(ErrValidation, ECode(err))
+	if remoteErr := ERemote(err); assert.NotNil(remoteErr) {
+		assert.Equal(http.StatusBadRequest, remoteErr.Status)
+		assert.Contains(remoteErr.Response, `"violations":["(root): status is required"]`)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import "example.com/oligzeev/pp-gin/pkg/api"
+
+// Types of the API
+type (
+	Body                   = api.Body
+	Process                = api.Process
+	Task                   = api.Task
+	TaskRelation           = api.TaskRelation
+	HttpTaskConfig         = api.HttpTaskConfig
+	ProcessDefinition      = api.ProcessDefinition
+	TaskDefinition         = api.TaskDefinition
+	ReadMapping            = api.ReadMapping
+	Order                  = api.Order
+	BulkOrderResult        = api.BulkOrderResult
+	BulkOrderItem          = api.BulkOrderItem
+	Job                    = api.Job
+	JobCompleteMessage     = api.JobCompleteMessage
+	JobRef                 = api.JobRef
+	JobFetchRequest        = api.JobFetchRequest
+	LockedJob              = api.LockedJob
+	JobLockCompleteMessage = api.JobLockCompleteMessage
+	JobLockFailMessage     = api.JobLockFailMessage
+	JobLockExtendMessage   = api.JobLockExtendMessage
+	Breaker                = api.Breaker
+	Error                  = api.Error
+	RemoteError            = api.RemoteError
+	ErrCode                = api.ErrCode
+	PageQuery              = api.PageQuery
+	Page                   = api.Page
+	OrderQuery             = api.OrderQuery
+	OrderSearch            = api.OrderSearch
+	SearchPredicate        = api.SearchPredicate
+)
+
+// Categories of the tasks
+const (
+	HttpTaskCategory     = api.HttpTaskCategory
+	NatsTaskCategory     = api.NatsTaskCategory
+	ExternalTaskCategory = api.ExternalTaskCategory
+	TimerTaskCategory    = api.TimerTaskCategory
+)
+
+// Statuses of the jobs
+const (
+	JobWaiting   = api.JobWaiting
+	JobReady     = api.JobReady
+	JobStarted   = api.JobStarted
+	JobCompleted = api.JobCompleted
+	JobFailed    = api.JobFailed
+	JobCancelled = api.JobCancelled
+)
+
+// Statuses of the orders (filter of the orders)
+const (
+	OrderRunning   = api.OrderRunning
+	OrderCompleted = api.OrderCompleted
+	OrderFailed    = api.OrderFailed
+)
+
+// Codes of the errors
+const (
+	ErrInternal        = api.ErrInternal
+	ErrNotFound        = api.ErrNotFound
+	ErrStaleAttempt    = api.ErrStaleAttempt
+	ErrRemoteRetryable = api.ErrRemoteRetryable
+	ErrRemotePermanent = api.ErrRemotePermanent
+	ErrValidation      = api.ErrValidation
+	ErrCancelled       = api.ErrCancelled
+	ErrConflict        = api.ErrConflict
+)
+
+// Code of the error returned by the client
+func ECode(err error) ErrCode {
+	return api.ECode(err)
+}
+
+// Failed response of the server (if any)
+func ERemote(err error) *RemoteError {
+	return api.ERemote(err)
+}
+
+const HeaderIdempotencyKey = api.HeaderIdempotencyKey
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Receive start message of the http task, handler is chosen by the path of the request.
+// Asynchronous job is acknowledged (202) at once and completed by the callback, repeated start of
+// the running attempt is acknowledged without handling
+func (w *Worker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	const op = "Worker.ServeHTTP"
+
+	h, ok := w.handler(r.URL.Path)
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	var msg api.JobStartMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		log.Error(api.E(op, "can't decode start message", err))
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	if msg.AttemptId == "" {
+		msg.AttemptId = r.Header.Get(api.HeaderIdempotencyKey)
+	}
+	job := Job{
+		TaskId:    msg.TaskId,
+		OrderId:   msg.OrderId,
+		AttemptId: msg.AttemptId,
+		Topic:     r.URL.Path,
+		Body:      msg.Body,
+	}
+	span, spanCtx := startSpanFromRequest(r)
+
+	if h.sync {
+		defer span.Finish()
+		output, err := h.handler(spanCtx, &job)
+		if err != nil {
+			log.Error(api.E(op, "job failed", err))
+			status := http.StatusInternalServerError
+			if IsPermanent(err) {
+				status = http.StatusUnprocessableEntity
+			}
+			writeError(rw, status, err)
+			return
+		}
+		rw.Header().Set(api.HeaderContentType, api.ContentTypeApplicationJson)
+		_ = json.NewEncoder(rw).Encode(output)
+		return
+	}
+
+	if !w.startAttempt(job.AttemptId) {
+		span.Finish()
+		rw.WriteHeader(http.StatusAccepted)
+		return
+	}
+	go func() {
+		defer w.finishAttempt(job.AttemptId)
+		asyncSpan := opentracing.GlobalTracer().StartSpan("Worker.HandleJob", opentracing.FollowsFrom(span.Context()))
+		asyncCtx := opentracing.ContextWithSpan(context.Background(), asyncSpan)
+		defer asyncSpan.Finish()
+
+		output, err := h.handler(asyncCtx, &job)
+		if err := w.finish(asyncCtx, &job, output, err); err != nil {
+			log.Error(err)
+		}
+	}()
+	span.Finish()
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func (w *Worker) startAttempt(attemptId string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if attemptId == "" {
+		return true
+	}
+	if w.running[attemptId] {
+		return false
+	}
+	w.running[attemptId] = true
+	return true
+}
+
+func (w *Worker) finishAttempt(attemptId string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.running, attemptId)
+}
+
+func startSpanFromRequest(r *http.Request) (opentracing.Span, context.Context) {
+	tracer := opentracing.GlobalTracer()
+	operationName := r.Method + " " + r.URL.Path
+	var span opentracing.Span
+	spanContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	if err != nil {
+		span = tracer.StartSpan(operationName)
+	} else {
+		span = tracer.StartSpan(operationName, ext.RPCServerOption(spanContext))
+	}
+	return span, opentracing.ContextWithSpan(r.Context(), span)
+}
+
+func writeError(rw http.ResponseWriter, status int, err error) {
+	rw.Header().Set(api.HeaderContentType, api.ContentTypeApplicationJson)
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"fmt"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber/jaeger-client-go"
+	"sync"
+	"time"
+)
+
+// Fetch and handle jobs of the registered topics until the context is done,
+// jobs which are being handled are finished before the exit
+func (w *Worker) Run(ctx context.Context) error {
+	const op = "Worker.Run"
+
+	topics := w.topics()
+	if len(topics) == 0 {
+		return api.E(op, "there are no handlers")
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, w.cfg.MaxJobs)
+	log.Tracef("%s: starting (%v)", op, topics)
+	for {
+		// Fetch jobs for the free slots
+		free := w.cfg.MaxJobs - len(slots)
+		var jobs []api.LockedJob
+		if free > 0 {
+			req := api.JobFetchRequest{
+				Topics:          topics,
+				MaxJobs:         free,
+				LockDurationSec: int(w.cfg.LockDuration / time.Second),
+			}
+			if err := w.client.FetchJobs(ctx, &req, &jobs); err != nil && ctx.Err() == nil {
+				log.Error(api.E(op, err))
+			}
+		}
+		for _, locked := range jobs {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(locked api.LockedJob) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				w.handleLocked(&locked)
+			}(locked)
+		}
+		if len(jobs) > 0 && len(jobs) == free {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Tracef("%s: exit", op)
+			return ctx.Err()
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) handleLocked(locked *api.LockedJob) {
+	const op = "Worker.HandleJob"
+
+	// Propagate span of the order or use background
+	var opts []opentracing.StartSpanOption
+	if orderSpanCtx, err := jaeger.ContextFromString(locked.Trace); err == nil {
+		opts = append(opts, ext.RPCServerOption(orderSpanCtx))
+	}
+	span := opentracing.GlobalTracer().StartSpan(op, opts...)
+	spanCtx := opentracing.ContextWithSpan(context.Background(), span)
+	defer span.Finish()
+
+	h, ok := w.handler(locked.Topic)
+	if !ok {
+		log.Error(api.E(op, fmt.Sprintf("there's no handler (%s)", locked.Topic)))
+		return
+	}
+	job := Job{
+		TaskId:    locked.TaskId,
+		OrderId:   locked.OrderId,
+		AttemptId: locked.LockId,
+		Topic:     locked.Topic,
+		Body:      locked.Body,
+	}
+
+	// Extend the lock while the handler is running, handler is cancelled if the lock is lost
+	handlerCtx, cancel := context.WithCancel(spanCtx)
+	defer cancel()
+	heartbeatDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.cfg.LockDuration / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatDone:
+				return
+			case <-ticker.C:
+				msg := api.JobLockExtendMessage{LockDurationSec: int(w.cfg.LockDuration / time.Second)}
+				if err := w.client.ExtendJobLock(spanCtx, job.AttemptId, &msg); err != nil {
+					log.Error(err)
+					if code := api.ECode(err); code == api.ErrStaleAttempt || code == api.ErrNotFound {
+						cancel()
+						return
+					}
+				}
+			}
+		}
+	}()
+	output, err := h.handler(handlerCtx, &job)
+	close(heartbeatDone)
+
+	if handlerCtx.Err() != nil && spanCtx.Err() == nil {
+		log.Warn(api.E(op, fmt.Sprintf("job lock is lost (%s)", job.AttemptId)))
+		return
+	}
+	if err := w.finish(spanCtx, &job, output, err); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package worker is a runtime of the task handlers. Jobs are received either by HTTP (tasks of the http
+// category, the worker is a http.Handler) or fetched by the pull loop (tasks of the external category).
+// Job is completed or failed by the result of the handler, locks of the fetched jobs are extended while
+// the handler is running.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/oligzeev/pp-gin/pkg/client"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	ServerUrl  string        // Base url of pp-gin, e.g. http://localhost:8080
+	RetriesMax int           // Retries of the calls to pp-gin
+	Timeout    time.Duration // Timeout of the calls to pp-gin
+
+	// Pull loop
+	MaxJobs      int           // Max count of jobs handled at once
+	LockDuration time.Duration // Lock of the fetched job, it's extended in the half of the duration
+	PollInterval time.Duration // Delay of the next fetch if there are no jobs
+}
+
+// Job received by the worker
+type Job struct {
+	TaskId    string
+	OrderId   string
+	AttemptId string // It's also lock id of the fetched job
+	Topic     string // Topic of the external task or path of the http task action
+	Body      map[string]interface{}
+}
+
+// Decode body of the job into the typed structure
+func (j *Job) Decode(v interface{}) error {
+	bodyBytes, err := json.Marshal(j.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bodyBytes, v)
+}
+
+// Handler returns output of the job or error, job is retried by pp-gin unless it's a permanent error
+type Handler func(ctx context.Context, job *Job) (map[string]interface{}, error)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent error fails the job without retries
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var e *permanentError
+	return errors.As(err, &e)
+}
+
+type handler struct {
+	handler Handler
+	sync    bool
+}
+
+type Worker struct {
+	cfg      Config
+	client   *client.Client
+	mu       sync.RWMutex
+	handlers map[string]handler
+	running  map[string]bool // Attempts of http jobs which are being handled
+}
+
+func New(cfg Config) *Worker {
+	if cfg.MaxJobs < 1 {
+		cfg.MaxJobs = 1
+	}
+	if cfg.LockDuration <= 0 {
+		cfg.LockDuration = 30 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &Worker{cfg: cfg, client: client.New(client.Config{
+		Url:        cfg.ServerUrl,
+		RetriesMax: cfg.RetriesMax,
+		Timeout:    cfg.Timeout,
+	}), handlers: make(map[string]handler),
+		running: make(map[string]bool)}
+}
+
+// Register handler of the topic (or path of http task action), job is completed by the callback
+func (w *Worker) Handle(topic string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[topic] = handler{handler: h}
+}
+
+// Register handler of the synchronous http task, output is returned in the response
+func (w *Worker) HandleSync(path string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[path] = handler{handler: h, sync: true}
+}
+
+func (w *Worker) handler(topic string) (handler, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	h, ok := w.handlers[topic]
+	return h, ok
+}
+
+func (w *Worker) topics() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	result := make([]string, 0, len(w.handlers))
+	for topic, h := range w.handlers {
+		if !h.sync {
+			result = append(result, topic)
+		}
+	}
+	return result
+}
+
+// Complete or fail the job by the result of the handler
+func (w *Worker) finish(ctx context.Context, job *Job, output map[string]interface{}, err error) error {
+	if err != nil {
+		msg := client.JobLockFailMessage{Error: err.Error(), Permanent: IsPermanent(err)}
+		return w.client.FailLockedJob(ctx, job.AttemptId, &msg)
+	}
+	msg := client.JobCompleteMessage{
+		TaskId:    job.TaskId,
+		OrderId:   job.OrderId,
+		AttemptId: job.AttemptId,
+		Body:      output,
+	}
+	return w.client.CompleteJob(ctx, &msg)
+}
@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/oligzeev/pp-gin/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// Fake pp-gin which records the requests and returns the jobs on the first fetch
+type testServer struct {
+	mu       sync.Mutex
+	jobs     []api.LockedJob
+	requests chan testRequest
+}
+
+func newTestServer(jobs []api.LockedJob) (*testServer, *httptest.Server) {
+	s := &testServer{jobs: jobs, requests: make(chan testRequest, 100)}
+	return s, httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+}
+
+func (s *testServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(r.Body)
+	if r.URL.Path == "/job/fetch" {
+		s.mu.Lock()
+		jobs := s.jobs
+		s.jobs = []api.LockedJob{}
+		s.mu.Unlock()
+		w.Header().Set(api.HeaderContentType, api.ContentTypeApplicationJson)
+		_ = json.NewEncoder(w).Encode(jobs)
+		return
+	}
+	s.requests <- testRequest{path: r.URL.Path, header: r.Header, body: body.Bytes()}
+}
+
+func (s *testServer) next(t *testing.T) testRequest {
+	select {
+	case request := <-s.requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("there's no request")
+	}
+	return testRequest{}
+}
+
+type testBody struct {
+	Key1 string `json:"key1"`
+}
+
+func testStart(t *testing.T, w *Worker, path string) *httptest.ResponseRecorder {
+	msgBytes, err := json.Marshal(api.JobStartMessage{
+		TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1", Body: api.Body{"key1": "value1"},
+	})
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	w.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(msgBytes)))
+	return recorder
+}
+
+func TestWorker_ServeHTTP_Complete(t *testing.T) {
+	assert := assert.New(t)
+
+	server, httpServer := newTestServer(nil)
+	defer httpServer.Close()
+
+	w := New(Config{ServerUrl: httpServer.URL})
+	w.Handle("/start", func(ctx context.Context, job *Job) (map[string]interface{}, error) {
+		var body testBody
+		if err := job.Decode(&body); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"result": body.Key1}, nil
+	})
+	assert.Equal(http.StatusAccepted, testStart(t, w, "/start").Code)
+
+	request := server.next(t)
+	assert.Equal("/job/complete", request.path)
+	assert.Equal("o1.t1.1", request.header.Get(api.HeaderIdempotencyKey))
+	var msg api.JobCompleteMessage
+	assert.Nil(json.Unmarshal(request.body, &msg))
+	assert.Equal(api.JobCompleteMessage{
+		TaskId: "t1", OrderId: "o1", AttemptId: "o1.t1.1", Body: api.Body{"result": "value1"},
+	}, msg)
+}
+
+func TestWorker_ServeHTTP_Fail(t *testing.T) {
+	assert := assert.New(t)
+
+	server, httpServer := newTestServer(nil)
+	defer httpServer.Close()
+
+	w := New(Config{ServerUrl: httpServer.URL})
+	w.Handle("/start", func(ctx context.Context, job *Job) (map[string]interface{}, error) {
+		return nil, Permanent(errors.New("invalid product"))
+	})
+	assert.Equal(http.StatusAccepted, testStart(t, w, "/start").Code)
+
+	request := server.next(t)
+	assert.Equal("/job/lock/o1.t1.1/fail", request.path)
+	var msg api.JobLockFailMessage
+	assert.Nil(json.Unmarshal(request.body, &msg))
+	assert.Equal(api.JobLockFailMessage{Error: "invalid product", Permanent: true}, msg)
+}
+
+func TestWorker_ServeHTTP_Sync(t *testing.T) {
+	assert := assert.New(t)
+
+	w := New(Config{})
+	w.HandleSync("/start", func(ctx context.Context, job *Job) (map[string]interface{}, error) {
+		return map[string]interface{}{"result": job.Body["key1"]}, nil
+	})
+	recorder := testStart(t, w, "/start")
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.JSONEq(`{"result": "value1"}`, recorder.Body.String())
+
+	assert.Equal(http.StatusNotFound, testStart(t, w, "/unknown").Code)
+}
+
+func TestWorker_Run(t *testing.T) {
+	assert := assert.New(t)
+
+	server, httpServer := newTestServer([]api.LockedJob{{
+		LockId: "o1.t1.1", TaskId: "t1", OrderId: "o1", Topic: "topic1", Body: api.Body{"key1": "value1"},
+	}})
+	defer httpServer.Close()
+
+	w := New(Config{ServerUrl: httpServer.URL, LockDuration: 2 * time.Second, PollInterval: 10 * time.Millisecond})
+	w.Handle("topic1", func(ctx context.Context, job *Job) (map[string]interface{}, error) {
+		time.Sleep(1500 * time.Millisecond) // Lock is extended
+		return map[string]interface{}{"result": job.Body["key1"]}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	request := server.next(t)
+	assert.Equal("/job/lock/o1.t1.1/extend", request.path)
+	var extendMsg api.JobLockExtendMessage
+	assert.Nil(json.Unmarshal(request.body, &extendMsg))
+	assert.Equal(2, extendMsg.LockDurationSec)
+
+	request = server.next(t)
+	assert.Equal("/job/complete", request.path)
+	var completeMsg api.JobCompleteMessage
+	assert.Nil(json.Unmarshal(request.body, &completeMsg))
+	assert.Equal(api.Body{"result": "value1"}, completeMsg.Body)
+
+	cancel()
+	assert.Equal(context.Canceled, <-done)
+}